@@ -0,0 +1,59 @@
+// Package accounts derives the prefunded development accounts of a devnet.
+package accounts
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+
+	hdwallet "github.com/ethereum-optimism/go-ethereum-hdwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// DefaultMnemonic is the mnemonic anvil uses to generate its prefunded accounts.
+const DefaultMnemonic = "test test test test test test test test test test test junk"
+
+type Account struct {
+	Address    common.Address
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// HexPrivateKey returns the 0x prefixed hex encoding of the private key.
+func (a Account) HexPrivateKey() string {
+	return hexutil.Encode(crypto.FromECDSA(a.PrivateKey))
+}
+
+// DerivationPath returns the BIP-44 path of the account at index.
+func DerivationPath(index uint) string {
+	return fmt.Sprintf("m/44'/60'/0'/0/%d", index)
+}
+
+// Derive returns the first n accounts of the mnemonic in the same order anvil funds them.
+func Derive(mnemonic string, n uint) ([]Account, error) {
+	wallet, err := hdwallet.NewFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	accounts := make([]Account, 0, n)
+	for i := uint(0); i < n; i++ {
+		path, err := hdwallet.ParseDerivationPath(DerivationPath(i))
+		if err != nil {
+			return nil, err
+		}
+		account, err := wallet.Derive(path, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive account %d: %w", i, err)
+		}
+		key, err := wallet.PrivateKey(account)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, Account{
+			Address:    account.Address,
+			PrivateKey: key,
+		})
+	}
+	return accounts, nil
+}
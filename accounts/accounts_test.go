@@ -0,0 +1,25 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveMatchesAnvilAccounts(t *testing.T) {
+	accounts, err := Derive(DefaultMnemonic, 3)
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+
+	// The first accounts anvil prints on startup
+	require.Equal(t, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), accounts[0].Address)
+	require.Equal(t, "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80", accounts[0].HexPrivateKey())
+	require.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), accounts[1].Address)
+	require.Equal(t, common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"), accounts[2].Address)
+}
+
+func TestDeriveInvalidMnemonic(t *testing.T) {
+	_, err := Derive("not a mnemonic", 1)
+	require.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/export"
+
+	oplog "github.com/ethereum-optimism/optimism/op-service/log"
+	"github.com/urfave/cli/v2"
+)
+
+func actionExport(ctx *cli.Context) error {
+	log := oplog.NewLogger(oplog.AppOut(ctx), oplog.ReadCLIConfig(ctx)).New("role", "mocktimism")
+	oplog.SetGlobalLogHandler(log.GetHandler())
+	cfg, err := config.LoadNewConfig(log, ctx.String(ConfigFlag.Name))
+	if err != nil {
+		log.Error("failed to load config", "errors", err)
+		return err
+	}
+
+	profileName := ctx.String(ProfileFlag.Name)
+	profile, ok := cfg.Profiles[profileName]
+	if !ok {
+		return fmt.Errorf("profile %q not found in config", profileName)
+	}
+
+	devnet, err := export.NewDevnet(profileName, profile)
+	if err != nil {
+		log.Error("failed to collect devnet", "err", err)
+		return err
+	}
+	return export.Render(os.Stdout, export.Format(ctx.String(FormatFlag.Name)), devnet)
+}
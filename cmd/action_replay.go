@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/gateway"
+	"github.com/ethereum-optimism/mocktimism/recorder"
+
+	oplog "github.com/ethereum-optimism/optimism/op-service/log"
+	"github.com/urfave/cli/v2"
+)
+
+func actionReplay(ctx *cli.Context) error {
+	log := oplog.NewLogger(oplog.AppOut(ctx), oplog.ReadCLIConfig(ctx)).New("role", "mocktimism")
+	oplog.SetGlobalLogHandler(log.GetHandler())
+
+	path := ctx.Args().First()
+	if path == "" {
+		return fmt.Errorf("path to a recording is required")
+	}
+	entries, err := recorder.Load(path)
+	if err != nil {
+		log.Error("failed to load recording", "err", err)
+		return err
+	}
+
+	cfg := config.Gateway{Host: ctx.String(HostFlag.Name), Port: ctx.Uint(PortFlag.Name)}
+	replayer, err := gateway.NewReplayer(log.New("service", "replay"), cfg, entries)
+	if err != nil {
+		log.Error("failed to create replay", "err", err)
+		return err
+	}
+	return replayer.Start(ctx.Context)
+}
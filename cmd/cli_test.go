@@ -3,13 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/export"
 	"github.com/ethereum-optimism/mocktimism/services/anvil"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/pelletier/go-toml"
 	"github.com/stretchr/testify/require"
@@ -87,6 +91,39 @@ func TestCliConfigCommandJson(t *testing.T) {
 	require.Equal(t, string(expectedBytes), string(out))
 }
 
+func TestCliExportCommand(t *testing.T) {
+	// Create a temp file to act as the config
+	tmpfile, err := os.CreateTemp("", "test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	// Use default config
+	err = os.WriteFile(tmpfile.Name(), []byte(""), 0644)
+	require.NoError(t, err)
+
+	app := newCli("testCommit", "testDate")
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err = app.Run([]string{"appName", "export", "--config", tmpfile.Name(), "--format", "json"})
+	require.NoError(t, err)
+
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = oldStdout
+
+	var devnet export.Devnet
+	require.NoError(t, json.Unmarshal(out, &devnet))
+	require.Equal(t, "default", devnet.Profile)
+	require.Len(t, devnet.Chains, len(config.DefaultProfile.Chains))
+
+	err = app.Run([]string{"appName", "export", "--config", tmpfile.Name(), "--profile", "missing"})
+	require.Error(t, err)
+}
+
 func TestCliAnvilCommand(t *testing.T) {
 	// Create a temp file to act as the config
 	tmpfile, err := os.CreateTemp("", "test.toml")
@@ -94,19 +131,21 @@ func TestCliAnvilCommand(t *testing.T) {
 	defer os.Remove(tmpfile.Name())
 	defer tmpfile.Close()
 
-	// TODO can move this to shared test fixture folder https://github.com/ethereum-optimism/mocktimism/issues/29
-	testData := `
+	l1Port, l2Port := simtest.FreePort(t), simtest.FreePort(t)
+	testData := fmt.Sprintf(`
 [profile.default]
 
 [[profile.default.chains]]
-port = 8545
+port = %d
 host = "127.0.0.1"
+backend = "simulated"
 
 # l2 chain
 [[profile.default.chains]]
-port = 9545
+port = %d
 host = "127.0.0.1"
-`
+backend = "simulated"
+`, l1Port, l2Port)
 
 	data := []byte(testData)
 	err = os.WriteFile(tmpfile.Name(), data, 0644)
@@ -141,7 +180,7 @@ loop:
 				log.New("module", "test"),
 				config.Chain{
 					Host: "127.0.0.1",
-					Port: 8545,
+					Port: l1Port,
 				})
 			if err != nil {
 				log.Error(err.Error())
@@ -162,7 +201,7 @@ loop:
 				log.New("module", "test"),
 				config.Chain{
 					Host: "127.0.0.1",
-					Port: 9545,
+					Port: l2Port,
 				})
 			if err != nil {
 				continue
@@ -185,19 +224,21 @@ func TestCliMocktimism(t *testing.T) {
 	defer os.Remove(tmpfile.Name())
 	defer tmpfile.Close()
 
-	// TODO can move this to shared test fixture folder https://github.com/ethereum-optimism/mocktimism/issues/29
-	testData := `
+	l1Port, l2Port := simtest.FreePort(t), simtest.FreePort(t)
+	testData := fmt.Sprintf(`
 [profile.default]
 
 [[profile.default.chains]]
-port = 8545
+port = %d
 host = "127.0.0.1"
+backend = "simulated"
 
 # l2 chain
 [[profile.default.chains]]
-port = 9545
+port = %d
 host = "127.0.0.1"
-`
+backend = "simulated"
+`, l1Port, l2Port)
 
 	data := []byte(testData)
 	err = os.WriteFile(tmpfile.Name(), data, 0644)
@@ -231,7 +272,7 @@ loop:
 				log.New("module", "test"),
 				config.Chain{
 					Host: "127.0.0.1",
-					Port: 8545,
+					Port: l1Port,
 				})
 			if err != nil {
 				break loop
@@ -246,7 +287,7 @@ loop:
 				log.New("module", "test"),
 				config.Chain{
 					Host: "127.0.0.1",
-					Port: 9545,
+					Port: l2Port,
 				})
 			if err != nil {
 				break loop
@@ -261,3 +302,40 @@ loop:
 	require.NoError(t, err, "Health check failed")
 	require.True(t, healthy, "Service is not healthy after waiting for 2 seconds")
 }
+
+func TestResetProfile(t *testing.T) {
+	state := t.TempDir()
+	profile := config.Profile{
+		State: state,
+		Chains: []config.Chain{
+			{Name: "L1", Backend: config.BackendGeth},
+			{Name: "L2", Backend: config.BackendAnvil},
+		},
+	}
+	require.Equal(t, filepath.Join(state, "L1", "geth"), gethDataDir(profile, profile.Chains[0]))
+	require.Empty(t, gethDataDir(config.Profile{}, profile.Chains[0]))
+
+	l1Dir := gethDataDir(profile, profile.Chains[0])
+	require.NoError(t, os.MkdirAll(l1Dir, 0o755))
+	l2Dir := filepath.Join(state, "L2")
+	require.NoError(t, os.MkdirAll(l2Dir, 0o755))
+
+	require.NoError(t, resetProfile(log.New(), profile))
+	require.NoDirExists(t, l1Dir)
+	require.DirExists(t, l2Dir)
+}
+
+func TestProfileProcessesClosesChainsOnFailure(t *testing.T) {
+	profile := config.Profile{
+		State: t.TempDir(),
+		Chains: []config.Chain{
+			{Name: "L1", ChainID: 900, BaseChainID: 900, Backend: config.BackendGeth, Host: "127.0.0.1", Port: 18545},
+			{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: "unknown"},
+		},
+	}
+	// The data directory of the geth L1 is locked until its node is closed
+	for i := 0; i < 2; i++ {
+		_, err := profileProcesses(log.New(), profile)
+		require.ErrorContains(t, err, "unknown backend")
+	}
+}
@@ -41,4 +41,35 @@ var (
 		Usage:   "print config in JSON form",
 		EnvVars: []string{"MOCKTIMISM_CONFIG_JSON"},
 	}
+	ProfileFlag = &cli.StringFlag{
+		Name:    "profile",
+		Value:   "default",
+		Aliases: []string{"p"},
+		Usage:   "name of the config profile to use",
+		EnvVars: []string{"MOCKTIMISM_PROFILE"},
+	}
+	ResetFlag = &cli.BoolFlag{
+		Name:    "reset",
+		Usage:   "wipe the persisted data of the chains before starting",
+		EnvVars: []string{"MOCKTIMISM_RESET"},
+	}
+	FormatFlag = &cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Usage:   "export format, one of foundry, hardhat, viem, env or json",
+		Value:   "json",
+		EnvVars: []string{"MOCKTIMISM_EXPORT_FORMAT"},
+	}
+	HostFlag = &cli.StringFlag{
+		Name:    "host",
+		Usage:   "host the replayed rpc listens on",
+		Value:   "127.0.0.1",
+		EnvVars: []string{"MOCKTIMISM_REPLAY_HOST"},
+	}
+	PortFlag = &cli.UintFlag{
+		Name:    "port",
+		Usage:   "port the replayed rpc listens on",
+		Value:   8555,
+		EnvVars: []string{"MOCKTIMISM_REPLAY_PORT"},
+	}
 )
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/control"
+	"github.com/ethereum-optimism/mocktimism/gateway"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/recorder"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	servicediscovery "github.com/ethereum-optimism/mocktimism/service-discovery"
+	"github.com/ethereum-optimism/mocktimism/services/anvil"
+	"github.com/ethereum-optimism/mocktimism/services/batcher"
+	"github.com/ethereum-optimism/mocktimism/services/challenger"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/interop"
+	"github.com/ethereum-optimism/mocktimism/services/opnode"
+	"github.com/ethereum-optimism/mocktimism/services/proposer"
+	"github.com/ethereum-optimism/mocktimism/services/relayer"
+	"github.com/ethereum-optimism/mocktimism/services/simulated"
+	"github.com/ethereum-optimism/mocktimism/services/tokens"
+
+	oplog "github.com/ethereum-optimism/optimism/op-service/log"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+func actionRun(ctx *cli.Context) error {
+	log := oplog.NewLogger(oplog.AppOut(ctx), oplog.ReadCLIConfig(ctx)).New("role", "mocktimism")
+	oplog.SetGlobalLogHandler(log.GetHandler())
+	cfg, err := config.LoadNewConfig(log, ctx.String(ConfigFlag.Name))
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+
+	profileName := ctx.String(ProfileFlag.Name)
+	profile, ok := cfg.Profiles[profileName]
+	if !ok {
+		return fmt.Errorf("profile %q not found in config", profileName)
+	}
+	if ctx.Bool(ResetFlag.Name) {
+		if err := resetProfile(log, profile); err != nil {
+			return err
+		}
+	}
+	return runProfile(ctx.Context, log, profile)
+}
+
+// gethDataDir returns the directory a geth chain persists to, or an empty string for a temporary directory
+func gethDataDir(profile config.Profile, chain config.Chain) string {
+	if profile.State == "" {
+		return ""
+	}
+	return filepath.Join(profile.State, chain.Name, "geth")
+}
+
+// resetProfile wipes the persisted data of every chain of a profile
+func resetProfile(log log.Logger, profile config.Profile) error {
+	for _, chain := range profile.Chains {
+		dir := gethDataDir(profile, chain)
+		if chain.Backend != config.BackendGeth || dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to reset chain %s: %w", chain.Name, err)
+		}
+		log.Info("Reset chain", "chain", chain.Name, "dir", dir)
+	}
+	return nil
+}
+
+// process is anything started for the lifetime of a devnet.
+// Processes implementing servicediscovery.Service are also registered for discovery.
+type process interface {
+	ID() string
+	Start(ctx context.Context) error
+}
+
+// profileProcesses creates every process of a profile without starting them.
+// On failure, the processes already created, like the in-process geth nodes, are closed again.
+func profileProcesses(log log.Logger, profile config.Profile) ([]process, error) {
+	var processes []process
+	created := false
+	defer func() {
+		if created {
+			return
+		}
+		for _, p := range processes {
+			if closer, ok := p.(io.Closer); ok {
+				if closeErr := closer.Close(); closeErr != nil {
+					log.Warn("failed to close process", "process", p.ID(), "err", closeErr)
+				}
+			}
+		}
+	}()
+
+	for _, chain := range profile.Chains {
+		p, err := chainProcess(log.New("chain", chain.Name), profile, chain)
+		if err != nil {
+			log.Error("failed to create chain", "chain", chain.Name, "backend", chain.Backend, "err", err)
+			return nil, err
+		}
+		processes = append(processes, p)
+	}
+
+	relayers, err := profileRelayers(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range relayers {
+		processes = append(processes, r)
+	}
+
+	interopRelayers, err := profileInteropRelayers(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range interopRelayers {
+		processes = append(processes, r)
+	}
+
+	rollupNodes, err := profileRollupNodes(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range rollupNodes {
+		processes = append(processes, n)
+	}
+
+	batchers, err := profileBatchers(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range batchers {
+		processes = append(processes, b)
+	}
+
+	proposers, err := profileProposers(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range proposers {
+		processes = append(processes, p)
+	}
+
+	challengers, err := profileChallengers(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range challengers {
+		processes = append(processes, c)
+	}
+
+	deployers, err := profileTokens(log, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployers {
+		processes = append(processes, d)
+	}
+
+	// Config validation enables the gateway for every profile with an L2 whose L1 is part of the profile
+	if profile.Gateway.Port != 0 {
+		gw, err := gateway.NewGateway(log.New("service", gateway.SERVICE_TYPE), profile.Gateway, profile.Chains)
+		if err != nil {
+			log.Error("failed to create gateway", "err", err)
+			return nil, err
+		}
+		api, err := control.NewAPI(log.New("service", control.NAMESPACE), control.Devnet{
+			Chains:      profile.Chains,
+			Relayers:    relayers,
+			Interop:     interopRelayers,
+			Batchers:    batchers,
+			Proposers:   proposers,
+			Challengers: challengers,
+			Tokens:      deployers,
+			Faults:      gw.FaultInjectors(),
+		})
+		if err != nil {
+			log.Error("failed to create control api", "err", err)
+			return nil, err
+		}
+		if err := gw.RegisterAPI(control.NAMESPACE, api); err != nil {
+			return nil, err
+		}
+		for _, pair := range profileL2s(profile) {
+			// op-node serves the rollup RPC of derivation L2s and reports their safe and finalized heads to
+			// op-geth itself
+			if pair.l2.Derivation {
+				for _, n := range rollupNodes {
+					if n.ID() != pair.l2.Name {
+						continue
+					}
+					if err := gw.RegisterChainAPI(pair.l2.Name, rollup.NAMESPACE, rollup.NewProxy(n)); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			api, err := rollup.NewAPI(log.New("service", rollup.NAMESPACE, "chain", pair.l2.Name), pair.l1, pair.l2)
+			if err != nil {
+				log.Error("failed to create rollup api", "chain", pair.l2.Name, "err", err)
+				return nil, err
+			}
+			if err := gw.RegisterChainAPI(pair.l2.Name, rollup.NAMESPACE, api); err != nil {
+				return nil, err
+			}
+			heads, err := rollup.NewHeads(pair.l1, pair.l2)
+			if err != nil {
+				log.Error("failed to create safe and finalized heads", "chain", pair.l2.Name, "err", err)
+				return nil, err
+			}
+			for _, b := range batchers {
+				if b.L2().Name == pair.l2.Name {
+					api.SetBatches(b)
+					heads.SetBatches(b)
+				}
+			}
+			if err := gw.SetBlockTags(pair.l2.Name, heads); err != nil {
+				return nil, err
+			}
+		}
+		if profile.Gateway.Record {
+			rec, err := recorder.NewRecorder(recorder.Path(profile.State))
+			if err != nil {
+				log.Error("failed to create recorder", "err", err)
+				return nil, err
+			}
+			gw.SetRecorder(rec)
+		}
+		processes = append(processes, gw)
+	}
+	created = true
+	return processes, nil
+}
+
+// chainProcess creates the node running a chain with its configured backend
+func chainProcess(log log.Logger, profile config.Profile, chain config.Chain) (process, error) {
+	switch chain.Backend {
+	case config.BackendGeth:
+		gethCfg := geth.GethConfig{
+			DataDir:   gethDataDir(profile, chain),
+			Host:      chain.Host,
+			HTTPPort:  int(chain.Port),
+			WSPort:    int(chain.WSPort),
+			Engine:    chain.AuthPort != 0,
+			AuthPort:  int(chain.AuthPort),
+			CORS:      []string{allowOrigin(chain)},
+			OpGeth:    chain.IsL2(),
+			BlockTime: uint64(chain.BlockTime),
+		}
+		for _, pair := range profileL2s(profile) {
+			// op-node sequences L2s with derivation
+			if pair.l2.Name != chain.Name || chain.Derivation {
+				continue
+			}
+			seqCfg, err := sequencerConfig(pair)
+			if err != nil {
+				return nil, err
+			}
+			gethCfg.BlockTime = rollup.BlockTime(chain)
+			gethCfg.Sequencer = seqCfg
+		}
+		genesis, err := geth.NewGenesis(chain)
+		if err != nil {
+			return nil, err
+		}
+		return geth.NewGeth(chain.Name, log, gethCfg, genesis, chain.IsL2())
+	case config.BackendSimulated:
+		return simulated.NewSimulated(chain.Name, log, chain)
+	case config.BackendAnvil, "":
+		return anvil.NewAnvilService(chain.Name, log, chain)
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", chain.Backend)
+	}
+}
+
+// allowOrigin returns the CORS origin of a chain, allowing every origin by default like anvil
+func allowOrigin(chain config.Chain) string {
+	if chain.AllowOrigin == "" {
+		return "*"
+	}
+	return chain.AllowOrigin
+}
+
+// sequencerConfig configures the sequencer of a geth L2 to derive from its L1
+func sequencerConfig(pair l2Pair) (*geth.SequencerConfig, error) {
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	sysCfg, err := rollup.GenesisSystemConfig(pair.l2)
+	if err != nil {
+		return nil, err
+	}
+	return &geth.SequencerConfig{
+		L1URL:            pair.l1.RPCURL(),
+		L1Genesis:        uint64(pair.l1.ForkBlockNumber),
+		Portal:           addresses["OptimismPortalProxy"],
+		SystemConfigAddr: addresses["SystemConfigProxy"],
+		SystemConfig:     sysCfg,
+		SeqWindowSize:    rollup.SeqWindowSize(pair.l2),
+		Hardforks:        pair.l2.Hardforks,
+	}, nil
+}
+
+type l2Pair struct {
+	l1 config.Chain
+	l2 config.Chain
+}
+
+// profileL2s returns every L2 whose L1 is part of the profile together with its L1
+func profileL2s(profile config.Profile) []l2Pair {
+	var pairs []l2Pair
+	for _, l2 := range profile.Chains {
+		if !l2.IsL2() {
+			continue
+		}
+		for _, l1 := range profile.Chains {
+			if l1.EffectiveChainID() == l2.BaseChainID {
+				pairs = append(pairs, l2Pair{l1: l1, l2: l2})
+			}
+		}
+	}
+	return pairs
+}
+
+// profileRelayers creates a deposit relayer for every anvil and simulated L2 whose L1 is part of the profile.
+// The sequencer of geth L2s includes deposits itself.
+func profileRelayers(log log.Logger, profile config.Profile) ([]*relayer.Relayer, error) {
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	var relayers []*relayer.Relayer
+	for _, pair := range profileL2s(profile) {
+		if pair.l2.Backend == config.BackendGeth {
+			continue
+		}
+		r, err := relayer.NewRelayer(log.New("service", relayer.SERVICE_TYPE, "chain", pair.l2.Name), pair.l1, pair.l2, addresses["OptimismPortalProxy"])
+		if err != nil {
+			log.Error("failed to create relayer", "chain", pair.l2.Name, "err", err)
+			return nil, err
+		}
+		relayers = append(relayers, r)
+	}
+	return relayers, nil
+}
+
+// profileInteropRelayers creates an interop relayer for every L2 with interop, relaying its messages to
+// the other L2s of its L1 with interop
+func profileInteropRelayers(log log.Logger, profile config.Profile) ([]*interop.Relayer, error) {
+	var relayers []*interop.Relayer
+	for _, l2 := range profile.Chains {
+		if !l2.Interop || !l2.IsL2() {
+			continue
+		}
+		var siblings []config.Chain
+		for _, s := range profile.Chains {
+			if s.Interop && s.IsL2() && s.BaseChainID == l2.BaseChainID && s.ChainID != l2.ChainID {
+				siblings = append(siblings, s)
+			}
+		}
+		r, err := interop.NewRelayer(log.New("service", interop.SERVICE_TYPE, "chain", l2.Name), l2, siblings)
+		if err != nil {
+			log.Error("failed to create interop relayer", "chain", l2.Name, "err", err)
+			return nil, err
+		}
+		relayers = append(relayers, r)
+	}
+	return relayers, nil
+}
+
+// profileRollupNodes creates an op-node for every geth L2 with derivation whose L1 is part of the profile
+func profileRollupNodes(log log.Logger, profile config.Profile) ([]*opnode.OpNode, error) {
+	var nodes []*opnode.OpNode
+	for _, pair := range profileL2s(profile) {
+		if !pair.l2.Derivation {
+			continue
+		}
+		n, err := opnode.NewOpNode(log.New("service", opnode.SERVICE_TYPE, "chain", pair.l2.Name), pair.l1, pair.l2)
+		if err != nil {
+			log.Error("failed to create op-node", "chain", pair.l2.Name, "err", err)
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// profileBatchers creates a batcher for every geth L2 with batcher whose L1 is part of the profile
+func profileBatchers(log log.Logger, profile config.Profile) ([]*batcher.Batcher, error) {
+	var batchers []*batcher.Batcher
+	for _, pair := range profileL2s(profile) {
+		if !pair.l2.Batcher {
+			continue
+		}
+		b, err := batcher.NewBatcher(log.New("service", batcher.SERVICE_TYPE, "chain", pair.l2.Name), pair.l1, pair.l2)
+		if err != nil {
+			log.Error("failed to create batcher", "chain", pair.l2.Name, "err", err)
+			return nil, err
+		}
+		batchers = append(batchers, b)
+	}
+	return batchers, nil
+}
+
+// profileProposers creates a proposer for every L2 with proposer whose L1 is part of the profile
+func profileProposers(log log.Logger, profile config.Profile) ([]*proposer.Proposer, error) {
+	var proposers []*proposer.Proposer
+	for _, pair := range profileL2s(profile) {
+		if !pair.l2.Proposer {
+			continue
+		}
+		p, err := proposer.NewProposer(log.New("service", proposer.SERVICE_TYPE, "chain", pair.l2.Name), pair.l1, pair.l2)
+		if err != nil {
+			log.Error("failed to create proposer", "chain", pair.l2.Name, "err", err)
+			return nil, err
+		}
+		proposers = append(proposers, p)
+	}
+	return proposers, nil
+}
+
+// profileChallengers creates a challenger for every L2 with fault_proofs whose L1 is part of the profile
+func profileChallengers(log log.Logger, profile config.Profile) ([]*challenger.Challenger, error) {
+	var challengers []*challenger.Challenger
+	for _, pair := range profileL2s(profile) {
+		if !pair.l2.FaultProofs {
+			continue
+		}
+		c, err := challenger.NewChallenger(log.New("service", challenger.SERVICE_TYPE, "chain", pair.l2.Name), pair.l1, pair.l2)
+		if err != nil {
+			log.Error("failed to create challenger", "chain", pair.l2.Name, "err", err)
+			return nil, err
+		}
+		challengers = append(challengers, c)
+	}
+	return challengers, nil
+}
+
+// profileTokens creates a token deployer for every L2 with tokens whose L1 is part of the profile
+func profileTokens(log log.Logger, profile config.Profile) ([]*tokens.Deployer, error) {
+	var deployers []*tokens.Deployer
+	for _, pair := range profileL2s(profile) {
+		var l2Tokens []config.Token
+		for _, token := range profile.Tokens {
+			if token.L2 == pair.l2.Name {
+				l2Tokens = append(l2Tokens, token)
+			}
+		}
+		if len(l2Tokens) == 0 {
+			continue
+		}
+		d, err := tokens.NewDeployer(log.New("service", tokens.SERVICE_TYPE, "chain", pair.l2.Name), pair.l1, pair.l2, l2Tokens)
+		if err != nil {
+			log.Error("failed to create token deployer", "chain", pair.l2.Name, "err", err)
+			return nil, err
+		}
+		deployers = append(deployers, d)
+	}
+	return deployers, nil
+}
+
+// runProfile starts every service of a profile and blocks until all of them exited.
+// A single service exiting cancels the remaining ones.
+func runProfile(ctx context.Context, log log.Logger, profile config.Profile) error {
+	processes, err := profileProcesses(log, profile)
+	if err != nil {
+		return err
+	}
+
+	serviceRegistry := servicediscovery.NewServiceDiscovery("mocktimism")
+	processCtx, processCancel := context.WithCancel(ctx)
+	defer processCancel()
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(processes))
+	for _, p := range processes {
+		if service, ok := p.(servicediscovery.Service); ok {
+			serviceRegistry.Register(service)
+		}
+
+		wg.Add(1)
+		go func(p process) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Error("Mocktimism had an unexpected fatal error", "process", p.ID(), "err", err)
+					debug.PrintStack()
+					errCh <- fmt.Errorf("panic: %v", err)
+				}
+
+				processCancel()
+				wg.Done()
+			}()
+
+			log.Info("Starting process", "process", p.ID())
+			errCh <- p.Start(processCtx)
+		}(p)
+	}
+	wg.Wait()
+	close(errCh)
+
+	var errs []error
+	for err := range errCh {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
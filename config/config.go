@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -15,9 +16,42 @@ type Config struct {
 }
 
 type Profile struct {
-	State  string  `toml:"state"`
-	Silent bool    `toml:"silent"`
-	Chains []Chain `toml:"chains"`
+	State   string  `toml:"state"`
+	Silent  bool    `toml:"silent"`
+	Chains  []Chain `toml:"chains"`
+	Gateway Gateway `toml:"gateway"`
+	Tokens  []Token `toml:"tokens"`
+}
+
+// Gateway configures the single port JSON-RPC gateway routing to every chain of the profile.
+type Gateway struct {
+	// The port the gateway will listen on. The gateway is disabled if set to 0,
+	// unless an L2 of the profile needs it, which defaults it to DefaultGatewayPort
+	Port uint `toml:"port"`
+	// The host the gateway will listen on
+	Host string `toml:"host"`
+	// Record every request and response through the gateway to a JSONL file under the state directory
+	Record bool `toml:"record"`
+}
+
+// DefaultGatewayPort is the port of the gateway of profiles with an L2 whose L1 is part of the profile
+const DefaultGatewayPort = 8555
+
+// Token is an ERC20 on an L1 paired with an OptimismMintableERC20 on one of its L2s at startup
+type Token struct {
+	// The name of the L2 the token is paired on
+	L2 string `toml:"l2"`
+	// The name and symbol of the token on both chains
+	Name   string `toml:"name"`
+	Symbol string `toml:"symbol"`
+	// The decimals of the token on both chains. Defaults to 18
+	Decimals uint8 `toml:"decimals"`
+	// The L1 token, e.g. a token of a forked L1. A fresh token is deployed to the L1 if unset
+	Address string `toml:"address"`
+	// The accounts minted the initial balance on the L2, and on the L1 for fresh tokens
+	Accounts []string `toml:"accounts"`
+	// The initial balance of each account in whole tokens
+	Balance uint `toml:"balance"`
 }
 
 type Chain struct {
@@ -55,10 +89,233 @@ type Chain struct {
 	Port uint `toml:"port"`
 	// The host the server will listen on
 	Host string `toml:"host"`
+	// The port websockets are served on. Defaults to Port. Only supported by the geth backend
+	WSPort uint `toml:"ws_port"`
+	// The port of the JWT authenticated engine API. Disabled if 0. Only supported by the geth backend
+	AuthPort uint `toml:"auth_port"`
 	// Block time in seconds for interval mining.
 	BlockTime uint `toml:"block_time"`
 	//  Don't keep full chain history. If a number argument is specified, at most this number of states is kept in memory.
 	PruneHistory uint `toml:"prune_history"`
+	// Faults injected into the requests the gateway forwards to the chain
+	Faults []Fault `toml:"faults"`
+	// The node running the chain, either anvil, geth or simulated. Defaults to anvil
+	Backend string `toml:"backend"`
+	// Runs op-node in-process to sequence the L2 and derive it from the batches and deposits on its L1.
+	// Only supported by geth L2s, which serve the engine API on auth_port for it
+	Derivation bool `toml:"derivation"`
+	// Submits the blocks of the L2 as batches to the batch inbox on its L1 like op-batcher.
+	// Only supported by geth L2s, whose blocks start with the L1 info deposit
+	Batcher bool `toml:"batcher"`
+	// Seconds between batch submissions. Defaults to 12
+	BatchInterval uint `toml:"batch_interval"`
+	// Number of L1 blocks after which the L1 block including an L2 block is final, which finalizes
+	// the L2 block. Only applies to L2s without derivation. Defaults to 32
+	L1FinalityDepth uint `toml:"l1_finality_depth"`
+	// Number of L1 blocks after the L1 origin of an L2 block at which it is safe, emulating the delay of
+	// batch submission. Only applies to L2s without derivation or batcher, which are safe once their batch is included
+	L1SafeDepth uint `toml:"l1_safe_depth"`
+	// Number of L1 blocks after the L1 origin of an L2 block within which its batch must be submitted.
+	// Once it elapses, the deposits of a stopped sequencer are force included. Defaults to 3600
+	SeqWindowSize uint `toml:"seq_window_size"`
+	// Proposes the outputs of the L2 to the L2OutputOracle on its L1 like op-proposer.
+	// Only one L2 of an L1 can propose, as they share the L1 contracts
+	Proposer bool `toml:"proposer"`
+	// Seconds between output proposals. Defaults to 12
+	ProposalInterval uint `toml:"proposal_interval"`
+	// Creates a dispute game through the DisputeGameFactory for every proposed output and runs an
+	// honest challenger resolving them. Requires proposer
+	FaultProofs bool `toml:"fault_proofs"`
+	// Seconds of the chess clocks of dispute games. Defaults to 1200, the duration of the devnet
+	// deployment. Requires an anvil or simulated L1 to register the game with the shortened clock
+	DisputeGameDuration uint `toml:"dispute_game_duration"`
+	// Relays the messages sent through the L2CrossDomainMessenger to the interop inbox of a sibling, an L2
+	// of the same L1 with interop, to the sibling. Only supported by anvil and simulated L2s, as the
+	// relayer installs the L2CrossDomainMessenger and impersonates the L1CrossDomainMessenger
+	Interop bool `toml:"interop"`
+	// Milliseconds a message sent to a sibling L2 waits before it is relayed
+	InteropLatencyMs uint `toml:"interop_latency_ms"`
+	// The OP Stack hardforks of the L2 in seconds after its genesis. Only supported by geth L2s
+	Hardforks Hardforks `toml:"hardforks"`
+	// The address of the L1 ERC20 the L2 pays for gas with instead of ETH. Deposits of the token mint the
+	// native balance of the L2, and withdrawals burn it. Requires an anvil or simulated L2 and L1
+	GasPayingToken string `toml:"gas_paying_token"`
+}
+
+// Hardforks schedules the OP Stack hardforks of an L2 in seconds after its genesis. Hardforks that are
+// not scheduled never activate, except Regolith which activates at genesis
+type Hardforks struct {
+	Regolith *uint `toml:"regolith" json:"regolith,omitempty"`
+	// Canyon activates Shanghai on the L2
+	Canyon *uint `toml:"canyon" json:"canyon,omitempty"`
+	// Delta allows span batches
+	Delta *uint `toml:"delta" json:"delta,omitempty"`
+	// Ecotone activates Cancun on the L2 and the L1 fee priced by the blob base fee
+	Ecotone *uint `toml:"ecotone" json:"ecotone,omitempty"`
+	// Fjord and later hardforks are not supported by the op-geth mocktimism runs and are rejected
+	Fjord *uint `toml:"fjord" json:"fjord,omitempty"`
+}
+
+// RegolithTime returns the activation timestamp of Regolith on an L2 with the given genesis timestamp
+func (h Hardforks) RegolithTime(genesisTime uint64) *uint64 {
+	if h.Regolith == nil {
+		return &genesisTime
+	}
+	return forkTime(genesisTime, h.Regolith)
+}
+
+// CanyonTime returns the activation timestamp of Canyon, or nil if it is not scheduled
+func (h Hardforks) CanyonTime(genesisTime uint64) *uint64 {
+	return forkTime(genesisTime, h.Canyon)
+}
+
+// DeltaTime returns the activation timestamp of Delta, or nil if it is not scheduled
+func (h Hardforks) DeltaTime(genesisTime uint64) *uint64 {
+	return forkTime(genesisTime, h.Delta)
+}
+
+// EcotoneTime returns the activation timestamp of Ecotone, or nil if it is not scheduled
+func (h Hardforks) EcotoneTime(genesisTime uint64) *uint64 {
+	return forkTime(genesisTime, h.Ecotone)
+}
+
+// Schedule returns the hardforks with the named hardfork activating offset seconds after genesis
+func (h Hardforks) Schedule(name string, offset uint) (Hardforks, error) {
+	switch name {
+	case "regolith":
+		h.Regolith = &offset
+	case "canyon":
+		h.Canyon = &offset
+	case "delta":
+		h.Delta = &offset
+	case "ecotone":
+		h.Ecotone = &offset
+	default:
+		return h, fmt.Errorf("unsupported hardfork: %s", name)
+	}
+	return h, h.validate()
+}
+
+// Scheduled reports whether any hardfork is scheduled
+func (h Hardforks) Scheduled() bool {
+	return h.Regolith != nil || h.Canyon != nil || h.Delta != nil || h.Ecotone != nil || h.Fjord != nil
+}
+
+func forkTime(genesisTime uint64, offset *uint) *uint64 {
+	if offset == nil {
+		return nil
+	}
+	t := genesisTime + uint64(*offset)
+	return &t
+}
+
+// validate returns an error if the hardforks can not be activated in order
+func (h Hardforks) validate() error {
+	if h.Fjord != nil {
+		return errors.New("fjord and later hardforks are not supported")
+	}
+	regolith := uint(0)
+	if h.Regolith != nil {
+		regolith = *h.Regolith
+	}
+	if h.Canyon != nil && *h.Canyon < regolith {
+		return errors.New("canyon cannot activate before regolith")
+	}
+	if h.Delta != nil && (h.Canyon == nil || *h.Delta < *h.Canyon) {
+		return errors.New("delta cannot activate before canyon")
+	}
+	if h.Ecotone != nil && (h.Delta == nil || *h.Ecotone < *h.Delta) {
+		return errors.New("ecotone cannot activate before delta")
+	}
+	return nil
+}
+
+const (
+	// Runs the chain with the anvil binary of the foundry toolchain
+	BackendAnvil = "anvil"
+	// Runs the chain in-process with go-ethereum
+	BackendGeth = "geth"
+	// Runs the chain in-process and in-memory with go-ethereum and the anvil methods the devnet relies on
+	BackendSimulated = "simulated"
+)
+
+const (
+	// Delays requests by LatencyMs
+	FaultLatency = "latency"
+	// Responds with a JSON-RPC error instead of forwarding requests
+	FaultError = "error"
+	// Closes the connection without responding
+	FaultDrop = "drop"
+	// Responds to eth_blockNumber with a block number StaleBlocks behind the head
+	FaultStaleBlockNumber = "stale_block_number"
+	// Responds with HTTP 429 Too Many Requests
+	FaultRateLimit = "rate_limit"
+)
+
+// Fault is a rule injecting failures into the requests to a chain
+type Fault struct {
+	// One of latency, error, drop, stale_block_number or rate_limit
+	Kind string `toml:"kind" json:"kind"`
+	// The methods the fault applies to. Applies to every method if empty
+	Methods []string `toml:"methods" json:"methods,omitempty"`
+	// The probability between 0 and 1 that a request is affected. If 0 every request is affected
+	Probability float64 `toml:"probability" json:"probability,omitempty"`
+	// Delay in milliseconds added by latency faults
+	LatencyMs uint `toml:"latency_ms" json:"latencyMs,omitempty"`
+	// The JSON-RPC error code returned by error faults
+	ErrorCode int `toml:"error_code" json:"errorCode,omitempty"`
+	// The JSON-RPC error message returned by error faults
+	ErrorMessage string `toml:"error_message" json:"errorMessage,omitempty"`
+	// The number of blocks stale_block_number faults lag behind the head
+	StaleBlocks uint `toml:"stale_blocks" json:"staleBlocks,omitempty"`
+}
+
+// ValidateFault returns an error if the fault can not be injected
+func ValidateFault(fault Fault) error {
+	switch fault.Kind {
+	case FaultLatency, FaultError, FaultDrop, FaultStaleBlockNumber, FaultRateLimit:
+	default:
+		return fmt.Errorf("unknown fault kind %q", fault.Kind)
+	}
+	if fault.Probability < 0 || fault.Probability > 1 {
+		return fmt.Errorf("fault probability must be between 0 and 1, got %v", fault.Probability)
+	}
+	return nil
+}
+
+// EffectiveChainID returns the chain id the chain will run with.
+// Forked chains leave ChainID unset and inherit the id of the forked chain.
+func (c Chain) EffectiveChainID() uint {
+	if c.ChainID != 0 {
+		return c.ChainID
+	}
+	return c.ForkChainID
+}
+
+// IsL2 returns true if the chain is a rollup settling on another chain of the profile.
+func (c Chain) IsL2() bool {
+	return c.BaseChainID != 0 && c.BaseChainID != c.EffectiveChainID()
+}
+
+// RPCURL returns the http endpoint of the chain.
+func (c Chain) RPCURL() string {
+	return fmt.Sprintf("http://%s:%d", c.Host, c.Port)
+}
+
+// WSURL returns the websocket endpoint of the chain.
+func (c Chain) WSURL() string {
+	if c.WSPort != 0 {
+		return fmt.Sprintf("ws://%s:%d", c.Host, c.WSPort)
+	}
+	return fmt.Sprintf("ws://%s:%d", c.Host, c.Port)
+}
+
+// AuthURL returns the engine API endpoint of the chain, or an empty string if it is disabled.
+func (c Chain) AuthURL() string {
+	if c.AuthPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("http://%s:%d", c.Host, c.AuthPort)
 }
 
 var DefaultProfile = Profile{
@@ -101,6 +358,10 @@ var DefaultProfile = Profile{
 			PruneHistory:       0,
 		},
 	},
+	Gateway: Gateway{
+		Port: DefaultGatewayPort,
+		Host: "127.0.0.1",
+	},
 }
 
 func validateChains(chains []Chain) ([]Chain, []error) {
@@ -108,6 +369,8 @@ func validateChains(chains []Chain) ([]Chain, []error) {
 	chainIDs := make(map[uint]bool)
 	forkURLs := make(map[string]bool)
 	ports := make(map[uint]bool)
+	// L2s proposing to the L2OutputOracle of an L1
+	proposers := make(map[uint]bool)
 
 	for i, chain := range chains {
 		if chain.ForkChainID != 0 && chain.ChainID != 0 && chain.ChainID != chain.ForkChainID {
@@ -120,6 +383,13 @@ func validateChains(chains []Chain) ([]Chain, []error) {
 		if ports[chain.Port] {
 			errs = append(errs, fmt.Errorf("duplicate port detected for chain: %s", chain.Name))
 		}
+		// Websockets may share the http port
+		if chain.WSPort != 0 && chain.WSPort != chain.Port && ports[chain.WSPort] {
+			errs = append(errs, fmt.Errorf("duplicate ws_port detected for chain: %s", chain.Name))
+		}
+		if chain.AuthPort != 0 && (ports[chain.AuthPort] || chain.AuthPort == chain.Port || chain.AuthPort == chain.WSPort) {
+			errs = append(errs, fmt.Errorf("duplicate auth_port detected for chain: %s", chain.Name))
+		}
 
 		// Validate BaseChainID
 		isBaseChain := chain.BaseChainID != 0 && chain.BaseChainID != chain.ChainID
@@ -148,6 +418,12 @@ func validateChains(chains []Chain) ([]Chain, []error) {
 		}
 		forkURLs[chain.ForkURL] = true
 
+		for _, fault := range chain.Faults {
+			if err := ValidateFault(fault); err != nil {
+				errs = append(errs, fmt.Errorf("invalid fault for chain %s: %w", chain.Name, err))
+			}
+		}
+
 		// Validate ForkBlockNumber
 		if chain.ForkBlockNumber != 0 && chain.ForkURL == "" {
 			errs = append(errs, fmt.Errorf("ForkBlockNumber is set but no ForkURL is not provided for chain: %s", chain.Name))
@@ -155,6 +431,114 @@ func validateChains(chains []Chain) ([]Chain, []error) {
 		if chain.ForkBlockNumber != 0 && !isBaseChain {
 			errs = append(errs, fmt.Errorf("ForkBlockNumber cannot be set for L2 network: %s. Try setting fork-block-number on the L1 network instead", chain.Name))
 		}
+		switch chain.Backend {
+		case "":
+			chain.Backend = BackendAnvil
+		case BackendAnvil:
+		case BackendGeth, BackendSimulated:
+			if chain.ForkURL != "" {
+				errs = append(errs, fmt.Errorf("the %s backend cannot fork, remove ForkURL for chain: %s", chain.Backend, chain.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown backend %q for chain: %s", chain.Backend, chain.Name))
+		}
+		if chain.Backend != BackendGeth && (chain.WSPort != 0 || chain.AuthPort != 0) {
+			errs = append(errs, fmt.Errorf("ws_port and auth_port are only supported by the geth backend for chain: %s", chain.Name))
+		}
+		if chain.Derivation && (chain.Backend != BackendGeth || !isBaseChain) {
+			errs = append(errs, fmt.Errorf("derivation is only supported by geth L2s for chain: %s", chain.Name))
+		}
+		if chain.Batcher && (chain.Backend != BackendGeth || !isBaseChain) {
+			errs = append(errs, fmt.Errorf("batcher is only supported by geth L2s for chain: %s", chain.Name))
+		}
+		if chain.L1FinalityDepth != 0 && (!isBaseChain || chain.Derivation) {
+			errs = append(errs, fmt.Errorf("l1_finality_depth is only supported by L2s without derivation for chain: %s", chain.Name))
+		}
+		if chain.L1SafeDepth != 0 && (!isBaseChain || chain.Derivation || chain.Batcher) {
+			errs = append(errs, fmt.Errorf("l1_safe_depth is only supported by L2s without derivation or batcher for chain: %s", chain.Name))
+		}
+		if chain.SeqWindowSize != 0 && !isBaseChain {
+			errs = append(errs, fmt.Errorf("seq_window_size is only supported by L2s for chain: %s", chain.Name))
+		}
+		if chain.Proposer && !isBaseChain {
+			errs = append(errs, fmt.Errorf("proposer is only supported by L2s for chain: %s", chain.Name))
+		}
+		if chain.Proposer && isBaseChain {
+			if proposers[chain.BaseChainID] {
+				errs = append(errs, fmt.Errorf("only one L2 of an L1 can run a proposer for chain: %s", chain.Name))
+			}
+			proposers[chain.BaseChainID] = true
+		}
+		if chain.ProposalInterval != 0 && !chain.Proposer {
+			errs = append(errs, fmt.Errorf("proposal_interval requires proposer for chain: %s", chain.Name))
+		}
+		if chain.FaultProofs && !chain.Proposer {
+			errs = append(errs, fmt.Errorf("fault_proofs requires proposer for chain: %s", chain.Name))
+		}
+		if chain.DisputeGameDuration != 0 {
+			if !chain.FaultProofs {
+				errs = append(errs, fmt.Errorf("dispute_game_duration requires fault_proofs for chain: %s", chain.Name))
+			}
+			if chain.DisputeGameDuration < 2 {
+				errs = append(errs, fmt.Errorf("dispute_game_duration must be at least 2 seconds for chain: %s", chain.Name))
+			}
+			for _, c := range chains {
+				if (c.ChainID == chain.BaseChainID || c.ForkChainID == chain.BaseChainID) && c.Backend == BackendGeth {
+					errs = append(errs, fmt.Errorf("dispute_game_duration requires an anvil or simulated L1 for chain: %s", chain.Name))
+				}
+			}
+		}
+		if chain.Interop {
+			if chain.Backend == BackendGeth || !isBaseChain {
+				errs = append(errs, fmt.Errorf("interop is only supported by anvil and simulated L2s for chain: %s", chain.Name))
+			}
+			siblings := 0
+			for j, c := range chains {
+				if j != i && c.Interop && c.BaseChainID == chain.BaseChainID {
+					siblings++
+				}
+			}
+			if siblings == 0 {
+				errs = append(errs, fmt.Errorf("interop requires another L2 of the same L1 with interop for chain: %s", chain.Name))
+			}
+		}
+		if chain.InteropLatencyMs != 0 && !chain.Interop {
+			errs = append(errs, fmt.Errorf("interop_latency_ms requires interop for chain: %s", chain.Name))
+		}
+		if chain.Hardforks.Scheduled() {
+			if chain.Backend != BackendGeth || !isBaseChain {
+				errs = append(errs, fmt.Errorf("hardforks are only supported by geth L2s for chain: %s", chain.Name))
+			}
+			if err := chain.Hardforks.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid hardforks for chain %s: %w", chain.Name, err))
+			}
+			// op-node requires an L1 beacon API for Ecotone, which the L1s of mocktimism do not serve
+			if chain.Derivation && chain.Hardforks.Ecotone != nil {
+				errs = append(errs, fmt.Errorf("ecotone is not supported with derivation for chain: %s", chain.Name))
+			}
+		}
+		if chain.GasPayingToken != "" {
+			if chain.Backend == BackendGeth || !isBaseChain {
+				errs = append(errs, fmt.Errorf("gas_paying_token is only supported by anvil and simulated L2s for chain: %s", chain.Name))
+			}
+			if !common.IsHexAddress(chain.GasPayingToken) || common.HexToAddress(chain.GasPayingToken) == (common.Address{}) {
+				errs = append(errs, fmt.Errorf("invalid gas_paying_token address %q for chain: %s", chain.GasPayingToken, chain.Name))
+			}
+			// Withdrawals are released from the OptimismPortal by impersonating it
+			for j, c := range chains {
+				if (c.ChainID == chain.BaseChainID || c.ForkChainID == chain.BaseChainID) && c.Backend == BackendGeth {
+					errs = append(errs, fmt.Errorf("gas_paying_token requires an anvil or simulated L1 for chain: %s", chain.Name))
+				}
+				// The token is configured in the SystemConfig and deposited to the OptimismPortal the L2s of an L1 share
+				if j != i && c.BaseChainID == chain.BaseChainID && c.BaseChainID != c.ChainID {
+					errs = append(errs, fmt.Errorf("gas_paying_token requires the only L2 of its L1 for chain: %s", chain.Name))
+				}
+			}
+		}
+		if chain.BatchInterval != 0 && !chain.Batcher {
+			errs = append(errs, fmt.Errorf("batch_interval requires batcher for chain: %s", chain.Name))
+		}
+
 		// Defaults
 		if chain.Host == "" {
 			chain.Host = "127.0.0.1"
@@ -165,6 +549,9 @@ func validateChains(chains []Chain) ([]Chain, []error) {
 		if chain.Port == 0 {
 			chain.Port = findAvailablePort(ports, 8545)
 		}
+		if chain.Derivation && chain.AuthPort == 0 {
+			chain.AuthPort = findAvailablePort(ports, 8551)
+		}
 
 		if chain.Name == "" {
 			chain.Name = fmt.Sprintf("%d", chain.ChainID)
@@ -178,6 +565,12 @@ func validateChains(chains []Chain) ([]Chain, []error) {
 			chainIDs[chain.ForkChainID] = true
 		}
 		ports[chain.Port] = true
+		if chain.WSPort != 0 {
+			ports[chain.WSPort] = true
+		}
+		if chain.AuthPort != 0 {
+			ports[chain.AuthPort] = true
+		}
 	}
 
 	return chains, errs
@@ -223,9 +616,114 @@ func validateProfile(profile Profile, path string) (Profile, []error) {
 
 	profile.Chains = validatedChains
 
+	// The control API, the rollup RPC and the safe and finalized heads of L2s are only served by the gateway
+	if profile.Gateway.Port == 0 {
+		for _, l2 := range profile.Chains {
+			if l2.IsL2() && hasL1(profile.Chains, l2) {
+				profile.Gateway.Port = DefaultGatewayPort
+			}
+		}
+	}
+	if profile.Gateway.Port != 0 {
+		if profile.Gateway.Host == "" {
+			profile.Gateway.Host = "127.0.0.1"
+		}
+		for _, chain := range profile.Chains {
+			if chain.Port == profile.Gateway.Port {
+				errs = append(errs, fmt.Errorf("gateway port %d is already used by chain: %s", profile.Gateway.Port, chain.Name))
+			}
+		}
+	}
+	// The control API, the rollup RPC and the safe and finalized heads of L2s are only served by the gateway
+	if profile.Gateway.Port == 0 {
+		for _, l2 := range profile.Chains {
+			if l2.IsL2() && hasL1(profile.Chains, l2) {
+				errs = append(errs, fmt.Errorf("chain %s requires a gateway port to serve its rollup RPC and safe and finalized heads", l2.Name))
+			}
+		}
+	}
+	if profile.Gateway.Record {
+		if profile.Gateway.Port == 0 {
+			errs = append(errs, fmt.Errorf("gateway record requires a gateway port"))
+		}
+		if profile.State == "" {
+			errs = append(errs, fmt.Errorf("gateway record requires a state directory"))
+		}
+	}
+
+	// The L1 origins of a persisted geth L2 must survive restarts, otherwise its sequencer rewinds it to the
+	// genesis on the next start. Only geth L1s are persisted in the state directory.
+	if profile.State != "" {
+		for _, l2 := range profile.Chains {
+			if !l2.IsL2() || l2.Backend != BackendGeth {
+				continue
+			}
+			for _, l1 := range profile.Chains {
+				if !l1.IsL2() && l1.EffectiveChainID() == l2.BaseChainID && l1.Backend != BackendGeth {
+					errs = append(errs, fmt.Errorf("chain %s is persisted in the state directory but its %s L1 %s is not, use a geth L1 or no state", l2.Name, l1.Backend, l1.Name))
+				}
+			}
+		}
+	}
+
+	errs = append(errs, validateTokens(profile.Tokens, profile.Chains)...)
+
 	return profile, errs
 }
 
+func validateTokens(tokens []Token, chains []Chain) []error {
+	var errs []error
+	for _, token := range tokens {
+		if token.Name == "" || token.Symbol == "" {
+			errs = append(errs, fmt.Errorf("token name and symbol are required for token: %s", token.Symbol))
+		}
+		if token.Address != "" && !common.IsHexAddress(token.Address) {
+			errs = append(errs, fmt.Errorf("invalid address %q for token: %s", token.Address, token.Symbol))
+		}
+		// Fresh L1 tokens keep their name and symbol in a single word
+		if token.Address == "" && (len(token.Name) >= common.HashLength || len(token.Symbol) >= common.HashLength) {
+			errs = append(errs, fmt.Errorf("name and symbol of fresh tokens must be shorter than %d bytes for token: %s", common.HashLength, token.Symbol))
+		}
+		for _, account := range token.Accounts {
+			if !common.IsHexAddress(account) {
+				errs = append(errs, fmt.Errorf("invalid account %q for token: %s", account, token.Symbol))
+			}
+		}
+		if token.Balance != 0 && len(token.Accounts) == 0 {
+			errs = append(errs, fmt.Errorf("balance requires accounts for token: %s", token.Symbol))
+		}
+
+		var l2 *Chain
+		for i := range chains {
+			if chains[i].Name == token.L2 && chains[i].IsL2() {
+				l2 = &chains[i]
+			}
+		}
+		if l2 == nil {
+			errs = append(errs, fmt.Errorf("unknown L2 %q for token: %s", token.L2, token.Symbol))
+			continue
+		}
+		if !hasL1(chains, *l2) {
+			errs = append(errs, fmt.Errorf("L1 of chain %s is not part of the profile for token: %s", l2.Name, token.Symbol))
+		}
+		// The L2 token is minted by impersonating the L2StandardBridge
+		if l2.Backend == BackendGeth {
+			errs = append(errs, fmt.Errorf("tokens require an anvil or simulated L2 for token: %s", token.Symbol))
+		}
+	}
+	return errs
+}
+
+// hasL1 reports whether the L1 of an L2 is one of the chains
+func hasL1(chains []Chain, l2 Chain) bool {
+	for _, chain := range chains {
+		if !chain.IsL2() && chain.EffectiveChainID() == l2.BaseChainID {
+			return true
+		}
+	}
+	return false
+}
+
 func LoadNewConfig(log log.Logger, path string) (Config, error) {
 	errs := []error{}
 	if path == "" {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -418,3 +419,862 @@ fork_url = "https://op.alchemy.infura.io"
 	_, err = LoadNewConfig(logger, tmpfile.Name())
 	require.Error(t, err, "ForkBlockNumber cannot be set for L2 network: optimism. Try setting fork-block-number on the L1 network instead")
 }
+
+func TestGatewayConfig(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[profile.default.gateway]
+port = 8555
+[[profile.default.chains]]
+name = "mainnet"
+chain_id = 1
+
+[profile.collision]
+[profile.collision.gateway]
+port = 8545
+[[profile.collision.chains]]
+name = "mainnet"
+chain_id = 1
+port = 8545
+
+[profile.rollup]
+[[profile.rollup.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+[[profile.rollup.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+
+[profile.l1]
+[[profile.l1.chains]]
+name = "mainnet"
+chain_id = 1
+`
+
+	data := []byte(testData)
+	err = os.WriteFile(tmpfile.Name(), data, 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.Error(t, err, "gateway port 8545 is already used by chain: mainnet")
+
+	gateway := cfg.Profiles["default"].Gateway
+	require.Equal(t, uint(8555), gateway.Port)
+	require.Equal(t, "127.0.0.1", gateway.Host)
+
+	// The gateway serves the rollup RPC and the safe and finalized heads of L2s
+	require.Equal(t, uint(DefaultGatewayPort), cfg.Profiles["rollup"].Gateway.Port)
+	require.Zero(t, cfg.Profiles["l1"].Gateway.Port)
+}
+
+func TestValidatesFaults(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+state = "path/to/state"
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+[[profile.default.chains.faults]]
+kind = "latency"
+latency_ms = 200
+methods = ["eth_call"]
+`
+	invalidFault := `
+[[profile.default.chains.faults]]
+kind = "flaky"
+probability = 0.5
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData+invalidFault), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	_, err = LoadNewConfig(logger, tmpfile.Name())
+	require.ErrorContains(t, err, `invalid fault for chain mainnet: unknown fault kind "flaky"`)
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData), 0644)
+	require.NoError(t, err)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	faults := cfg.Profiles["default"].Chains[0].Faults
+	require.Len(t, faults, 1)
+	require.Equal(t, FaultLatency, faults[0].Kind)
+	require.Equal(t, uint(200), faults[0].LatencyMs)
+	require.Equal(t, []string{"eth_call"}, faults[0].Methods)
+}
+
+func TestValidatesBackend(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+state = "path/to/state"
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+backend = "geth"
+[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	require.Equal(t, BackendGeth, cfg.Profiles["default"].Chains[0].Backend)
+	require.Equal(t, BackendAnvil, cfg.Profiles["default"].Chains[1].Backend)
+
+	for _, invalid := range []string{
+		`backend = "hardhat"`,
+		`backend = "geth"
+fork_url = "https://op.alchemy.infura.io"`,
+		`backend = "simulated"
+fork_url = "https://op.alchemy.infura.io"`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesGethPorts(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+backend = "geth"
+port = 8545
+ws_port = 8546
+auth_port = 8551
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	chain := cfg.Profiles["default"].Chains[0]
+	require.Equal(t, "ws://127.0.0.1:8546", chain.WSURL())
+	require.Equal(t, "http://127.0.0.1:8551", chain.AuthURL())
+
+	for _, invalid := range []string{
+		// ports of other chains
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+backend = "geth"
+port = 8546`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+backend = "geth"
+ws_port = 8551`,
+		// only geth serves websockets and the engine API on separate ports
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+auth_port = 9551`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesDerivation(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+backend = "geth"
+port = 8545
+auth_port = 8551
+`
+	l2 := `[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+backend = "geth"
+port = 9545
+derivation = true
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData+l2), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	chain := cfg.Profiles["default"].Chains[1]
+	require.True(t, chain.Derivation)
+	// The engine API op-node drives defaults to the next free port
+	require.Equal(t, uint(8552), chain.AuthPort)
+
+	for _, invalid := range []string{
+		// derivation needs an op-geth L2
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+derivation = true`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+backend = "simulated"
+derivation = true`,
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+backend = "geth"
+derivation = true`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesPersistedL2(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	l2 := `[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+backend = "geth"
+port = 9545
+`
+	logger := testlog.Logger(t, log.LvlInfo)
+	for l1Backend, valid := range map[string]bool{"geth": true, "anvil": false, "simulated": false} {
+		testData := fmt.Sprintf(`
+[profile.default]
+state = "state"
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+backend = %q
+port = 8545
+`, l1Backend)
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+l2), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		if valid {
+			require.NoError(t, err, l1Backend)
+		} else {
+			// The sequencer would rewind the L2 once the blocks of the L1 are gone after a restart
+			require.ErrorContains(t, err, "is persisted in the state directory", l1Backend)
+		}
+	}
+
+	// Without state the L1 and L2 are both discarded on shutdown
+	err = os.WriteFile(tmpfile.Name(), []byte(`
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+backend = "anvil"
+port = 8545
+`+l2), 0644)
+	require.NoError(t, err)
+	_, err = LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+}
+
+func TestValidatesBatcher(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+backend = "geth"
+port = 8545
+`
+	l2 := `[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+backend = "geth"
+port = 9545
+batcher = true
+batch_interval = 4
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData+l2), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	chain := cfg.Profiles["default"].Chains[1]
+	require.True(t, chain.Batcher)
+	require.Equal(t, uint(4), chain.BatchInterval)
+
+	for _, invalid := range []string{
+		// batches are only built from op-geth blocks
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+batcher = true`,
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+backend = "geth"
+batcher = true`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+backend = "geth"
+batch_interval = 4`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesRollupWindows(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+port = 8545
+`
+	l2 := `[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+l1_finality_depth = 10
+l1_safe_depth = 5
+seq_window_size = 20
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData+l2), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	require.Equal(t, uint(10), cfg.Profiles["default"].Chains[1].L1FinalityDepth)
+	require.Equal(t, uint(5), cfg.Profiles["default"].Chains[1].L1SafeDepth)
+	require.Equal(t, uint(20), cfg.Profiles["default"].Chains[1].SeqWindowSize)
+
+	for _, invalid := range []string{
+		// L1s finalize on their own
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+l1_finality_depth = 10`,
+		// op-node reports the heads of derivation L2s
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+backend = "geth"
+derivation = true
+l1_finality_depth = 10`,
+		// Batched L2s are safe once their batch is included
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+backend = "geth"
+batcher = true
+l1_safe_depth = 5`,
+		// L1s have no sequencing window
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+seq_window_size = 10`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesProposer(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+port = 8545
+`
+	l2 := `[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+proposer = true
+proposal_interval = 4
+fault_proofs = true
+dispute_game_duration = 60
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData+l2), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	chain := cfg.Profiles["default"].Chains[1]
+	require.True(t, chain.Proposer)
+	require.Equal(t, uint(4), chain.ProposalInterval)
+	require.True(t, chain.FaultProofs)
+	require.Equal(t, uint(60), chain.DisputeGameDuration)
+
+	for _, invalid := range []string{
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+proposer = true`,
+		// L2s of an L1 share its L2OutputOracle
+		l2 + `[[profile.default.chains]]
+chain_id = 11
+base_chain_id = 1
+proposer = true`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+proposal_interval = 4`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+fault_proofs = true`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+proposer = true
+dispute_game_duration = 60`,
+		`[[profile.default.chains]]
+chain_id = 10
+base_chain_id = 1
+proposer = true
+fault_proofs = true
+dispute_game_duration = 1`,
+		// the game is registered by impersonating the owner of the factory
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+backend = "geth"
+[[profile.default.chains]]
+chain_id = 15
+base_chain_id = 5
+proposer = true
+fault_proofs = true
+dispute_game_duration = 60`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesInterop(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+port = 8545
+[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+interop = true
+`
+	sibling := `[[profile.default.chains]]
+name = "base"
+base_chain_id = 1
+chain_id = 8453
+port = 9546
+backend = "simulated"
+interop = true
+interop_latency_ms = 500
+`
+
+	err = os.WriteFile(tmpfile.Name(), []byte(testData+sibling), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	require.True(t, cfg.Profiles["default"].Chains[1].Interop)
+	require.Equal(t, uint(500), cfg.Profiles["default"].Chains[2].InteropLatencyMs)
+
+	for _, invalid := range []string{
+		// messages need a sibling to go to
+		``,
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+port = 7545
+[[profile.default.chains]]
+chain_id = 420
+base_chain_id = 5
+interop = true`,
+		// messages are relayed by impersonating their sender
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+interop = true`,
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+interop = true`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+interop_latency_ms = 500`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesTokens(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+port = 8545
+[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+[[profile.default.tokens]]
+l2 = "optimism"
+name = "Test Token"
+symbol = "TEST"
+accounts = ["0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"]
+balance = 1000
+`
+	err = os.WriteFile(tmpfile.Name(), []byte(testData), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	tokens := cfg.Profiles["default"].Tokens
+	require.Len(t, tokens, 1)
+	require.Equal(t, "optimism", tokens[0].L2)
+	require.Equal(t, uint(1000), tokens[0].Balance)
+
+	for _, invalid := range []string{
+		// tokens are paired on an L2 of the profile
+		`[[profile.default.tokens]]
+l2 = "base"
+name = "Base Token"
+symbol = "BASE"`,
+		`[[profile.default.tokens]]
+l2 = "mainnet"
+name = "Base Token"
+symbol = "BASE"`,
+		`[[profile.default.tokens]]
+l2 = "optimism"
+symbol = "BASE"`,
+		`[[profile.default.tokens]]
+l2 = "optimism"
+name = "Base Token"
+symbol = "BASE"
+address = "0x1234"`,
+		// fresh L1 tokens keep their name in a word
+		`[[profile.default.tokens]]
+l2 = "optimism"
+name = "A Base Token With A Very Long Name"
+symbol = "BASE"`,
+		`[[profile.default.tokens]]
+l2 = "optimism"
+name = "Base Token"
+symbol = "BASE"
+accounts = ["bob"]`,
+		`[[profile.default.tokens]]
+l2 = "optimism"
+name = "Base Token"
+symbol = "BASE"
+balance = 1`,
+		// the L2 token is minted by impersonating the bridge
+		`[[profile.default.chains]]
+name = "base"
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+[[profile.default.tokens]]
+l2 = "base"
+name = "Base Token"
+symbol = "BASE"`,
+		// the L1 token lives on the L1 of the L2
+		`[[profile.default.chains]]
+name = "zora"
+chain_id = 7777777
+base_chain_id = 5
+[[profile.default.tokens]]
+l2 = "zora"
+name = "Zora Token"
+symbol = "ZORA"`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesHardforks(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+port = 8545
+[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+backend = "geth"
+[profile.default.chains.hardforks]
+canyon = 0
+delta = 60
+ecotone = 120
+`
+	err = os.WriteFile(tmpfile.Name(), []byte(testData), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	hardforks := cfg.Profiles["default"].Chains[1].Hardforks
+	require.Nil(t, hardforks.Regolith)
+	require.Equal(t, uint64(1000), *hardforks.RegolithTime(1000))
+	require.Equal(t, uint64(1000), *hardforks.CanyonTime(1000))
+	require.Equal(t, uint64(1060), *hardforks.DeltaTime(1000))
+	require.Equal(t, uint64(1120), *hardforks.EcotoneTime(1000))
+
+	// Hardforks scheduled at runtime keep the order
+	scheduled, err := Hardforks{}.Schedule("canyon", 30)
+	require.NoError(t, err)
+	require.Equal(t, uint(30), *scheduled.Canyon)
+	_, err = scheduled.Schedule("ecotone", 60)
+	require.Error(t, err)
+	_, err = scheduled.Schedule("fjord", 60)
+	require.Error(t, err)
+
+	for _, invalid := range []string{
+		// hardforks are only run by op-geth
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+[profile.default.chains.hardforks]
+canyon = 0`,
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+backend = "geth"
+[profile.default.chains.hardforks]
+canyon = 0`,
+		// hardforks activate in order
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+[profile.default.chains.hardforks]
+regolith = 10
+canyon = 5`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+[profile.default.chains.hardforks]
+delta = 5`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+[profile.default.chains.hardforks]
+canyon = 0
+ecotone = 10`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+[profile.default.chains.hardforks]
+canyon = 0
+delta = 0
+ecotone = 0
+fjord = 10`,
+		// op-node requires an L1 beacon API for Ecotone
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+derivation = true
+[profile.default.chains.hardforks]
+canyon = 0
+delta = 0
+ecotone = 10`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidatesGasPayingToken(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "default_test.toml")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	testData := `
+[profile.default]
+[[profile.default.chains]]
+name = "mainnet"
+base_chain_id = 1
+chain_id = 1
+port = 8545
+[[profile.default.chains]]
+name = "optimism"
+base_chain_id = 1
+chain_id = 10
+port = 9545
+gas_paying_token = "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+`
+	err = os.WriteFile(tmpfile.Name(), []byte(testData), 0644)
+	require.NoError(t, err)
+
+	logger := testlog.Logger(t, log.LvlInfo)
+	cfg, err := LoadNewConfig(logger, tmpfile.Name())
+	require.NoError(t, err)
+	require.Equal(t, "0x5FbDB2315678afecb367f032d93F642f64180aa3", cfg.Profiles["default"].Chains[1].GasPayingToken)
+
+	for _, invalid := range []string{
+		// deposits of the token are relayed to anvil and simulated L2s only
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+gas_paying_token = "0x5FbDB2315678afecb367f032d93F642f64180aa3"`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+backend = "geth"
+gas_paying_token = "0x5FbDB2315678afecb367f032d93F642f64180aa3"`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+gas_paying_token = "token"`,
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1
+gas_paying_token = "0x0000000000000000000000000000000000000000"`,
+		// the L2s of an L1 share its OptimismPortal and SystemConfig
+		`[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 1`,
+		// withdrawals impersonate the OptimismPortal
+		`[[profile.default.chains]]
+chain_id = 5
+base_chain_id = 5
+backend = "geth"
+[[profile.default.chains]]
+chain_id = 8453
+base_chain_id = 5
+gas_paying_token = "0x5FbDB2315678afecb367f032d93F642f64180aa3"`,
+	} {
+		err = os.WriteFile(tmpfile.Name(), []byte(testData+invalid), 0644)
+		require.NoError(t, err)
+		_, err = LoadNewConfig(logger, tmpfile.Name())
+		require.Error(t, err, invalid)
+	}
+}
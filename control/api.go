@@ -0,0 +1,829 @@
+// Package control implements the mocktimism_* JSON-RPC namespace used to drive every chain of a devnet through a single client.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/faults"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/batcher"
+	"github.com/ethereum-optimism/mocktimism/services/challenger"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/interop"
+	"github.com/ethereum-optimism/mocktimism/services/proposer"
+	"github.com/ethereum-optimism/mocktimism/services/relayer"
+	"github.com/ethereum-optimism/mocktimism/services/tokens"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	oprollup "github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	opeth "github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// NAMESPACE is the JSON-RPC namespace the API is served under
+const NAMESPACE = "mocktimism"
+
+// The JSON-RPC error code of chains without a method
+const methodNotFoundCode = -32601
+
+type ChainInfo struct {
+	Name        string          `json:"name"`
+	ChainID     hexutil.Uint64  `json:"chainId"`
+	BaseChainID *hexutil.Uint64 `json:"baseChainId,omitempty"`
+	L2          bool            `json:"l2"`
+	RPCURL      string          `json:"rpcUrl"`
+	WSURL       string          `json:"wsUrl"`
+	AuthURL     string          `json:"authUrl,omitempty"`
+}
+
+type ChainStatus struct {
+	Name        string         `json:"name"`
+	ChainID     hexutil.Uint64 `json:"chainId"`
+	Healthy     bool           `json:"healthy"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Timestamp   hexutil.Uint64 `json:"timestamp"`
+	RPCURL      string         `json:"rpcUrl"`
+	WSURL       string         `json:"wsUrl"`
+	AuthURL     string         `json:"authUrl,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// snapshot maps a mocktimism snapshot to the snapshot of every chain
+type snapshot struct {
+	chains  map[string]*hexutil.Big
+	cursors map[string]relayer.Cursor
+	// Cursors of the interop relayers, batchers, proposers and challengers by their L2
+	interop     map[string]uint64
+	batchers    map[string]*types.Header
+	proposers   map[string]uint64
+	challengers map[string]uint64
+}
+
+// Devnet is everything the API controls
+type Devnet struct {
+	Chains      []config.Chain
+	Relayers    []*relayer.Relayer
+	Interop     []*interop.Relayer
+	Batchers    []*batcher.Batcher
+	Proposers   []*proposer.Proposer
+	Challengers []*challenger.Challenger
+	Tokens      []*tokens.Deployer
+	// Fault injectors keyed by chain name
+	Faults map[string]*faults.Injector
+}
+
+type API struct {
+	log         log.Logger
+	chains      []config.Chain
+	clients     map[string]*rpc.Client
+	relayers    []*relayer.Relayer
+	interop     []*interop.Relayer
+	batchers    []*batcher.Batcher
+	proposers   []*proposer.Proposer
+	challengers []*challenger.Challenger
+	tokens      []*tokens.Deployer
+	faults      map[string]*faults.Injector
+
+	mu           sync.Mutex
+	snapshots    map[uint64]snapshot
+	nextSnapshot uint64
+}
+
+func NewAPI(logger log.Logger, devnet Devnet) (*API, error) {
+	clients := make(map[string]*rpc.Client, len(devnet.Chains))
+	for _, chain := range devnet.Chains {
+		client, err := rpc.Dial(chain.RPCURL())
+		if err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, fmt.Errorf("failed to dial RPC of chain %s: %w", chain.Name, err)
+		}
+		clients[chain.Name] = client
+	}
+	return &API{
+		log:         logger,
+		chains:      devnet.Chains,
+		clients:     clients,
+		relayers:    devnet.Relayers,
+		interop:     devnet.Interop,
+		batchers:    devnet.Batchers,
+		proposers:   devnet.Proposers,
+		challengers: devnet.Challengers,
+		tokens:      devnet.Tokens,
+		faults:      devnet.Faults,
+		snapshots:   make(map[uint64]snapshot),
+	}, nil
+}
+
+// Close closes the connections to every chain
+func (api *API) Close() {
+	for _, client := range api.clients {
+		client.Close()
+	}
+}
+
+// Chains returns the chains of the devnet
+func (api *API) Chains() []ChainInfo {
+	infos := make([]ChainInfo, 0, len(api.chains))
+	for _, chain := range api.chains {
+		info := ChainInfo{
+			Name:    chain.Name,
+			ChainID: hexutil.Uint64(chain.EffectiveChainID()),
+			L2:      chain.IsL2(),
+			RPCURL:  chain.RPCURL(),
+			WSURL:   chain.WSURL(),
+			AuthURL: chain.AuthURL(),
+		}
+		if info.L2 {
+			baseChainID := hexutil.Uint64(chain.BaseChainID)
+			info.BaseChainID = &baseChainID
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Status returns the head of every chain
+func (api *API) Status(ctx context.Context) []ChainStatus {
+	statuses := make([]ChainStatus, 0, len(api.chains))
+	for _, chain := range api.chains {
+		status := ChainStatus{
+			Name:    chain.Name,
+			ChainID: hexutil.Uint64(chain.EffectiveChainID()),
+			RPCURL:  chain.RPCURL(),
+			WSURL:   chain.WSURL(),
+			AuthURL: chain.AuthURL(),
+		}
+		var head struct {
+			Number    hexutil.Uint64 `json:"number"`
+			Timestamp hexutil.Uint64 `json:"timestamp"`
+		}
+		if err := api.clients[chain.Name].CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+			status.Error = err.Error()
+		} else {
+			status.Healthy = true
+			status.BlockNumber = head.Number
+			status.Timestamp = head.Timestamp
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Snapshot snapshots every chain and returns the id to revert all of them at once
+func (api *API) Snapshot(ctx context.Context) (hexutil.Uint64, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	snap := snapshot{
+		chains:      make(map[string]*hexutil.Big, len(api.chains)),
+		cursors:     make(map[string]relayer.Cursor, len(api.relayers)),
+		interop:     make(map[string]uint64, len(api.interop)),
+		batchers:    make(map[string]*types.Header, len(api.batchers)),
+		proposers:   make(map[string]uint64, len(api.proposers)),
+		challengers: make(map[string]uint64, len(api.challengers)),
+	}
+	for _, r := range api.relayers {
+		snap.cursors[r.L2().Name] = r.Cursor()
+	}
+	for _, r := range api.interop {
+		snap.interop[r.L2().Name] = r.Cursor()
+	}
+	for _, b := range api.batchers {
+		snap.batchers[b.L2().Name] = b.Cursor()
+	}
+	for _, p := range api.proposers {
+		snap.proposers[p.L2().Name] = p.Cursor()
+	}
+	for _, c := range api.challengers {
+		snap.challengers[c.L2().Name] = c.Cursor()
+	}
+	for _, chain := range api.chains {
+		var id hexutil.Big
+		if err := api.clients[chain.Name].CallContext(ctx, &id, "evm_snapshot"); err != nil {
+			api.releaseSnapshots(snap.chains)
+			// Geth L2s without a sequencer have no block producer to rewind
+			var rpcErr rpc.Error
+			if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+				return 0, fmt.Errorf("snapshots are not supported by chain %s", chain.Name)
+			}
+			return 0, fmt.Errorf("failed to snapshot chain %s: %w", chain.Name, err)
+		}
+		snap.chains[chain.Name] = &id
+	}
+
+	id := api.nextSnapshot
+	api.nextSnapshot++
+	api.snapshots[id] = snap
+	api.log.Info("took snapshot", "id", id)
+	return hexutil.Uint64(id), nil
+}
+
+// releaseSnapshots reverts chains to the snapshots of a failed Snapshot, as chains keep a snapshot until it
+// is reverted to. Only blocks mined since the snapshot was taken are undone.
+func (api *API) releaseSnapshots(chains map[string]*hexutil.Big) {
+	for name, id := range chains {
+		if err := api.clients[name].CallContext(context.Background(), nil, "evm_revert", id); err != nil {
+			api.log.Warn("failed to release snapshot of chain", "chain", name, "id", id, "err", err)
+		}
+	}
+}
+
+// Revert reverts every chain to a snapshot. Like anvil, the snapshot and every later snapshot can no longer be reverted to.
+func (api *API) Revert(ctx context.Context, id hexutil.Uint64) (bool, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	snap, ok := api.snapshots[uint64(id)]
+	if !ok {
+		return false, nil
+	}
+
+	reverted := true
+	for _, chain := range api.chains {
+		var ok bool
+		if err := api.clients[chain.Name].CallContext(ctx, &ok, "evm_revert", snap.chains[chain.Name]); err != nil {
+			return false, fmt.Errorf("failed to revert chain %s: %w", chain.Name, err)
+		}
+		reverted = reverted && ok
+	}
+	for snapshotID := range api.snapshots {
+		if snapshotID >= uint64(id) {
+			delete(api.snapshots, snapshotID)
+		}
+	}
+	for _, r := range api.relayers {
+		r.SetCursor(snap.cursors[r.L2().Name])
+	}
+	for _, r := range api.interop {
+		r.SetCursor(snap.interop[r.L2().Name])
+	}
+	for _, b := range api.batchers {
+		b.SetCursor(snap.batchers[b.L2().Name])
+	}
+	for _, p := range api.proposers {
+		p.SetCursor(snap.proposers[p.L2().Name])
+	}
+	for _, c := range api.challengers {
+		c.SetCursor(snap.challengers[c.L2().Name])
+	}
+	api.log.Info("reverted to snapshot", "id", id, "reverted", reverted)
+	return reverted, nil
+}
+
+// MineAll mines blocks on every chain, defaulting to a single block
+func (api *API) MineAll(ctx context.Context, blocks *hexutil.Uint64) error {
+	count := hexutil.Uint64(1)
+	if blocks != nil {
+		count = *blocks
+	}
+	for _, chain := range api.chains {
+		if err := api.clients[chain.Name].CallContext(ctx, nil, "anvil_mine", count); err != nil {
+			return fmt.Errorf("failed to mine chain %s: %w", chain.Name, err)
+		}
+	}
+	return nil
+}
+
+// Mine mines blocks on a single chain, defaulting to a single block
+func (api *API) Mine(ctx context.Context, chain string, blocks *hexutil.Uint64) error {
+	client, ok := api.clients[chain]
+	if !ok {
+		return fmt.Errorf("unknown chain: %s", chain)
+	}
+	count := hexutil.Uint64(1)
+	if blocks != nil {
+		count = *blocks
+	}
+	if err := client.CallContext(ctx, nil, "anvil_mine", count); err != nil {
+		return fmt.Errorf("failed to mine chain %s: %w", chain, err)
+	}
+	return nil
+}
+
+// IncreaseTime increases the timestamp of the next block of every chain by seconds. Chains are shifted independently:
+// geth L2s round up to their block time and the L1 info of L2s is not updated, so use TimeTravel to move an L1 and
+// its L2s together.
+func (api *API) IncreaseTime(ctx context.Context, seconds hexutil.Uint64) error {
+	for _, chain := range api.chains {
+		if err := api.clients[chain.Name].CallContext(ctx, nil, "evm_increaseTime", seconds); err != nil {
+			return fmt.Errorf("failed to increase time of chain %s: %w", chain.Name, err)
+		}
+	}
+	return nil
+}
+
+// Warp is the head of a chain after time travel
+type Warp struct {
+	Name        string         `json:"name"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Timestamp   hexutil.Uint64 `json:"timestamp"`
+	// The L1 block in the L1 info of the head of an L2
+	L1Origin *hexutil.Uint64 `json:"l1Origin,omitempty"`
+}
+
+// TimeTravel moves an L1 and its L2s forward by seconds and returns their new heads. The L1 mines a block
+// seconds after its head. Every L2 mines a block at the first multiple of its block time after its head that is at
+// least seconds later and not before the new L1 block, and takes the new L1 block as its L1 info.
+// Geth L2s advance their L1 origin by one L1 block per L2 block, so they mine until it is the new L1 block.
+func (api *API) TimeTravel(ctx context.Context, chain string, seconds hexutil.Uint64) ([]Warp, error) {
+	var l1 *config.Chain
+	for i := range api.chains {
+		if api.chains[i].Name == chain {
+			l1 = &api.chains[i]
+		}
+	}
+	if l1 == nil {
+		return nil, fmt.Errorf("unknown chain: %s", chain)
+	}
+	if l1.IsL2() {
+		return nil, fmt.Errorf("chain %s is not an L1", chain)
+	}
+	var l2s []config.Chain
+	for _, c := range api.chains {
+		if !c.IsL2() || c.BaseChainID != l1.EffectiveChainID() {
+			continue
+		}
+		// op-node builds the blocks of the L2 by the wall clock
+		if c.Derivation {
+			return nil, fmt.Errorf("time travel is not supported by chain %s with derivation", c.Name)
+		}
+		l2s = append(l2s, c)
+	}
+
+	if seconds == 0 {
+		return nil, errors.New("time travel requires at least one second")
+	}
+
+	l1Client := api.clients[l1.Name]
+	l1Head, err := ethclient.NewClient(l1Client).HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch head of chain %s: %w", l1.Name, err)
+	}
+	if err := l1Client.CallContext(ctx, nil, "evm_setNextBlockTimestamp", hexutil.Uint64(l1Head.Time+uint64(seconds))); err != nil {
+		return nil, fmt.Errorf("failed to set next block timestamp of chain %s: %w", l1.Name, err)
+	}
+	if err := l1Client.CallContext(ctx, nil, "anvil_mine", hexutil.Uint64(1)); err != nil {
+		return nil, fmt.Errorf("failed to mine chain %s: %w", l1.Name, err)
+	}
+	l1Head, err = ethclient.NewClient(l1Client).HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch head of chain %s: %w", l1.Name, err)
+	}
+	warps := []Warp{{Name: l1.Name, BlockNumber: hexutil.Uint64(l1Head.Number.Uint64()), Timestamp: hexutil.Uint64(l1Head.Time)}}
+	api.log.Info("time traveled", "chain", l1.Name, "seconds", uint64(seconds), "block", l1Head.Number, "timestamp", l1Head.Time)
+
+	for _, l2 := range l2s {
+		warp, err := api.timeTravelL2(ctx, l2, uint64(seconds), l1Head)
+		if err != nil {
+			return warps, fmt.Errorf("failed to time travel chain %s: %w", l2.Name, err)
+		}
+		warps = append(warps, *warp)
+		api.log.Info("time traveled", "chain", l2.Name, "block", uint64(warp.BlockNumber), "timestamp", uint64(warp.Timestamp), "l1Origin", uint64(*warp.L1Origin))
+	}
+	return warps, nil
+}
+
+// timeTravelL2 moves an L2 to the first multiple of its block time at least seconds after its head and not
+// before the L1 head, and updates its L1 info to the L1 head
+func (api *API) timeTravelL2(ctx context.Context, l2 config.Chain, seconds uint64, l1Head *types.Header) (*Warp, error) {
+	rpcClient := api.clients[l2.Name]
+	client := ethclient.NewClient(rpcClient)
+	// Installing the predeploy mines a block on simulated L2s, so it precedes the block of the new time
+	if l2.Backend != config.BackendGeth {
+		if err := api.installL1Block(ctx, l2); err != nil {
+			return nil, err
+		}
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	blockTime := rollup.BlockTime(l2)
+	earliest := max(head.Time+seconds, l1Head.Time)
+	blocks := max((earliest-head.Time+blockTime-1)/blockTime, 1)
+	if err := rpcClient.CallContext(ctx, nil, "evm_setNextBlockTimestamp", hexutil.Uint64(head.Time+blocks*blockTime)); err != nil {
+		return nil, err
+	}
+
+	l1Block, err := bindings.NewL1BlockCaller(predeploys.L1BlockAddr, client)
+	if err != nil {
+		return nil, err
+	}
+	if l2.Backend == config.BackendGeth {
+		// The sequencer includes the L1 info deposit, advancing the L1 origin by at most one block per block
+		origin, err := l1Block.Number(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return nil, err
+		}
+		for remaining := max(l1Head.Number.Uint64()-min(origin, l1Head.Number.Uint64()), 1); remaining > 0; remaining-- {
+			if err := rpcClient.CallContext(ctx, nil, "anvil_mine", hexutil.Uint64(1)); err != nil {
+				return nil, err
+			}
+			if origin, err = l1Block.Number(&bind.CallOpts{Context: ctx}); err != nil {
+				return nil, err
+			}
+			if origin >= l1Head.Number.Uint64() {
+				break
+			}
+		}
+	} else if err := api.setL1Info(ctx, l2, l1Head); err != nil {
+		return nil, err
+	}
+
+	head, err = client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	origin, err := l1Block.Number(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	return &Warp{
+		Name:        l2.Name,
+		BlockNumber: hexutil.Uint64(head.Number.Uint64()),
+		Timestamp:   hexutil.Uint64(head.Time),
+		L1Origin:    (*hexutil.Uint64)(&origin),
+	}, nil
+}
+
+// installL1Block installs the L1Block predeploy on an anvil or simulated L2 without it
+func (api *API) installL1Block(ctx context.Context, l2 config.Chain) error {
+	client := api.clients[l2.Name]
+	var code hexutil.Bytes
+	if err := client.CallContext(ctx, &code, "eth_getCode", predeploys.L1BlockAddr, "latest"); err != nil {
+		return err
+	}
+	if len(code) > 0 {
+		return nil
+	}
+	if err := client.CallContext(ctx, nil, "anvil_setCode", predeploys.L1BlockAddr, bindings.L1BlockDeployedBin); err != nil {
+		return fmt.Errorf("failed to install L1Block predeploy: %w", err)
+	}
+	return nil
+}
+
+// setL1Info executes the L1 info deposit of an L1 block on an anvil or simulated L2, which mines its next block
+func (api *API) setL1Info(ctx context.Context, l2 config.Chain, l1Block *types.Header) error {
+	client := api.clients[l2.Name]
+	sysCfg, err := rollup.GenesisSystemConfig(l2)
+	if err != nil {
+		return err
+	}
+	// anvil and simulated L2s run no hardforks, so the deposit has the Regolith format they start with
+	regolith := &oprollup.Config{RegolithTime: new(uint64)}
+	deposit, err := derive.L1InfoDeposit(regolith, sysCfg, 0, opeth.HeaderBlockInfo(l1Block), l1Block.Time)
+	if err != nil {
+		return err
+	}
+	_, _, err = relayer.Execute(ctx, api.log, client, relayer.Call{
+		From:  deposit.From,
+		To:    deposit.To,
+		Value: deposit.Value,
+		Gas:   deposit.Gas,
+		Data:  deposit.Data,
+	})
+	return err
+}
+
+// RelayPending relays every pending deposit to the L2 chains, and every pending withdrawal of their custom gas
+// tokens, and returns the number of relayed deposits and withdrawals
+func (api *API) RelayPending(ctx context.Context) (hexutil.Uint64, error) {
+	total := 0
+	for _, r := range api.relayers {
+		relayed, err := r.RelayPending(ctx)
+		total += relayed
+		if err != nil {
+			return hexutil.Uint64(total), fmt.Errorf("failed to relay deposits to chain %s: %w", r.L2().Name, err)
+		}
+	}
+	return hexutil.Uint64(total), nil
+}
+
+// RelayMessages relays every message sent between sibling L2s without waiting for the interop latency
+// and returns the relayed messages
+func (api *API) RelayMessages(ctx context.Context) ([]interop.Message, error) {
+	messages := []interop.Message{}
+	for _, r := range api.interop {
+		relayed, err := r.RelayPending(ctx)
+		messages = append(messages, relayed...)
+		if err != nil {
+			return messages, fmt.Errorf("failed to relay messages of chain %s: %w", r.L2().Name, err)
+		}
+	}
+	return messages, nil
+}
+
+// Messages returns the most recent messages sent from an L2 to its siblings
+func (api *API) Messages(chain string) ([]interop.Message, error) {
+	for _, r := range api.interop {
+		if r.L2().Name == chain {
+			return r.Messages(), nil
+		}
+	}
+	return nil, fmt.Errorf("no interop relayer for chain %s", chain)
+}
+
+// Reorg replaces the latest blocks of an L1 with as many new blocks and rolls back the deposits relayed
+// from the orphaned blocks on its L2s. Returns the number of rolled back deposits
+func (api *API) Reorg(ctx context.Context, chain string, depth hexutil.Uint64) (hexutil.Uint64, error) {
+	client, ok := api.clients[chain]
+	if !ok {
+		return 0, fmt.Errorf("unknown chain: %s", chain)
+	}
+	for _, c := range api.chains {
+		if c.Name == chain && c.IsL2() {
+			return 0, fmt.Errorf("chain %s is not an L1", chain)
+		}
+	}
+
+	var head hexutil.Uint64
+	if err := client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return 0, fmt.Errorf("failed to fetch head of chain %s: %w", chain, err)
+	}
+	if depth > head {
+		return 0, fmt.Errorf("cannot reorg %d blocks of chain %s at block %d", depth, chain, head)
+	}
+	opts := map[string]interface{}{"depth": uint64(depth), "txBlockPairs": []interface{}{}}
+	if err := client.CallContext(ctx, nil, "anvil_reorg", opts); err != nil {
+		return 0, fmt.Errorf("failed to reorg chain %s: %w", chain, err)
+	}
+
+	total := 0
+	for _, r := range api.relayers {
+		if r.L1().Name != chain {
+			continue
+		}
+		rolledBack, err := r.Reorg(ctx, uint64(head-depth))
+		total += rolledBack
+		if err != nil {
+			return hexutil.Uint64(total), fmt.Errorf("failed to roll back deposits of chain %s: %w", r.L2().Name, err)
+		}
+	}
+	api.log.Info("reorged chain", "chain", chain, "depth", depth, "deposits", total)
+	return hexutil.Uint64(total), nil
+}
+
+// SubmitBatches submits the new blocks of every L2 with a batcher and returns the submitted batches
+func (api *API) SubmitBatches(ctx context.Context) ([]batcher.Batch, error) {
+	batches := []batcher.Batch{}
+	for _, b := range api.batchers {
+		batch, err := b.SubmitPending(ctx)
+		if err != nil {
+			return batches, fmt.Errorf("failed to submit batch of chain %s: %w", b.L2().Name, err)
+		}
+		if batch != nil {
+			batches = append(batches, *batch)
+		}
+	}
+	return batches, nil
+}
+
+// Batches returns the most recent batches submitted for an L2
+func (api *API) Batches(chain string) ([]batcher.Batch, error) {
+	for _, b := range api.batchers {
+		if b.L2().Name == chain {
+			return b.Batches(), nil
+		}
+	}
+	return nil, fmt.Errorf("no batcher for chain %s", chain)
+}
+
+// ProposeOutputs proposes the pending outputs of every L2 with a proposer and returns the proposals
+func (api *API) ProposeOutputs(ctx context.Context) ([]proposer.Proposal, error) {
+	proposals := []proposer.Proposal{}
+	for _, p := range api.proposers {
+		proposed, err := p.ProposePending(ctx)
+		proposals = append(proposals, proposed...)
+		if err != nil {
+			return proposals, fmt.Errorf("failed to propose outputs of chain %s: %w", p.L2().Name, err)
+		}
+	}
+	return proposals, nil
+}
+
+// Proposals returns the most recent outputs proposed for an L2
+func (api *API) Proposals(chain string) ([]proposer.Proposal, error) {
+	for _, p := range api.proposers {
+		if p.L2().Name == chain {
+			return p.Proposals(), nil
+		}
+	}
+	return nil, fmt.Errorf("no proposer for chain %s", chain)
+}
+
+// DisputeGames plays the dispute games of an L2 and returns the most recent ones
+func (api *API) DisputeGames(ctx context.Context, chain string) ([]challenger.Game, error) {
+	for _, c := range api.challengers {
+		if c.L2().Name == chain {
+			if err := c.Play(ctx); err != nil {
+				return nil, err
+			}
+			return c.Games(), nil
+		}
+	}
+	return nil, fmt.Errorf("no challenger for chain %s", chain)
+}
+
+// Tokens deploys the tokens of an L2 not paired yet and returns the pairs of its tokens
+func (api *API) Tokens(ctx context.Context, chain string) ([]tokens.Pair, error) {
+	for _, d := range api.tokens {
+		if d.L2().Name == chain {
+			return d.Deploy(ctx)
+		}
+	}
+	return nil, fmt.Errorf("no tokens for chain %s", chain)
+}
+
+// sequencer returns the client of a geth L2 whose blocks are built by the built-in sequencer
+func (api *API) sequencer(chain string) (*rpc.Client, error) {
+	for _, c := range api.chains {
+		if c.Name != chain {
+			continue
+		}
+		if !c.IsL2() || c.Backend != config.BackendGeth || c.Derivation {
+			return nil, fmt.Errorf("chain %s is not a geth L2 without derivation", chain)
+		}
+		return api.clients[chain], nil
+	}
+	return nil, fmt.Errorf("unknown chain: %s", chain)
+}
+
+// PauseSequencer stops the block production of an L2 and returns the hash of its head. Deposits are
+// queued until the sequencing window of their L1 block elapses or the sequencer resumes
+func (api *API) PauseSequencer(ctx context.Context, chain string) (common.Hash, error) {
+	client, err := api.sequencer(chain)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var head common.Hash
+	if err := client.CallContext(ctx, &head, "admin_stopSequencer"); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to pause sequencer of chain %s: %w", chain, err)
+	}
+	api.log.Info("paused sequencer", "chain", chain, "head", head)
+	return head, nil
+}
+
+// ResumeSequencer resumes the block production of an L2 on top of its head
+func (api *API) ResumeSequencer(ctx context.Context, chain string) error {
+	client, err := api.sequencer(chain)
+	if err != nil {
+		return err
+	}
+	var status geth.SequencerStatus
+	if err := client.CallContext(ctx, &status, "admin_sequencerStatus"); err != nil {
+		return fmt.Errorf("failed to fetch sequencer status of chain %s: %w", chain, err)
+	}
+	if err := client.CallContext(ctx, nil, "admin_startSequencer", status.Head); err != nil {
+		return fmt.Errorf("failed to resume sequencer of chain %s: %w", chain, err)
+	}
+	api.log.Info("resumed sequencer", "chain", chain, "head", status.Head, "queuedDeposits", uint64(status.QueuedDeposits))
+	return nil
+}
+
+// SequencerStatus reports whether the sequencer of an L2 is paused and the deposits it has yet to include
+func (api *API) SequencerStatus(ctx context.Context, chain string) (*geth.SequencerStatus, error) {
+	client, err := api.sequencer(chain)
+	if err != nil {
+		return nil, err
+	}
+	var status geth.SequencerStatus
+	if err := client.CallContext(ctx, &status, "admin_sequencerStatus"); err != nil {
+		return nil, fmt.Errorf("failed to fetch sequencer status of chain %s: %w", chain, err)
+	}
+	return &status, nil
+}
+
+// Hardfork reports the activation of an OP Stack hardfork on an L2
+type Hardfork struct {
+	Name string `json:"name"`
+	// Activation timestamp, unset if the hardfork is not scheduled
+	Time *hexutil.Uint64 `json:"time,omitempty"`
+	// Whether the hardfork is active at the head of the L2
+	Active bool `json:"active"`
+}
+
+// Hardforks returns the hardforks of a geth L2 and whether they are active at its head
+func (api *API) Hardforks(ctx context.Context, chain string) ([]Hardfork, error) {
+	var l2 *config.Chain
+	for i := range api.chains {
+		if api.chains[i].Name == chain {
+			l2 = &api.chains[i]
+		}
+	}
+	if l2 == nil {
+		return nil, fmt.Errorf("unknown chain: %s", chain)
+	}
+	if !l2.IsL2() || l2.Backend != config.BackendGeth {
+		return nil, fmt.Errorf("chain %s is not a geth L2", chain)
+	}
+
+	schedule := l2.Hardforks
+	client := api.clients[chain]
+	// The built-in sequencer reports the hardforks scheduled while it runs
+	if !l2.Derivation {
+		if err := client.CallContext(ctx, &schedule, "admin_hardforks"); err != nil {
+			return nil, fmt.Errorf("failed to fetch hardforks of chain %s: %w", chain, err)
+		}
+	}
+	var genesis, head struct {
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	if err := client.CallContext(ctx, &genesis, "eth_getBlockByNumber", "0x0", false); err != nil {
+		return nil, fmt.Errorf("failed to fetch genesis of chain %s: %w", chain, err)
+	}
+	if err := client.CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return nil, fmt.Errorf("failed to fetch head of chain %s: %w", chain, err)
+	}
+	genesisTime := uint64(genesis.Timestamp)
+	hardforks := []Hardfork{}
+	for _, fork := range []struct {
+		name string
+		time *uint64
+	}{
+		{"regolith", schedule.RegolithTime(genesisTime)},
+		{"canyon", schedule.CanyonTime(genesisTime)},
+		{"delta", schedule.DeltaTime(genesisTime)},
+		{"ecotone", schedule.EcotoneTime(genesisTime)},
+	} {
+		hardfork := Hardfork{Name: fork.name}
+		if fork.time != nil {
+			hardfork.Time = (*hexutil.Uint64)(fork.time)
+			hardfork.Active = uint64(head.Timestamp) >= *fork.time
+		}
+		hardforks = append(hardforks, hardfork)
+	}
+	return hardforks, nil
+}
+
+// ScheduleHardfork activates a hardfork of a geth L2 with its first block at or after timestamp, which must be
+// after its head, to rehearse an upgrade. Hardforks active at the head cannot be rescheduled
+func (api *API) ScheduleHardfork(ctx context.Context, chain string, name string, timestamp hexutil.Uint64) error {
+	client, err := api.sequencer(chain)
+	if err != nil {
+		return err
+	}
+	if err := client.CallContext(ctx, nil, "admin_scheduleHardfork", name, timestamp); err != nil {
+		return fmt.Errorf("failed to schedule %s on chain %s: %w", name, chain, err)
+	}
+	api.log.Info("scheduled hardfork", "chain", chain, "name", name, "time", uint64(timestamp))
+	return nil
+}
+
+type FaultsStatus struct {
+	Enabled bool           `json:"enabled"`
+	Rules   []config.Fault `json:"rules"`
+}
+
+func (api *API) faultInjector(chain string) (*faults.Injector, error) {
+	injector, ok := api.faults[chain]
+	if !ok {
+		return nil, fmt.Errorf("no fault injector for chain %s", chain)
+	}
+	return injector, nil
+}
+
+// Faults returns the fault rules of a chain
+func (api *API) Faults(chain string) (*FaultsStatus, error) {
+	injector, err := api.faultInjector(chain)
+	if err != nil {
+		return nil, err
+	}
+	return &FaultsStatus{Enabled: injector.Enabled(), Rules: injector.Rules()}, nil
+}
+
+// SetFaults replaces the fault rules of a chain
+func (api *API) SetFaults(chain string, rules []config.Fault) error {
+	injector, err := api.faultInjector(chain)
+	if err != nil {
+		return err
+	}
+	if err := injector.SetRules(rules); err != nil {
+		return err
+	}
+	api.log.Info("updated faults", "chain", chain, "rules", len(rules))
+	return nil
+}
+
+// SetFaultsEnabled toggles the fault injection of a chain
+func (api *API) SetFaultsEnabled(chain string, enabled bool) error {
+	injector, err := api.faultInjector(chain)
+	if err != nil {
+		return err
+	}
+	injector.SetEnabled(enabled)
+	api.log.Info("toggled faults", "chain", chain, "enabled", enabled)
+	return nil
+}
@@ -0,0 +1,455 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/faults"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnvil implements the subset of the anvil RPC the control API relies on
+type fakeAnvil struct {
+	mu        sync.Mutex
+	number    uint64
+	timestamp uint64
+	snapshots []uint64
+	reorgs    []uint64
+	stopped   bool
+	hardforks config.Hardforks
+}
+
+type fakeEth struct{ *fakeAnvil }
+
+func (f fakeEth) GetBlockByNumber(tag string, full bool) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// The genesis is at timestamp 0
+	if tag == "0x0" {
+		return map[string]interface{}{"number": hexutil.Uint64(0), "timestamp": hexutil.Uint64(0)}
+	}
+	return map[string]interface{}{
+		"number":    hexutil.Uint64(f.number),
+		"timestamp": hexutil.Uint64(f.timestamp),
+	}
+}
+
+func (f fakeEth) BlockNumber() hexutil.Uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return hexutil.Uint64(f.number)
+}
+
+type fakeEvm struct{ *fakeAnvil }
+
+func (f fakeEvm) Snapshot() *hexutil.Big {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.snapshots = append(f.snapshots, f.number)
+	return (*hexutil.Big)(hexutil.MustDecodeBig(hexutil.EncodeUint64(uint64(len(f.snapshots) - 1))))
+}
+
+func (f fakeEvm) Revert(id *hexutil.Big) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := id.ToInt().Uint64()
+	if i >= uint64(len(f.snapshots)) {
+		return false
+	}
+	f.number = f.snapshots[i]
+	f.snapshots = f.snapshots[:i]
+	return true
+}
+
+func (f fakeEvm) IncreaseTime(seconds hexutil.Uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.timestamp += uint64(seconds)
+}
+
+type fakeAnvilNamespace struct{ *fakeAnvil }
+
+func (f fakeAnvilNamespace) Mine(blocks hexutil.Uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.number += uint64(blocks)
+}
+
+func (f fakeAnvilNamespace) Reorg(opts struct {
+	Depth uint64 `json:"depth"`
+}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reorgs = append(f.reorgs, opts.Depth)
+}
+
+// fakeAdmin implements the sequencer methods of the admin namespace of geth L2s
+type fakeAdmin struct{ *fakeAnvil }
+
+func (f fakeAdmin) head() common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(f.number))
+}
+
+func (f fakeAdmin) StopSequencer() (common.Hash, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return common.Hash{}, errors.New("sequencer not running")
+	}
+	f.stopped = true
+	return f.head(), nil
+}
+
+func (f fakeAdmin) StartSequencer(head common.Hash) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.stopped {
+		return errors.New("sequencer already running")
+	}
+	if head != f.head() {
+		return errors.New("block hash does not match")
+	}
+	f.stopped = false
+	return nil
+}
+
+func (f fakeAdmin) SequencerStatus() geth.SequencerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return geth.SequencerStatus{Active: !f.stopped, Head: f.head(), QueuedDeposits: 1}
+}
+
+func (f fakeAdmin) Hardforks() config.Hardforks {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hardforks
+}
+
+func (f fakeAdmin) ScheduleHardfork(name string, timestamp hexutil.Uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if uint64(timestamp) <= f.timestamp {
+		return errors.New("hardfork must be scheduled after the head")
+	}
+	// The genesis is at timestamp 0
+	hardforks, err := f.hardforks.Schedule(name, uint(timestamp))
+	if err != nil {
+		return err
+	}
+	f.hardforks = hardforks
+	return nil
+}
+
+func newFakeAnvil(t *testing.T, chain config.Chain) (config.Chain, *fakeAnvil) {
+	anvil := &fakeAnvil{hardforks: chain.Hardforks}
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", fakeEth{anvil}))
+	require.NoError(t, srv.RegisterName("evm", fakeEvm{anvil}))
+	require.NoError(t, srv.RegisterName("anvil", fakeAnvilNamespace{anvil}))
+	require.NoError(t, srv.RegisterName("admin", fakeAdmin{anvil}))
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+
+	u, err := url.Parse(httpSrv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	chain.Host = u.Hostname()
+	chain.Port = uint(port)
+	return chain, anvil
+}
+
+func newTestClient(t *testing.T, chains []config.Chain) *Client {
+	api, err := NewAPI(log.New("module", "test"), Devnet{
+		Chains: chains,
+		Faults: map[string]*faults.Injector{"L1": faults.NewInjector(nil)},
+	})
+	require.NoError(t, err)
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName(NAMESPACE, api))
+	t.Cleanup(func() {
+		srv.Stop()
+		api.Close()
+	})
+	return NewClient(rpc.DialInProc(srv))
+}
+
+func TestControlAPI(t *testing.T) {
+	l1, l1Anvil := newFakeAnvil(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900})
+	l2, l2Anvil := newFakeAnvil(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900})
+	client := newTestClient(t, []config.Chain{l1, l2})
+	ctx := context.Background()
+
+	chains, err := client.Chains(ctx)
+	require.NoError(t, err)
+	require.Len(t, chains, 2)
+	require.Equal(t, "L1", chains[0].Name)
+	require.False(t, chains[0].L2)
+	require.Nil(t, chains[0].BaseChainID)
+	require.Equal(t, hexutil.Uint64(901), chains[1].ChainID)
+	require.True(t, chains[1].L2)
+	require.Equal(t, hexutil.Uint64(900), *chains[1].BaseChainID)
+	require.Equal(t, l1.WSURL(), chains[0].WSURL)
+	require.Empty(t, chains[0].AuthURL)
+
+	require.NoError(t, client.MineAll(ctx, 2))
+	require.Equal(t, uint64(2), l1Anvil.number)
+	require.Equal(t, uint64(2), l2Anvil.number)
+
+	require.NoError(t, client.Mine(ctx, "L2", 3))
+	require.Equal(t, uint64(2), l1Anvil.number)
+	require.Equal(t, uint64(5), l2Anvil.number)
+	require.Error(t, client.Mine(ctx, "L3", 1))
+
+	id, err := client.Snapshot(ctx)
+	require.NoError(t, err)
+	require.NoError(t, client.MineAll(ctx, 3))
+	require.NoError(t, client.IncreaseTime(ctx, 60))
+
+	statuses, err := client.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		require.True(t, status.Healthy)
+		require.Equal(t, hexutil.Uint64(60), status.Timestamp)
+	}
+	require.Equal(t, hexutil.Uint64(5), statuses[0].BlockNumber)
+	require.Equal(t, l1.RPCURL(), statuses[0].RPCURL)
+	require.Equal(t, l2.WSURL(), statuses[1].WSURL)
+	require.Equal(t, hexutil.Uint64(8), statuses[1].BlockNumber)
+
+	reverted, err := client.Revert(ctx, id)
+	require.NoError(t, err)
+	require.True(t, reverted)
+	require.Equal(t, uint64(2), l1Anvil.number)
+	require.Equal(t, uint64(5), l2Anvil.number)
+
+	// A snapshot can only be reverted to once
+	reverted, err = client.Revert(ctx, id)
+	require.NoError(t, err)
+	require.False(t, reverted)
+
+	relayed, err := client.RelayPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), relayed)
+
+	// Only L1s are reorged, by at most their number of blocks
+	rolledBack, err := client.Reorg(ctx, "L1", 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), rolledBack)
+	require.Equal(t, []uint64{2}, l1Anvil.reorgs)
+	_, err = client.Reorg(ctx, "L1", 3)
+	require.Error(t, err)
+	_, err = client.Reorg(ctx, "L2", 1)
+	require.Error(t, err)
+	require.Empty(t, l2Anvil.reorgs)
+}
+
+func TestControlAPISnapshotReleasesSnapshotsOnFailure(t *testing.T) {
+	l1, l1Anvil := newFakeAnvil(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900})
+	unreachable := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, Host: "127.0.0.1", Port: 1}
+	client := newTestClient(t, []config.Chain{l1, unreachable})
+
+	_, err := client.Snapshot(context.Background())
+	require.Error(t, err)
+	require.Empty(t, l1Anvil.snapshots)
+}
+
+func TestControlAPISequencer(t *testing.T) {
+	l1, _ := newFakeAnvil(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, Backend: config.BackendGeth})
+	l2, l2Node := newFakeAnvil(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendGeth})
+	derived, _ := newFakeAnvil(t, config.Chain{Name: "L3", ChainID: 902, BaseChainID: 900, Backend: config.BackendGeth, Derivation: true})
+	client := newTestClient(t, []config.Chain{l1, l2, derived})
+	ctx := context.Background()
+
+	head, err := client.PauseSequencer(ctx, "L2")
+	require.NoError(t, err)
+	require.Equal(t, common.BigToHash(common.Big0), head)
+	_, err = client.PauseSequencer(ctx, "L2")
+	require.ErrorContains(t, err, "sequencer not running")
+	status, err := client.SequencerStatus(ctx, "L2")
+	require.NoError(t, err)
+	require.False(t, status.Active)
+	require.EqualValues(t, 1, status.QueuedDeposits)
+
+	// The sequencer resumes on top of blocks built while paused
+	require.NoError(t, client.Mine(ctx, "L2", 2))
+	require.NoError(t, client.ResumeSequencer(ctx, "L2"))
+	require.False(t, l2Node.stopped)
+	require.Error(t, client.ResumeSequencer(ctx, "L2"))
+
+	// Only geth L2s with the built-in sequencer are paused
+	for _, chain := range []string{"L1", "L3", "L4"} {
+		_, err := client.PauseSequencer(ctx, chain)
+		require.Error(t, err, chain)
+	}
+}
+
+func TestControlAPIHardforks(t *testing.T) {
+	canyon, delta := uint(60), uint(120)
+	l1, _ := newFakeAnvil(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900})
+	l2, _ := newFakeAnvil(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendGeth,
+		Hardforks: config.Hardforks{Canyon: &canyon, Delta: &delta}})
+	plain, _ := newFakeAnvil(t, config.Chain{Name: "L3", ChainID: 902, BaseChainID: 900, Backend: config.BackendGeth})
+	client := newTestClient(t, []config.Chain{l1, l2, plain})
+	ctx := context.Background()
+
+	// Canyon activates once the head reaches its timestamp
+	require.NoError(t, client.IncreaseTime(ctx, 60))
+	hardforks, err := client.Hardforks(ctx, "L2")
+	require.NoError(t, err)
+	regolith, canyonTime, deltaTime := hexutil.Uint64(0), hexutil.Uint64(60), hexutil.Uint64(120)
+	require.Equal(t, []Hardfork{
+		{Name: "regolith", Time: &regolith, Active: true},
+		{Name: "canyon", Time: &canyonTime, Active: true},
+		{Name: "delta", Time: &deltaTime, Active: false},
+		{Name: "ecotone"},
+	}, hardforks)
+
+	// Ecotone is scheduled on the running sequencer, after the head and Delta
+	require.Error(t, client.ScheduleHardfork(ctx, "L2", "ecotone", 60))
+	require.Error(t, client.ScheduleHardfork(ctx, "L2", "ecotone", 90))
+	require.Error(t, client.ScheduleHardfork(ctx, "L2", "fjord", 300))
+	require.NoError(t, client.ScheduleHardfork(ctx, "L2", "ecotone", 300))
+	hardforks, err = client.Hardforks(ctx, "L2")
+	require.NoError(t, err)
+	ecotoneTime := hexutil.Uint64(300)
+	require.Equal(t, Hardfork{Name: "ecotone", Time: &ecotoneTime, Active: false}, hardforks[3])
+	require.Error(t, client.ScheduleHardfork(ctx, "L1", "ecotone", 300))
+
+	hardforks, err = client.Hardforks(ctx, "L3")
+	require.NoError(t, err)
+	require.True(t, hardforks[0].Active)
+	require.Nil(t, hardforks[1].Time)
+	require.False(t, hardforks[1].Active)
+
+	for _, chain := range []string{"L1", "L4"} {
+		_, err := client.Hardforks(ctx, chain)
+		require.Error(t, err, chain)
+	}
+}
+
+func TestControlAPIStatusUnhealthy(t *testing.T) {
+	client := newTestClient(t, []config.Chain{{Name: "L1", ChainID: 900, Host: "127.0.0.1", Port: 1}})
+
+	statuses, err := client.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.False(t, statuses[0].Healthy)
+	require.NotEmpty(t, statuses[0].Error)
+}
+
+func TestControlAPIFaults(t *testing.T) {
+	l1, _ := newFakeAnvil(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900})
+	client := newTestClient(t, []config.Chain{l1})
+	ctx := context.Background()
+
+	status, err := client.Faults(ctx, "L1")
+	require.NoError(t, err)
+	require.False(t, status.Enabled)
+	require.Empty(t, status.Rules)
+
+	rules := []config.Fault{{Kind: config.FaultLatency, LatencyMs: 100, Methods: []string{"eth_call"}}}
+	require.NoError(t, client.SetFaults(ctx, "L1", rules))
+	require.NoError(t, client.SetFaultsEnabled(ctx, "L1", true))
+	status, err = client.Faults(ctx, "L1")
+	require.NoError(t, err)
+	require.True(t, status.Enabled)
+	require.Equal(t, rules, status.Rules)
+
+	require.Error(t, client.SetFaults(ctx, "L1", []config.Fault{{Kind: "flaky"}}))
+	require.Error(t, client.SetFaultsEnabled(ctx, "L2", true))
+}
+
+func TestControlAPITimeTravel(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	anvilL2 := config.Chain{Name: "A", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000,
+		Backend: config.BackendSimulated}
+	gethL2 := config.Chain{Name: "B", ChainID: 902, BaseChainID: 900, GasLimit: 30_000_000,
+		Backend: config.BackendGeth, BlockTime: 4}
+	l1, _ = simtest.StartSimulated(t, l1)
+	anvilL2, _ = simtest.StartSimulated(t, anvilL2)
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	sysCfg, err := rollup.GenesisSystemConfig(gethL2)
+	require.NoError(t, err)
+	gethL2, _ = simtest.StartGeth(t, gethL2, geth.GethConfig{
+		OpGeth:    true,
+		BlockTime: 4,
+		Sequencer: &geth.SequencerConfig{
+			L1URL:         l1.RPCURL(),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  sysCfg,
+			SeqWindowSize: 3600,
+		},
+	})
+
+	client := newTestClient(t, []config.Chain{l1, anvilL2, gethL2})
+	ctx := context.Background()
+	// The L1 origin of the geth L2 lags behind the L1 head
+	require.NoError(t, client.MineAll(ctx, 3))
+	require.NoError(t, client.Mine(ctx, "L1", 5))
+	before, err := client.Status(ctx)
+	require.NoError(t, err)
+
+	warps, err := client.TimeTravel(ctx, "L1", 3600)
+	require.NoError(t, err)
+	require.Len(t, warps, 3)
+	l1Warp := warps[0]
+	require.Equal(t, "L1", l1Warp.Name)
+	require.Nil(t, l1Warp.L1Origin)
+	require.Equal(t, before[0].BlockNumber+1, l1Warp.BlockNumber)
+	require.Equal(t, before[0].Timestamp+3600, l1Warp.Timestamp)
+
+	for i, l2 := range []config.Chain{anvilL2, gethL2} {
+		warp := warps[i+1]
+		require.Equal(t, before[i+1].Name, warp.Name)
+		// The L2 follows the L1 at a multiple of its block time, with the new L1 block as its L1 info
+		require.GreaterOrEqual(t, uint64(warp.Timestamp), uint64(l1Warp.Timestamp), warp.Name)
+		require.Equal(t, l1Warp.BlockNumber, *warp.L1Origin, warp.Name)
+		l2Client, err := ethclient.Dial(l2.RPCURL())
+		require.NoError(t, err)
+		defer l2Client.Close()
+		parent, err := l2Client.HeaderByNumber(ctx, new(big.Int).SetUint64(uint64(warp.BlockNumber)-1))
+		require.NoError(t, err)
+		require.Zero(t, (uint64(warp.Timestamp)-parent.Time)%rollup.BlockTime(l2), warp.Name)
+
+		l1Block, err := bindings.NewL1BlockCaller(predeploys.L1BlockAddr, l2Client)
+		require.NoError(t, err)
+		timestamp, err := l1Block.Timestamp(&bind.CallOpts{BlockNumber: new(big.Int).SetUint64(uint64(warp.BlockNumber))})
+		require.NoError(t, err)
+		require.Equal(t, uint64(l1Warp.Timestamp), timestamp, warp.Name)
+	}
+	// The simulated L2 mined a block installing the L1Block predeploy and the block of the new time, while
+	// the geth L2 mined until its L1 origin caught up
+	require.Equal(t, before[1].BlockNumber+2, warps[1].BlockNumber)
+	require.Greater(t, uint64(warps[2].BlockNumber), uint64(before[2].BlockNumber)+1)
+
+	for _, chain := range []string{"A", "L2"} {
+		_, err := client.TimeTravel(ctx, chain, 60)
+		require.Error(t, err, chain)
+	}
+	_, err = client.TimeTravel(ctx, "L1", 0)
+	require.Error(t, err)
+}
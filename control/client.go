@@ -0,0 +1,180 @@
+package control
+
+import (
+	"context"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/services/batcher"
+	"github.com/ethereum-optimism/mocktimism/services/challenger"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/interop"
+	"github.com/ethereum-optimism/mocktimism/services/proposer"
+	"github.com/ethereum-optimism/mocktimism/services/tokens"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client is a typed client of the mocktimism_* namespace for go tests
+type Client struct {
+	rpc *rpc.Client
+}
+
+func NewClient(client *rpc.Client) *Client {
+	return &Client{rpc: client}
+}
+
+// Dial connects to the mocktimism gateway, e.g. http://127.0.0.1:8555
+func Dial(url string) (*Client, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(client), nil
+}
+
+func (c *Client) Close() {
+	c.rpc.Close()
+}
+
+func (c *Client) Chains(ctx context.Context) ([]ChainInfo, error) {
+	var chains []ChainInfo
+	err := c.rpc.CallContext(ctx, &chains, NAMESPACE+"_chains")
+	return chains, err
+}
+
+func (c *Client) Status(ctx context.Context) ([]ChainStatus, error) {
+	var statuses []ChainStatus
+	err := c.rpc.CallContext(ctx, &statuses, NAMESPACE+"_status")
+	return statuses, err
+}
+
+func (c *Client) Snapshot(ctx context.Context) (uint64, error) {
+	var id hexutil.Uint64
+	err := c.rpc.CallContext(ctx, &id, NAMESPACE+"_snapshot")
+	return uint64(id), err
+}
+
+func (c *Client) Revert(ctx context.Context, id uint64) (bool, error) {
+	var reverted bool
+	err := c.rpc.CallContext(ctx, &reverted, NAMESPACE+"_revert", hexutil.Uint64(id))
+	return reverted, err
+}
+
+func (c *Client) MineAll(ctx context.Context, blocks uint64) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_mineAll", hexutil.Uint64(blocks))
+}
+
+func (c *Client) Mine(ctx context.Context, chain string, blocks uint64) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_mine", chain, hexutil.Uint64(blocks))
+}
+
+func (c *Client) IncreaseTime(ctx context.Context, seconds uint64) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_increaseTime", hexutil.Uint64(seconds))
+}
+
+func (c *Client) TimeTravel(ctx context.Context, chain string, seconds uint64) ([]Warp, error) {
+	var warps []Warp
+	err := c.rpc.CallContext(ctx, &warps, NAMESPACE+"_timeTravel", chain, hexutil.Uint64(seconds))
+	return warps, err
+}
+
+func (c *Client) RelayPending(ctx context.Context) (uint64, error) {
+	var relayed hexutil.Uint64
+	err := c.rpc.CallContext(ctx, &relayed, NAMESPACE+"_relayPending")
+	return uint64(relayed), err
+}
+
+func (c *Client) RelayMessages(ctx context.Context) ([]interop.Message, error) {
+	var messages []interop.Message
+	err := c.rpc.CallContext(ctx, &messages, NAMESPACE+"_relayMessages")
+	return messages, err
+}
+
+func (c *Client) Messages(ctx context.Context, chain string) ([]interop.Message, error) {
+	var messages []interop.Message
+	err := c.rpc.CallContext(ctx, &messages, NAMESPACE+"_messages", chain)
+	return messages, err
+}
+
+func (c *Client) Reorg(ctx context.Context, chain string, depth uint64) (uint64, error) {
+	var rolledBack hexutil.Uint64
+	err := c.rpc.CallContext(ctx, &rolledBack, NAMESPACE+"_reorg", chain, hexutil.Uint64(depth))
+	return uint64(rolledBack), err
+}
+
+func (c *Client) SubmitBatches(ctx context.Context) ([]batcher.Batch, error) {
+	var batches []batcher.Batch
+	err := c.rpc.CallContext(ctx, &batches, NAMESPACE+"_submitBatches")
+	return batches, err
+}
+
+func (c *Client) Batches(ctx context.Context, chain string) ([]batcher.Batch, error) {
+	var batches []batcher.Batch
+	err := c.rpc.CallContext(ctx, &batches, NAMESPACE+"_batches", chain)
+	return batches, err
+}
+
+func (c *Client) ProposeOutputs(ctx context.Context) ([]proposer.Proposal, error) {
+	var proposals []proposer.Proposal
+	err := c.rpc.CallContext(ctx, &proposals, NAMESPACE+"_proposeOutputs")
+	return proposals, err
+}
+
+func (c *Client) Proposals(ctx context.Context, chain string) ([]proposer.Proposal, error) {
+	var proposals []proposer.Proposal
+	err := c.rpc.CallContext(ctx, &proposals, NAMESPACE+"_proposals", chain)
+	return proposals, err
+}
+
+func (c *Client) DisputeGames(ctx context.Context, chain string) ([]challenger.Game, error) {
+	var games []challenger.Game
+	err := c.rpc.CallContext(ctx, &games, NAMESPACE+"_disputeGames", chain)
+	return games, err
+}
+
+func (c *Client) Tokens(ctx context.Context, chain string) ([]tokens.Pair, error) {
+	var pairs []tokens.Pair
+	err := c.rpc.CallContext(ctx, &pairs, NAMESPACE+"_tokens", chain)
+	return pairs, err
+}
+
+func (c *Client) PauseSequencer(ctx context.Context, chain string) (common.Hash, error) {
+	var head common.Hash
+	err := c.rpc.CallContext(ctx, &head, NAMESPACE+"_pauseSequencer", chain)
+	return head, err
+}
+
+func (c *Client) ResumeSequencer(ctx context.Context, chain string) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_resumeSequencer", chain)
+}
+
+func (c *Client) SequencerStatus(ctx context.Context, chain string) (*geth.SequencerStatus, error) {
+	var status geth.SequencerStatus
+	err := c.rpc.CallContext(ctx, &status, NAMESPACE+"_sequencerStatus", chain)
+	return &status, err
+}
+
+func (c *Client) Hardforks(ctx context.Context, chain string) ([]Hardfork, error) {
+	var hardforks []Hardfork
+	err := c.rpc.CallContext(ctx, &hardforks, NAMESPACE+"_hardforks", chain)
+	return hardforks, err
+}
+
+func (c *Client) ScheduleHardfork(ctx context.Context, chain string, name string, timestamp hexutil.Uint64) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_scheduleHardfork", chain, name, timestamp)
+}
+
+func (c *Client) Faults(ctx context.Context, chain string) (*FaultsStatus, error) {
+	var status FaultsStatus
+	err := c.rpc.CallContext(ctx, &status, NAMESPACE+"_faults", chain)
+	return &status, err
+}
+
+func (c *Client) SetFaults(ctx context.Context, chain string, rules []config.Fault) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_setFaults", chain, rules)
+}
+
+func (c *Client) SetFaultsEnabled(ctx context.Context, chain string, enabled bool) error {
+	return c.rpc.CallContext(ctx, nil, NAMESPACE+"_setFaultsEnabled", chain, enabled)
+}
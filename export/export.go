@@ -0,0 +1,304 @@
+// Package export renders a mocktimism profile into the configuration formats of common ethereum tooling.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type Format string
+
+const (
+	FormatFoundry Format = "foundry"
+	FormatHardhat Format = "hardhat"
+	FormatViem    Format = "viem"
+	FormatEnv     Format = "env"
+	FormatJSON    Format = "json"
+)
+
+// Formats lists every supported export format
+var Formats = []Format{FormatFoundry, FormatHardhat, FormatViem, FormatEnv, FormatJSON}
+
+// anvil funds 10 accounts when no account count is configured
+const defaultAccounts = 10
+
+type Account struct {
+	Address    common.Address `json:"address"`
+	PrivateKey string         `json:"privateKey"`
+}
+
+type Chain struct {
+	Name        string                    `json:"name"`
+	ChainID     uint                      `json:"chainId"`
+	BaseChainID uint                      `json:"baseChainId,omitempty"`
+	L2          bool                      `json:"l2"`
+	RPCURL      string                    `json:"rpcUrl"`
+	Accounts    []Account                 `json:"accounts"`
+	Contracts   map[string]common.Address `json:"contracts,omitempty"`
+}
+
+// Devnet is the tool agnostic description of a profile that every format is rendered from.
+type Devnet struct {
+	Profile  string  `json:"profile"`
+	Mnemonic string  `json:"mnemonic"`
+	Chains   []Chain `json:"chains"`
+}
+
+// NewDevnet collects the chains, funded accounts and contract addresses of a profile. Every backend funds
+// the first accounts of the mnemonic of a chain, anvil through its --accounts flag.
+func NewDevnet(name string, profile config.Profile) (*Devnet, error) {
+	maxAccounts := uint(0)
+	for _, chain := range profile.Chains {
+		maxAccounts = max(maxAccounts, accountCount(chain))
+	}
+	funded, err := accounts.Derive(accounts.DefaultMnemonic, maxAccounts)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	devnet := &Devnet{
+		Profile:  name,
+		Mnemonic: accounts.DefaultMnemonic,
+		Chains:   make([]Chain, 0, len(profile.Chains)),
+	}
+	for _, chain := range profile.Chains {
+		c := Chain{
+			Name:     chain.Name,
+			ChainID:  chain.EffectiveChainID(),
+			L2:       chain.IsL2(),
+			RPCURL:   chain.RPCURL(),
+			Accounts: make([]Account, 0, accountCount(chain)),
+		}
+		if c.L2 {
+			c.BaseChainID = chain.BaseChainID
+		} else {
+			// The generated deployment only lives on the L1
+			c.Contracts = addresses
+		}
+		for _, account := range funded[:accountCount(chain)] {
+			c.Accounts = append(c.Accounts, Account{
+				Address:    account.Address,
+				PrivateKey: account.HexPrivateKey(),
+			})
+		}
+		devnet.Chains = append(devnet.Chains, c)
+	}
+	return devnet, nil
+}
+
+func accountCount(chain config.Chain) uint {
+	if chain.Accounts == 0 {
+		return defaultAccounts
+	}
+	return chain.Accounts
+}
+
+// Render writes the devnet to w in the given format.
+func Render(w io.Writer, format Format, devnet *Devnet) error {
+	switch format {
+	case FormatFoundry:
+		return renderFoundry(w, devnet)
+	case FormatHardhat:
+		return renderHardhat(w, devnet)
+	case FormatViem:
+		return renderViem(w, devnet)
+	case FormatEnv:
+		return renderEnv(w, devnet)
+	case FormatJSON:
+		s, err := json.MarshalIndent(devnet, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(s))
+		return err
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func (d *Devnet) chainByID(id uint) *Chain {
+	for i := range d.Chains {
+		if d.Chains[i].ChainID == id {
+			return &d.Chains[i]
+		}
+	}
+	return nil
+}
+
+func renderFoundry(w io.Writer, devnet *Devnet) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by `mocktimism export` for profile %q\n", devnet.Profile)
+	b.WriteString("[rpc_endpoints]\n")
+	for _, chain := range devnet.Chains {
+		fmt.Fprintf(&b, "%q = %q\n", chain.Name, chain.RPCURL)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderHardhat(w io.Writer, devnet *Devnet) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by `mocktimism export` for profile %q\n", devnet.Profile)
+	b.WriteString("module.exports = {\n  networks: {\n")
+	for _, chain := range devnet.Chains {
+		fmt.Fprintf(&b, "    %q: {\n", chain.Name)
+		fmt.Fprintf(&b, "      url: %q,\n", chain.RPCURL)
+		fmt.Fprintf(&b, "      chainId: %d,\n", chain.ChainID)
+		b.WriteString("      accounts: [\n")
+		for _, account := range chain.Accounts {
+			fmt.Fprintf(&b, "        %q,\n", account.PrivateKey)
+		}
+		b.WriteString("      ],\n    },\n")
+	}
+	b.WriteString("  },\n};\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// l2Contracts are the L1 contracts viem's op stack actions look up on an L2 chain definition
+var l2Contracts = []struct {
+	viem       string
+	deployment string
+}{
+	{"l1StandardBridge", "L1StandardBridgeProxy"},
+	{"l2OutputOracle", "L2OutputOracleProxy"},
+	{"portal", "OptimismPortalProxy"},
+}
+
+func renderViem(w io.Writer, devnet *Devnet) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by `mocktimism export` for profile %q\n", devnet.Profile)
+	b.WriteString("import { defineChain } from 'viem'\n\n")
+	for _, chain := range devnet.Chains {
+		fmt.Fprintf(&b, "export const %s = defineChain({\n", identifier(chain.Name))
+		fmt.Fprintf(&b, "  id: %d,\n", chain.ChainID)
+		fmt.Fprintf(&b, "  name: '%s',\n", chain.Name)
+		fmt.Fprintf(&b, "  network: '%s',\n", strings.ToLower(chain.Name))
+		b.WriteString("  nativeCurrency: { name: 'Ether', symbol: 'ETH', decimals: 18 },\n")
+		b.WriteString("  rpcUrls: {\n")
+		fmt.Fprintf(&b, "    default: { http: ['%s'] },\n", chain.RPCURL)
+		fmt.Fprintf(&b, "    public: { http: ['%s'] },\n", chain.RPCURL)
+		b.WriteString("  },\n")
+		if base := devnet.chainByID(chain.BaseChainID); chain.L2 && base != nil {
+			fmt.Fprintf(&b, "  sourceId: %d,\n", base.ChainID)
+			b.WriteString("  contracts: {\n")
+			for _, c := range l2Contracts {
+				if address, ok := base.Contracts[c.deployment]; ok {
+					fmt.Fprintf(&b, "    %s: { [%d]: { address: '%s' } },\n", c.viem, base.ChainID, address.Hex())
+				}
+			}
+			b.WriteString("  },\n")
+		}
+		b.WriteString("})\n\n")
+	}
+	b.WriteString("export const privateKeys = [\n")
+	for _, account := range devnet.accounts() {
+		fmt.Fprintf(&b, "  '%s',\n", account.PrivateKey)
+	}
+	b.WriteString("] as const\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderEnv(w io.Writer, devnet *Devnet) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by `mocktimism export` for profile %q\n", devnet.Profile)
+	fmt.Fprintf(&b, "MNEMONIC=%q\n", devnet.Mnemonic)
+	for i, account := range devnet.accounts() {
+		fmt.Fprintf(&b, "ADDRESS_%d=%s\n", i, account.Address.Hex())
+		fmt.Fprintf(&b, "PRIVATE_KEY_%d=%s\n", i, account.PrivateKey)
+	}
+	for _, chain := range devnet.Chains {
+		prefix := screamingSnake(chain.Name)
+		fmt.Fprintf(&b, "\n%s_RPC_URL=%s\n", prefix, chain.RPCURL)
+		fmt.Fprintf(&b, "%s_CHAIN_ID=%d\n", prefix, chain.ChainID)
+		names := make([]string, 0, len(chain.Contracts))
+		for name := range chain.Contracts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s_%s_ADDRESS=%s\n", prefix, screamingSnake(name), chain.Contracts[name].Hex())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// accounts returns the accounts of the chain funding the most accounts.
+// Every chain derives from the same mnemonic so the lists only differ in length.
+func (d *Devnet) accounts() []Account {
+	var accounts []Account
+	for _, chain := range d.Chains {
+		if len(chain.Accounts) > len(accounts) {
+			accounts = chain.Accounts
+		}
+	}
+	return accounts
+}
+
+// identifier turns a chain name into a valid javascript identifier, e.g. "op-mainnet" becomes "opMainnet"
+func identifier(name string) string {
+	var b strings.Builder
+	upperNext := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upperNext = b.Len() > 0
+			continue
+		}
+		if b.Len() == 0 {
+			if unicode.IsDigit(r) {
+				b.WriteString("chain")
+				b.WriteRune(r)
+			} else {
+				b.WriteRune(unicode.ToLower(r))
+			}
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "chain"
+	}
+	return b.String()
+}
+
+// screamingSnake turns names like "L1CrossDomainMessengerProxy" or "op-mainnet" into "L1_CROSS_DOMAIN_MESSENGER_PROXY" and "OP_MAINNET"
+func screamingSnake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteRune('_')
+			}
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || ((unicode.IsUpper(prev) || unicode.IsDigit(prev)) && nextIsLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDevnet(t *testing.T) {
+	devnet, err := NewDevnet("default", config.DefaultProfile)
+	require.NoError(t, err)
+	require.Len(t, devnet.Chains, 2)
+
+	l1 := devnet.Chains[0]
+	require.Equal(t, "L1", l1.Name)
+	require.Equal(t, uint(900), l1.ChainID)
+	require.False(t, l1.L2)
+	require.Equal(t, "http://127.0.0.1:8545", l1.RPCURL)
+	require.Len(t, l1.Accounts, 10)
+	require.Equal(t, "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", l1.Accounts[0].Address.Hex())
+	require.Contains(t, l1.Contracts, "OptimismPortalProxy")
+
+	l2 := devnet.Chains[1]
+	require.Equal(t, uint(901), l2.ChainID)
+	require.Equal(t, uint(900), l2.BaseChainID)
+	require.True(t, l2.L2)
+	require.Empty(t, l2.Contracts)
+}
+
+func TestRender(t *testing.T) {
+	devnet, err := NewDevnet("default", config.DefaultProfile)
+	require.NoError(t, err)
+
+	tests := []struct {
+		format   Format
+		contains []string
+	}{
+		{FormatFoundry, []string{"[rpc_endpoints]", `"L1" = "http://127.0.0.1:8545"`, `"L2" = "http://localhost:9545"`}},
+		{FormatHardhat, []string{`url: "http://127.0.0.1:8545"`, "chainId: 901", `"0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"`}},
+		{FormatViem, []string{"export const l1 = defineChain({", "export const l2 = defineChain({", "sourceId: 900", "portal: { [900]: { address: '0xf5faA1161967DfCfc99A28a246234176d0c92989' } }"}},
+		{FormatEnv, []string{"L1_RPC_URL=http://127.0.0.1:8545", "L2_CHAIN_ID=901", "L1_OPTIMISM_PORTAL_PROXY_ADDRESS=0xf5faA1161967DfCfc99A28a246234176d0c92989", "PRIVATE_KEY_0=0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, Render(&out, test.format, devnet))
+			for _, s := range test.contains {
+				require.Contains(t, out.String(), s)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Render(&out, FormatJSON, devnet))
+	var decoded Devnet
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	require.Equal(t, *devnet, decoded)
+
+	require.Error(t, Render(&out, Format("truffle"), devnet))
+}
+
+func TestNames(t *testing.T) {
+	require.Equal(t, "L1_CROSS_DOMAIN_MESSENGER_PROXY", screamingSnake("L1CrossDomainMessengerProxy"))
+	require.Equal(t, "OPTIMISM_MINTABLE_ERC20_FACTORY", screamingSnake("OptimismMintableERC20Factory"))
+	require.Equal(t, "OP_MAINNET", screamingSnake("op-mainnet"))
+	require.Equal(t, "opMainnet", identifier("op-mainnet"))
+	require.Equal(t, "chain901", identifier("901"))
+}
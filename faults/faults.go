@@ -0,0 +1,91 @@
+// Package faults decides which configured faults are injected into the requests to a chain.
+package faults
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+)
+
+// Injector holds the fault rules of a single chain. Rules can be replaced and toggled at runtime.
+type Injector struct {
+	mu      sync.Mutex
+	enabled bool
+	rules   []config.Fault
+	rand    *rand.Rand
+}
+
+// NewInjector returns an injector for the rules. It is enabled if any rule is configured.
+func NewInjector(rules []config.Fault) *Injector {
+	return &Injector{
+		enabled: len(rules) > 0,
+		rules:   rules,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (i *Injector) Enabled() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.enabled
+}
+
+func (i *Injector) SetEnabled(enabled bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.enabled = enabled
+}
+
+func (i *Injector) Rules() []config.Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]config.Fault{}, i.rules...)
+}
+
+// SetRules replaces every rule of the injector
+func (i *Injector) SetRules(rules []config.Fault) error {
+	for _, rule := range rules {
+		if err := config.ValidateFault(rule); err != nil {
+			return err
+		}
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append([]config.Fault{}, rules...)
+	return nil
+}
+
+// Sample returns the faults to inject into a request of method
+func (i *Injector) Sample(method string) []config.Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !i.enabled {
+		return nil
+	}
+
+	var fired []config.Fault
+	for _, rule := range i.rules {
+		if !appliesTo(rule, method) {
+			continue
+		}
+		if rule.Probability != 0 && i.rand.Float64() >= rule.Probability {
+			continue
+		}
+		fired = append(fired, rule)
+	}
+	return fired
+}
+
+func appliesTo(rule config.Fault, method string) bool {
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, m := range rule.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
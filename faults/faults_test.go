@@ -0,0 +1,48 @@
+package faults
+
+import (
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectorSample(t *testing.T) {
+	injector := NewInjector([]config.Fault{
+		{Kind: config.FaultError, Methods: []string{"eth_call"}},
+		{Kind: config.FaultLatency, LatencyMs: 10},
+		{Kind: config.FaultDrop, Probability: 0.000001},
+	})
+	require.True(t, injector.Enabled())
+
+	fired := injector.Sample("eth_call")
+	require.Len(t, fired, 2)
+	require.Equal(t, config.FaultError, fired[0].Kind)
+	require.Equal(t, config.FaultLatency, fired[1].Kind)
+
+	fired = injector.Sample("eth_chainId")
+	require.Len(t, fired, 1)
+	require.Equal(t, config.FaultLatency, fired[0].Kind)
+
+	injector.SetEnabled(false)
+	require.Empty(t, injector.Sample("eth_call"))
+}
+
+func TestInjectorSetRules(t *testing.T) {
+	injector := NewInjector(nil)
+	require.False(t, injector.Enabled())
+	require.Empty(t, injector.Rules())
+
+	require.Error(t, injector.SetRules([]config.Fault{{Kind: "flaky"}}))
+	require.Error(t, injector.SetRules([]config.Fault{{Kind: config.FaultDrop, Probability: 2}}))
+	require.Empty(t, injector.Rules())
+
+	rules := []config.Fault{{Kind: config.FaultRateLimit}}
+	require.NoError(t, injector.SetRules(rules))
+	require.Equal(t, rules, injector.Rules())
+
+	// Rules only fire once enabled
+	require.Empty(t, injector.Sample("eth_call"))
+	injector.SetEnabled(true)
+	require.Len(t, injector.Sample("eth_call"), 1)
+}
@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// BlockTagResolver resolves the safe and finalized block tags of a chain to block numbers
+type BlockTagResolver interface {
+	Number(ctx context.Context, tag string) (uint64, error)
+}
+
+// blockTagParams maps the methods taking a block number or tag to the position of that parameter
+var blockTagParams = map[string]int{
+	"eth_getBlockByNumber":                    0,
+	"eth_getBlockTransactionCountByNumber":    0,
+	"eth_getTransactionByBlockNumberAndIndex": 0,
+	"eth_getBlockReceipts":                    0,
+	"eth_getBalance":                          1,
+	"eth_getCode":                             1,
+	"eth_getTransactionCount":                 1,
+	"eth_call":                                1,
+	"eth_estimateGas":                         1,
+	"eth_feeHistory":                          1,
+	"eth_getStorageAt":                        2,
+	"eth_getProof":                            2,
+	"debug_traceBlockByNumber":                0,
+	"debug_traceCall":                         1,
+}
+
+// filterParams maps the methods taking a filter object to the position of that parameter
+var filterParams = map[string]int{
+	"eth_getLogs":   0,
+	"eth_newFilter": 0,
+}
+
+// filterBlockFields are the fields of a filter object taking a block number or tag
+var filterBlockFields = []string{"fromBlock", "toBlock"}
+
+// taggedParams are the parameters of a request with a safe or finalized tag
+type taggedParams struct {
+	params []json.RawMessage
+	// The position of the parameter holding the tags
+	index int
+	// The fields of the filter object at index, nil if the parameter is the tag itself
+	filter map[string]json.RawMessage
+	// The tags by the filter field holding them, or by the empty string for the parameter
+	tags map[string]string
+}
+
+// parseBlockTag returns the safe or finalized tag of a parameter or filter field
+func parseBlockTag(param json.RawMessage) (string, bool) {
+	// Block numbers, hashes and objects are left as is
+	var tag string
+	if err := json.Unmarshal(param, &tag); err != nil {
+		return "", false
+	}
+	return tag, tag == "safe" || tag == "finalized"
+}
+
+// blockTags returns the parameters of a request referring to the safe or finalized tag, either as a
+// parameter or in the block range of a filter object
+func blockTags(msg *jsonrpcMessage) (*taggedParams, bool) {
+	index, ok := blockTagParams[msg.Method]
+	isFilter := false
+	if !ok {
+		if index, ok = filterParams[msg.Method]; !ok {
+			return nil, false
+		}
+		isFilter = true
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(msg.Params, &params); err != nil || len(params) <= index {
+		return nil, false
+	}
+	tagged := &taggedParams{params: params, index: index, tags: make(map[string]string)}
+	if !isFilter {
+		if tag, ok := parseBlockTag(params[index]); ok {
+			tagged.tags[""] = tag
+		}
+	} else if err := json.Unmarshal(params[index], &tagged.filter); err == nil {
+		for _, field := range filterBlockFields {
+			if tag, ok := parseBlockTag(tagged.filter[field]); ok {
+				tagged.tags[field] = tag
+			}
+		}
+	}
+	return tagged, len(tagged.tags) > 0
+}
+
+// resolvesBlockTag reports whether the block tag of a request is resolved by the gateway
+func (r *route) resolvesBlockTag(msg *jsonrpcMessage) bool {
+	if r.blockTags == nil {
+		return false
+	}
+	_, ok := blockTags(msg)
+	return ok
+}
+
+// resolveBlockTag returns a copy of the request with its safe and finalized tags replaced by the block
+// numbers of the resolver, or the request itself if there is nothing to resolve
+func (r *route) resolveBlockTag(ctx context.Context, msg *jsonrpcMessage) (*jsonrpcMessage, error) {
+	if r.blockTags == nil {
+		return msg, nil
+	}
+	tagged, ok := blockTags(msg)
+	if !ok {
+		return msg, nil
+	}
+	// A range from safe to safe resolves both ends to the same block
+	numbers := make(map[string]json.RawMessage)
+	for field, tag := range tagged.tags {
+		number, ok := numbers[tag]
+		if !ok {
+			n, err := r.blockTags.Number(ctx, tag)
+			if err != nil {
+				return nil, err
+			}
+			if number, err = json.Marshal(hexutil.Uint64(n)); err != nil {
+				return nil, err
+			}
+			numbers[tag] = number
+		}
+		if tagged.filter == nil {
+			tagged.params[tagged.index] = number
+		} else {
+			tagged.filter[field] = number
+		}
+	}
+
+	var err error
+	if tagged.filter != nil {
+		if tagged.params[tagged.index], err = json.Marshal(tagged.filter); err != nil {
+			return nil, err
+		}
+	}
+	resolved := *msg
+	if resolved.Params, err = json.Marshal(tagged.params); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
@@ -0,0 +1,239 @@
+// Package gateway serves every chain of a profile behind a single JSON-RPC port.
+//
+// Requests to /<chain name> or /chain/<chain id> are forwarded over HTTP or WebSocket to the chain,
+// except for namespaces registered with RegisterChainAPI like the optimism_* rollup RPC of an L2.
+// The safe and finalized block tags of a chain with a BlockTagResolver are replaced by block numbers.
+// APIs registered with RegisterAPI, like the mocktimism_* namespace, are served on the root path.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/faults"
+	"github.com/ethereum-optimism/mocktimism/recorder"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	SERVICE_TYPE = "gateway"
+)
+
+type Gateway struct {
+	log    log.Logger
+	config config.Gateway
+
+	routesByName map[string]*route
+	routesByID   map[uint]*route
+	rpcServer    *rpc.Server
+	wsHandler    http.Handler
+	server       *http.Server
+	recorder     *recorder.Recorder
+
+	// The port the gateway listens on, which is only known once started for a free port
+	mu   sync.Mutex
+	port int
+}
+
+func validateConfig(cfg config.Gateway) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	return nil
+}
+
+func NewGateway(logger log.Logger, cfg config.Gateway, chains []config.Chain) (*Gateway, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	rpcServer := rpc.NewServer()
+	g := &Gateway{
+		log:          logger,
+		config:       cfg,
+		routesByName: make(map[string]*route),
+		routesByID:   make(map[uint]*route),
+		rpcServer:    rpcServer,
+		wsHandler:    rpcServer.WebsocketHandler([]string{"*"}),
+		port:         int(cfg.Port),
+	}
+	for _, chain := range chains {
+		r := newRoute(logger.New("chain", chain.Name), chain)
+		if _, ok := g.routesByName[chain.Name]; ok {
+			return nil, fmt.Errorf("duplicate chain name: %s", chain.Name)
+		}
+		g.routesByName[chain.Name] = r
+		if id := chain.EffectiveChainID(); id != 0 {
+			g.routesByID[id] = r
+		}
+	}
+	g.server = &http.Server{
+		Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Handler:           g,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return g, nil
+}
+
+func (g *Gateway) Hostname() string {
+	return g.config.Host
+}
+
+// Port returns the port of the gateway. A free port is used if the configured port is 0, which is 0 until the gateway started
+func (g *Gateway) Port() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.port
+}
+
+func (g *Gateway) ServiceType() string {
+	return SERVICE_TYPE
+}
+
+func (g *Gateway) ID() string {
+	return SERVICE_TYPE
+}
+
+func (g *Gateway) Config() interface{} {
+	return g.config
+}
+
+// FaultInjectors returns the fault injector of every chain keyed by chain name
+func (g *Gateway) FaultInjectors() map[string]*faults.Injector {
+	injectors := make(map[string]*faults.Injector, len(g.routesByName))
+	for name, r := range g.routesByName {
+		injectors[name] = r.faults
+	}
+	return injectors
+}
+
+// SetRecorder records the traffic of every chain. The gateway closes the recorder once it stops.
+func (g *Gateway) SetRecorder(rec *recorder.Recorder) {
+	g.recorder = rec
+	for _, r := range g.routesByName {
+		r.recorder = rec
+	}
+}
+
+// RegisterAPI serves the methods of api under the namespace on the root path of the gateway
+func (g *Gateway) RegisterAPI(namespace string, api interface{}) error {
+	return g.rpcServer.RegisterName(namespace, api)
+}
+
+// RegisterChainAPI serves the methods of api under the namespace on the path of a chain instead of forwarding them to the chain
+func (g *Gateway) RegisterChainAPI(chain string, namespace string, api interface{}) error {
+	r, ok := g.routesByName[chain]
+	if !ok {
+		return fmt.Errorf("unknown chain: %s", chain)
+	}
+	return r.registerAPI(namespace, api)
+}
+
+// SetBlockTags resolves the safe and finalized block tags of requests to a chain with resolver instead of the chain
+func (g *Gateway) SetBlockTags(chain string, resolver BlockTagResolver) error {
+	r, ok := g.routesByName[chain]
+	if !ok {
+		return fmt.Errorf("unknown chain: %s", chain)
+	}
+	r.blockTags = resolver
+	return nil
+}
+
+// Start serves the gateway until the context is canceled
+func (g *Gateway) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", g.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", g.server.Addr, err)
+	}
+	g.mu.Lock()
+	g.port = listener.Addr().(*net.TCPAddr).Port
+	g.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := g.server.Shutdown(shutdownCtx); err != nil {
+			g.log.Error("failed to shutdown gateway", "err", err)
+		}
+		g.rpcServer.Stop()
+		for _, r := range g.routesByName {
+			if r.local != nil {
+				r.localClient.Close()
+				r.local.Stop()
+			}
+		}
+		if g.recorder != nil {
+			if err := g.recorder.Close(); err != nil {
+				g.log.Error("failed to close recording", "err", err)
+			}
+		}
+	}()
+
+	if g.recorder != nil {
+		g.log.Info("Recording rpc traffic", "path", g.recorder.Path())
+	}
+	g.log.Info("Started gateway", "addr", listener.Addr().String())
+	if err := g.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if strings.Trim(req.URL.Path, "/") == "" {
+		if websocket.IsWebSocketUpgrade(req) {
+			g.wsHandler.ServeHTTP(w, req)
+		} else {
+			g.rpcServer.ServeHTTP(w, req)
+		}
+		return
+	}
+
+	r, ok := g.lookupRoute(req.URL.Path)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no chain found for path %s", req.URL.Path), http.StatusNotFound)
+		return
+	}
+	r.ServeHTTP(w, req)
+}
+
+// lookupRoute resolves /<chain name> and /chain/<chain id> paths
+func (g *Gateway) lookupRoute(path string) (*route, bool) {
+	name, id, ok := parseChainPath(path)
+	if !ok {
+		return nil, false
+	}
+	if name != "" {
+		r, ok := g.routesByName[name]
+		return r, ok
+	}
+	r, ok := g.routesByID[id]
+	return r, ok
+}
+
+// parseChainPath returns either the chain name of /<chain name> or the chain id of /chain/<chain id>
+func parseChainPath(path string) (string, uint, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 2 && segments[0] == "chain" {
+		id, err := strconv.ParseUint(segments[1], 10, 64)
+		if err != nil {
+			return "", 0, false
+		}
+		return "", uint(id), true
+	}
+	if len(segments) == 1 && segments[0] != "" {
+		return segments[0], 0, true
+	}
+	return "", 0, false
+}
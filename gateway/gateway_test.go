@@ -0,0 +1,576 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/recorder"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+type testEthAPI struct {
+	chainID uint64
+}
+
+func (api *testEthAPI) ChainId() hexutil.Uint64 {
+	return hexutil.Uint64(api.chainID)
+}
+
+func (api *testEthAPI) BlockNumber() hexutil.Uint64 {
+	return 100
+}
+
+// GetBlockTransactionCountByNumber echoes the block number or tag it was called with
+func (api *testEthAPI) GetBlockTransactionCountByNumber(block string) string {
+	return block
+}
+
+// GetLogs echoes the filter it was called with
+func (api *testEthAPI) GetLogs(filter map[string]interface{}) map[string]interface{} {
+	return filter
+}
+
+func (api *testEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	go func() {
+		for i := 0; i < 3; i++ {
+			_ = notifier.Notify(sub.ID, hexutil.Uint64(i))
+		}
+	}()
+	return sub, nil
+}
+
+// newTestChain serves a minimal eth namespace over http and websocket on the same port like anvil does
+func newTestChain(t *testing.T, name string, chainID uint64) config.Chain {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", &testEthAPI{chainID: chainID}))
+	ws := srv.WebsocketHandler([]string{"*"})
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "websocket" {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+
+	u, err := url.Parse(httpSrv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	return config.Chain{
+		Name:    name,
+		ChainID: uint(chainID),
+		Host:    u.Hostname(),
+		Port:    uint(port),
+	}
+}
+
+// waitPort waits until a server started on a free port listens and returns the port
+func waitPort(t *testing.T, port func() int) uint {
+	require.Eventually(t, func() bool {
+		return port() != 0
+	}, 2*time.Second, 20*time.Millisecond)
+	return uint(port())
+}
+
+func startGateway(t *testing.T, chains ...config.Chain) string {
+	cfg := config.Gateway{Host: "127.0.0.1"}
+	gw, err := NewGateway(log.New("module", "test"), cfg, chains)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- gw.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	return fmt.Sprintf("127.0.0.1:%d", waitPort(t, gw.Port))
+}
+
+func TestGatewayValidation(t *testing.T) {
+	_, err := NewGateway(log.New("module", "test"), config.Gateway{Port: 8555}, nil)
+	require.Error(t, err)
+	_, err = NewGateway(log.New("module", "test"), config.Gateway{Host: "127.0.0.1", Port: 8555}, []config.Chain{{Name: "L1"}, {Name: "L1"}})
+	require.Error(t, err)
+}
+
+func TestGatewayRoutesHTTP(t *testing.T) {
+	addr := startGateway(t, newTestChain(t, "L1", 900), newTestChain(t, "L2", 901))
+
+	for path, expected := range map[string]uint64{
+		"/L1":        900,
+		"/L2/":       901,
+		"/chain/900": 900,
+		"/chain/901": 901,
+	} {
+		client, err := rpc.Dial("http://" + addr + path)
+		require.NoError(t, err)
+		var chainID hexutil.Uint64
+		require.NoError(t, client.Call(&chainID, "eth_chainId"))
+		require.Equal(t, expected, uint64(chainID), path)
+		client.Close()
+	}
+
+	for _, path := range []string{"/L3", "/chain/1", "/chain/abc", "/L1/unknown"} {
+		resp, err := http.Post("http://"+addr+path, "application/json", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`))
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+func TestGatewayBatch(t *testing.T) {
+	addr := startGateway(t, newTestChain(t, "L1", 900))
+
+	client, err := rpc.Dial("http://" + addr + "/L1")
+	require.NoError(t, err)
+	defer client.Close()
+
+	var first, second hexutil.Uint64
+	batch := []rpc.BatchElem{
+		{Method: "eth_chainId", Result: &first},
+		{Method: "eth_chainId", Result: &second},
+		{Method: "eth_unknown", Result: new(interface{})},
+	}
+	require.NoError(t, client.BatchCall(batch))
+	require.NoError(t, batch[0].Error)
+	require.NoError(t, batch[1].Error)
+	require.Error(t, batch[2].Error)
+	require.Equal(t, uint64(900), uint64(first))
+	require.Equal(t, uint64(900), uint64(second))
+
+	// Oversized requests are rejected instead of forwarded truncated
+	resp, err := http.Post("http://"+addr+"/L1", "application/json", bytes.NewReader(make([]byte, maxRequestContentLength+1)))
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestGatewayWebsocket(t *testing.T) {
+	addr := startGateway(t, newTestChain(t, "L1", 900))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := rpc.DialContext(ctx, "ws://"+addr+"/chain/900")
+	require.NoError(t, err)
+	defer client.Close()
+
+	var chainID hexutil.Uint64
+	require.NoError(t, client.CallContext(ctx, &chainID, "eth_chainId"))
+	require.Equal(t, uint64(900), uint64(chainID))
+
+	heads := make(chan hexutil.Uint64)
+	sub, err := client.EthSubscribe(ctx, heads, "newHeads")
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	for i := 0; i < 3; i++ {
+		select {
+		case head := <-heads:
+			require.Equal(t, uint64(i), uint64(head))
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for subscription")
+		}
+	}
+}
+
+type testControlAPI struct{}
+
+func (api *testControlAPI) Ping() string {
+	return "pong"
+}
+
+func TestGatewayServesAPIsOnRoot(t *testing.T) {
+	cfg := config.Gateway{Host: "127.0.0.1"}
+	gw, err := NewGateway(log.New("module", "test"), cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, gw.RegisterAPI("mocktimism", &testControlAPI{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = gw.Start(ctx)
+	}()
+	cfg.Port = waitPort(t, gw.Port)
+
+	for _, endpoint := range []string{"http://%s:%d", "ws://%s:%d/"} {
+		var client *rpc.Client
+		require.Eventually(t, func() bool {
+			client, err = rpc.Dial(fmt.Sprintf(endpoint, cfg.Host, cfg.Port))
+			return err == nil
+		}, 2*time.Second, 20*time.Millisecond)
+
+		var pong string
+		require.Eventually(t, func() bool {
+			return client.Call(&pong, "mocktimism_ping") == nil
+		}, 2*time.Second, 20*time.Millisecond)
+		require.Equal(t, "pong", pong)
+		client.Close()
+	}
+}
+
+func TestGatewayFaultsHTTP(t *testing.T) {
+	chain := newTestChain(t, "L1", 900)
+	chain.Faults = []config.Fault{
+		{Kind: config.FaultError, Methods: []string{"eth_call"}, ErrorCode: -32603, ErrorMessage: "boom"},
+		{Kind: config.FaultStaleBlockNumber, Methods: []string{"eth_blockNumber"}, StaleBlocks: 10},
+		{Kind: config.FaultRateLimit, Methods: []string{"eth_gasPrice"}},
+		{Kind: config.FaultDrop, Methods: []string{"eth_syncing"}},
+		{Kind: config.FaultLatency, Methods: []string{"eth_chainId"}, LatencyMs: 200},
+	}
+	addr := startGateway(t, chain)
+
+	client, err := rpc.Dial("http://" + addr + "/L1")
+	require.NoError(t, err)
+	defer client.Close()
+
+	var result interface{}
+	err = client.Call(&result, "eth_call")
+	var rpcErr rpc.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32603, rpcErr.ErrorCode())
+	require.Equal(t, "boom", rpcErr.Error())
+
+	var number hexutil.Uint64
+	require.NoError(t, client.Call(&number, "eth_blockNumber"))
+	require.Equal(t, uint64(90), uint64(number))
+
+	start := time.Now()
+	var chainID hexutil.Uint64
+	require.NoError(t, client.Call(&chainID, "eth_chainId"))
+	require.Equal(t, uint64(900), uint64(chainID))
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	resp, err := http.Post("http://"+addr+"/L1", "application/json", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_gasPrice"}`))
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	_, err = http.Post("http://"+addr+"/L1", "application/json", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_syncing"}`))
+	require.Error(t, err)
+}
+
+func TestGatewayFaultsToggle(t *testing.T) {
+	chain := newTestChain(t, "L1", 900)
+	chain.Faults = []config.Fault{{Kind: config.FaultError, Methods: []string{"eth_chainId"}}}
+	cfg := config.Gateway{Host: "127.0.0.1"}
+	gw, err := NewGateway(log.New("module", "test"), cfg, []config.Chain{chain})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = gw.Start(ctx)
+	}()
+	cfg.Port = waitPort(t, gw.Port)
+
+	var client *rpc.Client
+	require.Eventually(t, func() bool {
+		client, err = rpc.Dial(fmt.Sprintf("http://%s:%d/L1", cfg.Host, cfg.Port))
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	defer client.Close()
+
+	var chainID hexutil.Uint64
+	require.Eventually(t, func() bool {
+		err := client.Call(&chainID, "eth_chainId")
+		return err != nil && err.Error() == "injected fault"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	gw.FaultInjectors()["L1"].SetEnabled(false)
+	require.NoError(t, client.Call(&chainID, "eth_chainId"))
+	require.Equal(t, uint64(900), uint64(chainID))
+}
+
+func TestGatewayFaultsWebsocket(t *testing.T) {
+	chain := newTestChain(t, "L1", 900)
+	chain.Faults = []config.Fault{
+		{Kind: config.FaultError, Methods: []string{"eth_call"}},
+		{Kind: config.FaultStaleBlockNumber, StaleBlocks: 5},
+		{Kind: config.FaultLatency, Methods: []string{"eth_getBlockTransactionCountByNumber"}, LatencyMs: 500},
+		{Kind: config.FaultRateLimit, Methods: []string{"eth_gasPrice"}},
+	}
+	addr := startGateway(t, chain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := rpc.DialContext(ctx, "ws://"+addr+"/L1")
+	require.NoError(t, err)
+	defer client.Close()
+
+	var result interface{}
+	require.EqualError(t, client.CallContext(ctx, &result, "eth_call"), "injected fault")
+
+	var number hexutil.Uint64
+	require.NoError(t, client.CallContext(ctx, &number, "eth_blockNumber"))
+	require.Equal(t, uint64(95), uint64(number))
+
+	// Every member of a batch is answered by its own faults
+	var gasPrice, chainID hexutil.Uint64
+	batch := []rpc.BatchElem{
+		{Method: "eth_call", Result: &result},
+		{Method: "eth_blockNumber", Result: &number},
+		{Method: "eth_gasPrice", Result: &gasPrice},
+		{Method: "eth_chainId", Result: &chainID},
+	}
+	require.NoError(t, client.BatchCallContext(ctx, batch))
+	require.EqualError(t, batch[0].Error, "injected fault")
+	require.NoError(t, batch[1].Error)
+	require.Equal(t, uint64(95), uint64(number))
+	require.EqualError(t, batch[2].Error, "rate limited")
+	require.NoError(t, batch[3].Error)
+	require.Equal(t, uint64(900), uint64(chainID))
+
+	// A delayed request does not hold up the requests after it
+	delayed := make(chan error)
+	go func() {
+		var count string
+		delayed <- client.CallContext(ctx, &count, "eth_getBlockTransactionCountByNumber", "latest")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, client.CallContext(ctx, &chainID, "eth_chainId"))
+	select {
+	case <-delayed:
+		t.Fatal("the delayed request was answered first")
+	default:
+	}
+	require.NoError(t, <-delayed)
+}
+
+func TestGatewayRecordReplay(t *testing.T) {
+	chain := newTestChain(t, "L1", 900)
+	chain.Faults = []config.Fault{{Kind: config.FaultError, Methods: []string{"eth_call"}}}
+	cfg := config.Gateway{Host: "127.0.0.1"}
+	gw, err := NewGateway(log.New("module", "test"), cfg, []config.Chain{chain})
+	require.NoError(t, err)
+	rec, err := recorder.NewRecorder(recorder.Path(t.TempDir()))
+	require.NoError(t, err)
+	gw.SetRecorder(rec)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- gw.Start(ctx)
+	}()
+	cfg.Port = waitPort(t, gw.Port)
+
+	var client *rpc.Client
+	require.Eventually(t, func() bool {
+		client, err = rpc.Dial(fmt.Sprintf("http://%s:%d/L1", cfg.Host, cfg.Port))
+		if err != nil {
+			return false
+		}
+		var chainID hexutil.Uint64
+		return client.Call(&chainID, "eth_chainId") == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	var number hexutil.Uint64
+	require.NoError(t, client.Call(&number, "eth_blockNumber"))
+	require.EqualError(t, client.Call(new(interface{}), "eth_call", map[string]string{}, "latest"), "injected fault")
+	client.Close()
+
+	wsClient, err := rpc.Dial(fmt.Sprintf("ws://%s:%d/L1", cfg.Host, cfg.Port))
+	require.NoError(t, err)
+	var chainID hexutil.Uint64
+	require.NoError(t, wsClient.Call(&chainID, "eth_chainId"))
+	wsClient.Close()
+
+	cancel()
+	require.NoError(t, <-done)
+
+	entries, err := recorder.Load(rec.Path())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(entries), 4)
+	last := entries[len(entries)-1]
+	require.Equal(t, "ws", last.Transport)
+	require.Equal(t, "eth_chainId", last.Method)
+	require.Equal(t, uint(900), last.ChainID)
+	require.JSONEq(t, `"0x384"`, string(last.Result))
+
+	// Replay the recording without the chain
+	replayCfg := config.Gateway{Host: "127.0.0.1"}
+	replayer, err := NewReplayer(log.New("module", "test"), replayCfg, entries)
+	require.NoError(t, err)
+	replayCtx, replayCancel := context.WithCancel(context.Background())
+	defer replayCancel()
+	go func() {
+		_ = replayer.Start(replayCtx)
+	}()
+	replayCfg.Port = waitPort(t, replayer.Port)
+
+	require.Eventually(t, func() bool {
+		client, err = rpc.Dial(fmt.Sprintf("http://%s:%d/chain/900", replayCfg.Host, replayCfg.Port))
+		return err == nil && client.Call(&chainID, "eth_chainId") == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	defer client.Close()
+	require.Equal(t, uint64(900), uint64(chainID))
+	require.NoError(t, client.Call(&number, "eth_blockNumber"))
+	require.Equal(t, uint64(100), uint64(number))
+	require.EqualError(t, client.Call(new(interface{}), "eth_call", map[string]string{}, "latest"), "injected fault")
+	require.ErrorContains(t, client.Call(new(interface{}), "eth_gasPrice"), "no recorded response")
+}
+
+type testRollupAPI struct{}
+
+func (api *testRollupAPI) OutputAtBlock(number hexutil.Uint64) (hexutil.Uint64, error) {
+	if number > 100 {
+		return 0, fmt.Errorf("block %d not found", number)
+	}
+	return number * 2, nil
+}
+
+func TestGatewayServesChainAPIs(t *testing.T) {
+	cfg := config.Gateway{Host: "127.0.0.1"}
+	gw, err := NewGateway(log.New("module", "test"), cfg, []config.Chain{newTestChain(t, "L1", 900), newTestChain(t, "L2", 901)})
+	require.NoError(t, err)
+	require.NoError(t, gw.RegisterChainAPI("L2", "optimism", &testRollupAPI{}))
+	require.Error(t, gw.RegisterChainAPI("L3", "optimism", &testRollupAPI{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = gw.Start(ctx)
+	}()
+	cfg.Port = waitPort(t, gw.Port)
+
+	for _, endpoint := range []string{"http://%s:%d/L2", "ws://%s:%d/L2"} {
+		var client *rpc.Client
+		require.Eventually(t, func() bool {
+			client, err = rpc.Dial(fmt.Sprintf(endpoint, cfg.Host, cfg.Port))
+			return err == nil
+		}, 2*time.Second, 20*time.Millisecond)
+
+		var output hexutil.Uint64
+		require.Eventually(t, func() bool {
+			return client.Call(&output, "optimism_outputAtBlock", hexutil.Uint64(21)) == nil
+		}, 2*time.Second, 20*time.Millisecond)
+		require.Equal(t, uint64(42), uint64(output))
+		require.EqualError(t, client.Call(&output, "optimism_outputAtBlock", hexutil.Uint64(101)), "block 101 not found")
+
+		// Other methods are still forwarded to the chain
+		var chainID hexutil.Uint64
+		require.NoError(t, client.Call(&chainID, "eth_chainId"))
+		require.Equal(t, uint64(901), uint64(chainID))
+		client.Close()
+	}
+
+	var output hexutil.Uint64
+	for _, endpoint := range []string{"http://%s:%d/L2", "ws://%s:%d/L2"} {
+		client, err := rpc.Dial(fmt.Sprintf(endpoint, cfg.Host, cfg.Port))
+		require.NoError(t, err)
+		var chainID hexutil.Uint64
+		output = 0
+		batch := []rpc.BatchElem{
+			{Method: "eth_chainId", Result: &chainID},
+			{Method: "optimism_outputAtBlock", Args: []interface{}{hexutil.Uint64(1)}, Result: &output},
+		}
+		require.NoError(t, client.BatchCall(batch))
+		require.NoError(t, batch[0].Error, endpoint)
+		require.NoError(t, batch[1].Error, endpoint)
+		require.Equal(t, uint64(901), uint64(chainID), endpoint)
+		require.Equal(t, uint64(2), uint64(output), endpoint)
+		client.Close()
+	}
+
+	// The namespace is only served for the chain it was registered for
+	l1, err := rpc.Dial(fmt.Sprintf("http://%s:%d/L1", cfg.Host, cfg.Port))
+	require.NoError(t, err)
+	defer l1.Close()
+	require.Error(t, l1.Call(&output, "optimism_outputAtBlock", hexutil.Uint64(1)))
+}
+
+type testBlockTags map[string]uint64
+
+func (tags testBlockTags) Number(ctx context.Context, tag string) (uint64, error) {
+	number, ok := tags[tag]
+	if !ok {
+		return 0, fmt.Errorf("no %s block", tag)
+	}
+	return number, nil
+}
+
+func TestGatewayResolvesBlockTags(t *testing.T) {
+	cfg := config.Gateway{Host: "127.0.0.1"}
+	gw, err := NewGateway(log.New("module", "test"), cfg, []config.Chain{newTestChain(t, "L1", 900), newTestChain(t, "L2", 901)})
+	require.NoError(t, err)
+	require.NoError(t, gw.SetBlockTags("L2", testBlockTags{"safe": 80}))
+	require.Error(t, gw.SetBlockTags("L3", testBlockTags{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = gw.Start(ctx)
+	}()
+	cfg.Port = waitPort(t, gw.Port)
+
+	for _, endpoint := range []string{"http://%s:%d/L2", "ws://%s:%d/L2"} {
+		var client *rpc.Client
+		require.Eventually(t, func() bool {
+			client, err = rpc.Dial(fmt.Sprintf(endpoint, cfg.Host, cfg.Port))
+			return err == nil
+		}, 2*time.Second, 20*time.Millisecond)
+
+		var block string
+		require.Eventually(t, func() bool {
+			return client.Call(&block, "eth_getBlockTransactionCountByNumber", "safe") == nil
+		}, 2*time.Second, 20*time.Millisecond)
+		require.Equal(t, "0x50", block)
+		require.ErrorContains(t, client.Call(&block, "eth_getBlockTransactionCountByNumber", "finalized"), "no finalized block")
+
+		// Other tags and numbers are forwarded as is
+		require.NoError(t, client.Call(&block, "eth_getBlockTransactionCountByNumber", "latest"))
+		require.Equal(t, "latest", block)
+
+		// Tags in the block range of filters are resolved too
+		var filter map[string]interface{}
+		require.NoError(t, client.Call(&filter, "eth_getLogs", map[string]interface{}{"fromBlock": "safe", "toBlock": "latest", "address": "0x01"}))
+		require.Equal(t, map[string]interface{}{"fromBlock": "0x50", "toBlock": "latest", "address": "0x01"}, filter)
+
+		var safe, latest string
+		batch := []rpc.BatchElem{
+			{Method: "eth_getBlockTransactionCountByNumber", Args: []interface{}{"safe"}, Result: &safe},
+			{Method: "eth_getBlockTransactionCountByNumber", Args: []interface{}{"0x1"}, Result: &latest},
+		}
+		require.NoError(t, client.BatchCall(batch))
+		require.NoError(t, batch[0].Error)
+		require.NoError(t, batch[1].Error)
+		require.Equal(t, "0x50", safe)
+		require.Equal(t, "0x1", latest)
+		client.Close()
+	}
+
+	// Chains without a resolver serve the tags themselves
+	l1, err := rpc.Dial(fmt.Sprintf("http://%s:%d/L1", cfg.Host, cfg.Port))
+	require.NoError(t, err)
+	defer l1.Close()
+	var block string
+	require.NoError(t, l1.Call(&block, "eth_getBlockTransactionCountByNumber", "safe"))
+	require.Equal(t, "safe", block)
+}
@@ -0,0 +1,43 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// jsonrpcMessage is either a JSON-RPC request, response or notification
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonError      `json:"error,omitempty"`
+}
+
+type jsonError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// parseMessages decodes a single JSON-RPC message or a batch of them.
+func parseMessages(data []byte) ([]*jsonrpcMessage, bool, error) {
+	if isBatch(data) {
+		var msgs []*jsonrpcMessage
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return nil, true, err
+		}
+		return msgs, true, nil
+	}
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, false, err
+	}
+	return []*jsonrpcMessage{&msg}, false, nil
+}
+
+func isBatch(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
@@ -0,0 +1,182 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/recorder"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Returned for requests without a recorded response
+const notRecordedErrorCode = -32601
+
+// Replayer serves recorded responses under the paths of the gateway. Identical requests receive
+// their recorded responses in the recorded order, repeating the last one once all were served.
+type Replayer struct {
+	log    log.Logger
+	config config.Gateway
+	server *http.Server
+
+	mu        sync.Mutex
+	port      int
+	chainIDs  map[uint]string
+	responses map[string][]recorder.Entry
+	served    map[string]int
+}
+
+func NewReplayer(logger log.Logger, cfg config.Gateway, entries []recorder.Entry) (*Replayer, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{
+		log:       logger,
+		config:    cfg,
+		port:      int(cfg.Port),
+		chainIDs:  make(map[uint]string),
+		responses: make(map[string][]recorder.Entry),
+		served:    make(map[string]int),
+	}
+	for _, entry := range entries {
+		if entry.ChainID != 0 {
+			r.chainIDs[entry.ChainID] = entry.Chain
+		}
+		key := replayKey(entry.Chain, entry.Method, entry.Params)
+		r.responses[key] = append(r.responses[key], entry)
+	}
+	r.server = &http.Server{
+		Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return r, nil
+}
+
+func (r *Replayer) ID() string {
+	return "replay"
+}
+
+// Port returns the port of the replay. A free port is used if the configured port is 0, which is 0 until the replay started
+func (r *Replayer) Port() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.port
+}
+
+// Start serves the recording until the context is canceled
+func (r *Replayer) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", r.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", r.server.Addr, err)
+	}
+	r.mu.Lock()
+	r.port = listener.Addr().(*net.TCPAddr).Port
+	r.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.server.Shutdown(shutdownCtx); err != nil {
+			r.log.Error("failed to shutdown replay", "err", err)
+		}
+	}()
+
+	r.log.Info("Started replay", "addr", listener.Addr().String(), "requests", len(r.responses))
+	if err := r.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (r *Replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, id, ok := parseChainPath(req.URL.Path)
+	if ok && name == "" {
+		name, ok = r.chainIDs[id]
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("no chain found for path %s", req.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	body, ok := readBody(w, req)
+	if !ok {
+		return
+	}
+	reqs, batch, err := parseMessages(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid json-rpc request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resps := make([]*jsonrpcMessage, 0, len(reqs))
+	for _, msg := range reqs {
+		resp := r.replay(name, msg)
+		// Notifications have no response
+		if msg.ID != nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case batch:
+		_ = json.NewEncoder(w).Encode(resps)
+	case len(resps) == 1:
+		_ = json.NewEncoder(w).Encode(resps[0])
+	}
+}
+
+// replay returns the next recorded response to a request
+func (r *Replayer) replay(chain string, msg *jsonrpcMessage) *jsonrpcMessage {
+	key := replayKey(chain, msg.Method, msg.Params)
+	r.mu.Lock()
+	recorded := r.responses[key]
+	i := r.served[key]
+	if i < len(recorded)-1 {
+		r.served[key]++
+	}
+	r.mu.Unlock()
+
+	if len(recorded) == 0 {
+		r.log.Warn("no recorded response", "chain", chain, "method", msg.Method, "params", string(msg.Params))
+		return &jsonrpcMessage{Version: "2.0", ID: msg.ID, Error: &jsonError{
+			Code:    notRecordedErrorCode,
+			Message: fmt.Sprintf("no recorded response for %s on chain %s", msg.Method, chain),
+		}}
+	}
+	entry := recorded[i]
+	r.log.Info("replayed rpc request", "chain", chain, "method", msg.Method, "id", string(msg.ID))
+	resp := &jsonrpcMessage{Version: "2.0", ID: msg.ID, Result: entry.Result}
+	if entry.Error != nil {
+		resp.Result = nil
+		resp.Error = &jsonError{Code: entry.Error.Code, Message: entry.Error.Message, Data: entry.Error.Data}
+	} else if resp.Result == nil {
+		resp.Result = json.RawMessage("null")
+	}
+	return resp
+}
+
+// replayKey identifies identical requests regardless of the formatting of their params
+func replayKey(chain, method string, params json.RawMessage) string {
+	var compact bytes.Buffer
+	if len(params) == 0 || json.Compact(&compact, params) != nil {
+		compact.Reset()
+		compact.WriteString("[]")
+	}
+	return chain + "/" + method + "/" + compact.String()
+}
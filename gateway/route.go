@@ -0,0 +1,702 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/faults"
+	"github.com/ethereum-optimism/mocktimism/recorder"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// Same limit go-ethereum applies to http requests
+const maxRequestContentLength = 1024 * 1024 * 5
+
+const (
+	// Returned by error faults without an error code and by failing local APIs
+	defaultFaultErrorCode  = -32000
+	invalidParamsErrorCode = -32602
+	// Returned in place of HTTP 429 on websockets, the code commonly used by providers for rate limits
+	rateLimitErrorCode = -32005
+)
+
+var upgrader = websocket.Upgrader{
+	// The gateway only serves local devnets
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// route forwards the requests of a single chain to its rpc endpoints
+type route struct {
+	log    log.Logger
+	chain  config.Chain
+	client *http.Client
+	faults *faults.Injector
+	// Records the traffic of the chain if set
+	recorder *recorder.Recorder
+	// Resolves the safe and finalized block tags instead of the chain if set
+	blockTags BlockTagResolver
+
+	// Serves the namespaces registered for the chain instead of forwarding them
+	local           *rpc.Server
+	localClient     *rpc.Client
+	localNamespaces map[string]bool
+}
+
+func newRoute(logger log.Logger, chain config.Chain) *route {
+	return &route{
+		log:    logger,
+		chain:  chain,
+		client: &http.Client{},
+		faults: faults.NewInjector(chain.Faults),
+	}
+}
+
+// registerAPI serves the methods of api under the namespace on the route instead of the chain
+func (r *route) registerAPI(namespace string, api interface{}) error {
+	if r.local == nil {
+		r.local = rpc.NewServer()
+		r.localClient = rpc.DialInProc(r.local)
+		r.localNamespaces = make(map[string]bool)
+	}
+	if err := r.local.RegisterName(namespace, api); err != nil {
+		return err
+	}
+	r.localNamespaces[namespace] = true
+	return nil
+}
+
+func (r *route) isLocal(method string) bool {
+	namespace, _, ok := strings.Cut(method, "_")
+	return ok && r.localNamespaces[namespace]
+}
+
+// serveLocal answers a request with the APIs registered on the route
+func (r *route) serveLocal(ctx context.Context, req *jsonrpcMessage) *jsonrpcMessage {
+	var params []json.RawMessage
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return &jsonrpcMessage{Version: "2.0", ID: req.ID, Error: &jsonError{Code: invalidParamsErrorCode, Message: err.Error()}}
+		}
+	}
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+
+	var result json.RawMessage
+	if err := r.localClient.CallContext(ctx, &result, req.Method, args...); err != nil {
+		resp := &jsonrpcMessage{Version: "2.0", ID: req.ID, Error: &jsonError{Code: defaultFaultErrorCode, Message: err.Error()}}
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			resp.Error.Code = rpcErr.ErrorCode()
+		}
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) {
+			resp.Error.Data, _ = json.Marshal(dataErr.ErrorData())
+		}
+		return resp
+	}
+	return &jsonrpcMessage{Version: "2.0", ID: req.ID, Result: result}
+}
+
+func (r *route) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if websocket.IsWebSocketUpgrade(req) {
+		r.serveWebsocket(w, req)
+		return
+	}
+
+	if r.chain.AllowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", r.chain.AllowOrigin)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	}
+	switch req.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := readBody(w, req)
+	if !ok {
+		return
+	}
+
+	reqs, batch, err := parseMessages(body)
+	if err != nil {
+		// Let the chain respond with the parse error
+		r.forwardRaw(w, req.Context(), body)
+		return
+	}
+	fired := make([][]config.Fault, len(reqs))
+	intercept := false
+	for i, msg := range reqs {
+		fired[i] = r.faults.Sample(msg.Method)
+		intercept = intercept || len(fired[i]) > 0 || r.isLocal(msg.Method) || r.resolvesBlockTag(msg)
+	}
+	if !intercept {
+		r.forwardRaw(w, req.Context(), body)
+		return
+	}
+	r.serveMessages(w, req, reqs, batch, fired)
+}
+
+// readBody reads the body of a request, rejecting bodies above the limit of go-ethereum with 413 like go-ethereum does
+func readBody(w http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxRequestContentLength))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("content length too large (>%d)", maxRequestContentLength), http.StatusRequestEntityTooLarge)
+		return nil, false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// forwardRaw forwards the request body as is
+func (r *route) forwardRaw(w http.ResponseWriter, ctx context.Context, body []byte) {
+	start := time.Now()
+	status, contentType, respBody, err := r.post(ctx, body)
+	if err != nil {
+		r.log.Error("failed to forward request", "err", err)
+		http.Error(w, fmt.Sprintf("chain %s is unavailable: %v", r.chain.Name, err), http.StatusBadGateway)
+		return
+	}
+	r.logExchange(body, respBody, time.Since(start))
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(respBody)
+}
+
+func (r *route) post(ctx context.Context, body []byte) (int, string, []byte, error) {
+	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.chain.RPCURL(), bytes.NewReader(body))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	upstreamReq.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(upstreamReq)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("failed to read upstream response: %w", err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), respBody, nil
+}
+
+// serveMessages serves a request for which at least one fault fired, which calls a local API or whose block tag is resolved
+func (r *route) serveMessages(w http.ResponseWriter, req *http.Request, reqs []*jsonrpcMessage, batch bool, fired [][]config.Fault) {
+	start := time.Now()
+	var latency time.Duration
+	for i, msg := range reqs {
+		for _, fault := range fired[i] {
+			r.log.Info("injecting fault", "kind", fault.Kind, "method", msg.Method, "id", string(msg.ID))
+			switch fault.Kind {
+			case config.FaultDrop:
+				// Makes net/http close the connection without writing a response
+				panic(http.ErrAbortHandler)
+			case config.FaultRateLimit:
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_ = json.NewEncoder(w).Encode(rateLimitResponse(msg))
+				return
+			case config.FaultLatency:
+				latency = max(latency, time.Duration(fault.LatencyMs)*time.Millisecond)
+			}
+		}
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	resps := make([]*jsonrpcMessage, len(reqs))
+	var forward []*jsonrpcMessage
+	for i, msg := range reqs {
+		if fault, ok := findFault(fired[i], config.FaultError); ok {
+			resps[i] = errorResponse(msg, fault)
+			continue
+		}
+		if r.isLocal(msg.Method) {
+			resps[i] = r.serveLocal(req.Context(), msg)
+			// Notifications have no response
+			if msg.ID == nil {
+				resps[i] = nil
+			}
+			continue
+		}
+		resolved, err := r.resolveBlockTag(req.Context(), msg)
+		if err != nil {
+			resps[i] = &jsonrpcMessage{Version: "2.0", ID: msg.ID, Error: &jsonError{Code: defaultFaultErrorCode, Message: fmt.Sprintf("failed to resolve block tag: %v", err)}}
+			continue
+		}
+		forward = append(forward, resolved)
+	}
+
+	if len(forward) > 0 {
+		var body []byte
+		var err error
+		if batch {
+			body, err = json.Marshal(forward)
+		} else {
+			body, err = json.Marshal(forward[0])
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _, respBody, err := r.post(req.Context(), body)
+		if err != nil {
+			r.log.Error("failed to forward request", "err", err)
+			http.Error(w, fmt.Sprintf("chain %s is unavailable: %v", r.chain.Name, err), http.StatusBadGateway)
+			return
+		}
+		upstreamResps, _, err := parseMessages(respBody)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid response from chain %s: %v", r.chain.Name, err), http.StatusBadGateway)
+			return
+		}
+		byID := make(map[string]*jsonrpcMessage, len(upstreamResps))
+		for _, resp := range upstreamResps {
+			byID[string(resp.ID)] = resp
+		}
+		for i, msg := range reqs {
+			if resps[i] != nil {
+				continue
+			}
+			resps[i] = byID[string(msg.ID)]
+			if fault, ok := findFault(fired[i], config.FaultStaleBlockNumber); ok && resps[i] != nil {
+				staleBlockNumber(resps[i], msg.Method, fault)
+			}
+		}
+	}
+
+	duration := time.Since(start)
+	var out []*jsonrpcMessage
+	for i, msg := range reqs {
+		ctx := []interface{}{"method", msg.Method, "id", string(msg.ID), "duration", duration}
+		if resps[i] != nil && resps[i].Error != nil {
+			ctx = append(ctx, "err", resps[i].Error.Message)
+		}
+		r.log.Info("rpc request", ctx...)
+		r.record("http", msg, resps[i], duration)
+		// Notifications have no response
+		if resps[i] != nil {
+			out = append(out, resps[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case batch:
+		_ = json.NewEncoder(w).Encode(out)
+	case len(out) == 1:
+		_ = json.NewEncoder(w).Encode(out[0])
+	}
+}
+
+func findFault(fired []config.Fault, kind string) (config.Fault, bool) {
+	for _, fault := range fired {
+		if fault.Kind == kind {
+			return fault, true
+		}
+	}
+	return config.Fault{}, false
+}
+
+func errorResponse(req *jsonrpcMessage, fault config.Fault) *jsonrpcMessage {
+	code := fault.ErrorCode
+	if code == 0 {
+		code = defaultFaultErrorCode
+	}
+	message := fault.ErrorMessage
+	if message == "" {
+		message = "injected fault"
+	}
+	return &jsonrpcMessage{Version: "2.0", ID: req.ID, Error: &jsonError{Code: code, Message: message}}
+}
+
+func rateLimitResponse(req *jsonrpcMessage) *jsonrpcMessage {
+	return &jsonrpcMessage{Version: "2.0", ID: req.ID, Error: &jsonError{Code: rateLimitErrorCode, Message: "rate limited"}}
+}
+
+// staleBlockNumber rewinds the result of an eth_blockNumber response by the configured number of blocks
+func staleBlockNumber(resp *jsonrpcMessage, method string, fault config.Fault) {
+	if method != "eth_blockNumber" || resp.Error != nil {
+		return
+	}
+	var number hexutil.Uint64
+	if err := json.Unmarshal(resp.Result, &number); err != nil {
+		return
+	}
+	stale := uint64(0)
+	if uint64(number) > uint64(fault.StaleBlocks) {
+		stale = uint64(number) - uint64(fault.StaleBlocks)
+	}
+	resp.Result, _ = json.Marshal(hexutil.Uint64(stale))
+}
+
+// logExchange logs and records every request of a single or batch exchange together with its outcome
+func (r *route) logExchange(reqBody, respBody []byte, duration time.Duration) {
+	reqs, batch, err := parseMessages(reqBody)
+	if err != nil {
+		r.log.Warn("received invalid json-rpc request", "err", err)
+		return
+	}
+	resps, _, _ := parseMessages(respBody)
+	byID := make(map[string]*jsonrpcMessage, len(resps))
+	for _, resp := range resps {
+		byID[string(resp.ID)] = resp
+	}
+
+	for _, req := range reqs {
+		ctx := []interface{}{"method", req.Method, "id", string(req.ID), "duration", duration}
+		if batch {
+			ctx = append(ctx, "batch", len(reqs))
+		}
+		resp := byID[string(req.ID)]
+		if resp != nil && resp.Error != nil {
+			ctx = append(ctx, "err", resp.Error.Message)
+		}
+		r.log.Info("rpc request", ctx...)
+		r.record("http", req, resp, duration)
+	}
+}
+
+// record writes a request and its response to the recording, if any. resp is nil for notifications.
+func (r *route) record(transport string, req, resp *jsonrpcMessage, duration time.Duration) {
+	if r.recorder == nil {
+		return
+	}
+	entry := recorder.Entry{
+		Time:      time.Now().UTC(),
+		Chain:     r.chain.Name,
+		ChainID:   r.chain.EffectiveChainID(),
+		Transport: transport,
+		Method:    req.Method,
+		Params:    req.Params,
+		LatencyMs: duration.Milliseconds(),
+	}
+	if resp != nil {
+		entry.Result = resp.Result
+		if resp.Error != nil {
+			entry.Error = &recorder.Error{Code: resp.Error.Code, Message: resp.Error.Message, Data: resp.Error.Data}
+		}
+	}
+	if err := r.recorder.Record(entry); err != nil {
+		r.log.Error("failed to record rpc request", "method", req.Method, "err", err)
+	}
+}
+
+// wsConn serializes the writes to one side of a proxied websocket
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *wsConn) WriteMessage(msgType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteMessage(msgType, data)
+}
+
+// writeJSON writes a single message or a batch
+func (c *wsConn) writeJSON(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+type pendingRequest struct {
+	req   *jsonrpcMessage
+	start time.Time
+	stale *config.Fault
+	// Responses of the gateway to the other members of the batch of req, merged into the batch response of the chain
+	local []*jsonrpcMessage
+}
+
+func (r *route) serveWebsocket(w http.ResponseWriter, req *http.Request) {
+	u, _, err := websocket.DefaultDialer.DialContext(req.Context(), r.chain.WSURL(), nil)
+	if err != nil {
+		r.log.Error("failed to dial websocket", "err", err)
+		http.Error(w, fmt.Sprintf("chain %s is unavailable: %v", r.chain.Name, err), http.StatusBadGateway)
+		return
+	}
+	upstream := &wsConn{Conn: u}
+	defer upstream.Close()
+
+	c, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		r.log.Error("failed to upgrade websocket", "err", err)
+		return
+	}
+	conn := &wsConn{Conn: c}
+	defer conn.Close()
+
+	// Requests are matched with their responses by id to log and record them and to rewrite stale responses
+	var mu sync.Mutex
+	pending := make(map[string]pendingRequest)
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- r.pumpRequests(req.Context(), conn, upstream, func(msg *jsonrpcMessage, stale *config.Fault, local []*jsonrpcMessage) {
+			mu.Lock()
+			defer mu.Unlock()
+			pending[string(msg.ID)] = pendingRequest{req: msg, start: time.Now(), stale: stale, local: local}
+			r.log.Info("rpc request", "method", msg.Method, "id", string(msg.ID), "transport", "ws")
+		})
+	}()
+	go func() {
+		errc <- pumpResponses(upstream.Conn, conn, func(data []byte) []byte {
+			msgs, batch, err := parseMessages(data)
+			if err != nil {
+				return data
+			}
+			rewritten := false
+			var local []*jsonrpcMessage
+			for _, msg := range msgs {
+				// Subscription notifications have no id
+				if msg.ID == nil {
+					continue
+				}
+				mu.Lock()
+				p, ok := pending[string(msg.ID)]
+				delete(pending, string(msg.ID))
+				mu.Unlock()
+				if !ok {
+					continue
+				}
+
+				duration := time.Since(p.start)
+				ctx := []interface{}{"id", string(msg.ID), "duration", duration, "transport", "ws"}
+				if msg.Error != nil {
+					ctx = append(ctx, "err", msg.Error.Message)
+				}
+				r.log.Debug("rpc response", ctx...)
+				if p.stale != nil {
+					staleBlockNumber(msg, "eth_blockNumber", *p.stale)
+					rewritten = true
+				}
+				local = append(local, p.local...)
+				r.record("ws", p.req, msg, duration)
+			}
+			if !rewritten && len(local) == 0 {
+				return data
+			}
+			var out interface{} = msgs[0]
+			if batch {
+				out = append(msgs, local...)
+			}
+			if data, err := json.Marshal(out); err == nil {
+				return data
+			}
+			return data
+		})
+	}()
+	if err := <-errc; err != nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		r.log.Debug("websocket closed", "err", err)
+	}
+}
+
+// pumpRequests forwards the requests of the client to the chain, injecting faults on the way. Like on HTTP, a
+// delayed message is forwarded in the background without holding up the messages after it.
+func (r *route) pumpRequests(ctx context.Context, conn *wsConn, upstream *wsConn, observe observeFunc) error {
+	errc := make(chan error, 1)
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			_ = upstream.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			// A delayed message that failed closed the connection
+			select {
+			case err := <-errc:
+				return err
+			default:
+			}
+			return err
+		}
+
+		msgs, batch, err := parseMessages(data)
+		if err != nil {
+			if err := upstream.WriteMessage(msgType, data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fired := make([][]config.Fault, len(msgs))
+		var latency time.Duration
+		for i, msg := range msgs {
+			fired[i] = r.faults.Sample(msg.Method)
+			for _, fault := range fired[i] {
+				r.log.Info("injecting fault", "kind", fault.Kind, "method", msg.Method, "id", string(msg.ID), "transport", "ws")
+				switch fault.Kind {
+				case config.FaultDrop:
+					return fmt.Errorf("dropped connection")
+				case config.FaultLatency:
+					latency = max(latency, time.Duration(fault.LatencyMs)*time.Millisecond)
+				}
+			}
+		}
+		if latency == 0 {
+			if err := r.forwardMessages(ctx, conn, upstream, msgType, data, msgs, batch, fired, observe); err != nil {
+				return err
+			}
+			continue
+		}
+		go func() {
+			select {
+			case <-time.After(latency):
+			case <-ctx.Done():
+				return
+			}
+			if err := r.forwardMessages(ctx, conn, upstream, msgType, data, msgs, batch, fired, observe); err != nil {
+				select {
+				case errc <- err:
+				default:
+				}
+				_ = conn.Close()
+			}
+		}()
+	}
+}
+
+// observeFunc tracks a request forwarded over a websocket until the chain responds. stale is the
+// stale_block_number fault to apply to the response and local the responses the gateway answered
+// to the other members of its batch.
+type observeFunc func(msg *jsonrpcMessage, stale *config.Fault, local []*jsonrpcMessage)
+
+// forwardMessages applies the fired faults other than latency and drops to a message of the client and forwards it to the chain.
+// Like on HTTP, every member of a batch is answered by its faults, the local APIs or the chain on its own.
+func (r *route) forwardMessages(ctx context.Context, conn *wsConn, upstream *wsConn, msgType int, data []byte, msgs []*jsonrpcMessage, batch bool, fired [][]config.Fault, observe observeFunc) error {
+	start := time.Now()
+	var forward, local []*jsonrpcMessage
+	var stale []*config.Fault
+	for i, msg := range msgs {
+		resp, forwarded := r.interceptMessage(ctx, msg, fired[i])
+		if forwarded == nil {
+			r.record("ws", msg, resp, time.Since(start))
+			// Notifications have no response
+			if resp != nil {
+				local = append(local, resp)
+			}
+			continue
+		}
+		forward = append(forward, forwarded)
+		if fault, ok := findFault(fired[i], config.FaultStaleBlockNumber); ok && msg.Method == "eth_blockNumber" {
+			stale = append(stale, &fault)
+		} else {
+			stale = append(stale, nil)
+		}
+	}
+
+	// The responses of the gateway are merged into the response of the chain to the first forwarded request
+	merged := false
+	for i, msg := range forward {
+		var merge []*jsonrpcMessage
+		if !merged && msg.ID != nil {
+			merge, merged = local, true
+		}
+		observe(msg, stale[i], merge)
+	}
+	if !merged && len(local) > 0 {
+		var resp interface{} = local[0]
+		if batch {
+			resp = local
+		}
+		if err := conn.writeJSON(resp); err != nil {
+			return err
+		}
+	}
+	if len(forward) == 0 {
+		return nil
+	}
+	if len(forward) != len(msgs) || !sameMessages(forward, msgs) {
+		var err error
+		if batch {
+			data, err = json.Marshal(forward)
+		} else {
+			data, err = json.Marshal(forward[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return upstream.WriteMessage(msgType, data)
+}
+
+// interceptMessage answers a request with its error or rate limit fault, the local APIs or a failure to resolve its
+// block tag. Otherwise it returns the request to forward, with its block tag resolved.
+func (r *route) interceptMessage(ctx context.Context, msg *jsonrpcMessage, fired []config.Fault) (*jsonrpcMessage, *jsonrpcMessage) {
+	if _, ok := findFault(fired, config.FaultRateLimit); ok {
+		return rateLimitResponse(msg), nil
+	}
+	if fault, ok := findFault(fired, config.FaultError); ok {
+		return errorResponse(msg, fault), nil
+	}
+	if r.isLocal(msg.Method) {
+		resp := r.serveLocal(ctx, msg)
+		if msg.ID == nil {
+			resp = nil
+		}
+		return resp, nil
+	}
+	if !r.resolvesBlockTag(msg) {
+		return nil, msg
+	}
+	tagged, err := r.resolveBlockTag(ctx, msg)
+	if err != nil {
+		return &jsonrpcMessage{Version: "2.0", ID: msg.ID, Error: &jsonError{Code: defaultFaultErrorCode, Message: fmt.Sprintf("failed to resolve block tag: %v", err)}}, nil
+	}
+	return nil, tagged
+}
+
+// sameMessages reports whether the forwarded requests are the requests of the client as is
+func sameMessages(forward, msgs []*jsonrpcMessage) bool {
+	for i := range forward {
+		if forward[i] != msgs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pumpResponses copies the messages of the chain to the client until either side closes
+func pumpResponses(upstream *websocket.Conn, conn *wsConn, rewrite func([]byte) []byte) error {
+	for {
+		msgType, data, err := upstream.ReadMessage()
+		if err != nil {
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return err
+		}
+		if err := conn.WriteMessage(msgType, rewrite(data)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,528 @@
+// Command allocs generates addresses.json and allocs-l1.json of the generated package.
+//
+// Like the Deploy script of contracts-bedrock run for the devnet, it deploys the L1 contracts of the OP Stack
+// with the devnet deploy config and dumps the resulting state. The contracts are deployed from the op-bindings
+// of the optimism module mocktimism depends on into an in-memory chain, so the deployment always matches the
+// bindings the services use and no foundry toolchain is needed.
+//
+// op-bindings carries no L1ChugSplashProxy and ResolvedDelegateProxy, so the L1StandardBridge and
+// L1CrossDomainMessenger are behind the same ERC-1967 proxy as the other contracts.
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-chain-ops/genesis"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// Game types of the DisputeGameFactory
+	gameTypeCannon   uint32 = 0
+	gameTypeAlphabet uint32 = 255
+	// Depth of the trace of the AlphabetVM below the split depth
+	alphabetTraceDepth = 3
+	gasLimit           = 100_000_000
+)
+
+var (
+	// The deployer only deploys and initializes the contracts, which are owned by the SystemOwnerSafe
+	deployerKey, _  = crypto.ToECDSA(crypto.Keccak256([]byte("mocktimism deployer")))
+	deployerBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+)
+
+func main() {
+	deployConfig := flag.String("deploy-config", "", "Path of the contracts-bedrock deploy config")
+	outDir := flag.String("outdir", "generated", "Directory the addresses and allocs are written to")
+	flag.Parse()
+
+	if err := run(*deployConfig, *outDir); err != nil {
+		log.Crit("failed to generate allocs", "err", err)
+	}
+}
+
+func run(deployConfigPath string, outDir string) error {
+	cfg, err := genesis.NewDeployConfig(deployConfigPath)
+	if err != nil {
+		return err
+	}
+	d, err := newDeployer(cfg)
+	if err != nil {
+		return err
+	}
+	defer d.backend.Close()
+	if err := d.deploy(); err != nil {
+		return err
+	}
+
+	addresses := make(map[string]string, len(d.addresses))
+	for name, addr := range d.addresses {
+		addresses[name] = addr.Hex()
+	}
+	if err := writeJSON(filepath.Join(outDir, "addresses.json"), addresses); err != nil {
+		return err
+	}
+	allocs, err := d.dump()
+	if err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(outDir, "allocs-l1.json"), allocs)
+}
+
+// deployer deploys the contracts in the order of the Deploy script, saving their addresses under the same names
+type deployer struct {
+	cfg       *genesis.DeployConfig
+	backend   *backends.SimulatedBackend
+	key       *ecdsa.PrivateKey
+	opts      *bind.TransactOpts
+	addresses map[string]common.Address
+}
+
+func newDeployer(cfg *genesis.DeployConfig) (*deployer, error) {
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.ChainID = new(big.Int).SetUint64(cfg.L1ChainID)
+	from := crypto.PubkeyToAddress(deployerKey.PublicKey)
+	backend := backends.NewSimulatedBackendWithOpts(
+		backends.WithGenesis(core.Genesis{
+			Config:   &chainConfig,
+			GasLimit: gasLimit,
+			Alloc:    core.GenesisAlloc{from: {Balance: deployerBalance}},
+		}),
+		// Dumping the state looks up the addresses and slots of the trie keys
+		backends.WithCacheConfig(&core.CacheConfig{Preimages: true, SnapshotLimit: 0}),
+	)
+	opts, err := bind.NewKeyedTransactorWithChainID(deployerKey, chainConfig.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	return &deployer{
+		cfg:       cfg,
+		backend:   backend,
+		key:       deployerKey,
+		opts:      opts,
+		addresses: make(map[string]common.Address),
+	}, nil
+}
+
+func (d *deployer) deploy() error {
+	for _, step := range []func() error{
+		d.deploySafe,
+		d.setupSuperchain,
+		d.deployProxies,
+		d.deployImplementations,
+		d.initializeImplementations,
+		d.setFaultGameImplementations,
+	} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deploySafe deploys the SystemOwnerSafe with the deployer as its only owner
+func (d *deployer) deploySafe() error {
+	factory, err := d.deployContract("SafeProxyFactory", func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeploySafeProxyFactory(d.opts, d.backend)
+		return addr, tx, err
+	})
+	if err != nil {
+		return err
+	}
+	singleton, err := d.deployContract("SafeSingleton", func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeploySafe(d.opts, d.backend)
+		return addr, tx, err
+	})
+	if err != nil {
+		return err
+	}
+
+	setup, err := pack(bindings.SafeMetaData, "setup", []common.Address{d.opts.From}, common.Big1, common.Address{}, []byte{}, common.Address{}, common.Address{}, common.Big0, common.Address{})
+	if err != nil {
+		return err
+	}
+	proxyFactory, err := bindings.NewSafeProxyFactory(factory, d.backend)
+	if err != nil {
+		return err
+	}
+	receipt, err := d.transact(proxyFactory.CreateProxyWithNonce(d.opts, singleton, setup, common.Big0))
+	if err != nil {
+		return fmt.Errorf("failed to create SystemOwnerSafe: %w", err)
+	}
+	for _, l := range receipt.Logs {
+		if created, err := proxyFactory.ParseProxyCreation(*l); err == nil {
+			d.addresses["SystemOwnerSafe"] = created.Proxy
+			return nil
+		}
+	}
+	return fmt.Errorf("SystemOwnerSafe creation emitted no ProxyCreation event")
+}
+
+// setupSuperchain deploys the ProxyAdmin and AddressManager, and the SuperchainConfig and ProtocolVersions
+// shared by the chains of the superchain
+func (d *deployer) setupSuperchain() error {
+	addressManager, err := d.deployContract("AddressManager", func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeployAddressManager(d.opts, d.backend)
+		return addr, tx, err
+	})
+	if err != nil {
+		return err
+	}
+	proxyAdminAddr, err := d.deployContract("ProxyAdmin", func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeployProxyAdmin(d.opts, d.backend, d.opts.From)
+		return addr, tx, err
+	})
+	if err != nil {
+		return err
+	}
+	proxyAdmin, err := bindings.NewProxyAdmin(proxyAdminAddr, d.backend)
+	if err != nil {
+		return err
+	}
+	if _, err := d.transact(proxyAdmin.SetAddressManager(d.opts, addressManager)); err != nil {
+		return fmt.Errorf("failed to set address manager: %w", err)
+	}
+	if _, err := d.transact(proxyAdmin.TransferOwnership(d.opts, d.addresses["SystemOwnerSafe"])); err != nil {
+		return fmt.Errorf("failed to transfer ProxyAdmin ownership: %w", err)
+	}
+
+	if err := d.deployProxy("SuperchainConfigProxy"); err != nil {
+		return err
+	}
+	if _, err := d.deployContract("SuperchainConfig", func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeploySuperchainConfig(d.opts, d.backend)
+		return addr, tx, err
+	}); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("SuperchainConfig", bindings.SuperchainConfigMetaData, d.cfg.SuperchainConfigGuardian, false); err != nil {
+		return err
+	}
+
+	if err := d.deployProxy("ProtocolVersionsProxy"); err != nil {
+		return err
+	}
+	if _, err := d.deployContract("ProtocolVersions", func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeployProtocolVersions(d.opts, d.backend)
+		return addr, tx, err
+	}); err != nil {
+		return err
+	}
+	return d.upgradeAndCall("ProtocolVersions", bindings.ProtocolVersionsMetaData, d.cfg.FinalSystemOwner,
+		new(big.Int).SetBytes(d.cfg.RequiredProtocolVersion[:]), new(big.Int).SetBytes(d.cfg.RecommendedProtocolVersion[:]))
+}
+
+func (d *deployer) deployProxies() error {
+	for _, name := range []string{
+		"OptimismPortalProxy",
+		"L2OutputOracleProxy",
+		"SystemConfigProxy",
+		"L1StandardBridgeProxy",
+		"L1CrossDomainMessengerProxy",
+		"OptimismMintableERC20FactoryProxy",
+		"L1ERC721BridgeProxy",
+		"DisputeGameFactoryProxy",
+	} {
+		if err := d.deployProxy(name); err != nil {
+			return err
+		}
+	}
+	addressManager, err := bindings.NewAddressManager(d.addresses["AddressManager"], d.backend)
+	if err != nil {
+		return err
+	}
+	if _, err := d.transact(addressManager.TransferOwnership(d.opts, d.addresses["ProxyAdmin"])); err != nil {
+		return fmt.Errorf("failed to transfer AddressManager ownership: %w", err)
+	}
+	return nil
+}
+
+func (d *deployer) deployImplementations() error {
+	for _, impl := range []struct {
+		name   string
+		deploy func() (common.Address, *types.Transaction, error)
+	}{
+		{"OptimismPortal", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployOptimismPortal(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"L1CrossDomainMessenger", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployL1CrossDomainMessenger(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"L2OutputOracle", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployL2OutputOracle(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"OptimismMintableERC20Factory", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployOptimismMintableERC20Factory(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"SystemConfig", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeploySystemConfig(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"L1StandardBridge", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployL1StandardBridge(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"L1ERC721Bridge", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployL1ERC721Bridge(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"DisputeGameFactory", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployDisputeGameFactory(d.opts, d.backend)
+			return addr, tx, err
+		}},
+		{"PreimageOracle", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployPreimageOracle(d.opts, d.backend,
+				new(big.Int).SetUint64(d.cfg.PreimageOracleMinProposalSize), new(big.Int).SetUint64(d.cfg.PreimageOracleChallengePeriod))
+			return addr, tx, err
+		}},
+		{"Mips", func() (common.Address, *types.Transaction, error) {
+			addr, tx, _, err := bindings.DeployMIPS(d.opts, d.backend, d.addresses["PreimageOracle"])
+			return addr, tx, err
+		}},
+	} {
+		if _, err := d.deployContract(impl.name, impl.deploy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deployer) initializeImplementations() error {
+	cfg := d.cfg
+	if err := d.upgradeAndCall("DisputeGameFactory", bindings.DisputeGameFactoryMetaData, d.opts.From); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("SystemConfig", bindings.SystemConfigMetaData,
+		cfg.FinalSystemOwner,
+		new(big.Int).SetUint64(cfg.GasPriceOracleOverhead),
+		new(big.Int).SetUint64(cfg.GasPriceOracleScalar),
+		common.BytesToHash(cfg.BatchSenderAddress.Bytes()),
+		uint64(cfg.L2GenesisBlockGasLimit),
+		cfg.P2PSequencerAddress,
+		genesis.DefaultResourceConfig,
+		cfg.BatchInboxAddress,
+		bindings.SystemConfigAddresses{
+			L1CrossDomainMessenger:       d.addresses["L1CrossDomainMessengerProxy"],
+			L1ERC721Bridge:               d.addresses["L1ERC721BridgeProxy"],
+			L1StandardBridge:             d.addresses["L1StandardBridgeProxy"],
+			L2OutputOracle:               d.addresses["L2OutputOracleProxy"],
+			OptimismPortal:               d.addresses["OptimismPortalProxy"],
+			OptimismMintableERC20Factory: d.addresses["OptimismMintableERC20FactoryProxy"],
+		},
+	); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("L1StandardBridge", bindings.L1StandardBridgeMetaData,
+		d.addresses["L1CrossDomainMessengerProxy"], d.addresses["SuperchainConfigProxy"]); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("L1ERC721Bridge", bindings.L1ERC721BridgeMetaData,
+		d.addresses["L1CrossDomainMessengerProxy"], d.addresses["SuperchainConfigProxy"]); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("OptimismMintableERC20Factory", bindings.OptimismMintableERC20FactoryMetaData,
+		d.addresses["L1StandardBridgeProxy"]); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("L1CrossDomainMessenger", bindings.L1CrossDomainMessengerMetaData,
+		d.addresses["SuperchainConfigProxy"], d.addresses["OptimismPortalProxy"]); err != nil {
+		return err
+	}
+	if err := d.upgradeAndCall("L2OutputOracle", bindings.L2OutputOracleMetaData,
+		new(big.Int).SetUint64(cfg.L2OutputOracleSubmissionInterval),
+		new(big.Int).SetUint64(cfg.L2BlockTime),
+		new(big.Int).SetUint64(cfg.L2OutputOracleStartingBlockNumber),
+		big.NewInt(int64(cfg.L2OutputOracleStartingTimestamp)),
+		cfg.L2OutputOracleProposer,
+		cfg.L2OutputOracleChallenger,
+		new(big.Int).SetUint64(cfg.FinalizationPeriodSeconds),
+	); err != nil {
+		return err
+	}
+	return d.upgradeAndCall("OptimismPortal", bindings.OptimismPortalMetaData,
+		d.addresses["L2OutputOracleProxy"], d.addresses["SystemConfigProxy"], d.addresses["SuperchainConfigProxy"])
+}
+
+// setFaultGameImplementations registers the alphabet and cannon FaultDisputeGames with the factory, then hands
+// the factory over to the SystemOwnerSafe
+func (d *deployer) setFaultGameImplementations() error {
+	cfg := d.cfg
+	factory, err := bindings.NewDisputeGameFactory(d.addresses["DisputeGameFactoryProxy"], d.backend)
+	if err != nil {
+		return err
+	}
+	alphabetVM, tx, _, err := bindings.DeployAlphabetVM(d.opts, d.backend, cfg.FaultGameAbsolutePrestate)
+	if _, err := d.transact(tx, err); err != nil {
+		return fmt.Errorf("failed to deploy AlphabetVM: %w", err)
+	}
+	for _, game := range []struct {
+		gameType uint32
+		maxDepth uint64
+		vm       common.Address
+	}{
+		// The split depth is inclusive, so the alphabet trace starts one level below it
+		{gameTypeAlphabet, cfg.FaultGameSplitDepth + alphabetTraceDepth + 1, alphabetVM},
+		{gameTypeCannon, cfg.FaultGameMaxDepth, d.addresses["Mips"]},
+	} {
+		impl, tx, _, err := bindings.DeployFaultDisputeGame(d.opts, d.backend, game.gameType, cfg.FaultGameAbsolutePrestate,
+			new(big.Int).SetUint64(cfg.FaultGameGenesisBlock), cfg.FaultGameGenesisOutputRoot,
+			new(big.Int).SetUint64(game.maxDepth), new(big.Int).SetUint64(cfg.FaultGameSplitDepth), cfg.FaultGameMaxDuration, game.vm)
+		if _, err := d.transact(tx, err); err != nil {
+			return fmt.Errorf("failed to deploy FaultDisputeGame of game type %d: %w", game.gameType, err)
+		}
+		if _, err := d.transact(factory.SetImplementation(d.opts, game.gameType, impl)); err != nil {
+			return fmt.Errorf("failed to set FaultDisputeGame of game type %d: %w", game.gameType, err)
+		}
+	}
+	if _, err := d.transact(factory.TransferOwnership(d.opts, d.addresses["SystemOwnerSafe"])); err != nil {
+		return fmt.Errorf("failed to transfer DisputeGameFactory ownership: %w", err)
+	}
+	return nil
+}
+
+// deployProxy deploys an ERC-1967 proxy administered by the ProxyAdmin
+func (d *deployer) deployProxy(name string) error {
+	_, err := d.deployContract(name, func() (common.Address, *types.Transaction, error) {
+		addr, tx, _, err := bindings.DeployProxy(d.opts, d.backend, d.addresses["ProxyAdmin"])
+		return addr, tx, err
+	})
+	return err
+}
+
+// upgradeAndCall points the proxy of a contract to its implementation and initializes it through the
+// ProxyAdmin, which the SystemOwnerSafe owns
+func (d *deployer) upgradeAndCall(name string, metaData *bind.MetaData, args ...interface{}) error {
+	initialize, err := pack(metaData, "initialize", args...)
+	if err != nil {
+		return err
+	}
+	data, err := pack(bindings.ProxyAdminMetaData, "upgradeAndCall", d.addresses[name+"Proxy"], d.addresses[name], initialize)
+	if err != nil {
+		return err
+	}
+	if err := d.callViaSafe(d.addresses["ProxyAdmin"], data); err != nil {
+		return fmt.Errorf("failed to initialize %s: %w", name, err)
+	}
+	return nil
+}
+
+// callViaSafe calls a contract from the SystemOwnerSafe, signed by the deployer as the owner sending the transaction
+func (d *deployer) callViaSafe(to common.Address, data []byte) error {
+	safe, err := bindings.NewSafe(d.addresses["SystemOwnerSafe"], d.backend)
+	if err != nil {
+		return err
+	}
+	signature := append(common.LeftPadBytes(d.opts.From.Bytes(), 32), append(make([]byte, 32), 1)...)
+	_, err = d.transact(safe.ExecTransaction(d.opts, to, common.Big0, data, 0, common.Big0, common.Big0, common.Big0,
+		common.Address{}, common.Address{}, signature))
+	return err
+}
+
+func (d *deployer) deployContract(name string, deploy func() (common.Address, *types.Transaction, error)) (common.Address, error) {
+	addr, tx, err := deploy()
+	if _, err := d.transact(tx, err); err != nil {
+		return common.Address{}, fmt.Errorf("failed to deploy %s: %w", name, err)
+	}
+	d.addresses[name] = addr
+	return addr, nil
+}
+
+// transact mines a transaction and fails if it reverted
+func (d *deployer) transact(tx *types.Transaction, err error) (*types.Receipt, error) {
+	if err != nil {
+		return nil, err
+	}
+	d.backend.Commit()
+	receipt, err := d.backend.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("transaction %s reverted", tx.Hash())
+	}
+	return receipt, nil
+}
+
+// dumpAccount is an account of the state dump of forge, as read by AllocsL1
+type dumpAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// dump returns the accounts the deployment created or used. Fees paid to the coinbase are left out.
+func (d *deployer) dump() (interface{}, error) {
+	statedb, err := d.backend.Blockchain().State()
+	if err != nil {
+		return nil, err
+	}
+	raw := statedb.RawDump(&state.DumpConfig{})
+	accounts := make(map[common.Address]dumpAccount, len(raw.Accounts))
+	for addr, account := range raw.Accounts {
+		if len(account.Code) == 0 && account.Nonce == 0 {
+			continue
+		}
+		balance, ok := new(big.Int).SetString(account.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance of %s: %s", addr, account.Balance)
+		}
+		storage := make(map[common.Hash]common.Hash, len(account.Storage))
+		for key, value := range account.Storage {
+			storage[key] = common.HexToHash(value)
+		}
+		accounts[addr] = dumpAccount{
+			Balance: (*hexutil.Big)(balance),
+			Nonce:   account.Nonce,
+			Code:    account.Code,
+			Storage: storage,
+		}
+	}
+	for name, addr := range d.addresses {
+		if _, ok := accounts[addr]; !ok {
+			return nil, fmt.Errorf("%s missing from the state dump", name)
+		}
+	}
+	return map[string]interface{}{"accounts": accounts}, nil
+}
+
+func pack(metaData *bind.MetaData, method string, args ...interface{}) ([]byte, error) {
+	parsed, err := metaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	data, err := parsed.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+	return data, nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,70 @@
+// Package generated exposes the artifacts produced by `make generate-allocs`.
+package generated
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+//go:embed addresses.json
+var addressesJSON []byte
+
+//go:embed allocs-l1.json
+var allocsL1JSON []byte
+
+// Addresses returns the L1 contract addresses of the generated devnet deployment keyed by contract name.
+func Addresses() (map[string]common.Address, error) {
+	var addresses map[string]common.Address
+	if err := json.Unmarshal(addressesJSON, &addresses); err != nil {
+		return nil, fmt.Errorf("failed to decode generated addresses: %w", err)
+	}
+	return addresses, nil
+}
+
+// dumpAccount is an account of a state dump as written by anvil_dumpState and geth dump
+type dumpAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   json.RawMessage   `json:"nonce"`
+	Code    hexutil.Bytes     `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// AllocsL1 returns the L1 state of the generated devnet deployment
+func AllocsL1() (core.GenesisAlloc, error) {
+	var dump struct {
+		Accounts map[common.Address]dumpAccount `json:"accounts"`
+	}
+	if err := json.Unmarshal(allocsL1JSON, &dump); err != nil {
+		return nil, fmt.Errorf("failed to decode generated allocs: %w", err)
+	}
+
+	alloc := make(core.GenesisAlloc, len(dump.Accounts))
+	for addr, account := range dump.Accounts {
+		balance, ok := new(big.Int).SetString(account.Balance, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance of %s: %s", addr, account.Balance)
+		}
+		// Nonces are encoded either as numbers or as strings
+		nonce, err := strconv.ParseUint(strings.Trim(string(account.Nonce), `"`), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nonce of %s: %w", addr, err)
+		}
+		genesisAccount := core.GenesisAccount{Balance: balance, Nonce: nonce, Code: account.Code}
+		if len(account.Storage) > 0 {
+			genesisAccount.Storage = make(map[common.Hash]common.Hash, len(account.Storage))
+			for key, value := range account.Storage {
+				genesisAccount.Storage[common.HexToHash(key)] = common.HexToHash(value)
+			}
+		}
+		alloc[addr] = genesisAccount
+	}
+	return alloc, nil
+}
@@ -0,0 +1,110 @@
+// Package recorder writes the JSON-RPC traffic of a devnet to a JSONL file and reads it back for replays.
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is a single recorded request and its response
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Chain     string          `json:"chain"`
+	ChainID   uint            `json:"chainId"`
+	Transport string          `json:"transport"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+	LatencyMs int64           `json:"latencyMs"`
+}
+
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Path returns a new recording path under the state directory of a profile
+func Path(state string) string {
+	return filepath.Join(state, "recordings", fmt.Sprintf("rpc-%s.jsonl", time.Now().UTC().Format("20060102T150405Z")))
+}
+
+// Recorder appends entries to a JSONL file. It is safe for concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	w    *bufio.Writer
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return &Recorder{path: path, file: file, w: bufio.NewWriter(file)}, nil
+}
+
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record appends an entry to the recording. Entries are flushed right away so a crashed run keeps its traffic.
+func (r *Recorder) Record(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
+
+// Load reads every entry of a recording
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	// Responses like eth_getLogs can be large
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d of %s: %w", line, path, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return entries, nil
+}
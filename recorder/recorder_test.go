@@ -0,0 +1,53 @@
+package recorder
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	path := Path(t.TempDir())
+	require.Equal(t, "recordings", filepath.Base(filepath.Dir(path)))
+
+	rec, err := NewRecorder(path)
+	require.NoError(t, err)
+	entries := []Entry{
+		{
+			Time:      time.Unix(1700000000, 0).UTC(),
+			Chain:     "L1",
+			ChainID:   900,
+			Transport: "http",
+			Method:    "eth_blockNumber",
+			Params:    json.RawMessage(`[]`),
+			Result:    json.RawMessage(`"0x10"`),
+			LatencyMs: 3,
+		},
+		{
+			Time:      time.Unix(1700000001, 0).UTC(),
+			Chain:     "L2",
+			ChainID:   901,
+			Transport: "ws",
+			Method:    "eth_call",
+			Params:    json.RawMessage(`[{"to":"0x0000000000000000000000000000000000000000"},"latest"]`),
+			Error:     &Error{Code: 3, Message: "execution reverted"},
+		},
+	}
+	for _, entry := range entries {
+		require.NoError(t, rec.Record(entry))
+	}
+
+	// Entries are readable before the recorder is closed
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, entries, loaded)
+	require.NoError(t, rec.Close())
+
+	require.NoError(t, os.WriteFile(path, []byte("{not json}\n"), 0644))
+	_, err = Load(path)
+	require.ErrorContains(t, err, "line 1")
+}
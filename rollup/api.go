@@ -0,0 +1,417 @@
+// Package rollup serves the optimism_* methods of the op-node rollup RPC for L2s without op-node.
+//
+// Outputs are computed from the state of the L2 and sync status from the heads of the L1 and L2,
+// so tooling depending on a rollup node works without running op-node. The rollup RPC of L2s with
+// derivation is forwarded to their op-node by Proxy.
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	oprollup "github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// NAMESPACE is the JSON-RPC namespace of the op-node rollup RPC
+const NAMESPACE = "optimism"
+
+const (
+	defaultBlockTime = 2
+	// Defaults of the op-node devnet configuration
+	maxSequencerDrift    = 600
+	defaultSeqWindowSize = 3600
+	channelTimeout       = 300
+	gasPriceOverhead     = 188
+	gasPriceScalar       = 684_000
+	// Index of the batcher among the accounts of the devnet mnemonic
+	batcherAccount = 2
+	// Indexes of the proposer and challenger of the L2OutputOracle of the devnet deployment
+	proposerAccount   = 1
+	challengerAccount = 4
+	// Index of the account deploying the tokens of the profile
+	tokenDeployerAccount = 3
+)
+
+// Genesis mirrors the genesis of the op-node rollup config
+type Genesis struct {
+	L1           eth.BlockID      `json:"l1"`
+	L2           eth.BlockID      `json:"l2"`
+	L2Time       uint64           `json:"l2_time"`
+	SystemConfig eth.SystemConfig `json:"system_config"`
+}
+
+// Config mirrors the JSON encoding of the op-node rollup config
+type Config struct {
+	Genesis                 Genesis        `json:"genesis"`
+	BlockTime               uint64         `json:"block_time"`
+	MaxSequencerDrift       uint64         `json:"max_sequencer_drift"`
+	SeqWindowSize           uint64         `json:"seq_window_size"`
+	ChannelTimeout          uint64         `json:"channel_timeout"`
+	L1ChainID               *big.Int       `json:"l1_chain_id"`
+	L2ChainID               *big.Int       `json:"l2_chain_id"`
+	RegolithTime            *uint64        `json:"regolith_time,omitempty"`
+	CanyonTime              *uint64        `json:"canyon_time,omitempty"`
+	DeltaTime               *uint64        `json:"delta_time,omitempty"`
+	EcotoneTime             *uint64        `json:"ecotone_time,omitempty"`
+	BatchInboxAddress       common.Address `json:"batch_inbox_address"`
+	DepositContractAddress  common.Address `json:"deposit_contract_address"`
+	L1SystemConfigAddress   common.Address `json:"l1_system_config_address"`
+	ProtocolVersionsAddress common.Address `json:"protocol_versions_address,omitempty"`
+}
+
+// header is the subset of a block the rollup RPC needs
+type header struct {
+	Hash       common.Hash    `json:"hash"`
+	Number     hexutil.Uint64 `json:"number"`
+	ParentHash common.Hash    `json:"parentHash"`
+	Timestamp  hexutil.Uint64 `json:"timestamp"`
+	StateRoot  common.Hash    `json:"stateRoot"`
+}
+
+type API struct {
+	log       log.Logger
+	l1        config.Chain
+	l2        config.Chain
+	addresses map[string]common.Address
+	sysCfg    eth.SystemConfig
+
+	l1Client *rpc.Client
+	l2Client *rpc.Client
+	heads    *Heads
+}
+
+func NewAPI(logger log.Logger, l1 config.Chain, l2 config.Chain) (*API, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	sysCfg, err := GenesisSystemConfig(l2)
+	if err != nil {
+		return nil, err
+	}
+
+	l1Client, err := rpc.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	l2Client, err := rpc.Dial(l2.RPCURL())
+	if err != nil {
+		l1Client.Close()
+		return nil, fmt.Errorf("failed to dial L2 RPC: %w", err)
+	}
+	return &API{
+		log:       logger,
+		l1:        l1,
+		l2:        l2,
+		addresses: addresses,
+		sysCfg:    sysCfg,
+		l1Client:  l1Client,
+		l2Client:  l2Client,
+		heads:     newHeads(l1, l2, l1Client, l2Client),
+	}, nil
+}
+
+// SetBatches derives the safe and finalized heads from the batches of the batcher of the L2
+func (api *API) SetBatches(batches BatchSource) {
+	api.heads.SetBatches(batches)
+}
+
+// Close closes the connections to the L1 and L2
+func (api *API) Close() {
+	api.l1Client.Close()
+	api.l2Client.Close()
+}
+
+// BatchInboxAddress returns the batch inbox of an L2 following the 0xff00..<chain id> devnet convention
+func BatchInboxAddress(chainID uint) common.Address {
+	return common.HexToAddress(fmt.Sprintf("0xff%038d", chainID))
+}
+
+// BlockTime returns the seconds between blocks of an L2, defaulting to the op-node devnet block time
+func BlockTime(l2 config.Chain) uint64 {
+	if l2.BlockTime == 0 {
+		return defaultBlockTime
+	}
+	return uint64(l2.BlockTime)
+}
+
+// SeqWindowSize returns the sequencing window of the L2 in L1 blocks
+func SeqWindowSize(l2 config.Chain) uint64 {
+	if l2.SeqWindowSize == 0 {
+		return defaultSeqWindowSize
+	}
+	return uint64(l2.SeqWindowSize)
+}
+
+// Batcher returns the devnet account allowed to submit batches by the genesis system config
+func Batcher() (accounts.Account, error) {
+	return devnetAccount(batcherAccount)
+}
+
+// Proposer returns the devnet account allowed to propose outputs to the L2OutputOracle
+func Proposer() (accounts.Account, error) {
+	return devnetAccount(proposerAccount)
+}
+
+// Challenger returns the devnet account allowed to delete outputs of the L2OutputOracle
+func Challenger() (accounts.Account, error) {
+	return devnetAccount(challengerAccount)
+}
+
+// TokenDeployer returns the devnet account deploying and minting the fresh L1 tokens of a profile
+func TokenDeployer() (accounts.Account, error) {
+	return devnetAccount(tokenDeployerAccount)
+}
+
+func devnetAccount(index uint) (accounts.Account, error) {
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, index+1)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	return accs[index], nil
+}
+
+// GenesisSystemConfig returns the system config an L2 starts with
+func GenesisSystemConfig(l2 config.Chain) (eth.SystemConfig, error) {
+	batcher, err := Batcher()
+	if err != nil {
+		return eth.SystemConfig{}, err
+	}
+	return eth.SystemConfig{
+		BatcherAddr: batcher.Address,
+		Overhead:    eth.Bytes32(common.BigToHash(big.NewInt(gasPriceOverhead))),
+		Scalar:      eth.Bytes32(common.BigToHash(big.NewInt(gasPriceScalar))),
+		GasLimit:    uint64(l2.GasLimit),
+	}, nil
+}
+
+func (api *API) blockTime() uint64 {
+	return BlockTime(api.l2)
+}
+
+// RollupConfig returns the rollup config of the L2
+func (api *API) RollupConfig(ctx context.Context) (*Config, error) {
+	l1Genesis, err := getHeader(ctx, api.l1Client, hexutil.EncodeUint64(uint64(api.l1.ForkBlockNumber)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 genesis: %w", err)
+	}
+	l2Genesis, err := getHeader(ctx, api.l2Client, "0x0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 genesis: %w", err)
+	}
+
+	hardforks, err := api.hardforks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l2Time := uint64(l2Genesis.Timestamp)
+	return &Config{
+		Genesis: Genesis{
+			L1:           eth.BlockID{Hash: l1Genesis.Hash, Number: uint64(l1Genesis.Number)},
+			L2:           eth.BlockID{Hash: l2Genesis.Hash, Number: uint64(l2Genesis.Number)},
+			L2Time:       l2Time,
+			SystemConfig: api.sysCfg,
+		},
+		BlockTime:               api.blockTime(),
+		MaxSequencerDrift:       maxSequencerDrift,
+		SeqWindowSize:           SeqWindowSize(api.l2),
+		ChannelTimeout:          channelTimeout,
+		L1ChainID:               new(big.Int).SetUint64(uint64(api.l1.EffectiveChainID())),
+		L2ChainID:               new(big.Int).SetUint64(uint64(api.l2.EffectiveChainID())),
+		RegolithTime:            hardforks.RegolithTime(l2Time),
+		CanyonTime:              hardforks.CanyonTime(l2Time),
+		DeltaTime:               hardforks.DeltaTime(l2Time),
+		EcotoneTime:             hardforks.EcotoneTime(l2Time),
+		BatchInboxAddress:       BatchInboxAddress(api.l2.EffectiveChainID()),
+		DepositContractAddress:  api.addresses["OptimismPortalProxy"],
+		L1SystemConfigAddress:   api.addresses["SystemConfigProxy"],
+		ProtocolVersionsAddress: api.addresses["ProtocolVersionsProxy"],
+	}, nil
+}
+
+// hardforks returns the hardforks of the L2. The built-in sequencer of a geth L2 reports them, as hardforks
+// can be scheduled while it runs
+func (api *API) hardforks(ctx context.Context) (config.Hardforks, error) {
+	if api.l2.Backend != config.BackendGeth || api.l2.Derivation {
+		return api.l2.Hardforks, nil
+	}
+	var hardforks config.Hardforks
+	if err := api.l2Client.CallContext(ctx, &hardforks, "admin_hardforks"); err != nil {
+		return config.Hardforks{}, fmt.Errorf("failed to fetch hardforks of the L2: %w", err)
+	}
+	return hardforks, nil
+}
+
+// OpNodeConfig decodes the rollup config into the rollup config of op-node
+func (c *Config) OpNodeConfig() (*oprollup.Config, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var rollupCfg oprollup.Config
+	if err := json.Unmarshal(data, &rollupCfg); err != nil {
+		return nil, fmt.Errorf("failed to decode rollup config: %w", err)
+	}
+	return &rollupCfg, nil
+}
+
+// SyncStatus returns the L1 and L2 heads. Every L2 block is derived from the L1 head at its timestamp,
+// and the safe and finalized heads are those served for the block tags of the L2.
+func (api *API) SyncStatus(ctx context.Context) (*eth.SyncStatus, error) {
+	var status eth.SyncStatus
+	for _, ref := range []struct {
+		tag string
+		out *eth.L1BlockRef
+	}{{"latest", &status.HeadL1}, {"safe", &status.SafeL1}, {"finalized", &status.FinalizedL1}} {
+		h, err := api.heads.l1Head(ctx, ref.tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s L1 block: %w", ref.tag, err)
+		}
+		*ref.out = l1BlockRef(h)
+	}
+	status.CurrentL1 = status.HeadL1
+	status.CurrentL1Finalized = status.FinalizedL1
+
+	for _, ref := range []struct {
+		tag string
+		out *eth.L2BlockRef
+	}{{"latest", &status.UnsafeL2}, {"safe", &status.SafeL2}, {"finalized", &status.FinalizedL2}} {
+		var err error
+		if *ref.out, err = api.l2HeadRef(ctx, ref.tag); err != nil {
+			return nil, err
+		}
+	}
+	// Nothing is derived ahead of the safe head
+	status.PendingSafeL2 = status.SafeL2
+	return &status, nil
+}
+
+// OutputAtBlock returns the output root of an L2 block
+func (api *API) OutputAtBlock(ctx context.Context, number hexutil.Uint64) (*eth.OutputResponse, error) {
+	h, err := getHeader(ctx, api.l2Client, number.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 block %d: %w", number, err)
+	}
+	var proof struct {
+		StorageHash common.Hash `json:"storageHash"`
+	}
+	err = api.l2Client.CallContext(ctx, &proof, "eth_getProof", predeploys.L2ToL1MessagePasserAddr, []common.Hash{}, h.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch storage root of the L2ToL1MessagePasser: %w", err)
+	}
+	ref, err := api.l2BlockRef(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	// Clients of outputs like op-proposer only check the safe head of the status, so the other heads are
+	// left out instead of searching the L1 origins of every head
+	safe, err := api.l2HeadRef(ctx, "safe")
+	if err != nil {
+		return nil, err
+	}
+
+	output := &eth.OutputV0{
+		StateRoot:                eth.Bytes32(h.StateRoot),
+		MessagePasserStorageRoot: eth.Bytes32(proof.StorageHash),
+		BlockHash:                h.Hash,
+	}
+	return &eth.OutputResponse{
+		Version:               output.Version(),
+		OutputRoot:            eth.OutputRoot(output),
+		BlockRef:              ref,
+		WithdrawalStorageRoot: proof.StorageHash,
+		StateRoot:             h.StateRoot,
+		Status:                &eth.SyncStatus{SafeL2: safe},
+	}, nil
+}
+
+// l2HeadRef returns the L2 head of a block tag
+func (api *API) l2HeadRef(ctx context.Context, tag string) (eth.L2BlockRef, error) {
+	h, err := api.heads.l2Head(ctx, tag)
+	if err != nil {
+		return eth.L2BlockRef{}, fmt.Errorf("failed to fetch %s L2 block: %w", tag, err)
+	}
+	return api.l2BlockRef(ctx, h)
+}
+
+func getHeader(ctx context.Context, client *rpc.Client, tag string) (*header, error) {
+	var h *header
+	if err := client.CallContext(ctx, &h, "eth_getBlockByNumber", tag, false); err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("block %s not found", tag)
+	}
+	return h, nil
+}
+
+func l1BlockRef(h *header) eth.L1BlockRef {
+	return eth.L1BlockRef{Hash: h.Hash, Number: uint64(h.Number), ParentHash: h.ParentHash, Time: uint64(h.Timestamp)}
+}
+
+// l2BlockRef attributes an L2 block to the latest L1 block at its timestamp
+func (api *API) l2BlockRef(ctx context.Context, h *header) (eth.L2BlockRef, error) {
+	origin, err := api.l1Origin(ctx, uint64(h.Timestamp))
+	if err != nil {
+		return eth.L2BlockRef{}, fmt.Errorf("failed to find L1 origin of L2 block %d: %w", h.Number, err)
+	}
+	ref := eth.L2BlockRef{
+		Hash:       h.Hash,
+		Number:     uint64(h.Number),
+		ParentHash: h.ParentHash,
+		Time:       uint64(h.Timestamp),
+		L1Origin:   eth.BlockID{Hash: origin.Hash, Number: uint64(origin.Number)},
+	}
+	if ref.Time > uint64(origin.Timestamp) {
+		ref.SequenceNumber = (ref.Time - uint64(origin.Timestamp)) / api.blockTime()
+	}
+	return ref, nil
+}
+
+// l1Origin binary searches the latest L1 block with a timestamp not after timestamp
+func (api *API) l1Origin(ctx context.Context, timestamp uint64) (*header, error) {
+	head, err := getHeader(ctx, api.l1Client, "latest")
+	if err != nil {
+		return nil, err
+	}
+	if uint64(head.Timestamp) <= timestamp {
+		return head, nil
+	}
+
+	lo, hi := uint64(api.l1.ForkBlockNumber), uint64(head.Number)
+	origin, err := getHeader(ctx, api.l1Client, hexutil.EncodeUint64(lo))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(origin.Timestamp) > timestamp {
+		// The L2 predates the L1, attribute it to the L1 genesis
+		return origin, nil
+	}
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		h, err := getHeader(ctx, api.l1Client, hexutil.EncodeUint64(mid))
+		if err != nil {
+			return nil, err
+		}
+		if uint64(h.Timestamp) <= timestamp {
+			lo, origin = mid, h
+		} else {
+			hi = mid - 1
+		}
+	}
+	return origin, nil
+}
@@ -0,0 +1,256 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChain serves blocks with a fixed block time starting at genesisTime
+type fakeChain struct {
+	name        string
+	head        uint64
+	genesisTime uint64
+	blockTime   uint64
+	// Lag of the safe and finalized heads behind the head
+	safeLag      uint64
+	finalizedLag uint64
+}
+
+func (c *fakeChain) hash(number uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte(fmt.Sprintf("%s-%d", c.name, number)))
+}
+
+func (c *fakeChain) block(number uint64) map[string]interface{} {
+	parent := common.Hash{}
+	if number > 0 {
+		parent = c.hash(number - 1)
+	}
+	return map[string]interface{}{
+		"hash":       c.hash(number),
+		"number":     hexutil.Uint64(number),
+		"parentHash": parent,
+		"timestamp":  hexutil.Uint64(c.genesisTime + number*c.blockTime),
+		"stateRoot":  crypto.Keccak256Hash([]byte(fmt.Sprintf("%s-state-%d", c.name, number))),
+	}
+}
+
+type fakeEth struct{ *fakeChain }
+
+func (f fakeEth) GetBlockByNumber(tag string, full bool) (map[string]interface{}, error) {
+	switch tag {
+	case "latest":
+		return f.block(f.head), nil
+	case "safe":
+		return f.block(f.head - f.safeLag), nil
+	case "finalized":
+		return f.block(f.head - f.finalizedLag), nil
+	}
+	number, err := hexutil.DecodeUint64(tag)
+	if err != nil {
+		return nil, err
+	}
+	if number > f.head {
+		return nil, nil
+	}
+	return f.block(number), nil
+}
+
+func (f fakeEth) GetProof(address common.Address, keys []common.Hash, block common.Hash) (map[string]interface{}, error) {
+	if address != predeploys.L2ToL1MessagePasserAddr {
+		return nil, fmt.Errorf("unexpected address %s", address)
+	}
+	return map[string]interface{}{"storageHash": crypto.Keccak256Hash(block[:])}, nil
+}
+
+func newFakeChain(t *testing.T, chain config.Chain, fake *fakeChain) config.Chain {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", fakeEth{fake}))
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+
+	u, err := url.Parse(httpSrv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	chain.Host = u.Hostname()
+	chain.Port = uint(port)
+	return chain
+}
+
+func newTestAPI(t *testing.T) (*API, *fakeChain, *fakeChain) {
+	return newTestAPIWithL2(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, L1FinalityDepth: 10})
+}
+
+func newTestAPIWithL2(t *testing.T, l2 config.Chain) (*API, *fakeChain, *fakeChain) {
+	l1Fake := &fakeChain{name: "L1", head: 20, genesisTime: 1000, blockTime: 12, safeLag: 2, finalizedLag: 4}
+	l2Fake := &fakeChain{name: "L2", head: 130, genesisTime: 1000, blockTime: 2, safeLag: 10, finalizedLag: 50}
+	l1 := newFakeChain(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}, l1Fake)
+	l2 = newFakeChain(t, l2, l2Fake)
+
+	api, err := NewAPI(log.New("module", "test"), l1, l2)
+	require.NoError(t, err)
+	t.Cleanup(api.Close)
+	return api, l1Fake, l2Fake
+}
+
+func TestNewAPIRequiresL2(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}
+	_, err := NewAPI(log.New("module", "test"), l1, l1)
+	require.Error(t, err)
+}
+
+func TestRollupConfig(t *testing.T) {
+	api, l1, l2 := newTestAPI(t)
+
+	cfg, err := api.RollupConfig(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, eth.BlockID{Hash: l1.hash(0), Number: 0}, cfg.Genesis.L1)
+	require.Equal(t, eth.BlockID{Hash: l2.hash(0), Number: 0}, cfg.Genesis.L2)
+	require.Equal(t, uint64(1000), cfg.Genesis.L2Time)
+	require.Equal(t, uint64(30_000_000), cfg.Genesis.SystemConfig.GasLimit)
+	require.Equal(t, common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"), cfg.Genesis.SystemConfig.BatcherAddr)
+	require.Equal(t, uint64(2), cfg.BlockTime)
+	require.Equal(t, uint64(3600), cfg.SeqWindowSize)
+	require.Equal(t, uint64(900), cfg.L1ChainID.Uint64())
+	require.Equal(t, uint64(901), cfg.L2ChainID.Uint64())
+	require.Equal(t, common.HexToAddress("0xff00000000000000000000000000000000000901"), cfg.BatchInboxAddress)
+	require.Equal(t, common.HexToAddress("0xf5faA1161967DfCfc99A28a246234176d0c92989"), cfg.DepositContractAddress)
+}
+
+func TestRollupConfigHardforks(t *testing.T) {
+	api, _, _ := newTestAPI(t)
+	cfg, err := api.RollupConfig(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), *cfg.RegolithTime)
+	require.Nil(t, cfg.CanyonTime)
+	require.Nil(t, cfg.DeltaTime)
+
+	canyon, delta := uint(60), uint(120)
+	api, _, _ = newTestAPIWithL2(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000,
+		Hardforks: config.Hardforks{Canyon: &canyon, Delta: &delta}})
+	cfg, err = api.RollupConfig(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), *cfg.RegolithTime)
+	require.Equal(t, uint64(1060), *cfg.CanyonTime)
+	require.Equal(t, uint64(1120), *cfg.DeltaTime)
+}
+
+func TestSyncStatus(t *testing.T) {
+	api, l1, l2 := newTestAPI(t)
+
+	status, err := api.SyncStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, l1.hash(20), status.HeadL1.Hash)
+	require.Equal(t, status.HeadL1, status.SafeL1)
+	require.Equal(t, uint64(10), status.FinalizedL1.Number)
+	require.Equal(t, status.HeadL1, status.CurrentL1)
+
+	// L2 blocks are safe once the L1 head at 1240 includes them and final once it is 10 blocks deep
+	require.Equal(t, l2.hash(130), status.UnsafeL2.Hash)
+	require.Equal(t, uint64(120), status.SafeL2.Number)
+	require.Equal(t, uint64(60), status.FinalizedL2.Number)
+
+	// L2 block 60 at 1120 is built on L1 block 10 at 1120
+	require.Equal(t, eth.BlockID{Hash: l1.hash(10), Number: 10}, status.FinalizedL2.L1Origin)
+	require.Equal(t, uint64(0), status.FinalizedL2.SequenceNumber)
+	// L2 block 130 at 1260 is built on the L1 head at 1240
+	require.Equal(t, uint64(20), status.UnsafeL2.L1Origin.Number)
+
+	number, err := api.heads.Number(context.Background(), "safe")
+	require.NoError(t, err)
+	require.Equal(t, uint64(120), number)
+	_, err = api.heads.Number(context.Background(), "pending")
+	require.Error(t, err)
+}
+
+func TestSyncStatusSafeDepth(t *testing.T) {
+	api, _, _ := newTestAPIWithL2(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000,
+		L1FinalityDepth: 10, L1SafeDepth: 5})
+
+	// L2 blocks are safe once L1 block 20 is 5 blocks after their L1 origin at 1180,
+	// and final once L1 block 10 is, i.e. their L1 origin is L1 block 5 at 1060 or before
+	status, err := api.SyncStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), status.SafeL1.Number)
+	require.Equal(t, uint64(90), status.SafeL2.Number)
+	require.Equal(t, uint64(30), status.FinalizedL2.Number)
+}
+
+// fakeBatches submits the L2 blocks up to each value in the L1 block of its key
+type fakeBatches map[uint64]uint64
+
+func (f fakeBatches) SubmittedAt(l1Block uint64) uint64 {
+	var submitted uint64
+	for included, last := range f {
+		if included <= l1Block && last > submitted {
+			submitted = last
+		}
+	}
+	return submitted
+}
+
+func TestSyncStatusBatches(t *testing.T) {
+	api, _, _ := newTestAPI(t)
+	api.SetBatches(fakeBatches{8: 40, 18: 100, 21: 130})
+
+	// L2 blocks are safe once the L1 head includes their batch and final once it is 10 blocks deep
+	status, err := api.SyncStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), status.SafeL2.Number)
+	require.Equal(t, uint64(40), status.FinalizedL2.Number)
+
+	// Blocks of batches submitted before a reorg are safe up to the new head
+	api.SetBatches(fakeBatches{18: 150})
+	number, err := api.heads.Number(context.Background(), "safe")
+	require.NoError(t, err)
+	require.Equal(t, uint64(130), number)
+}
+
+func TestSyncStatusDerivation(t *testing.T) {
+	api, _, _ := newTestAPIWithL2(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Derivation: true})
+
+	// op-node reports the heads of L2s with derivation
+	status, err := api.SyncStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(18), status.SafeL1.Number)
+	require.Equal(t, uint64(16), status.FinalizedL1.Number)
+	require.Equal(t, uint64(120), status.SafeL2.Number)
+	require.Equal(t, uint64(80), status.FinalizedL2.Number)
+}
+
+func TestOutputAtBlock(t *testing.T) {
+	api, _, l2 := newTestAPI(t)
+
+	output, err := api.OutputAtBlock(context.Background(), 42)
+	require.NoError(t, err)
+	block := l2.block(42)
+	stateRoot := block["stateRoot"].(common.Hash)
+	storageRoot := crypto.Keccak256Hash(l2.hash(42).Bytes())
+	expected := crypto.Keccak256Hash(make([]byte, 32), stateRoot[:], storageRoot[:], l2.hash(42).Bytes())
+	require.Equal(t, eth.Bytes32(expected), output.OutputRoot)
+	require.Equal(t, eth.OutputVersionV0, output.Version)
+	require.Equal(t, stateRoot, output.StateRoot)
+	require.Equal(t, storageRoot, output.WithdrawalStorageRoot)
+	require.Equal(t, uint64(42), output.BlockRef.Number)
+	require.Equal(t, uint64(120), output.Status.SafeL2.Number)
+
+	_, err = api.OutputAtBlock(context.Background(), 131)
+	require.Error(t, err)
+}
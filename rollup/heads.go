@@ -0,0 +1,158 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Default number of L1 blocks after which an L1 block is final, the epoch length of the geth L1
+const defaultL1FinalityDepth = 32
+
+// BatchSource reports the L2 blocks submitted to the L1 by a batcher
+type BatchSource interface {
+	// SubmittedAt returns the last L2 block submitted in the L1 blocks up to l1Block, 0 if none
+	SubmittedAt(l1Block uint64) uint64
+}
+
+// Heads emulates the safe and finalized heads of an L2 whose node reports every block as final.
+// An L2 block is safe once the L1 head includes its batch and finalized once that L1 block is
+// l1_finality_depth blocks deep. Without a batcher, the batch of an L2 block is included l1_safe_depth
+// L1 blocks after its L1 origin, the latest L1 block at its timestamp. L2s running op-node report their own heads.
+type Heads struct {
+	l1 config.Chain
+	l2 config.Chain
+
+	l1Client *rpc.Client
+	l2Client *rpc.Client
+	batches  BatchSource
+}
+
+func NewHeads(l1 config.Chain, l2 config.Chain) (*Heads, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	l1Client, err := rpc.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	l2Client, err := rpc.Dial(l2.RPCURL())
+	if err != nil {
+		l1Client.Close()
+		return nil, fmt.Errorf("failed to dial L2 RPC: %w", err)
+	}
+	return newHeads(l1, l2, l1Client, l2Client), nil
+}
+
+func newHeads(l1 config.Chain, l2 config.Chain, l1Client *rpc.Client, l2Client *rpc.Client) *Heads {
+	return &Heads{l1: l1, l2: l2, l1Client: l1Client, l2Client: l2Client}
+}
+
+// SetBatches derives the safe and finalized heads from the batches of the batcher of the L2
+func (h *Heads) SetBatches(batches BatchSource) {
+	h.batches = batches
+}
+
+// Close closes the connections to the L1 and L2
+func (h *Heads) Close() {
+	h.l1Client.Close()
+	h.l2Client.Close()
+}
+
+// Number returns the number of the L2 block of the safe or finalized tag
+func (h *Heads) Number(ctx context.Context, tag string) (uint64, error) {
+	head, err := h.l2Head(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(head.Number), nil
+}
+
+// l1Head returns the L1 block L2 blocks of the latest, safe or finalized tag are included by
+func (h *Heads) l1Head(ctx context.Context, tag string) (*header, error) {
+	if h.l2.Derivation {
+		return getHeader(ctx, h.l1Client, tag)
+	}
+	head, err := getHeader(ctx, h.l1Client, "latest")
+	if err != nil || tag != "finalized" {
+		return head, err
+	}
+	depth := uint64(h.l2.L1FinalityDepth)
+	if depth == 0 {
+		depth = defaultL1FinalityDepth
+	}
+	finalized := uint64(h.l1.ForkBlockNumber)
+	if uint64(head.Number) > finalized+depth {
+		finalized = uint64(head.Number) - depth
+	}
+	return getHeader(ctx, h.l1Client, hexutil.EncodeUint64(finalized))
+}
+
+// l2Head returns the L2 block of the latest, safe or finalized tag
+func (h *Heads) l2Head(ctx context.Context, tag string) (*header, error) {
+	if h.l2.Derivation || tag == "latest" {
+		return getHeader(ctx, h.l2Client, tag)
+	}
+	if tag != "safe" && tag != "finalized" {
+		return nil, fmt.Errorf("unknown block tag %s", tag)
+	}
+	l1Head, err := h.l1Head(ctx, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s L1 block: %w", tag, err)
+	}
+	if h.batches != nil {
+		// Blocks reorged after their batch was submitted are replaced by a shorter chain
+		submitted := h.batches.SubmittedAt(uint64(l1Head.Number))
+		head, err := getHeader(ctx, h.l2Client, "latest")
+		if err != nil || uint64(head.Number) <= submitted {
+			return head, err
+		}
+		return getHeader(ctx, h.l2Client, hexutil.EncodeUint64(submitted))
+	}
+
+	// The L1 origins of the blocks whose batches are included by the L1 head
+	origin := l1Head
+	if depth := uint64(h.l2.L1SafeDepth); depth != 0 {
+		number := uint64(h.l1.ForkBlockNumber)
+		if uint64(l1Head.Number) > number+depth {
+			number = uint64(l1Head.Number) - depth
+		}
+		if origin, err = getHeader(ctx, h.l1Client, hexutil.EncodeUint64(number)); err != nil {
+			return nil, fmt.Errorf("failed to fetch L1 block %d: %w", number, err)
+		}
+	}
+	return h.latestAt(ctx, uint64(origin.Timestamp))
+}
+
+// latestAt binary searches the latest L2 block with a timestamp not after timestamp. The genesis is always included
+func (h *Heads) latestAt(ctx context.Context, timestamp uint64) (*header, error) {
+	head, err := getHeader(ctx, h.l2Client, "latest")
+	if err != nil {
+		return nil, err
+	}
+	if uint64(head.Timestamp) <= timestamp {
+		return head, nil
+	}
+
+	lo, hi := uint64(0), uint64(head.Number)
+	latest, err := getHeader(ctx, h.l2Client, hexutil.EncodeUint64(lo))
+	if err != nil {
+		return nil, err
+	}
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		b, err := getHeader(ctx, h.l2Client, hexutil.EncodeUint64(mid))
+		if err != nil {
+			return nil, err
+		}
+		if uint64(b.Timestamp) <= timestamp {
+			lo, latest = mid, b
+		} else {
+			hi = mid - 1
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,95 @@
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RollupNode is a rollup node serving the rollup RPC, like the op-node of an L2 with derivation
+type RollupNode interface {
+	// RollupRPC returns the URL of the rollup RPC, an error while the node is not running
+	RollupRPC() (string, error)
+}
+
+// Proxy serves the rollup RPC of an L2 with derivation by forwarding it to its op-node, so the heads and
+// L1 origins are those op-node derived. Responses are passed through as op-node encodes them.
+type Proxy struct {
+	node RollupNode
+
+	mu     sync.Mutex
+	url    string
+	client *rpc.Client
+}
+
+func NewProxy(node RollupNode) *Proxy {
+	return &Proxy{node: node}
+}
+
+// RollupConfig forwards optimism_rollupConfig
+func (p *Proxy) RollupConfig(ctx context.Context) (json.RawMessage, error) {
+	return p.call(ctx, "rollupConfig")
+}
+
+// SyncStatus forwards optimism_syncStatus
+func (p *Proxy) SyncStatus(ctx context.Context) (json.RawMessage, error) {
+	return p.call(ctx, "syncStatus")
+}
+
+// OutputAtBlock forwards optimism_outputAtBlock
+func (p *Proxy) OutputAtBlock(ctx context.Context, number hexutil.Uint64) (json.RawMessage, error) {
+	return p.call(ctx, "outputAtBlock", number)
+}
+
+// Version forwards optimism_version
+func (p *Proxy) Version(ctx context.Context) (json.RawMessage, error) {
+	return p.call(ctx, "version")
+}
+
+// Close closes the connection to the rollup node
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+}
+
+func (p *Proxy) call(ctx context.Context, method string, args ...interface{}) (json.RawMessage, error) {
+	client, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result json.RawMessage
+	if err := client.CallContext(ctx, &result, NAMESPACE+"_"+method, args...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// dial connects to the rollup RPC of the node, which moves to a new port when the node restarts
+func (p *Proxy) dial(ctx context.Context) (*rpc.Client, error) {
+	url, err := p.node.RollupRPC()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil && p.url == url {
+		return p.client, nil
+	}
+	if p.client != nil {
+		p.client.Close()
+	}
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial rollup RPC: %w", err)
+	}
+	p.url, p.client = url, client
+	return client, nil
+}
@@ -0,0 +1,68 @@
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRollupNode serves the rollup RPC on url, or not at all while url is empty
+type fakeRollupNode struct{ url string }
+
+func (n *fakeRollupNode) RollupRPC() (string, error) {
+	if n.url == "" {
+		return "", errors.New("not running")
+	}
+	return n.url, nil
+}
+
+type fakeOptimism struct{}
+
+func (fakeOptimism) OutputAtBlock(number hexutil.Uint64) (map[string]interface{}, error) {
+	return map[string]interface{}{"blockRef": map[string]interface{}{"number": number}}, nil
+}
+
+func (fakeOptimism) Version() string {
+	return "v0.0.0"
+}
+
+func TestProxy(t *testing.T) {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName(NAMESPACE, fakeOptimism{}))
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+
+	node := &fakeRollupNode{}
+	proxy := NewProxy(node)
+	t.Cleanup(proxy.Close)
+	_, err := proxy.Version(context.Background())
+	require.Error(t, err)
+
+	// The responses of the node are passed through
+	node.url = httpSrv.URL
+	version, err := proxy.Version(context.Background())
+	require.NoError(t, err)
+	require.JSONEq(t, `"v0.0.0"`, string(version))
+	output, err := proxy.OutputAtBlock(context.Background(), 42)
+	require.NoError(t, err)
+	var decoded struct {
+		BlockRef struct {
+			Number hexutil.Uint64 `json:"number"`
+		} `json:"blockRef"`
+	}
+	require.NoError(t, json.Unmarshal(output, &decoded))
+	require.Equal(t, hexutil.Uint64(42), decoded.BlockRef.Number)
+
+	// Methods the node does not serve fail
+	_, err = proxy.SyncStatus(context.Background())
+	require.Error(t, err)
+}
@@ -66,25 +66,7 @@ func (a *AnvilService) Config() interface{} {
 }
 
 func (a *AnvilService) Start(ctx context.Context) error {
-	args := []string{}
-
-	if a.config.Port != 0 {
-		args = append(args, "--port", fmt.Sprintf("%d", a.config.Port))
-	}
-	if a.config.Host != "" {
-		args = append(args, "--host", a.config.Host)
-	}
-	if a.config.ForkBlockNumber != 0 {
-		args = append(args, "--fork-block-number", fmt.Sprintf("%d", a.config.ForkBlockNumber))
-	}
-	if a.config.ForkChainID != 0 {
-		args = append(args, "--fork-chain-id", fmt.Sprintf("%d", a.config.ForkChainID))
-	}
-	if a.config.ForkURL != "" {
-		args = append(args, "--fork-url", a.config.ForkURL)
-	}
-
-	a.cmd = exec.CommandContext(ctx, "anvil", args...)
+	a.cmd = exec.CommandContext(ctx, "anvil", a.args()...)
 
 	stdout, _ := a.cmd.StdoutPipe()
 	stderr, _ := a.cmd.StderrPipe()
@@ -118,6 +100,35 @@ func (a *AnvilService) Start(ctx context.Context) error {
 	return nil
 }
 
+// args returns the flags of the anvil binary for the chain
+func (a *AnvilService) args() []string {
+	args := []string{}
+
+	if a.config.Port != 0 {
+		args = append(args, "--port", fmt.Sprintf("%d", a.config.Port))
+	}
+	if a.config.Host != "" {
+		args = append(args, "--host", a.config.Host)
+	}
+	if a.config.ForkBlockNumber != 0 {
+		args = append(args, "--fork-block-number", fmt.Sprintf("%d", a.config.ForkBlockNumber))
+	}
+	if a.config.ForkChainID != 0 {
+		args = append(args, "--fork-chain-id", fmt.Sprintf("%d", a.config.ForkChainID))
+	}
+	if a.config.ForkURL != "" {
+		args = append(args, "--fork-url", a.config.ForkURL)
+	}
+	// The genesis of the other backends and the exported devnets fund the same accounts
+	if a.config.Accounts != 0 {
+		args = append(args, "--accounts", fmt.Sprintf("%d", a.config.Accounts))
+	}
+	if a.config.Balance != 0 {
+		args = append(args, "--balance", fmt.Sprintf("%d", a.config.Balance))
+	}
+	return args
+}
+
 func (a *AnvilService) Stop() error {
 	if a.cmd == nil {
 		return fmt.Errorf("Service is not running")
@@ -2,6 +2,7 @@ package anvil
 
 import (
 	"context"
+	"os/exec"
 	"testing"
 	"time"
 
@@ -27,7 +28,26 @@ func TestAnvilServiceValidation(t *testing.T) {
 	}
 }
 
+func TestAnvilArgs(t *testing.T) {
+	service, err := NewAnvilService("TestService", log.New("module", "test"), config.Chain{Host: "127.0.0.1", Port: 8545})
+	require.NoError(t, err)
+	require.Equal(t, []string{"--port", "8545", "--host", "127.0.0.1"}, service.args())
+
+	// The exported devnet lists the configured accounts, so anvil funds as many
+	service, err = NewAnvilService("TestService", log.New("module", "test"), config.Chain{Host: "127.0.0.1", Port: 8545, Accounts: 20, Balance: 100})
+	require.NoError(t, err)
+	require.Equal(t, []string{"--port", "8545", "--host", "127.0.0.1", "--accounts", "20", "--balance", "100"}, service.args())
+}
+
+// requireAnvil skips tests of the anvil binary if it is not installed. The simulated backend runs devnets without it.
+func requireAnvil(t *testing.T) {
+	if _, err := exec.LookPath("anvil"); err != nil {
+		t.Skip("anvil is not installed")
+	}
+}
+
 func TestAnvilService(t *testing.T) {
+	requireAnvil(t)
 	logger := log.New("module", "test")
 	logger.Info("running test")
 	cfg := config.Chain{
@@ -91,6 +111,7 @@ func TestStopWithoutStarting(t *testing.T) {
 }
 
 func TestForkBlockNumber(t *testing.T) {
+	requireAnvil(t)
 	logger := log.New("module", "test")
 	cfg := config.Chain{
 		Host:            "127.0.0.1",
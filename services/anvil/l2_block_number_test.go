@@ -38,14 +38,28 @@ func TestGetL2BlockNumberAtSpecificL1Block(t *testing.T) {
 	require.NoError(t, err)
 	defer backend.Close()
 
-	l2OutputOracleAddress, _, _, err := bindings.DeployL2OutputOracle(
-		opts,
-		backend,
+	// The L2OutputOracle is initialized behind a proxy, with the submission interval, block time and
+	// finalization period the test used to pass to its constructor
+	implAddress, _, _, err := bindings.DeployL2OutputOracle(opts, backend)
+	require.NoError(t, err)
+	l2OutputOracleAddress, _, proxy, err := bindings.DeployProxy(opts, backend, from)
+	require.NoError(t, err)
+
+	oracleABI, err := bindings.L2OutputOracleMetaData.GetAbi()
+	require.NoError(t, err)
+	initialize, err := oracleABI.Pack(
+		"initialize",
 		big.NewInt(10),
 		big.NewInt(2),
+		big.NewInt(0),
+		big.NewInt(0),
+		from,
+		from,
 		big.NewInt(100),
 	)
 	require.NoError(t, err)
+	_, err = proxy.UpgradeToAndCall(opts, implAddress, initialize)
+	require.NoError(t, err)
 
 	backend.Commit()
 
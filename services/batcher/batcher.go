@@ -0,0 +1,400 @@
+// Package batcher submits the blocks of a geth L2 to the batch inbox on its L1 like op-batcher.
+//
+// Blocks are compressed into a channel that is split into frames, and every frame is posted as
+// the calldata of a transaction from the batcher of the genesis system config, so op-node can
+// derive the safe chain of the L2 from the L1.
+package batcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/optimism/op-batcher/compressor"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	SERVICE_TYPE = "batcher"
+)
+
+const (
+	defaultBatchInterval = 12 * time.Second
+	// Defaults of the op-batcher max-l1-tx-size-bytes, target-num-frames and approx-compr-ratio flags
+	maxFrameSize     = 120_000
+	targetNumFrames  = 1
+	approxComprRatio = 0.4
+	// Number of batches reported by Batches
+	maxBatchHistory = 100
+	// Time to wait for the inclusion of a batch before its blocks are submitted again, so an L1 that
+	// stopped mining does not block the batcher and its control API
+	defaultInclusionTimeout = time.Minute
+)
+
+// Batch reports a channel submitted to the batch inbox
+type Batch struct {
+	Channel    string         `json:"channel"`
+	FirstBlock hexutil.Uint64 `json:"firstBlock"`
+	LastBlock  hexutil.Uint64 `json:"lastBlock"`
+	// Transactions posting the frames of the channel, one per frame
+	Transactions []common.Hash `json:"transactions"`
+	// The L1 block including the last frame, which makes the blocks of the channel safe
+	L1Block hexutil.Uint64 `json:"l1Block"`
+	// Calldata posted to the L1, including the derivation version byte of every frame
+	Bytes hexutil.Uint64 `json:"bytes"`
+	// Gas used by the transactions on the L1 and the fees paid for it
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Fee     *hexutil.Big   `json:"fee"`
+}
+
+type Batcher struct {
+	log      log.Logger
+	l1       config.Chain
+	l2       config.Chain
+	inbox    common.Address
+	account  accounts.Account
+	interval time.Duration
+	// Time to wait for the inclusion of the transactions of a batch
+	inclusionTimeout time.Duration
+
+	l1Client *ethclient.Client
+	l2Client *ethclient.Client
+	// Serves the rollup config blocks are batched with
+	rollup *rollup.API
+
+	mu sync.Mutex
+	// The last submitted block, or the safe head the batcher started from
+	last *types.Header
+
+	// Guards the batches apart from mu, so they are reported while a batch is submitted
+	batchesMu sync.Mutex
+	batches   []Batch
+}
+
+func NewBatcher(logger log.Logger, l1 config.Chain, l2 config.Chain) (*Batcher, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	if l2.Backend != config.BackendGeth {
+		return nil, fmt.Errorf("batcher requires a geth L2")
+	}
+	account, err := rollup.Batcher()
+	if err != nil {
+		return nil, err
+	}
+	interval := defaultBatchInterval
+	if l2.BatchInterval != 0 {
+		interval = time.Duration(l2.BatchInterval) * time.Second
+	}
+
+	l1Client, err := ethclient.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	l2Client, err := ethclient.Dial(l2.RPCURL())
+	if err != nil {
+		l1Client.Close()
+		return nil, fmt.Errorf("failed to dial L2 RPC: %w", err)
+	}
+	rollupAPI, err := rollup.NewAPI(logger, l1, l2)
+	if err != nil {
+		l1Client.Close()
+		l2Client.Close()
+		return nil, err
+	}
+
+	return &Batcher{
+		log:              logger,
+		l1:               l1,
+		l2:               l2,
+		inbox:            rollup.BatchInboxAddress(l2.ChainID),
+		account:          account,
+		interval:         interval,
+		inclusionTimeout: defaultInclusionTimeout,
+		l1Client:         l1Client,
+		l2Client:         l2Client,
+		rollup:           rollupAPI,
+	}, nil
+}
+
+func (b *Batcher) ID() string {
+	return fmt.Sprintf("%s-%s", SERVICE_TYPE, b.l2.Name)
+}
+
+// L2 returns the config of the chain whose blocks are submitted
+func (b *Batcher) L2() config.Chain {
+	return b.l2
+}
+
+// Start submits the new blocks of the L2 every batch interval until the context is canceled
+func (b *Batcher) Start(ctx context.Context) error {
+	defer b.l1Client.Close()
+	defer b.l2Client.Close()
+	defer b.rollup.Close()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := b.SubmitPending(ctx); err != nil {
+				b.log.Warn("failed to submit batch", "err", err)
+			}
+		}
+	}
+}
+
+// Batches returns the most recently submitted batches, oldest first
+func (b *Batcher) Batches() []Batch {
+	b.batchesMu.Lock()
+	defer b.batchesMu.Unlock()
+	return append([]Batch(nil), b.batches...)
+}
+
+// Cursor returns the last submitted L2 block, or nil before the first submission
+func (b *Batcher) Cursor() *types.Header {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// SetCursor moves the batcher back to a submitted L2 block, e.g. after the chains were reverted to a snapshot.
+// Batches of later blocks are dropped, so their blocks are submitted again.
+func (b *Batcher) SetCursor(last *types.Header) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last = last
+
+	b.batchesMu.Lock()
+	defer b.batchesMu.Unlock()
+	batches := b.batches[:0]
+	for _, batch := range b.batches {
+		if last != nil && uint64(batch.LastBlock) <= last.Number.Uint64() {
+			batches = append(batches, batch)
+		}
+	}
+	b.batches = batches
+}
+
+// SubmittedAt returns the last L2 block of the batches included in the L1 blocks up to l1Block, 0 if none
+func (b *Batcher) SubmittedAt(l1Block uint64) uint64 {
+	b.batchesMu.Lock()
+	defer b.batchesMu.Unlock()
+	var submitted uint64
+	for _, batch := range b.batches {
+		if uint64(batch.L1Block) <= l1Block && uint64(batch.LastBlock) > submitted {
+			submitted = uint64(batch.LastBlock)
+		}
+	}
+	return submitted
+}
+
+// SubmitPending submits the blocks up to the current L2 head in a single channel and returns its
+// report, or nil if there are no new blocks. Blocks that do not fit the channel are left for the next call.
+func (b *Batcher) SubmitPending(ctx context.Context) (*Batch, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last != nil {
+		// Like op-batcher, start over from the safe head when the submitted blocks were reorged
+		canonical, err := b.l2Client.HeaderByNumber(ctx, b.last.Number)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to fetch L2 block %d: %w", b.last.Number, err)
+		}
+		if canonical == nil || canonical.Hash() != b.last.Hash() {
+			b.log.Warn("submitted blocks were reorged, restarting from the safe head", "block", b.last.Number)
+			b.last = nil
+		}
+	}
+	if b.last == nil {
+		// The genesis block is never submitted
+		safe, err := b.l2Client.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch L2 safe head: %w", err)
+		}
+		b.last = safe
+	}
+	next := b.last.Number.Uint64() + 1
+	head, err := b.l2Client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 head: %w", err)
+	}
+	if next > head {
+		return nil, nil
+	}
+
+	channel, last, err := b.buildChannel(ctx, next, head)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := b.submitChannel(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	batch.FirstBlock = hexutil.Uint64(next)
+	batch.LastBlock = hexutil.Uint64(last.Number.Uint64())
+	b.log.Info("submitted batch", "channel", batch.Channel, "blocks", last.Number.Uint64()-next+1, "first", next, "last", last.Number,
+		"frames", len(batch.Transactions), "bytes", uint64(batch.Bytes), "gasUsed", uint64(batch.GasUsed), "fee", batch.Fee)
+
+	b.last = last
+	b.batchesMu.Lock()
+	b.batches = append(b.batches, *batch)
+	if len(b.batches) > maxBatchHistory {
+		b.batches = b.batches[len(b.batches)-maxBatchHistory:]
+	}
+	b.batchesMu.Unlock()
+	return batch, nil
+}
+
+// buildChannel adds the blocks from next up to head to a channel until it is full and returns the closed
+// channel with the last block it contains
+func (b *Batcher) buildChannel(ctx context.Context, next uint64, head uint64) (derive.ChannelOut, *types.Header, error) {
+	cfg, err := b.rollup.RollupConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	rollupCfg, err := cfg.OpNodeConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	comp, err := compressor.Config{
+		TargetFrameSize:  maxFrameSize,
+		TargetNumFrames:  targetNumFrames,
+		ApproxComprRatio: approxComprRatio,
+	}.NewCompressor()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Singular batches remain valid after Delta, so span batches are not built
+	channel, err := derive.NewChannelOut(derive.SingularBatchType, comp, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var last *types.Header
+	for number := next; number <= head; number++ {
+		block, err := b.l2Client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch L2 block %d: %w", number, err)
+		}
+		if _, err := channel.AddBlock(rollupCfg, block); errors.Is(err, derive.ErrTooManyRLPBytes) || errors.Is(err, derive.CompressorFullErr) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to add L2 block %d: %w", number, err)
+		}
+		last = block.Header()
+		if channel.FullErr() != nil {
+			break
+		}
+	}
+	if last == nil {
+		return nil, nil, fmt.Errorf("L2 block %d does not fit a channel", next)
+	}
+	if err := channel.Close(); err != nil {
+		return nil, nil, err
+	}
+	return channel, last, nil
+}
+
+// submitChannel posts every frame of the channel to the batch inbox and waits for their inclusion
+// up to the inclusion timeout
+func (b *Batcher) submitChannel(ctx context.Context, channel derive.ChannelOut) (*Batch, error) {
+	batch := &Batch{Channel: channel.ID().String(), Fee: (*hexutil.Big)(new(big.Int))}
+
+	nonce, err := b.l1Client.PendingNonceAt(ctx, b.account.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch batcher nonce: %w", err)
+	}
+	var txs []*types.Transaction
+	for done := false; !done; nonce++ {
+		var buf bytes.Buffer
+		buf.WriteByte(derive.DerivationVersion0)
+		if _, err := channel.OutputFrame(&buf, maxFrameSize-1); errors.Is(err, io.EOF) {
+			done = true
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to output frame: %w", err)
+		}
+
+		tx, err := b.sendTx(ctx, nonce, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+		batch.Transactions = append(batch.Transactions, tx.Hash())
+		batch.Bytes += hexutil.Uint64(buf.Len())
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, b.inclusionTimeout)
+	defer cancel()
+	for _, tx := range txs {
+		receipt, err := bind.WaitMined(waitCtx, b.l1Client, tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wait for batch transaction %s: %w", tx.Hash(), err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return nil, fmt.Errorf("batch transaction %s failed", tx.Hash())
+		}
+		batch.GasUsed += hexutil.Uint64(receipt.GasUsed)
+		if number := hexutil.Uint64(receipt.BlockNumber.Uint64()); number > batch.L1Block {
+			batch.L1Block = number
+		}
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+		batch.Fee.ToInt().Add(batch.Fee.ToInt(), fee)
+	}
+	return batch, nil
+}
+
+func (b *Batcher) sendTx(ctx context.Context, nonce uint64, data []byte) (*types.Transaction, error) {
+	chainID, err := b.l1Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 chain id: %w", err)
+	}
+	head, err := b.l1Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	tip, err := b.l1Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 gas tip: %w", err)
+	}
+	gas, err := b.l1Client.EstimateGas(ctx, ethereum.CallMsg{From: b.account.Address, To: &b.inbox, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate batch gas: %w", err)
+	}
+
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	tx, err := types.SignNewTx(b.account.PrivateKey, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &b.inbox,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := b.l1Client.SendTransaction(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to send batch transaction: %w", err)
+	}
+	return tx, nil
+}
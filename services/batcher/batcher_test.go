@@ -0,0 +1,115 @@
+package batcher
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatcherValidation(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}
+	for _, l2 := range []config.Chain{
+		{Name: "L2", ChainID: 901, BaseChainID: 1, Backend: config.BackendGeth},
+		{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendAnvil},
+		{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendSimulated},
+	} {
+		_, err := NewBatcher(log.New("module", "test"), l1, l2)
+		require.Error(t, err)
+	}
+}
+
+func TestBatcherSubmitsBlocks(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Backend: config.BackendGeth, Batcher: true}
+	l1, _ = simtest.StartGeth(t, l1, geth.GethConfig{})
+	sysCfg, err := rollup.GenesisSystemConfig(l2)
+	require.NoError(t, err)
+	l2, l2Service := simtest.StartGeth(t, l2, geth.GethConfig{OpGeth: true, BlockTime: 2, Sequencer: &geth.SequencerConfig{
+		L1URL:         l1.RPCURL(),
+		Portal:        addresses["OptimismPortalProxy"],
+		SystemConfig:  sysCfg,
+		SeqWindowSize: rollup.SeqWindowSize(l2),
+	}})
+	_, err = l2Service.Mine(5)
+	require.NoError(t, err)
+
+	b, err := NewBatcher(log.New("module", "test", "service", SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	batch, err := b.SubmitPending(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, batch)
+	require.EqualValues(t, 1, batch.FirstBlock)
+	require.EqualValues(t, 5, batch.LastBlock)
+	require.Len(t, batch.Transactions, 1)
+	require.NotZero(t, batch.GasUsed)
+	require.Positive(t, batch.Fee.ToInt().Sign())
+	require.Equal(t, []Batch{*batch}, b.Batches())
+	require.NotZero(t, batch.L1Block)
+	require.EqualValues(t, 5, b.SubmittedAt(uint64(batch.L1Block)))
+	require.Zero(t, b.SubmittedAt(uint64(batch.L1Block)-1))
+
+	// The frame is posted from the batcher of the system config to the batch inbox
+	client, err := ethclient.Dial(l1.RPCURL())
+	require.NoError(t, err)
+	defer client.Close()
+	tx, _, err := client.TransactionByHash(context.Background(), batch.Transactions[0])
+	require.NoError(t, err)
+	sender, err := types.LatestSignerForChainID(big.NewInt(900)).Sender(tx)
+	require.NoError(t, err)
+	require.Equal(t, sysCfg.BatcherAddr, sender)
+	require.Equal(t, rollup.BatchInboxAddress(901), *tx.To())
+	require.EqualValues(t, len(tx.Data()), batch.Bytes)
+	frames, err := derive.ParseFrames(tx.Data())
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	require.Equal(t, batch.Channel, frames[0].ID.String())
+	require.True(t, frames[0].IsLast)
+
+	// Submitted blocks are not submitted again
+	first := b.Cursor()
+	require.EqualValues(t, 5, first.Number.Uint64())
+	batch, err = b.SubmitPending(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, batch)
+	_, err = l2Service.Mine(1)
+	require.NoError(t, err)
+	batch, err = b.SubmitPending(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 6, batch.FirstBlock)
+	require.EqualValues(t, 6, batch.LastBlock)
+	require.EqualValues(t, 6, b.SubmittedAt(uint64(batch.L1Block)))
+
+	// Moving the cursor back drops the later batches and submits their blocks again
+	b.SetCursor(first)
+	require.Len(t, b.Batches(), 1)
+	batch, err = b.SubmitPending(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 6, batch.FirstBlock)
+	b.SetCursor(nil)
+	require.Empty(t, b.Batches())
+
+	// Batches not included in time are submitted again
+	b.SetCursor(first)
+	b.inclusionTimeout = time.Nanosecond
+	_, err = b.SubmitPending(context.Background())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, first, b.Cursor())
+	b.inclusionTimeout = defaultInclusionTimeout
+	batch, err = b.SubmitPending(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 6, batch.FirstBlock)
+}
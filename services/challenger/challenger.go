@@ -0,0 +1,436 @@
+// Package challenger runs an honest challenger playing the dispute games of an L2 like op-challenger.
+//
+// Every game of the proposer's game type is checked against the output computed from the L2. Root claims
+// of invalid outputs are attacked, and games are resolved once the chess clocks of their claims expire, so
+// honest outputs end with the defender winning and dishonest ones with the challenger winning.
+package challenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/proposer"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	SERVICE_TYPE = "challenger"
+)
+
+const (
+	pollInterval        = 2 * time.Second
+	receiptPollInterval = 100 * time.Millisecond
+	// Number of games reported by Games
+	maxGameHistory = 100
+)
+
+// GameStatus mirrors the GameStatus enum of the dispute game contracts
+type GameStatus uint8
+
+const (
+	GameInProgress GameStatus = iota
+	GameChallengerWins
+	GameDefenderWins
+)
+
+func (s GameStatus) String() string {
+	switch s {
+	case GameInProgress:
+		return "in_progress"
+	case GameChallengerWins:
+		return "challenger_wins"
+	case GameDefenderWins:
+		return "defender_wins"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(s))
+}
+
+func (s GameStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *GameStatus) UnmarshalText(text []byte) error {
+	for _, status := range []GameStatus{GameInProgress, GameChallengerWins, GameDefenderWins} {
+		if status.String() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown game status %q", text)
+}
+
+// Game reports a dispute game played by the challenger
+type Game struct {
+	Address       common.Address `json:"address"`
+	L2BlockNumber hexutil.Uint64 `json:"l2BlockNumber"`
+	RootClaim     common.Hash    `json:"rootClaim"`
+	// Whether the root claim matches the output of the L2. Root claims of invalid outputs are attacked.
+	OutputValid bool           `json:"outputValid"`
+	Status      GameStatus     `json:"status"`
+	CreatedAt   hexutil.Uint64 `json:"createdAt"`
+}
+
+type Challenger struct {
+	log     log.Logger
+	l1      config.Chain
+	l2      config.Chain
+	account accounts.Account
+
+	l1Client *ethclient.Client
+	rollup   *rollup.API
+	factory  *bindings.DisputeGameFactory
+
+	mu sync.Mutex
+	// Games by their index in the factory, and the number of factory games seen
+	games map[uint64]*Game
+	seen  uint64
+}
+
+func NewChallenger(logger log.Logger, l1 config.Chain, l2 config.Chain) (*Challenger, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	account, err := rollup.Challenger()
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	l1Client, err := ethclient.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	api, err := rollup.NewAPI(logger, l1, l2)
+	if err != nil {
+		l1Client.Close()
+		return nil, err
+	}
+	factory, err := bindings.NewDisputeGameFactory(addresses["DisputeGameFactoryProxy"], l1Client)
+	if err != nil {
+		l1Client.Close()
+		api.Close()
+		return nil, err
+	}
+
+	return &Challenger{
+		log:      logger,
+		l1:       l1,
+		l2:       l2,
+		account:  account,
+		l1Client: l1Client,
+		rollup:   api,
+		factory:  factory,
+		games:    make(map[uint64]*Game),
+	}, nil
+}
+
+func (c *Challenger) ID() string {
+	return fmt.Sprintf("%s-%s", SERVICE_TYPE, c.l2.Name)
+}
+
+// L2 returns the config of the chain whose outputs are disputed
+func (c *Challenger) L2() config.Chain {
+	return c.l2
+}
+
+// Start plays the dispute games every poll interval until the context is canceled
+func (c *Challenger) Start(ctx context.Context) error {
+	defer c.l1Client.Close()
+	defer c.rollup.Close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.Play(ctx); err != nil {
+				c.log.Warn("failed to play dispute games", "err", err)
+			}
+		}
+	}
+}
+
+// Games returns the most recent games, oldest first
+func (c *Challenger) Games() []Game {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	indexes := make([]uint64, 0, len(c.games))
+	for i := range c.games {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	games := make([]Game, 0, len(indexes))
+	for _, i := range indexes {
+		games = append(games, *c.games[i])
+	}
+	return games
+}
+
+// Cursor returns the number of factory games seen
+func (c *Challenger) Cursor() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seen
+}
+
+// SetCursor drops the games from an index in the factory on, e.g. after the chains were reverted to a
+// snapshot. The remaining games are played again from their status on the L1.
+func (c *Challenger) SetCursor(seen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, game := range c.games {
+		if i >= seen {
+			delete(c.games, i)
+		} else {
+			game.Status = GameInProgress
+		}
+	}
+	c.seen = seen
+}
+
+// Play picks up the games created since the last call, then moves in and resolves every game in progress
+func (c *Challenger) Play(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	callOpts := &bind.CallOpts{Context: ctx}
+	count, err := c.factory.GameCount(callOpts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch dispute game count: %w", err)
+	}
+	for ; c.seen < count.Uint64(); c.seen++ {
+		info, err := c.factory.GameAtIndex(callOpts, new(big.Int).SetUint64(c.seen))
+		if err != nil {
+			return fmt.Errorf("failed to fetch dispute game %d: %w", c.seen, err)
+		}
+		if info.GameType != proposer.GameType {
+			continue
+		}
+		game, err := c.newGame(ctx, info.Proxy, info.Timestamp)
+		if err != nil {
+			return err
+		}
+		c.games[c.seen] = game
+		if c.seen >= maxGameHistory {
+			delete(c.games, c.seen-maxGameHistory)
+		}
+	}
+
+	for _, game := range c.games {
+		if game.Status != GameInProgress {
+			continue
+		}
+		if err := c.play(ctx, game); err != nil {
+			return fmt.Errorf("failed to play dispute game %s: %w", game.Address, err)
+		}
+	}
+	return nil
+}
+
+// newGame checks the root claim of a game against the output of the L2
+func (c *Challenger) newGame(ctx context.Context, addr common.Address, createdAt uint64) (*Game, error) {
+	contract, err := bindings.NewFaultDisputeGameCaller(addr, c.l1Client)
+	if err != nil {
+		return nil, err
+	}
+	callOpts := &bind.CallOpts{Context: ctx}
+	number, err := contract.L2BlockNumber(callOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 block of dispute game %s: %w", addr, err)
+	}
+	rootClaim, err := contract.RootClaim(callOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch root claim of dispute game %s: %w", addr, err)
+	}
+	output, err := c.rollup.OutputAtBlock(ctx, hexutil.Uint64(number.Uint64()))
+	if err != nil {
+		return nil, err
+	}
+	return &Game{
+		Address:       addr,
+		L2BlockNumber: hexutil.Uint64(number.Uint64()),
+		RootClaim:     rootClaim,
+		OutputValid:   rootClaim == [32]byte(output.OutputRoot),
+		Status:        GameInProgress,
+		CreatedAt:     hexutil.Uint64(createdAt),
+	}, nil
+}
+
+// play attacks the root claim of a game disputing a valid output, then resolves the claims whose clocks
+// expired, deepest first, and the game once its root claim is resolved
+func (c *Challenger) play(ctx context.Context, game *Game) error {
+	contract, err := bindings.NewFaultDisputeGame(game.Address, c.l1Client)
+	if err != nil {
+		return err
+	}
+	callOpts := &bind.CallOpts{Context: ctx}
+	status, err := contract.Status(callOpts)
+	if err != nil {
+		return err
+	}
+	if game.Status = GameStatus(status); game.Status != GameInProgress {
+		return nil
+	}
+	duration, err := contract.GameDuration(callOpts)
+	if err != nil {
+		return err
+	}
+	head, err := c.l1Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	n, err := contract.ClaimDataLen(callOpts)
+	if err != nil {
+		return err
+	}
+	claims := make([]claim, n.Uint64())
+	for i := range claims {
+		data, err := contract.ClaimData(callOpts, big.NewInt(int64(i)))
+		if err != nil {
+			return err
+		}
+		claims[i] = claim{parent: data.ParentIndex, countered: data.CounteredBy != common.Address{}, clock: data.Clock}
+		if i > 0 {
+			claims[data.ParentIndex].children++
+		}
+	}
+
+	if !game.OutputValid && !claims[0].countered {
+		if !claims[0].expired(head.Time, duration) {
+			// The output bisection of the counter claim starts past the L2 block of any devnet game, so it
+			// commits to the valid output of the block
+			output, err := c.rollup.OutputAtBlock(ctx, game.L2BlockNumber)
+			if err != nil {
+				return err
+			}
+			opts, err := c.transactOpts(ctx)
+			if err != nil {
+				return err
+			}
+			tx, err := contract.Attack(opts, common.Big0, output.OutputRoot)
+			if err != nil {
+				return fmt.Errorf("failed to attack root claim: %w", err)
+			}
+			if err := c.waitMined(ctx, tx); err != nil {
+				return err
+			}
+			c.log.Info("attacked root claim", "game", game.Address, "block", uint64(game.L2BlockNumber))
+			return nil
+		}
+		c.log.Warn("root claim of an invalid output expired uncontested", "game", game.Address)
+	}
+
+	// Claims are appended after their parents, so resolving by descending index resolves subgames first
+	for i := len(claims) - 1; i >= 0; i-- {
+		if i != 0 && claims[i].children == 0 {
+			continue
+		}
+		if !claims[i].expired(head.Time, duration) {
+			return nil
+		}
+		opts, err := c.transactOpts(ctx)
+		if err != nil {
+			return err
+		}
+		tx, err := contract.ResolveClaim(opts, big.NewInt(int64(i)))
+		if err != nil {
+			return fmt.Errorf("failed to resolve claim %d: %w", i, err)
+		}
+		if err := c.waitMined(ctx, tx); err != nil {
+			return err
+		}
+		if i > 0 {
+			claims[claims[i].parent].children--
+		}
+	}
+	opts, err := c.transactOpts(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := contract.Resolve(opts)
+	if err != nil {
+		return fmt.Errorf("failed to resolve game: %w", err)
+	}
+	if err := c.waitMined(ctx, tx); err != nil {
+		return err
+	}
+	if status, err = contract.Status(callOpts); err != nil {
+		return err
+	}
+	game.Status = GameStatus(status)
+	c.log.Info("resolved dispute game", "game", game.Address, "block", uint64(game.L2BlockNumber), "status", game.Status)
+	return nil
+}
+
+// claim is the subset of the claim data of a game needed to resolve it
+type claim struct {
+	parent    uint32
+	countered bool
+	// Packed duration and timestamp of the chess clock of the claim
+	clock    *big.Int
+	children int
+}
+
+// expired reports whether the chess clock of the claim ran out at a timestamp
+func (c claim) expired(timestamp uint64, gameDuration uint64) bool {
+	duration := new(big.Int).Rsh(c.clock, 64).Uint64()
+	start := new(big.Int).And(c.clock, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	return timestamp > start && duration+timestamp-start > gameDuration/2
+}
+
+func (c *Challenger) transactOpts(ctx context.Context) (*bind.TransactOpts, error) {
+	chainID, err := c.l1Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 chain id: %w", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(c.account.PrivateKey, chainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = ctx
+	return opts, nil
+}
+
+// waitMined waits for the inclusion of a transaction and fails if it reverted
+func (c *Challenger) waitMined(ctx context.Context, tx *types.Transaction) error {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := c.l1Client.TransactionReceipt(ctx, tx.Hash())
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return fmt.Errorf("transaction %s failed", tx.Hash())
+			}
+			return nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("failed to fetch receipt of transaction %s: %w", tx.Hash(), err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package challenger
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/proposer"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameStatusText(t *testing.T) {
+	for _, status := range []GameStatus{GameInProgress, GameChallengerWins, GameDefenderWins} {
+		text, err := status.MarshalText()
+		require.NoError(t, err)
+		var decoded GameStatus
+		require.NoError(t, decoded.UnmarshalText(text))
+		require.Equal(t, status, decoded)
+	}
+	var decoded GameStatus
+	require.Error(t, decoded.UnmarshalText([]byte("unknown")))
+}
+
+func TestChallengerPlaysDisputeGames(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000,
+		Backend: config.BackendSimulated, Proposer: true, FaultProofs: true, DisputeGameDuration: 60}
+	l1, l1RPC := simtest.StartSimulated(t, l1)
+	l2, l2RPC := simtest.StartSimulated(t, l2)
+
+	p, err := proposer.NewProposer(log.New("module", "test", "service", proposer.SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	require.NoError(t, l2RPC.Call(nil, "anvil_mine", hexutil.Uint64(25)))
+	require.NoError(t, l1RPC.Call(nil, "evm_increaseTime", hexutil.Uint64(60)))
+	require.NoError(t, l1RPC.Call(nil, "anvil_mine", hexutil.Uint64(1)))
+	proposals, err := p.ProposePending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, proposals, 2)
+
+	// A dishonest game claims a wrong output of block 15
+	client := ethclient.NewClient(l1RPC)
+	factory, err := bindings.NewDisputeGameFactory(addresses["DisputeGameFactoryProxy"], client)
+	require.NoError(t, err)
+	account, err := rollup.Proposer()
+	require.NoError(t, err)
+	opts, err := bind.NewKeyedTransactorWithChainID(account.PrivateKey, big.NewInt(900))
+	require.NoError(t, err)
+	_, err = factory.Create(opts, proposer.GameType, common.Hash{1}, common.BigToHash(big.NewInt(15)).Bytes())
+	require.NoError(t, err)
+	require.NoError(t, l1RPC.Call(nil, "anvil_mine", hexutil.Uint64(1)))
+
+	c, err := NewChallenger(log.New("module", "test", "service", SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	require.NoError(t, c.Play(context.Background()))
+	games := c.Games()
+	require.Len(t, games, 3)
+	for i, proposal := range proposals {
+		require.Equal(t, *proposal.Game, games[i].Address)
+		require.Equal(t, proposal.L2BlockNumber, games[i].L2BlockNumber)
+		require.True(t, games[i].OutputValid)
+	}
+	require.EqualValues(t, 15, games[2].L2BlockNumber)
+	require.False(t, games[2].OutputValid)
+	for _, game := range games {
+		require.Equal(t, GameInProgress, game.Status)
+	}
+
+	// The games are resolved once the clocks of their claims expire
+	require.NoError(t, l1RPC.Call(nil, "evm_increaseTime", hexutil.Uint64(60)))
+	require.NoError(t, l1RPC.Call(nil, "anvil_mine", hexutil.Uint64(1)))
+	require.NoError(t, c.Play(context.Background()))
+	games = c.Games()
+	require.Equal(t, GameDefenderWins, games[0].Status)
+	require.Equal(t, GameDefenderWins, games[1].Status)
+	require.Equal(t, GameChallengerWins, games[2].Status)
+
+	// Moving the cursor back drops the later games and checks the status of the others again
+	require.EqualValues(t, 3, c.Cursor())
+	c.SetCursor(1)
+	require.Len(t, c.Games(), 1)
+	require.NoError(t, c.Play(context.Background()))
+	games = c.Games()
+	require.Len(t, games, 3)
+	require.Equal(t, GameDefenderWins, games[0].Status)
+	require.Equal(t, GameChallengerWins, games[2].Status)
+}
@@ -0,0 +1,190 @@
+package geth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// How long eth_sendTransaction waits for automining nodes to mine a transaction
+const mineTimeout = 5 * time.Second
+
+// devAccounts derives the accounts of the anvil mnemonic funded by the genesis, the accounts whose
+// transactions eth_sendTransaction signs like anvil. The genesis funds the first accounts of the mnemonic,
+// so they are derived until one is missing from the genesis.
+func devAccounts(genesis *core.Genesis) ([]accounts.Account, error) {
+	for n := uint(defaultAccounts); ; n *= 2 {
+		accs, err := accounts.Derive(accounts.DefaultMnemonic, n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive accounts: %w", err)
+		}
+		for i, acc := range accs {
+			if _, ok := genesis.Alloc[acc.Address]; !ok {
+				return accs[:i], nil
+			}
+		}
+	}
+}
+
+// ethAccountsAPI replaces eth_accounts and eth_sendTransaction to send transactions of the accounts of
+// the anvil mnemonic, signed with their keys. On nodes with cheats, transactions of the other impersonated
+// accounts are relayed.
+type ethAccountsAPI struct {
+	node  *node.Node
+	eth   *eth.Ethereum
+	addrs []common.Address
+	keys  map[common.Address]*ecdsa.PrivateKey
+	// Both nil on nodes without them
+	producer blockProducer
+	cheats   *cheats
+}
+
+func newEthAccountsAPI(n *node.Node, backend *eth.Ethereum, accs []accounts.Account, producer blockProducer, c *cheats) *ethAccountsAPI {
+	api := &ethAccountsAPI{
+		node:     n,
+		eth:      backend,
+		addrs:    make([]common.Address, 0, len(accs)),
+		keys:     make(map[common.Address]*ecdsa.PrivateKey, len(accs)),
+		producer: producer,
+		cheats:   c,
+	}
+	for _, acc := range accs {
+		api.addrs = append(api.addrs, acc.Address)
+		api.keys[acc.Address] = acc.PrivateKey
+	}
+	return api
+}
+
+// Accounts implements eth_accounts
+func (api *ethAccountsAPI) Accounts() []common.Address {
+	return api.addrs
+}
+
+// SendTransaction implements eth_sendTransaction. Like anvil, the key of an account is used even while it
+// is impersonated.
+func (api *ethAccountsAPI) SendTransaction(ctx context.Context, args sendTxArgs) (common.Hash, error) {
+	key, ok := api.keys[args.From]
+	if !ok {
+		if api.cheats != nil && api.cheats.isImpersonated(args.From) {
+			return api.cheats.sendTransaction(args)
+		}
+		if api.cheats != nil {
+			return common.Hash{}, fmt.Errorf("unknown account %s, impersonate it with anvil_impersonateAccount", args.From)
+		}
+		return common.Hash{}, fmt.Errorf("unknown account %s", args.From)
+	}
+
+	tx, err := api.signTransaction(ctx, key, args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := api.eth.APIBackend.SendTx(ctx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if api.producer != nil && api.producer.Automine() {
+		api.waitMined(ctx, tx.Hash())
+	}
+	return tx.Hash(), nil
+}
+
+// waitMined waits for an automining node to mine the transaction, so that like with anvil its receipt is
+// available once eth_sendTransaction returns. Transactions waiting for an earlier nonce are not mined, so
+// the wait is bounded.
+func (api *ethAccountsAPI) waitMined(ctx context.Context, hash common.Hash) {
+	heads := make(chan core.ChainHeadEvent, 1)
+	sub := api.eth.BlockChain().SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+	timeout := time.NewTimer(mineTimeout)
+	defer timeout.Stop()
+	for {
+		if tx, _, _, _ := rawdb.ReadTransaction(api.eth.ChainDb(), hash); tx != nil {
+			return
+		}
+		select {
+		case <-heads:
+		case <-timeout.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// signTransaction fills the nonce, gas and fees missing from args like eth_fillTransaction and signs
+// the transaction with key
+func (api *ethAccountsAPI) signTransaction(ctx context.Context, key *ecdsa.PrivateKey, args sendTxArgs) (*types.Transaction, error) {
+	chainConfig := api.eth.BlockChain().Config()
+	head := api.eth.BlockChain().CurrentBlock()
+
+	tx := &types.DynamicFeeTx{
+		ChainID: chainConfig.ChainID,
+		Nonce:   api.eth.TxPool().Nonce(args.From),
+		To:      args.To,
+		Value:   new(big.Int),
+		Data:    args.data(),
+	}
+	if args.Nonce != nil {
+		tx.Nonce = uint64(*args.Nonce)
+	}
+	if args.Value != nil {
+		tx.Value = args.Value.ToInt()
+	}
+
+	switch {
+	case args.GasPrice != nil:
+		tx.GasTipCap, tx.GasFeeCap = args.GasPrice.ToInt(), args.GasPrice.ToInt()
+	default:
+		if args.MaxPriorityFeePerGas != nil {
+			tx.GasTipCap = args.MaxPriorityFeePerGas.ToInt()
+		} else {
+			tip, err := api.eth.APIBackend.SuggestGasTipCap(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to suggest tip: %w", err)
+			}
+			tx.GasTipCap = tip
+		}
+		if args.MaxFeePerGas != nil {
+			tx.GasFeeCap = args.MaxFeePerGas.ToInt()
+		} else {
+			// Like geth, the fee cap covers the base fee doubling before inclusion
+			baseFee := new(big.Int)
+			if head.BaseFee != nil {
+				baseFee.Set(head.BaseFee)
+			}
+			tx.GasFeeCap = new(big.Int).Add(tx.GasTipCap, baseFee.Mul(baseFee, common.Big2))
+		}
+	}
+
+	if args.Gas != nil {
+		tx.Gas = uint64(*args.Gas)
+	} else {
+		call := map[string]interface{}{"from": args.From, "to": args.To, "input": hexutil.Bytes(tx.Data)}
+		if args.Value != nil {
+			call["value"] = args.Value
+		}
+		var gas hexutil.Uint64
+		client := api.node.Attach()
+		defer client.Close()
+		if err := client.CallContext(ctx, &gas, "eth_estimateGas", call); err != nil {
+			return nil, fmt.Errorf("failed to estimate gas: %w", err)
+		}
+		tx.Gas = uint64(gas)
+	}
+
+	signed, err := types.SignNewTx(key, types.LatestSignerForChainID(chainConfig.ChainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return signed, nil
+}
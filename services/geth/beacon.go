@@ -0,0 +1,589 @@
+package geth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/beacon/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
+)
+
+// Blocks are finalized once per epoch like in the geth --dev simulated beacon
+const epochLength = 32
+
+const (
+	// How long evm_revert waits for the transaction pool to reset to the rewound head
+	poolResetTimeout  = 5 * time.Second
+	poolResetInterval = 10 * time.Millisecond
+)
+
+var (
+	// The tip threshold of the transaction pools of the nodes, which accept transactions without tip like anvil
+	poolGasTip = new(big.Int)
+	// The highest tip threshold, which drops every transaction of a pool
+	maxTip = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+)
+
+// beacon drives block production of a post-merge L1 through the engine API.
+// Blocks are mined every period seconds or, if the period is 0, as soon as a transaction arrives.
+type beacon struct {
+	log    log.Logger
+	eth    *eth.Ethereum
+	engine *catalyst.ConsensusAPI
+	period uint64
+
+	// Serializes block production of the automine loop and manual mining
+	mu sync.Mutex
+	// Seconds added to the timestamp of new blocks by evm_increaseTime
+	timeOffset uint64
+	// The timestamp of the next block set by evm_setNextBlockTimestamp, 0 if unset
+	nextTimestamp uint64
+	// Transactions included ahead of the transaction pool, e.g. those of impersonated accounts
+	forced []*types.Transaction
+	// The base fee of the next block set by anvil_setNextBlockBaseFeePerGas, nil if unset
+	nextBaseFee *big.Int
+
+	shutdownCh chan struct{}
+	wg         sync.WaitGroup
+}
+
+func newBeacon(logger log.Logger, backend *eth.Ethereum, period uint64) *beacon {
+	return &beacon{
+		log:        logger,
+		eth:        backend,
+		engine:     catalyst.NewConsensusAPI(backend),
+		period:     period,
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle
+func (b *beacon) Start() error {
+	head := b.eth.BlockChain().CurrentBlock()
+	// Transition the genesis to proof of stake
+	if head.Number.Sign() == 0 {
+		state := engine.ForkchoiceStateV1{HeadBlockHash: head.Hash(), SafeBlockHash: head.Hash(), FinalizedBlockHash: head.Hash()}
+		if _, err := b.engine.ForkchoiceUpdatedV2(state, nil); err != nil {
+			return fmt.Errorf("failed to transition to proof of stake: %w", err)
+		}
+	}
+
+	b.wg.Add(1)
+	if b.period == 0 {
+		go b.loopOnDemand()
+	} else {
+		go b.loop()
+	}
+	return nil
+}
+
+// Stop implements node.Lifecycle
+func (b *beacon) Stop() error {
+	close(b.shutdownCh)
+	b.wg.Wait()
+	return nil
+}
+
+func (b *beacon) loop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(time.Duration(b.period) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.shutdownCh:
+			return
+		case <-ticker.C:
+			if _, err := b.Mine(1); err != nil {
+				b.log.Warn("failed to mine block", "err", err)
+			}
+		}
+	}
+}
+
+func (b *beacon) loopOnDemand() {
+	defer b.wg.Done()
+	newTxs := make(chan core.NewTxsEvent)
+	sub := b.eth.TxPool().SubscribeTransactions(newTxs, true)
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case <-b.shutdownCh:
+			return
+		case <-newTxs:
+			if _, err := b.Mine(1); err != nil {
+				b.log.Warn("failed to mine block", "err", err)
+			}
+		}
+	}
+}
+
+// Mine seals blocks with the pending transactions and returns the hash of the new head
+func (b *beacon) Mine(blocks uint64) (common.Hash, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	head := b.eth.BlockChain().CurrentBlock().Hash()
+	for i := uint64(0); i < blocks; i++ {
+		hash, err := b.sealBlock()
+		if err != nil {
+			return head, err
+		}
+		head = hash
+	}
+	return head, nil
+}
+
+func (b *beacon) sealBlock() (common.Hash, error) {
+	parent := b.eth.BlockChain().CurrentBlock()
+	var random common.Hash
+	if _, err := rand.Read(random[:]); err != nil {
+		return common.Hash{}, err
+	}
+
+	// Forced transactions that don't fit into the block wait for the next one
+	var forced []*types.Transaction
+	gas := uint64(0)
+	for len(b.forced) > 0 && gas+b.forced[0].Gas() <= parent.GasLimit {
+		gas += b.forced[0].Gas()
+		forced = append(forced, b.forced[0])
+		b.forced = b.forced[1:]
+	}
+	if b.nextBaseFee != nil || len(forced) > 0 {
+		return b.assembleBlock(parent, random, forced)
+	}
+
+	payload, err := b.eth.Miner().BuildPayload(&miner.BuildPayloadArgs{
+		Parent:       parent.Hash(),
+		Timestamp:    b.timestamp(parent),
+		FeeRecipient: blockSignerAddress,
+		Random:       random,
+		Withdrawals:  types.Withdrawals{},
+		Transactions: forced,
+	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to build payload: %w", err)
+	}
+	// Resolving interrupts the block being filled from the tx pool, so wait for it to be built first
+	payload.WaitFull()
+	data := payload.ResolveFull().ExecutionPayload
+
+	status, err := b.engine.NewPayloadV2(*data)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if status.Status != engine.VALID {
+		return common.Hash{}, fmt.Errorf("invalid payload: %s", status.Status)
+	}
+
+	finalized := b.eth.BlockChain().GetBlockByNumber(data.Number - data.Number%epochLength)
+	if finalized == nil {
+		return common.Hash{}, errors.New("finalized block not found")
+	}
+	finalizedHash := finalized.Hash()
+	if data.Number%epochLength == 0 {
+		finalizedHash = data.BlockHash
+	}
+	state := engine.ForkchoiceStateV1{HeadBlockHash: data.BlockHash, SafeBlockHash: data.BlockHash, FinalizedBlockHash: finalizedHash}
+	if _, err := b.engine.ForkchoiceUpdatedV2(state, nil); err != nil {
+		return common.Hash{}, err
+	}
+	b.log.Debug("mined block", "number", data.Number, "hash", data.BlockHash, "txs", len(data.Transactions))
+	return data.BlockHash, nil
+}
+
+// assembleBlock seals the next block with the base fee set by anvil_setNextBlockBaseFeePerGas or with the
+// relayed transactions of impersonated accounts, which execute with the forwarder in place of the code of the
+// account. The engine API can do neither, so the transactions are applied and the block is written here
+// without validation, like the blocks of state cheats.
+func (b *beacon) assembleBlock(parent *types.Header, random common.Hash, forced []*types.Transaction) (common.Hash, error) {
+	chain := b.eth.BlockChain()
+	statedb, err := chain.StateAt(parent.Root)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to open state of block %d: %w", parent.Number, err)
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  types.EmptyUncleHash,
+		Coinbase:   blockSignerAddress,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       b.timestamp(parent),
+		Difficulty: common.Big0,
+		MixDigest:  random,
+		BaseFee:    b.nextBaseFee,
+	}
+	if header.BaseFee == nil {
+		header.BaseFee = eip1559.CalcBaseFee(chain.Config(), parent, header.Time)
+	}
+	b.nextBaseFee = nil
+
+	candidates := forced
+	for _, txs := range b.eth.TxPool().Pending(false) {
+		for _, tx := range txs {
+			candidates = append(candidates, tx.Resolve())
+		}
+	}
+	var (
+		txs      []*types.Transaction
+		receipts []*types.Receipt
+		logs     []*types.Log
+	)
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+	for i, tx := range candidates {
+		if tx == nil {
+			continue
+		}
+		snap := statedb.Snapshot()
+		restore := func() {}
+		if i < len(forced) {
+			if restore, err = impersonate(statedb, tx); err != nil {
+				return common.Hash{}, err
+			}
+		}
+		statedb.SetTxContext(tx.Hash(), len(txs))
+		receipt, err := core.ApplyTransaction(chain.Config(), chain, &header.Coinbase, gasPool, statedb, header, tx, &header.GasUsed, *chain.GetVMConfig())
+		if err == nil {
+			restore()
+		} else {
+			// Like the miner, transactions that cannot be included, e.g. below the base fee, are skipped
+			statedb.RevertToSnapshot(snap)
+			b.log.Debug("skipped transaction", "hash", tx.Hash(), "err", err)
+			continue
+		}
+		txs = append(txs, tx)
+		receipts = append(receipts, receipt)
+		logs = append(logs, receipt.Logs...)
+	}
+
+	block, err := chain.Engine().FinalizeAndAssemble(chain, header, statedb, txs, nil, receipts, types.Withdrawals{})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to assemble block: %w", err)
+	}
+	// The receipts and logs were created before the block hash was known
+	for _, receipt := range receipts {
+		receipt.BlockHash = block.Hash()
+		for _, l := range receipt.Logs {
+			l.BlockHash = block.Hash()
+		}
+	}
+	if _, err := chain.WriteBlockAndSetHead(block, receipts, logs, statedb, true); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to write block: %w", err)
+	}
+	b.log.Debug("mined block", "number", block.Number(), "hash", block.Hash(), "txs", len(txs), "baseFee", header.BaseFee)
+	return block.Hash(), nil
+}
+
+// Rollback removes the latest blocks of the chain. Their transactions return to the transaction pool
+func (b *beacon) Rollback(depth uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rollback(depth)
+}
+
+// Reorg replaces the latest blocks of the chain with as many new blocks and returns the hash of the new head
+func (b *beacon) Reorg(depth uint64) (common.Hash, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.rollback(depth); err != nil {
+		return common.Hash{}, err
+	}
+	head := b.eth.BlockChain().CurrentBlock().Hash()
+	for i := uint64(0); i < depth; i++ {
+		hash, err := b.sealBlock()
+		if err != nil {
+			return head, err
+		}
+		head = hash
+	}
+	b.log.Info("reorged chain", "depth", depth, "head", head)
+	return head, nil
+}
+
+func (b *beacon) rollback(depth uint64) error {
+	chain := b.eth.BlockChain()
+	head := chain.CurrentBlock().Number.Uint64()
+	if depth > head {
+		return fmt.Errorf("cannot roll back %d blocks at block %d", depth, head)
+	}
+	if err := chain.SetHead(head - depth); err != nil {
+		return fmt.Errorf("failed to roll back to block %d: %w", head-depth, err)
+	}
+	return nil
+}
+
+// Rewind resets the head of the chain to a previous block, dropping the transactions waiting to be forced
+func (b *beacon) Rewind(number uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.forced = nil
+	if err := b.eth.BlockChain().SetHead(number); err != nil {
+		return fmt.Errorf("failed to rewind to block %d: %w", number, err)
+	}
+	return nil
+}
+
+// timestamp returns the timestamp of the block after parent
+func (b *beacon) timestamp(parent *types.Header) uint64 {
+	timestamp := uint64(time.Now().Unix()) + b.timeOffset
+	if b.nextTimestamp != 0 {
+		timestamp, b.nextTimestamp = b.nextTimestamp, 0
+	}
+	if timestamp <= parent.Time {
+		timestamp = parent.Time + 1
+	}
+	return timestamp
+}
+
+// IncreaseTime adds seconds to the timestamp of new blocks and returns the total offset
+func (b *beacon) IncreaseTime(seconds uint64) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeOffset += seconds
+	return b.timeOffset
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next block. Later blocks continue from it
+func (b *beacon) SetNextBlockTimestamp(timestamp uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if head := b.eth.BlockChain().CurrentBlock(); timestamp <= head.Time {
+		return fmt.Errorf("timestamp %d is not after the head at %d", timestamp, head.Time)
+	}
+	b.nextTimestamp = timestamp
+	if now := uint64(time.Now().Unix()); timestamp > now {
+		b.timeOffset = timestamp - now
+	}
+	return nil
+}
+
+// Clock returns the time travel of the beacon
+func (b *beacon) Clock() producerClock {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return producerClock{timeOffset: b.timeOffset, nextTimestamp: b.nextTimestamp}
+}
+
+// SetClock restores the time travel of a previous Clock
+func (b *beacon) SetClock(clock producerClock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeOffset, b.nextTimestamp = clock.timeOffset, clock.nextTimestamp
+}
+
+// Automine reports whether a block is mined for every transaction
+func (b *beacon) Automine() bool {
+	return b.period == 0
+}
+
+// blockProducer builds the blocks of a node
+type blockProducer interface {
+	Mine(blocks uint64) (common.Hash, error)
+	Automine() bool
+	IncreaseTime(seconds uint64) uint64
+	SetNextBlockTimestamp(timestamp uint64) error
+	// Rewind resets the head to a previous block, which evm_revert uses to restore snapshots
+	Rewind(number uint64) error
+	// Clock and SetClock save and restore the time travel of evm_increaseTime and evm_setNextBlockTimestamp
+	Clock() producerClock
+	SetClock(clock producerClock)
+}
+
+// producerClock is the time travel of a block producer
+type producerClock struct {
+	// Seconds the clock is ahead of the wall clock
+	timeOffset uint64
+	// The timestamp of the next block, 0 if unset
+	nextTimestamp uint64
+}
+
+// reorger is implemented by block producers that can replace the latest blocks of their chain
+type reorger interface {
+	Rollback(depth uint64) error
+	Reorg(depth uint64) (common.Hash, error)
+}
+
+// mineAPI serves the mining, time and snapshot methods of anvil so the control API can mine geth chains
+type mineAPI struct {
+	producer blockProducer
+	chain    *core.BlockChain
+	pool     *txpool.TxPool
+	// The anvil cheats of the node, nil without them
+	cheats *cheats
+
+	mu           sync.Mutex
+	snapshots    map[uint64]snapshot
+	nextSnapshot uint64
+}
+
+// snapshot is the state evm_revert restores besides the state of the chain
+type snapshot struct {
+	number       uint64
+	clock        producerClock
+	impersonated map[common.Address]bool
+	// Transactions of the pool, ordered by nonce per sender
+	pool []*types.Transaction
+}
+
+// Mine implements evm_mine
+func (api *mineAPI) Mine() (common.Hash, error) {
+	return api.producer.Mine(1)
+}
+
+// IncreaseTime implements evm_increaseTime and returns the total offset of block timestamps
+func (api *mineAPI) IncreaseTime(seconds hexutil.Uint64) hexutil.Uint64 {
+	return hexutil.Uint64(api.producer.IncreaseTime(uint64(seconds)))
+}
+
+// SetNextBlockTimestamp implements evm_setNextBlockTimestamp
+func (api *mineAPI) SetNextBlockTimestamp(timestamp hexutil.Uint64) error {
+	return api.producer.SetNextBlockTimestamp(uint64(timestamp))
+}
+
+// Snapshot implements evm_snapshot, recording the head, the time travel, the impersonated accounts and the
+// transaction pool to revert to
+func (api *mineAPI) Snapshot() *hexutil.Big {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	snap := snapshot{
+		number: api.chain.CurrentBlock().Number.Uint64(),
+		clock:  api.producer.Clock(),
+	}
+	if api.cheats != nil {
+		snap.impersonated = api.cheats.impersonations()
+	}
+	pending, queued := api.pool.Content()
+	for _, txs := range []map[common.Address][]*types.Transaction{pending, queued} {
+		for _, senderTxs := range txs {
+			snap.pool = append(snap.pool, senderTxs...)
+		}
+	}
+
+	id := api.nextSnapshot
+	api.nextSnapshot++
+	api.snapshots[id] = snap
+	return (*hexutil.Big)(new(big.Int).SetUint64(id))
+}
+
+// Revert implements evm_revert, rewinding the chain to a snapshot. Like anvil, the snapshot and every later snapshot are removed.
+func (api *mineAPI) Revert(id hexutil.Big) (bool, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if !id.ToInt().IsUint64() {
+		return false, nil
+	}
+	snap, ok := api.snapshots[id.ToInt().Uint64()]
+	if !ok {
+		return false, nil
+	}
+	for snapshotID := range api.snapshots {
+		if snapshotID >= id.ToInt().Uint64() {
+			delete(api.snapshots, snapshotID)
+		}
+	}
+	if err := api.producer.Rewind(snap.number); err != nil {
+		return false, err
+	}
+	api.producer.SetClock(snap.clock)
+	if api.cheats != nil {
+		api.cheats.setImpersonations(snap.impersonated)
+	}
+	if err := api.restorePool(snap.pool); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restorePool replaces the transactions of the pool with those of a snapshot. The pool keeps no local
+// transactions, so raising the tip threshold drops every transaction. The pool resets to the rewound head
+// in the background, so transactions are added again once their nonces are no longer too low.
+func (api *mineAPI) restorePool(txs []*types.Transaction) error {
+	api.pool.SetGasTip(maxTip)
+	api.pool.SetGasTip(poolGasTip)
+
+	deadline := time.Now().Add(poolResetTimeout)
+	for _, tx := range txs {
+		for {
+			err := api.pool.Add([]*types.Transaction{tx}, false, true)[0]
+			if err == nil || errors.Is(err, txpool.ErrAlreadyKnown) {
+				break
+			}
+			if !errors.Is(err, core.ErrNonceTooLow) || time.Now().After(deadline) {
+				return fmt.Errorf("failed to restore transaction %s: %w", tx.Hash(), err)
+			}
+			time.Sleep(poolResetInterval)
+		}
+	}
+	return nil
+}
+
+// anvilAPI implements the anvil_* methods the geth backend supports
+type anvilAPI struct {
+	producer blockProducer
+}
+
+// Mine implements anvil_mine, mining a single block by default
+func (api *anvilAPI) Mine(blocks *hexutil.Uint64) error {
+	n := uint64(1)
+	if blocks != nil {
+		n = uint64(*blocks)
+	}
+	_, err := api.producer.Mine(n)
+	return err
+}
+
+// GetAutomine implements anvil_getAutomine
+func (api *anvilAPI) GetAutomine() bool {
+	return api.producer.Automine()
+}
+
+// reorgOptions are the parameters of anvil_reorg
+type reorgOptions struct {
+	Depth uint64 `json:"depth"`
+	// Transactions to include in the new blocks, which are not supported by the geth backend
+	TxBlockPairs []json.RawMessage `json:"txBlockPairs"`
+}
+
+// Reorg implements anvil_reorg, replacing the latest blocks with as many new blocks
+func (api *anvilAPI) Reorg(opts reorgOptions) error {
+	r, ok := api.producer.(reorger)
+	if !ok {
+		return errors.New("reorgs are not supported by sequenced L2s")
+	}
+	if len(opts.TxBlockPairs) > 0 {
+		return errors.New("transactions of reorgs are not supported")
+	}
+	_, err := r.Reorg(opts.Depth)
+	return err
+}
+
+// Rollback implements anvil_rollback, removing a single block by default
+func (api *anvilAPI) Rollback(depth *uint64) error {
+	r, ok := api.producer.(reorger)
+	if !ok {
+		return errors.New("rollbacks are not supported by sequenced L2s")
+	}
+	n := uint64(1)
+	if depth != nil {
+		n = *depth
+	}
+	return r.Rollback(n)
+}
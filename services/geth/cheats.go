@@ -0,0 +1,313 @@
+package geth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// cheats implements the anvil methods modifying a chain outside of its transactions for the simulated backend.
+// Sealed blocks cannot change, so state changes are committed in a new block on top of the head.
+type cheats struct {
+	beacon *beacon
+
+	mu           sync.Mutex
+	impersonated map[common.Address]bool
+}
+
+// forwarderAsm is the code of impersonated accounts during their relayed transactions
+//
+//go:embed forwarder.asm
+var forwarderAsm []byte
+
+// forwarderRuntime compiles the code of impersonated accounts during their relayed transactions
+var forwarderRuntime = sync.OnceValues(func() ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex(forwarderAsm, false))
+	code, errs := compiler.Compile()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to compile forwarder: %w", errors.Join(errs...))
+	}
+	return common.FromHex(code), nil
+})
+
+var (
+	// relayKey signs the transactions relaying the calls of impersonated accounts. The relay account is only
+	// funded while its transactions execute, with relayFeeCap covering any base fee.
+	relayKey     = mustRelayKey()
+	relayAddress = crypto.PubkeyToAddress(relayKey.PublicKey)
+	relayFeeCap  = new(big.Int).Lsh(common.Big1, 64)
+	// The original code of an impersonated account while the forwarder replaces it, see forwarder.asm
+	impersonatedCodeAddress = common.HexToAddress("0xc0de")
+)
+
+// Gas of the forwarder besides the call it forwards
+const forwarderGas = 50_000
+
+func mustRelayKey() *ecdsa.PrivateKey {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("mocktimism impersonation relay")))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newCheats(b *beacon) *cheats {
+	return &cheats{
+		beacon:       b,
+		impersonated: make(map[common.Address]bool),
+	}
+}
+
+// commitState mines a block without transactions whose state is the state of the head modified by modify
+func (c *cheats) commitState(modify func(*state.StateDB)) error {
+	b := c.beacon
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chain := b.eth.BlockChain()
+	parent := chain.CurrentBlock()
+	statedb, err := chain.StateAt(parent.Root)
+	if err != nil {
+		return fmt.Errorf("failed to open state of block %d: %w", parent.Number, err)
+	}
+	modify(statedb)
+
+	var random common.Hash
+	if _, err := rand.Read(random[:]); err != nil {
+		return err
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  types.EmptyUncleHash,
+		Coinbase:   blockSignerAddress,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       b.timestamp(parent),
+		Difficulty: common.Big0,
+		MixDigest:  random,
+	}
+	header.BaseFee = eip1559.CalcBaseFee(chain.Config(), parent, header.Time)
+	if b.nextBaseFee != nil {
+		header.BaseFee, b.nextBaseFee = b.nextBaseFee, nil
+	}
+	block, err := chain.Engine().FinalizeAndAssemble(chain, header, statedb, nil, nil, nil, types.Withdrawals{})
+	if err != nil {
+		return fmt.Errorf("failed to assemble block: %w", err)
+	}
+	if _, err := chain.WriteBlockAndSetHead(block, nil, nil, statedb, true); err != nil {
+		return fmt.Errorf("failed to write block: %w", err)
+	}
+	b.log.Debug("committed state", "number", block.Number(), "hash", block.Hash())
+	return nil
+}
+
+// isImpersonated reports whether anvil_impersonateAccount impersonates addr
+func (c *cheats) isImpersonated(addr common.Address) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.impersonated[addr]
+}
+
+// impersonations returns a copy of the impersonated accounts
+func (c *cheats) impersonations() map[common.Address]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	impersonated := make(map[common.Address]bool, len(c.impersonated))
+	for addr := range c.impersonated {
+		impersonated[addr] = true
+	}
+	return impersonated
+}
+
+// setImpersonations replaces the impersonated accounts with those of impersonations
+func (c *cheats) setImpersonations(impersonated map[common.Address]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.impersonated = make(map[common.Address]bool, len(impersonated))
+	for addr := range impersonated {
+		c.impersonated[addr] = true
+	}
+}
+
+// sendTransaction includes a transaction of an impersonated account in the next block. Without its key,
+// the relay account sends a transaction to the impersonated account, which runs the forwarder for the
+// transaction to make the call of args itself: contracts see the impersonated account as msg.sender, but
+// the relay account is tx.origin and the sender in the receipt.
+func (c *cheats) sendTransaction(args sendTxArgs) (common.Hash, error) {
+	b := c.beacon
+	tx, err := c.forceRelayed(args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if b.Automine() {
+		if _, err := b.Mine(1); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	return tx.Hash(), nil
+}
+
+// forceRelayed signs the relayed transaction of args and queues it for the next block
+func (c *cheats) forceRelayed(args sendTxArgs) (*types.Transaction, error) {
+	b := c.beacon
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chain := b.eth.BlockChain()
+	head := chain.CurrentBlock()
+	statedb, err := chain.StateAt(head.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state of block %d: %w", head.Number, err)
+	}
+	gas := head.GasLimit
+	if args.Gas != nil {
+		// The forwarder passes at most 63/64 of its gas to the call
+		gas = min(uint64(*args.Gas)*64/63+forwarderGas, head.GasLimit)
+	}
+	tx, err := types.SignNewTx(relayKey, types.LatestSignerForChainID(chain.Config().ChainID), &types.DynamicFeeTx{
+		ChainID: chain.Config().ChainID,
+		// Relayed transactions are included in order, so the ones waiting take the next nonces
+		Nonce:     statedb.GetNonce(relayAddress) + uint64(len(b.forced)),
+		GasTipCap: new(big.Int),
+		GasFeeCap: relayFeeCap,
+		Gas:       gas,
+		To:        &args.From,
+		Data:      forwardCall(args),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign relayed transaction: %w", err)
+	}
+	b.forced = append(b.forced, tx)
+	return tx, nil
+}
+
+// forwardCall encodes the call of args for the forwarder: the target, the value and whether the
+// transaction creates a contract, followed by the call data
+func forwardCall(args sendTxArgs) []byte {
+	data := make([]byte, 0x60+len(args.data()))
+	if args.To != nil {
+		copy(data[12:32], args.To.Bytes())
+	} else {
+		data[0x5f] = 1
+	}
+	if args.Value != nil {
+		args.Value.ToInt().FillBytes(data[0x20:0x40])
+	}
+	copy(data[0x60:], args.data())
+	return data
+}
+
+// impersonate prepares statedb for a relayed transaction: the impersonated account runs the forwarder,
+// its own code moves to impersonatedCodeAddress, and the relay account can pay for the gas.
+// The returned function restores the state once the transaction executed.
+func impersonate(statedb *state.StateDB, tx *types.Transaction) (func(), error) {
+	forwarder, err := forwarderRuntime()
+	if err != nil {
+		return nil, err
+	}
+	from := *tx.To()
+	code := statedb.GetCode(from)
+	statedb.SetCode(impersonatedCodeAddress, code)
+	statedb.SetCode(from, forwarder)
+	statedb.SetBalance(relayAddress, new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap()))
+	return func() {
+		statedb.SetCode(from, code)
+		statedb.SetCode(impersonatedCodeAddress, nil)
+		statedb.SetBalance(relayAddress, new(big.Int))
+	}, nil
+}
+
+// sendTxArgs are the eth_sendTransaction arguments. Relayed transactions of impersonated accounts
+// ignore the nonce and fees.
+type sendTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	Value                *hexutil.Big    `json:"value"`
+	Data                 *hexutil.Bytes  `json:"data"`
+	Input                *hexutil.Bytes  `json:"input"`
+}
+
+func (args sendTxArgs) data() []byte {
+	if args.Input != nil {
+		return *args.Input
+	}
+	if args.Data != nil {
+		return *args.Data
+	}
+	return nil
+}
+
+// anvilCheatAPI implements the anvil_* cheat methods of the simulated backend
+type anvilCheatAPI struct {
+	cheats *cheats
+}
+
+// SetBalance implements anvil_setBalance
+func (api *anvilCheatAPI) SetBalance(addr common.Address, balance hexutil.Big) error {
+	return api.cheats.commitState(func(statedb *state.StateDB) {
+		statedb.SetBalance(addr, balance.ToInt())
+	})
+}
+
+// SetCode implements anvil_setCode
+func (api *anvilCheatAPI) SetCode(addr common.Address, code hexutil.Bytes) error {
+	return api.cheats.commitState(func(statedb *state.StateDB) {
+		statedb.SetCode(addr, code)
+	})
+}
+
+// SetNonce implements anvil_setNonce
+func (api *anvilCheatAPI) SetNonce(addr common.Address, nonce hexutil.Uint64) error {
+	return api.cheats.commitState(func(statedb *state.StateDB) {
+		statedb.SetNonce(addr, uint64(nonce))
+	})
+}
+
+// SetStorageAt implements anvil_setStorageAt
+func (api *anvilCheatAPI) SetStorageAt(addr common.Address, slot common.Hash, value common.Hash) (bool, error) {
+	err := api.cheats.commitState(func(statedb *state.StateDB) {
+		statedb.SetState(addr, slot, value)
+	})
+	return err == nil, err
+}
+
+// ImpersonateAccount implements anvil_impersonateAccount
+func (api *anvilCheatAPI) ImpersonateAccount(addr common.Address) {
+	api.cheats.mu.Lock()
+	defer api.cheats.mu.Unlock()
+	api.cheats.impersonated[addr] = true
+}
+
+// StopImpersonatingAccount implements anvil_stopImpersonatingAccount
+func (api *anvilCheatAPI) StopImpersonatingAccount(addr common.Address) {
+	api.cheats.mu.Lock()
+	defer api.cheats.mu.Unlock()
+	delete(api.cheats.impersonated, addr)
+}
+
+// SetNextBlockBaseFeePerGas implements anvil_setNextBlockBaseFeePerGas. Later blocks derive their
+// EIP-1559 base fee from it.
+func (api *anvilCheatAPI) SetNextBlockBaseFeePerGas(baseFee hexutil.Big) {
+	b := api.cheats.beacon
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextBaseFee = new(big.Int).Set(baseFee.ToInt())
+}
@@ -0,0 +1,65 @@
+;; Code of an impersonated account while a relayed transaction of it executes, compiled with core/asm.
+;;
+;; The relay account calls it with the target in the first word of the calldata, the value in the second,
+;; 1 in the third for contract creations and the call data of the transaction after them. Calls from other
+;; accounts run the original code of the account, which is kept at 0xc0de for the transaction.
+
+    caller
+    origin
+    eq
+    jumpi @forward
+    calldatasize
+    push 0
+    push 0
+    calldatacopy
+    push 0
+    push 0
+    calldatasize
+    push 0
+    push 0xc0de
+    gas
+    delegatecall
+result:
+    returndatasize
+    push 0
+    push 0
+    returndatacopy
+    jumpi @return
+    returndatasize
+    push 0
+    revert
+return:
+    returndatasize
+    push 0
+    return
+
+forward:
+    push 0x60
+    calldatasize
+    sub
+    dup1
+    push 0x60
+    push 0
+    calldatacopy
+    push 0x40
+    calldataload
+    jumpi @create
+    push 0
+    push 0
+    dup3
+    push 0
+    push 0x20
+    calldataload
+    push 0
+    calldataload
+    gas
+    call
+    jump @result
+create:
+    push 0
+    push 0x20
+    calldataload
+    create
+    iszero
+    iszero
+    jump @result
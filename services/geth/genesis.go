@@ -0,0 +1,169 @@
+package geth
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Same default number and balance in ether of funded accounts and block gas limit as anvil
+const (
+	defaultAccounts = 10
+	defaultBalance  = 10000
+	defaultGasLimit = 30_000_000
+)
+
+// The implementations of the predeploys are deployed at 0xc0d3..<last 2 bytes of the predeploy>
+const codeNamespace = "0xc0d3c0d3c0d3c0d3c0d3c0d3c0d3c0d3c0d30000"
+
+// EIP-1967 slots of the implementation and admin of a proxy
+var (
+	implementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	adminSlot          = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103")
+)
+
+// Defaults of the op-node devnet configuration
+const (
+	eip1559Elasticity        = 6
+	eip1559Denominator       = 50
+	eip1559DenominatorCanyon = 250
+)
+
+// NewGenesis returns the dev genesis of a chain. Like anvil, the first accounts of the default
+// mnemonic are funded with balance ether each, 10000 by default. L1s also include the generated OP contracts,
+// L2s start now with the L1Block predeploy and activate their hardforks relative to the genesis.
+// Simulated L2s are plain dev chains like anvil L2s, as deposits are relayed to them.
+func NewGenesis(chain config.Chain) (*core.Genesis, error) {
+	chainConfig := *params.AllDevChainProtocolChanges
+	chainConfig.ChainID = new(big.Int).SetUint64(uint64(chain.EffectiveChainID()))
+	opGeth := chain.IsL2() && chain.Backend != config.BackendSimulated
+	var timestamp uint64
+	if opGeth {
+		// L2 blocks follow the genesis at a fixed interval, so the chain has to start at the current time
+		timestamp = uint64(time.Now().Unix())
+		chainConfig.BedrockBlock = big.NewInt(0)
+		setHardforks(&chainConfig, chain.Hardforks, timestamp)
+		chainConfig.Optimism = &params.OptimismConfig{
+			EIP1559Elasticity:        eip1559Elasticity,
+			EIP1559Denominator:       eip1559Denominator,
+			EIP1559DenominatorCanyon: eip1559DenominatorCanyon,
+		}
+	}
+
+	baseFee := big.NewInt(params.InitialBaseFee)
+	if chain.BlockBaseFeePerGas != 0 {
+		baseFee = new(big.Int).SetUint64(uint64(chain.BlockBaseFeePerGas))
+	}
+
+	alloc := core.GenesisAlloc{}
+	if !chain.IsL2() {
+		allocs, err := generated.AllocsL1()
+		if err != nil {
+			return nil, err
+		}
+		alloc = allocs
+		if err := resetResourceMetering(alloc); err != nil {
+			return nil, err
+		}
+	} else if opGeth {
+		// The L1 info deposit of every block sets the L1 values read by the L1 fee of transactions. Like
+		// the predeploys of the OP Stack, L1Block and GasPriceOracle are proxies, which Ecotone upgrades
+		allocProxy(alloc, predeploys.L1BlockAddr, bindings.L1BlockDeployedBin)
+		allocProxy(alloc, predeploys.GasPriceOracleAddr, bindings.GasPriceOracleDeployedBin)
+	}
+	// Precompiles are funded like in the geth --dev genesis
+	for i := byte(1); i <= 9; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		if _, ok := alloc[addr]; !ok {
+			alloc[addr] = core.GenesisAccount{Balance: big.NewInt(1)}
+		}
+	}
+
+	n := chain.Accounts
+	if n == 0 {
+		n = defaultAccounts
+	}
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive accounts: %w", err)
+	}
+	ether := uint64(chain.Balance)
+	if ether == 0 {
+		ether = defaultBalance
+	}
+	balance := new(big.Int).Mul(new(big.Int).SetUint64(ether), big.NewInt(params.Ether))
+	gasLimit := uint64(chain.GasLimit)
+	if gasLimit == 0 {
+		gasLimit = defaultGasLimit
+	}
+	for _, acc := range accs {
+		// Keep the nonce, code and storage of accounts used by the deployment
+		account := alloc[acc.Address]
+		account.Balance = balance
+		alloc[acc.Address] = account
+	}
+
+	return &core.Genesis{
+		Config:     &chainConfig,
+		Timestamp:  timestamp,
+		GasLimit:   gasLimit,
+		BaseFee:    baseFee,
+		Difficulty: big.NewInt(0),
+		Alloc:      alloc,
+	}, nil
+}
+
+// setHardforks sets the activation of the hardforks of an L2 with the given genesis timestamp in its chain config
+func setHardforks(chainConfig *params.ChainConfig, hardforks config.Hardforks, genesisTime uint64) {
+	chainConfig.RegolithTime = hardforks.RegolithTime(genesisTime)
+	chainConfig.CanyonTime = hardforks.CanyonTime(genesisTime)
+	chainConfig.EcotoneTime = hardforks.EcotoneTime(genesisTime)
+	// Shanghai only activates on L2s with Canyon, and Cancun with Ecotone
+	chainConfig.ShanghaiTime = chainConfig.CanyonTime
+	chainConfig.CancunTime = chainConfig.EcotoneTime
+}
+
+// allocProxy allocates a predeploy as a proxy administered by the ProxyAdmin predeploy, whose implementation
+// is deployed at the code namespace address of the predeploy
+func allocProxy(alloc core.GenesisAlloc, addr common.Address, deployedBin string) {
+	impl := common.HexToAddress(codeNamespace)
+	copy(impl[18:], addr[18:])
+	alloc[addr] = core.GenesisAccount{
+		Code: common.FromHex(bindings.ProxyDeployedBin),
+		Storage: map[common.Hash]common.Hash{
+			implementationSlot: common.BytesToHash(impl.Bytes()),
+			adminSlot:          common.BytesToHash(predeploys.ProxyAdminAddr.Bytes()),
+		},
+		Balance: big.NewInt(0),
+	}
+	alloc[impl] = core.GenesisAccount{Code: common.FromHex(deployedBin), Balance: big.NewInt(0)}
+}
+
+// resetResourceMetering moves the last metered block of the OptimismPortal to the genesis.
+// The allocs were dumped a few blocks into the deployment, and deposits revert until the chain
+// reaches that block because the portal subtracts it from the current block number.
+func resetResourceMetering(alloc core.GenesisAlloc) error {
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return err
+	}
+	portal, ok := alloc[addresses["OptimismPortalProxy"]]
+	if !ok {
+		return fmt.Errorf("OptimismPortalProxy missing from the L1 allocs")
+	}
+	// ResourceParams{prevBaseFee uint128, prevBoughtGas uint64, prevBlockNum uint64} of slot 1
+	slot := common.BigToHash(big.NewInt(1))
+	resourceParams := portal.Storage[slot]
+	copy(resourceParams[:8], make([]byte, 8))
+	portal.Storage[slot] = resourceParams
+	return nil
+}
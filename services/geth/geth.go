@@ -2,49 +2,149 @@ package geth
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 
+	"github.com/ethereum-optimism/mocktimism/config"
 	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type GethConfig struct {
+	// Directory the chain is persisted to. A temporary directory removed on Close is used if empty
 	DataDir   string
 	Verbosity int
-	HTTPPort  int
-	OpGeth    bool
+	Host      string
+	// A free port is used if 0, which Port reports once the node started
+	HTTPPort int
+	// Websockets are served on the HTTP port if 0
+	WSPort int
+	// Serves the JWT authenticated engine API on AuthPort, a free port if 0
+	Engine   bool
+	AuthPort int
+	// Origins allowed by CORS and for websocket connections
+	CORS   []string
+	OpGeth bool
+	// Seconds between blocks. If 0 an L1 mines a block for every transaction
+	BlockTime uint64
+	// Builds the blocks of an L2, which has no blocks beyond its genesis without it
+	Sequencer *SequencerConfig
+	// Serves the anvil cheat methods of the simulated backend and keeps the genesis gas limit like anvil.
+	// Only supported by nodes mining their own blocks
+	Cheats bool
 }
 
+var (
+	SERVICE_TYPE = "geth"
+)
+
 type Geth struct {
+	id     string
 	log    log.Logger
 	config GethConfig
 
-	node *node.Node
-	eth  *eth.Ethereum
+	node    *node.Node
+	eth     *eth.Ethereum
+	dataDir string
+	// The data directory is temporary and removed on Close
+	ephemeral bool
+	// Builds the blocks of the node, nil for L2s without a sequencer
+	producer blockProducer
+
+	// The ports the node listens on, which are only known once started for free ports
+	mu       sync.Mutex
+	httpPort int
+	wsPort   int
+	authPort int
 }
 
-var (
-	blockSignerAddress = common.Address{}
-	gasCeilL1          = uint64(8000000)
-	testJwtSecret      = "688f5d737bad920bdfb2fc2f488d6b6209eebda1dae949a8de91398d932c517a"
-)
+var blockSignerAddress = common.Address{}
+
+// The transaction pools keep no local transactions, which their tip threshold would not apply to, so evm_revert
+// can drop every transaction. The pools start with the poolGasTip threshold of the unset price limit.
+var txPoolConfig = legacypool.Config{NoLocals: true}
+
+// JWTSecret authenticates the engine API of every geth node
+const JWTSecret = "0x688f5d737bad920bdfb2fc2f488d6b6209eebda1dae949a8de91398d932c517a"
+
+func validateConfig(cfg GethConfig) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	return nil
+}
 
 func NewGeth(name string, logger log.Logger, cfg GethConfig, genesis *core.Genesis, isL2 bool) (*Geth, error) {
-	// TODO we can delete handling genesis being nil once genesis is implemented https://github.com/ethereum-optimism/mocktimism/issues/86
-	if genesis != nil {
-		genesis = core.DefaultGenesisBlock()
+	if genesis == nil {
+		return nil, fmt.Errorf("genesis is required")
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	dataDir, ephemeral := cfg.DataDir, cfg.DataDir == ""
+	if ephemeral {
+		dir, err := os.MkdirTemp("", "mocktimism-geth-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+		dataDir = dir
+	} else if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	cleanup := func() {
+		if ephemeral {
+			os.RemoveAll(dataDir)
+		}
+	}
+
+	genesis, err := loadGenesis(dataDir, genesis)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	var seqCfg SequencerConfig
+	if isL2 && cfg.Sequencer != nil {
+		seqCfg = *cfg.Sequencer
+		if seqCfg.Hardforks, err = loadHardforks(dataDir, seqCfg.Hardforks); err != nil {
+			cleanup()
+			return nil, err
+		}
+		// Hardforks scheduled at runtime are not part of the stored genesis
+		chainConfig := *genesis.Config
+		setHardforks(&chainConfig, seqCfg.Hardforks, genesis.Timestamp)
+		scheduled := *genesis
+		scheduled.Config = &chainConfig
+		genesis = &scheduled
+	}
+
+	// Keep the gas limit of the genesis instead of moving blocks towards a default. Like geth, a genesis
+	// stored without gas limit has the default one.
+	gasCeil := genesis.GasLimit
+	if gasCeil == 0 {
+		gasCeil = params.GenesisGasLimit
 	}
 
 	var ethCfg *ethconfig.Config
@@ -58,6 +158,7 @@ func NewGeth(name string, logger log.Logger, cfg GethConfig, genesis *core.Genes
 			SyncMode:  downloader.FullSync,
 			// Warning archive node is required or else trie nodes are pruned within minutes of starting devnet
 			NoPruning: true,
+			TxPool:    txPoolConfig,
 			Miner: miner.Config{
 				// l2 shouldn't mine
 				Etherbase:         common.Address{},
@@ -78,38 +179,53 @@ func NewGeth(name string, logger log.Logger, cfg GethConfig, genesis *core.Genes
 			SyncMode:  downloader.FullSync,
 			// Warning archive node is required or else trie nodes are pruned within minutes of starting devnet
 			NoPruning: true,
+			TxPool:    txPoolConfig,
 			Miner: miner.Config{
 				Etherbase:         blockSignerAddress,
 				ExtraData:         nil,
 				Recommit:          0,
 				NewPayloadTimeout: 0,
-				GasCeil:           gasCeilL1,
+				GasCeil:           gasCeil,
 				GasFloor:          0,
 				GasPrice:          big.NewInt(1),
 			},
 		}
 	}
 
+	if cfg.Cheats && isL2 {
+		cleanup()
+		return nil, fmt.Errorf("cheats are not supported by L2 nodes")
+	}
+
+	// The node reads the JWT secret from a file
+	jwtSecretPath := filepath.Join(dataDir, "jwtsecret")
+	if err := os.WriteFile(jwtSecretPath, []byte(JWTSecret), 0o600); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write JWT secret: %w", err)
+	}
+
 	nodeCfg := &node.Config{
-		Name: formattedName,
-		// TODO hardcoded these need to read the chain config instead
-		HTTPHost:    "127.0.0.1",
-		HTTPPort:    cfg.HTTPPort,
-		WSHost:      "127.0.0.1",
-		WSPort:      0,
-		AuthAddr:    "127.0.0.1",
-		AuthPort:    0,
-		HTTPModules: []string{"debug", "admin", "eth", "txpool", "net", "rpc", "web3", "personal", "engine"},
-		WSModules:   []string{"debug", "admin", "eth", "txpool", "net", "rpc", "web3", "personal", "engine"},
-		// TODO I think we don't need this but leaving this here for now for reference. Delete if not needed when cleaning up
-		// WSPort: cfg.WSPort,
-		DataDir: cfg.DataDir,
+		Name:     formattedName,
+		HTTPHost: cfg.Host,
+		HTTPPort: cfg.HTTPPort,
+		HTTPCors: cfg.CORS,
+		// Devnets are only served locally
+		HTTPVirtualHosts: []string{"*"},
+		WSHost:           cfg.Host,
+		WSPort:           cfg.wsPort(),
+		WSOrigins:        cfg.CORS,
+		AuthAddr:         cfg.Host,
+		AuthPort:         cfg.AuthPort,
+		AuthVirtualHosts: []string{"*"},
+		HTTPModules:      []string{"debug", "admin", "eth", "txpool", "net", "rpc", "web3", "personal", "evm", "anvil"},
+		WSModules:        []string{"debug", "admin", "eth", "txpool", "net", "rpc", "web3", "personal", "evm", "anvil"},
+		DataDir:          dataDir,
 		P2P: p2p.Config{
 			NoDiscovery: true,
 			// For OP devnet max peers for l2 is 0 and 1 for l1. I don't think this matters though
 			MaxPeers: 1,
 		},
-		JWTSecret: testJwtSecret,
+		JWTSecret: jwtSecretPath,
 	}
 
 	n, err := node.New(nodeCfg)
@@ -117,40 +233,241 @@ func NewGeth(name string, logger log.Logger, cfg GethConfig, genesis *core.Genes
 	// TODO e2e utils call n.Merger().FinalizePos(). I don't think we need this. Delete this comment if not needed.
 	// e2e utils also run a fakePos via l1Node.RegisterLifecycle. This I also do not believe we need.
 	if err != nil {
+		cleanup()
 		return nil, err
 	}
 
 	backend, err := eth.New(n, ethCfg)
 	if err != nil {
+		n.Close()
+		cleanup()
 		return nil, err
 	}
 
-	// e2e utils call catalyst.Register(l2Node, backend) on the l2 node to enable engine api.
-	// I don't think we need this because we have engine enabled in HTTPModules but leaving this note here just in case
-	return &Geth{
-		log:    logger,
-		config: cfg,
-		node:   n,
-		eth:    backend,
-	}, nil
+	// eth_getLogs and the other filter methods are not part of the eth backend
+	filterSystem := filters.NewFilterSystem(backend.APIBackend, filters.Config{LogCacheSize: ethCfg.FilterLogCacheSize})
+	n.RegisterAPIs([]rpc.API{{Namespace: "eth", Service: filters.NewFilterAPI(filterSystem, false)}})
+
+	if cfg.Engine {
+		if err := catalyst.Register(n, backend); err != nil {
+			n.Close()
+			cleanup()
+			return nil, fmt.Errorf("failed to register engine API: %w", err)
+		}
+	}
+
+	g := &Geth{
+		id:        name,
+		log:       logger,
+		config:    cfg,
+		node:      n,
+		eth:       backend,
+		dataDir:   dataDir,
+		ephemeral: ephemeral,
+		httpPort:  cfg.HTTPPort,
+		wsPort:    cfg.wsPort(),
+		authPort:  cfg.AuthPort,
+	}
+	devAccs, err := devAccounts(genesis)
+	if err != nil {
+		g.Close()
+		return nil, err
+	}
+	var c *cheats
+	if !isL2 {
+		b := newBeacon(logger, backend, cfg.BlockTime)
+		n.RegisterLifecycle(b)
+		g.producer = b
+		if cfg.Cheats {
+			c = newCheats(b)
+			n.RegisterAPIs([]rpc.API{{Namespace: "anvil", Service: &anvilCheatAPI{c}}})
+		}
+	} else if cfg.Sequencer != nil {
+		seq, err := newSequencer(logger, backend, seqCfg, cfg.BlockTime, hardforksPath(dataDir))
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		n.RegisterLifecycle(seq)
+		n.RegisterAPIs([]rpc.API{{Namespace: "admin", Service: &adminAPI{seq}}})
+		g.producer = seq
+	}
+	// Registered after the eth backend to replace its eth_accounts and eth_sendTransaction
+	n.RegisterAPIs([]rpc.API{{Namespace: "eth", Service: newEthAccountsAPI(n, backend, devAccs, g.producer, c)}})
+	if g.producer != nil {
+		n.RegisterAPIs([]rpc.API{
+			{Namespace: "evm", Service: &mineAPI{producer: g.producer, chain: backend.BlockChain(), pool: backend.TxPool(), cheats: c, snapshots: make(map[uint64]snapshot)}},
+			{Namespace: "anvil", Service: &anvilAPI{g.producer}},
+		})
+	}
+	return g, nil
 }
 
-func (s *Geth) Start(ctx context.Context) error {
-	err := s.node.Start()
+func (cfg GethConfig) wsPort() int {
+	if cfg.WSPort == 0 {
+		return cfg.HTTPPort
+	}
+	return cfg.WSPort
+}
+
+// loadGenesis returns the genesis a data directory was initialized with and stores the genesis of new data directories.
+// A restarted chain keeps its genesis even if a new one, e.g. with a later timestamp, is generated.
+func loadGenesis(dataDir string, genesis *core.Genesis) (*core.Genesis, error) {
+	path := filepath.Join(dataDir, "genesis.json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err := json.Marshal(genesis)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode genesis: %w", err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write genesis: %w", err)
+		}
+		return genesis, nil
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read genesis: %w", err)
+	}
+	var stored core.Genesis
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode genesis %s: %w", path, err)
 	}
+	return &stored, nil
+}
 
-	go func() {
-		<-ctx.Done()
-		s.Close()
-	}()
+func hardforksPath(dataDir string) string {
+	return filepath.Join(dataDir, "hardforks.json")
+}
 
+// loadHardforks returns the hardforks a sequencer last ran with in a data directory, including the hardforks
+// scheduled at runtime, and stores the hardforks of new data directories
+func loadHardforks(dataDir string, hardforks config.Hardforks) (config.Hardforks, error) {
+	path := hardforksPath(dataDir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return hardforks, writeHardforks(path, hardforks)
+	}
+	if err != nil {
+		return config.Hardforks{}, fmt.Errorf("failed to read hardforks: %w", err)
+	}
+	var stored config.Hardforks
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return config.Hardforks{}, fmt.Errorf("failed to decode hardforks %s: %w", path, err)
+	}
+	return stored, nil
+}
+
+func writeHardforks(path string, hardforks config.Hardforks) error {
+	data, err := json.Marshal(hardforks)
+	if err != nil {
+		return fmt.Errorf("failed to encode hardforks: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write hardforks: %w", err)
+	}
 	return nil
 }
 
+// DataDir returns the directory the chain is stored in
+func (s *Geth) DataDir() string {
+	return s.dataDir
+}
+
+func (s *Geth) Hostname() string {
+	return s.config.Host
+}
+
+// Port returns the HTTP port of the node, which is 0 for a free port until the node started
+func (s *Geth) Port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.httpPort
+}
+
+// AuthPort returns the port of the engine API, which is 0 for a free port until the node started
+func (s *Geth) AuthPort() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authPort
+}
+
+func (s *Geth) ServiceType() string {
+	return SERVICE_TYPE
+}
+
+func (s *Geth) ID() string {
+	return s.id
+}
+
+// Config returns the endpoints of the node
+func (s *Geth) Config() interface{} {
+	return s.Endpoints()
+}
+
+// Endpoints returns the http, ws and, if enabled, auth endpoints of the node
+func (s *Geth) Endpoints() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := map[string]string{
+		"http": fmt.Sprintf("http://%s:%d", s.config.Host, s.httpPort),
+		"ws":   fmt.Sprintf("ws://%s:%d", s.config.Host, s.wsPort),
+	}
+	if s.config.Engine {
+		endpoints["auth"] = fmt.Sprintf("http://%s:%d", s.config.Host, s.authPort)
+	}
+	return endpoints
+}
+
+// Start runs the node until the context is canceled
+func (s *Geth) Start(ctx context.Context) error {
+	s.log.Info("Starting geth...")
+	if err := s.node.Start(); err != nil {
+		return fmt.Errorf("failed to start geth: %w", err)
+	}
+	s.mu.Lock()
+	s.httpPort = endpointPort(s.node.HTTPEndpoint())
+	s.wsPort = endpointPort(s.node.WSEndpoint())
+	if s.config.Engine {
+		s.authPort = endpointPort(s.node.HTTPAuthEndpoint())
+	}
+	s.mu.Unlock()
+	s.log.Info("Started geth", "http", s.node.HTTPEndpoint(), "ws", s.node.WSEndpoint(), "auth", s.Endpoints()["auth"])
+
+	<-ctx.Done()
+	if err := s.Close(); err != nil {
+		s.log.Error("failed to close geth", "err", err)
+		return err
+	}
+	s.log.Info("Geth terminated normally")
+	return nil
+}
+
+// endpointPort returns the port of the URL of a listening endpoint of the node
+func endpointPort(endpoint string) int {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+// Mine mines blocks and returns the hash of the new head
+func (s *Geth) Mine(blocks uint64) (common.Hash, error) {
+	if s.producer == nil {
+		return common.Hash{}, fmt.Errorf("node %s does not produce blocks", s.id)
+	}
+	return s.producer.Mine(blocks)
+}
+
 func (s *Geth) Close() error {
-	return s.node.Close()
+	err := s.node.Close()
+	if s.ephemeral {
+		if rmErr := os.RemoveAll(s.dataDir); rmErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove data directory: %w", rmErr)
+		}
+	}
+	return err
 }
 
 func (s *Geth) HealthCheck() (bool, error) {
@@ -169,8 +486,7 @@ func (s *Geth) HealthCheck() (bool, error) {
 }
 
 func (s *Geth) GetClient() (*rpc.Client, error) {
-	httpEndpoint := fmt.Sprintf("http://0.0.0.0:%d", s.config.HTTPPort) // Assuming the node is running locally, hence using 0.0.0.0
-	client, err := rpc.Dial(httpEndpoint)
+	client, err := rpc.Dial(s.Endpoints()["http"])
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial RPC: %w", err)
 	}
@@ -0,0 +1,934 @@
+package geth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	servicediscovery "github.com/ethereum-optimism/mocktimism/service-discovery"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	opeth "github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGethService(t *testing.T) {
+	cfg := GethConfig{Host: "127.0.0.1", Engine: true, CORS: []string{"*"}}
+	genesis, err := NewGenesis(config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000})
+	require.NoError(t, err)
+	service, err := NewGeth("L1", log.New("module", "test"), cfg, genesis, false)
+	require.NoError(t, err)
+	_, err = NewGeth("L1", log.New("module", "test"), cfg, nil, false)
+	require.Error(t, err)
+	_, err = NewGeth("L1", log.New("module", "test"), GethConfig{HTTPPort: 8545}, genesis, false)
+	require.Error(t, err)
+
+	var _ servicediscovery.Service = service
+	require.Equal(t, "L1", service.ID())
+	require.Equal(t, SERVICE_TYPE, service.ServiceType())
+	require.Equal(t, "127.0.0.1", service.Hostname())
+	// Free ports are only known once the node listens on them
+	require.Zero(t, service.Port())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- service.Start(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		healthy, _ := service.HealthCheck()
+		return healthy
+	}, 5*time.Second, 100*time.Millisecond)
+	require.NotZero(t, service.Port())
+	require.NotZero(t, service.AuthPort())
+	endpoints := map[string]string{
+		"http": fmt.Sprintf("http://127.0.0.1:%d", service.Port()),
+		"ws":   fmt.Sprintf("ws://127.0.0.1:%d", service.Port()),
+		"auth": fmt.Sprintf("http://127.0.0.1:%d", service.AuthPort()),
+	}
+	require.Equal(t, endpoints, service.Config())
+
+	client, err := service.GetClient()
+	require.NoError(t, err)
+	defer client.Close()
+	var chainID hexutil.Uint64
+	require.NoError(t, client.Call(&chainID, "eth_chainId"))
+	require.Equal(t, uint64(900), uint64(chainID))
+	var balance hexutil.Big
+	require.NoError(t, client.Call(&balance, "eth_getBalance", common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), "latest"))
+	require.Equal(t, "1000000000000000000000", balance.ToInt().String())
+
+	// Websockets share the http port
+	wsClient, err := rpc.Dial(endpoints["ws"])
+	require.NoError(t, err)
+	defer wsClient.Close()
+	require.NoError(t, wsClient.Call(&chainID, "eth_chainId"))
+
+	// The engine API requires the JWT secret
+	var capabilities []string
+	unauthenticated, err := rpc.Dial(endpoints["auth"])
+	require.NoError(t, err)
+	defer unauthenticated.Close()
+	require.Error(t, unauthenticated.Call(&capabilities, "engine_exchangeCapabilities", []string{}))
+	authClient, err := rpc.DialOptions(ctx, endpoints["auth"], rpc.WithHTTPAuth(node.NewJWTAuth(common.HexToHash(JWTSecret))))
+	require.NoError(t, err)
+	defer authClient.Close()
+	require.NoError(t, authClient.Call(&capabilities, "engine_exchangeCapabilities", []string{}))
+	require.NotEmpty(t, capabilities)
+
+	// CORS allows the configured origins
+	req, err := http.NewRequest(http.MethodPost, endpoints["http"], strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "http://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	// Start blocks until the context is canceled
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestNewGenesis(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	deployer := common.HexToAddress("0xbd244d8655c02e31e051f535e766e450fbb17fa9")
+	account := common.HexToAddress("0x90F79bf6EB2c4f870365E785982E1f101E93b906")
+
+	l1, err := NewGenesis(config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, BlockBaseFeePerGas: 7, Accounts: 5, Balance: 10})
+	require.NoError(t, err)
+	require.Equal(t, uint64(900), l1.Config.ChainID.Uint64())
+	require.Equal(t, uint64(30_000_000), l1.GasLimit)
+	require.Equal(t, uint64(7), l1.BaseFee.Uint64())
+	require.NotEmpty(t, l1.Alloc[addresses["OptimismPortalProxy"]].Code)
+	require.NotEmpty(t, l1.Alloc[addresses["OptimismPortalProxy"]].Storage)
+	require.Equal(t, uint64(46), l1.Alloc[deployer].Nonce)
+	require.Equal(t, "10000000000000000000", l1.Alloc[account].Balance.String())
+	_, funded := l1.Alloc[common.HexToAddress("0x9965507D1a55bcC2695C58ba16FB37d819B0A4dc")]
+	require.False(t, funded, "only the first 5 accounts are funded")
+	// The shared dev chain config is not modified
+	require.Equal(t, uint64(1337), params.AllDevChainProtocolChanges.ChainID.Uint64())
+
+	l2, err := NewGenesis(config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900})
+	require.NoError(t, err)
+	require.Equal(t, uint64(901), l2.Config.ChainID.Uint64())
+	require.Equal(t, uint64(defaultGasLimit), l2.GasLimit)
+	require.NotContains(t, l2.Alloc, addresses["OptimismPortalProxy"])
+	require.NotEmpty(t, l2.Alloc[predeploys.L1BlockAddr].Code)
+	l1BlockImpl := common.HexToAddress("0xc0d3c0d3c0d3c0d3c0d3c0d3c0d3c0d3c0d30015")
+	require.Equal(t, common.BytesToHash(l1BlockImpl.Bytes()), l2.Alloc[predeploys.L1BlockAddr].Storage[implementationSlot])
+	require.NotEmpty(t, l2.Alloc[l1BlockImpl].Code)
+	require.Len(t, l2.Alloc, 9+defaultAccounts+4)
+	require.Equal(t, "10000000000000000000000", l2.Alloc[account].Balance.String())
+	require.NotNil(t, l2.Config.Optimism)
+	require.True(t, l2.Config.IsRegolith(l2.Timestamp))
+	require.False(t, l2.Config.IsShanghai(big.NewInt(0), l2.Timestamp))
+	require.NotZero(t, l2.Timestamp)
+
+	// Simulated L2s are plain dev chains
+	simulated, err := NewGenesis(config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendSimulated})
+	require.NoError(t, err)
+	require.Nil(t, simulated.Config.Optimism)
+	require.NotContains(t, simulated.Alloc, predeploys.L1BlockAddr)
+	require.Zero(t, simulated.Timestamp)
+}
+
+var testL1 = config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+
+func startGeth(t *testing.T, chain config.Chain, cfg GethConfig) (*Geth, *rpc.Client) {
+	cfg.Host = "127.0.0.1"
+	genesis, err := NewGenesis(chain)
+	require.NoError(t, err)
+	service, err := NewGeth(chain.Name, log.New("module", "test", "chain", chain.Name), cfg, genesis, chain.IsL2())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- service.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	require.Eventually(t, func() bool {
+		healthy, _ := service.HealthCheck()
+		return healthy
+	}, 5*time.Second, 100*time.Millisecond)
+
+	client, err := service.GetClient()
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return service, client
+}
+
+func TestGethAutomine(t *testing.T) {
+	_, client := startGeth(t, testL1, GethConfig{})
+
+	var automine bool
+	require.NoError(t, client.Call(&automine, "anvil_getAutomine"))
+	require.True(t, automine)
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 1)
+	require.NoError(t, err)
+	to := common.HexToAddress("0x90F79bf6EB2c4f870365E785982E1f101E93b906")
+	tx, err := types.SignNewTx(accs[0].PrivateKey, types.LatestSignerForChainID(big.NewInt(900)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(900),
+		Gas:       21_000,
+		GasFeeCap: big.NewInt(params.GWei * 10),
+		GasTipCap: big.NewInt(params.GWei),
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	require.NoError(t, err)
+	raw, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, client.Call(nil, "eth_sendRawTransaction", hexutil.Encode(raw)))
+
+	// The transaction is included without an explicit mine
+	require.Eventually(t, func() bool {
+		var receipt map[string]interface{}
+		err := client.Call(&receipt, "eth_getTransactionReceipt", tx.Hash())
+		return err == nil && receipt != nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	var number hexutil.Uint64
+	require.NoError(t, client.Call(&number, "eth_blockNumber"))
+	require.NoError(t, client.Call(nil, "anvil_mine", hexutil.Uint64(3)))
+	require.NoError(t, client.Call(nil, "evm_mine"))
+	var mined hexutil.Uint64
+	require.NoError(t, client.Call(&mined, "eth_blockNumber"))
+	require.Equal(t, number+4, mined)
+}
+
+func TestGethSnapshot(t *testing.T) {
+	l1Service, l1Client := startGeth(t, testL1, GethConfig{})
+	require.NoError(t, l1Client.Call(nil, "anvil_mine", hexutil.Uint64(3)))
+
+	var snapshot hexutil.Big
+	require.NoError(t, l1Client.Call(&snapshot, "evm_snapshot"))
+	require.NoError(t, l1Client.Call(nil, "anvil_mine", hexutil.Uint64(2)))
+	var reverted bool
+	require.NoError(t, l1Client.Call(&reverted, "evm_revert", &snapshot))
+	require.True(t, reverted)
+	var number hexutil.Uint64
+	require.NoError(t, l1Client.Call(&number, "eth_blockNumber"))
+	require.Equal(t, uint64(3), uint64(number))
+	// The snapshot can only be reverted to once
+	require.NoError(t, l1Client.Call(&reverted, "evm_revert", &snapshot))
+	require.False(t, reverted)
+
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l2Chain := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	_, l2Client := startGeth(t, l2Chain, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  opeth.SystemConfig{GasLimit: 30_000_000},
+			SeqWindowSize: 3600,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+	require.NoError(t, l2Client.Call(nil, "anvil_mine", hexutil.Uint64(2)))
+	before, err := l2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.NoError(t, l2Client.Call(&snapshot, "evm_snapshot"))
+	require.NoError(t, l2Client.Call(nil, "anvil_mine", hexutil.Uint64(3)))
+	require.NoError(t, l2Client.Call(&reverted, "evm_revert", &snapshot))
+	require.True(t, reverted)
+
+	// The sequencer continues from the snapshotted head
+	after, err := l2.HeaderByNumber(context.Background(), before.Number)
+	require.NoError(t, err)
+	require.Equal(t, before.Hash(), after.Hash())
+	require.NoError(t, l2Client.Call(nil, "anvil_mine", hexutil.Uint64(1)))
+	next, err := l2.HeaderByNumber(context.Background(), new(big.Int).Add(before.Number, common.Big1))
+	require.NoError(t, err)
+	require.Equal(t, before.Hash(), next.ParentHash)
+}
+
+func TestGethSnapshotRestoresPoolAndCheats(t *testing.T) {
+	// Transactions wait in the pool until evm_mine
+	_, client := startGeth(t, testL1, GethConfig{BlockTime: 3600, Cheats: true})
+	var devAccounts []common.Address
+	require.NoError(t, client.Call(&devAccounts, "eth_accounts"))
+	recipient := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	send := func(from common.Address) common.Hash {
+		var hash common.Hash
+		require.NoError(t, client.Call(&hash, "eth_sendTransaction", map[string]interface{}{"from": from, "to": recipient, "value": "0x1"}))
+		return hash
+	}
+	impersonated := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+	waiting := send(devAccounts[0])
+	var offset hexutil.Uint64
+	require.NoError(t, client.Call(&offset, "evm_increaseTime", hexutil.Uint64(100)))
+	require.NoError(t, client.Call(nil, "anvil_impersonateAccount", impersonated))
+	var snapshot hexutil.Big
+	require.NoError(t, client.Call(&snapshot, "evm_snapshot"))
+
+	require.NoError(t, client.Call(&offset, "evm_increaseTime", hexutil.Uint64(1000)))
+	require.NoError(t, client.Call(nil, "anvil_stopImpersonatingAccount", impersonated))
+	require.NoError(t, client.Call(nil, "anvil_impersonateAccount", other))
+	sent := send(devAccounts[1])
+	require.NoError(t, client.Call(nil, "evm_mine"))
+	var reverted bool
+	require.NoError(t, client.Call(&reverted, "evm_revert", &snapshot))
+	require.True(t, reverted)
+
+	// The clock, the impersonated accounts and the pool are those of the snapshot
+	require.NoError(t, client.Call(&offset, "evm_increaseTime", hexutil.Uint64(0)))
+	require.Equal(t, hexutil.Uint64(100), offset)
+	tx := map[string]interface{}{"from": impersonated, "to": recipient}
+	require.NoError(t, client.Call(nil, "eth_sendTransaction", tx))
+	tx["from"] = other
+	require.Error(t, client.Call(nil, "eth_sendTransaction", tx))
+	var found *struct {
+		BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+	}
+	require.NoError(t, client.Call(&found, "eth_getTransactionByHash", waiting))
+	require.NotNil(t, found)
+	require.Nil(t, found.BlockNumber)
+	found = nil
+	require.NoError(t, client.Call(&found, "eth_getTransactionByHash", sent))
+	require.Nil(t, found)
+}
+
+func TestGethIntervalMining(t *testing.T) {
+	service, client := startGeth(t, testL1, GethConfig{BlockTime: 1})
+
+	var automine bool
+	require.NoError(t, client.Call(&automine, "anvil_getAutomine"))
+	require.False(t, automine)
+
+	require.Eventually(t, func() bool {
+		var number hexutil.Uint64
+		err := client.Call(&number, "eth_blockNumber")
+		return err == nil && number >= 2
+	}, 10*time.Second, 200*time.Millisecond)
+
+	// Finalized trails the head at epoch boundaries
+	var finalized map[string]interface{}
+	require.NoError(t, client.Call(&finalized, "eth_getBlockByNumber", "finalized", false))
+	require.Equal(t, "0x0", finalized["number"])
+
+	_, err := service.Mine(2)
+	require.NoError(t, err)
+
+	// Blocks keep the gas limit of the genesis
+	var head map[string]interface{}
+	require.NoError(t, client.Call(&head, "eth_getBlockByNumber", "latest", false))
+	require.Equal(t, hexutil.EncodeUint64(uint64(testL1.GasLimit)), head["gasLimit"])
+}
+
+func TestGethSequencer(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, l1Client := startGeth(t, testL1, GethConfig{})
+	l1 := ethclient.NewClient(l1Client)
+
+	// Deposit to an account without L2 balance
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	recipient := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	portal, err := bindings.NewOptimismPortal(addresses["OptimismPortalProxy"], l1)
+	require.NoError(t, err)
+	opts, err := bind.NewKeyedTransactorWithChainID(accs[0].PrivateKey, big.NewInt(900))
+	require.NoError(t, err)
+	opts.Value = big.NewInt(params.Ether)
+	// Resource metering burns L1 gas depending on the base fee, which gas estimation ignores
+	opts.GasLimit = 500_000
+	tx, err := portal.DepositTransaction(opts, recipient, big.NewInt(params.Ether), 100_000, false, nil)
+	require.NoError(t, err)
+	var receipt *types.Receipt
+	require.Eventually(t, func() bool {
+		receipt, err = l1.TransactionReceipt(context.Background(), tx.Hash())
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	sysCfg := opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000}
+	l2Chain := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2Service, l2Client := startGeth(t, l2Chain, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  sysCfg,
+			SeqWindowSize: 3600,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+
+	var automine bool
+	require.NoError(t, l2Client.Call(&automine, "anvil_getAutomine"))
+	require.False(t, automine)
+
+	// Every L2 block follows its parent by the block time and starts with the L1 info deposit
+	_, err = l2Service.Mine(10)
+	require.NoError(t, err)
+	genesis, err := l2.HeaderByNumber(context.Background(), big.NewInt(0))
+	require.NoError(t, err)
+	for i := int64(1); i <= 10; i++ {
+		block, err := l2.BlockByNumber(context.Background(), big.NewInt(i))
+		require.NoError(t, err)
+		require.Equal(t, genesis.Time+2*uint64(i), block.Time())
+		require.Equal(t, uint8(types.DepositTxType), block.Transactions()[0].Type())
+		require.Equal(t, predeploys.L1BlockAddr, *block.Transactions()[0].To())
+	}
+
+	// The L1 origin caught up with the L1 head and the deposit was included
+	l1Block, err := bindings.NewL1Block(predeploys.L1BlockAddr, l2)
+	require.NoError(t, err)
+	number, err := l1Block.Number(nil)
+	require.NoError(t, err)
+	require.Equal(t, receipt.BlockNumber.Uint64(), number)
+	balance, err := l2.BalanceAt(context.Background(), recipient, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(params.Ether), balance)
+}
+
+func TestGethDataDir(t *testing.T) {
+	run := func(cfg GethConfig, genesis *core.Genesis, blocks uint64) (*Geth, uint64) {
+		cfg.Host = "127.0.0.1"
+		service, err := NewGeth("L1", log.New("module", "test"), cfg, genesis, false)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- service.Start(ctx)
+		}()
+		require.Eventually(t, func() bool {
+			healthy, _ := service.HealthCheck()
+			return healthy
+		}, 5*time.Second, 100*time.Millisecond)
+		_, err = service.Mine(blocks)
+		require.NoError(t, err)
+		head := service.eth.BlockChain().CurrentBlock().Number.Uint64()
+		cancel()
+		require.NoError(t, <-done)
+		return service, head
+	}
+
+	genesis, err := NewGenesis(testL1)
+	require.NoError(t, err)
+
+	// Without a data dir the chain is stored in a temporary directory
+	service, _ := run(GethConfig{}, genesis, 1)
+	require.NotEmpty(t, service.DataDir())
+	require.NoDirExists(t, service.DataDir())
+
+	// A persisted chain continues from its head and keeps its genesis
+	dataDir := filepath.Join(t.TempDir(), "L1", "geth")
+	_, head := run(GethConfig{DataDir: dataDir}, genesis, 2)
+	require.Equal(t, uint64(2), head)
+	require.FileExists(t, filepath.Join(dataDir, "genesis.json"))
+
+	restarted := *genesis
+	restarted.Timestamp++
+	_, head = run(GethConfig{DataDir: dataDir}, &restarted, 1)
+	require.Equal(t, uint64(3), head)
+}
+
+func TestGethReorg(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, l1Client := startGeth(t, testL1, GethConfig{})
+	l1 := ethclient.NewClient(l1Client)
+	_, err = l1Service.Mine(4)
+	require.NoError(t, err)
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	sysCfg := opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000}
+	l2Service, l2Client := startGeth(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000}, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  sysCfg,
+			SeqWindowSize: 3600,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+	_, err = l2Service.Mine(10)
+	require.NoError(t, err)
+
+	// The latest blocks are replaced by as many new blocks
+	ancestor, err := l1.HeaderByNumber(context.Background(), big.NewInt(2))
+	require.NoError(t, err)
+	orphaned, err := l1.HeaderByNumber(context.Background(), big.NewInt(3))
+	require.NoError(t, err)
+	require.NoError(t, l1Client.Call(nil, "anvil_reorg", map[string]interface{}{"depth": 2, "txBlockPairs": []interface{}{}}))
+	head, err := l1.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), head.Number.Uint64())
+	block, err := l1.HeaderByNumber(context.Background(), big.NewInt(3))
+	require.NoError(t, err)
+	require.NotEqual(t, orphaned.Hash(), block.Hash())
+	block, err = l1.HeaderByNumber(context.Background(), big.NewInt(2))
+	require.NoError(t, err)
+	require.Equal(t, ancestor.Hash(), block.Hash())
+
+	// The L2 drops the blocks of orphaned L1 origins before building new blocks
+	_, err = l2Service.Mine(1)
+	require.NoError(t, err)
+	number, err := l2.BlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Less(t, number, uint64(11))
+	for i := uint64(1); i <= number; i++ {
+		block, err := l2.BlockByNumber(context.Background(), new(big.Int).SetUint64(i))
+		require.NoError(t, err)
+		info, err := derive.L1BlockInfoFromBytes(&rollup.Config{}, block.Time(), block.Transactions()[0].Data())
+		require.NoError(t, err)
+		origin, err := l1.HeaderByNumber(context.Background(), new(big.Int).SetUint64(info.Number))
+		require.NoError(t, err)
+		require.Equal(t, origin.Hash(), info.BlockHash)
+	}
+
+	require.NoError(t, l1Client.Call(nil, "anvil_rollback", 3))
+	head, err = l1.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), head.Number.Uint64())
+	require.Error(t, l2Client.Call(nil, "anvil_rollback", 1))
+}
+
+func TestGethForcedInclusion(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, l1Client := startGeth(t, testL1, GethConfig{})
+	l1 := ethclient.NewClient(l1Client)
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	sysCfg := opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000}
+	l2Service, l2Client := startGeth(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}, GethConfig{
+		OpGeth:    true,
+		BlockTime: 1,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  sysCfg,
+			SeqWindowSize: 3,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+
+	// A stopped sequencer builds no blocks on demand
+	var head common.Hash
+	require.NoError(t, l2Client.Call(&head, "admin_stopSequencer"))
+	require.Error(t, l2Client.Call(&head, "admin_stopSequencer"))
+	var active bool
+	require.NoError(t, l2Client.Call(&active, "admin_sequencerActive"))
+	require.False(t, active)
+	require.Error(t, l2Client.Call(nil, "anvil_mine"))
+
+	// Transactions sent to the L2 wait in the txpool
+	to := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	tx, err := types.SignNewTx(accs[1].PrivateKey, types.LatestSignerForChainID(big.NewInt(901)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(901),
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: big.NewInt(10 * params.GWei),
+		Gas:       21_000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	require.NoError(t, err)
+	require.NoError(t, l2.SendTransaction(context.Background(), tx))
+
+	recipient := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	portal, err := bindings.NewOptimismPortal(addresses["OptimismPortalProxy"], l1)
+	require.NoError(t, err)
+	opts, err := bind.NewKeyedTransactorWithChainID(accs[0].PrivateKey, big.NewInt(900))
+	require.NoError(t, err)
+	opts.Value = big.NewInt(params.Ether)
+	opts.GasLimit = 500_000
+	deposit, err := portal.DepositTransaction(opts, recipient, big.NewInt(params.Ether), 100_000, false, nil)
+	require.NoError(t, err)
+	var receipt *types.Receipt
+	require.Eventually(t, func() bool {
+		receipt, err = l1.TransactionReceipt(context.Background(), deposit.Hash())
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// The deposit is queued until the sequencing window of its L1 block elapses
+	var status SequencerStatus
+	require.NoError(t, l2Client.Call(&status, "admin_sequencerStatus"))
+	require.False(t, status.Active)
+	require.EqualValues(t, 1, status.QueuedDeposits)
+	require.NoError(t, l2Service.producer.(*sequencer).sequence())
+	balance, err := l2.BalanceAt(context.Background(), recipient, nil)
+	require.NoError(t, err)
+	require.Zero(t, balance.Sign())
+
+	// Once it elapsed, blocks with the deposits only are forced
+	_, err = l1Service.Mine(3)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		balance, err := l2.BalanceAt(context.Background(), recipient, nil)
+		return err == nil && balance.Cmp(big.NewInt(params.Ether)) == 0
+	}, 10*time.Second, 200*time.Millisecond)
+	_, err = l2.TransactionReceipt(context.Background(), tx.Hash())
+	require.Error(t, err)
+	require.NoError(t, l2Client.Call(&status, "admin_sequencerStatus"))
+	require.Zero(t, uint64(status.QueuedDeposits))
+	require.GreaterOrEqual(t, uint64(status.L1Origin), receipt.BlockNumber.Uint64())
+
+	// The sequencer resumes on top of the forced blocks and includes the txpool again
+	require.Error(t, l2Client.Call(nil, "admin_startSequencer", head))
+	forced, err := l2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.NoError(t, l2Client.Call(nil, "admin_startSequencer", forced.Hash()))
+	require.NoError(t, l2Client.Call(&active, "admin_sequencerActive"))
+	require.True(t, active)
+	require.NoError(t, l2Client.Call(nil, "anvil_mine"))
+	_, err = l2.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(t, err)
+}
+
+func TestGethSystemConfigUpdates(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, l1Client := startGeth(t, testL1, GethConfig{Cheats: true})
+	l1 := ethclient.NewClient(l1Client)
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	sysCfg := opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000}
+	l2Service, l2Client := startGeth(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000}, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:            fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:           addresses["OptimismPortalProxy"],
+			SystemConfigAddr: addresses["SystemConfigProxy"],
+			SystemConfig:     sysCfg,
+			SeqWindowSize:    3600,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+	_, err = l2Service.Mine(2)
+	require.NoError(t, err)
+
+	// The owner of the SystemConfig changes the fee parameters and the gas limit
+	systemConfig, err := bindings.NewSystemConfig(addresses["SystemConfigProxy"], l1)
+	require.NoError(t, err)
+	owner, err := systemConfig.Owner(nil)
+	require.NoError(t, err)
+	require.NoError(t, l1Client.Call(nil, "anvil_impersonateAccount", owner))
+	abi, err := bindings.SystemConfigMetaData.GetAbi()
+	require.NoError(t, err)
+	for _, call := range [][]interface{}{
+		{"setGasConfig", big.NewInt(2100), big.NewInt(1_000_000)},
+		{"setGasLimit", uint64(25_000_000)},
+	} {
+		data, err := abi.Pack(call[0].(string), call[1:]...)
+		require.NoError(t, err)
+		var hash common.Hash
+		require.NoError(t, l1Client.Call(&hash, "eth_sendTransaction", map[string]interface{}{
+			"from": owner,
+			"to":   addresses["SystemConfigProxy"],
+			"data": hexutil.Bytes(data),
+		}))
+		receipt, err := l1.TransactionReceipt(context.Background(), hash)
+		require.NoError(t, err)
+		require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	}
+	l1Head, err := l1.BlockNumber(context.Background())
+	require.NoError(t, err)
+
+	// The blocks of the L1 origins after the updates carry the new system config
+	l1Block, err := bindings.NewL1Block(predeploys.L1BlockAddr, l2)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		if _, err := l2Service.Mine(1); err != nil {
+			return false
+		}
+		number, err := l1Block.Number(nil)
+		return err == nil && number == l1Head
+	}, 10*time.Second, 10*time.Millisecond)
+	overhead, err := l1Block.L1FeeOverhead(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2100, overhead.Int64())
+	scalar, err := l1Block.L1FeeScalar(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1_000_000, scalar.Int64())
+	head, err := l2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 25_000_000, head.GasLimit)
+
+	// Blocks of the same epoch keep the config
+	_, err = l2Service.Mine(1)
+	require.NoError(t, err)
+	head, err = l2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 25_000_000, head.GasLimit)
+	batcher, err := l1Block.BatcherHash(nil)
+	require.NoError(t, err)
+	require.Equal(t, common.BytesToHash(accs[2].Address.Bytes()), common.Hash(batcher))
+}
+
+func TestGethHardforks(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, _ := startGeth(t, testL1, GethConfig{})
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	regolith, canyon := uint(4), uint(10)
+	l2Chain := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000,
+		Hardforks: config.Hardforks{Regolith: &regolith, Canyon: &canyon}}
+	l2Service, l2Client := startGeth(t, l2Chain, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000},
+			SeqWindowSize: 3600,
+			Hardforks:     l2Chain.Hardforks,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+	_, err = l2Service.Mine(6)
+	require.NoError(t, err)
+
+	// The L1 info deposit is a system transaction until Regolith
+	for i, system := range []bool{true, false, false} {
+		block, err := l2.BlockByNumber(context.Background(), big.NewInt(int64(i+1)))
+		require.NoError(t, err)
+		require.Equal(t, system, block.Transactions()[0].IsSystemTx(), i+1)
+	}
+
+	// Canyon activates Shanghai mid-run, so PUSH0 is only valid from its first block
+	push0 := ethereum.CallMsg{From: accs[0].Address, Data: []byte{0x5f}}
+	for number := int64(1); number <= 6; number++ {
+		header, err := l2.HeaderByNumber(context.Background(), big.NewInt(number))
+		require.NoError(t, err)
+		_, err = l2.CallContract(context.Background(), push0, big.NewInt(number))
+		if number < 5 {
+			require.Nil(t, header.WithdrawalsHash, number)
+			require.Error(t, err, number)
+		} else {
+			require.NotNil(t, header.WithdrawalsHash, number)
+			require.NoError(t, err, number)
+		}
+	}
+}
+
+func TestGethEcotone(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, _ := startGeth(t, testL1, GethConfig{})
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	canyon, delta := uint(0), uint(0)
+	l2Chain := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000,
+		Hardforks: config.Hardforks{Canyon: &canyon, Delta: &delta}}
+	l2Service, l2Client := startGeth(t, l2Chain, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000},
+			SeqWindowSize: 3600,
+			Hardforks:     l2Chain.Hardforks,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+	_, err = l2Service.Mine(2)
+	require.NoError(t, err)
+	head, err := l2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Nil(t, head.ParentBeaconRoot)
+
+	// Only hardforks that are not active yet are scheduled after the head
+	require.Error(t, l2Client.Call(nil, "admin_scheduleHardfork", "canyon", hexutil.Uint64(head.Time+10)))
+	require.Error(t, l2Client.Call(nil, "admin_scheduleHardfork", "ecotone", hexutil.Uint64(head.Time)))
+	require.NoError(t, l2Client.Call(nil, "admin_scheduleHardfork", "ecotone", hexutil.Uint64(head.Time+10)))
+	var hardforks config.Hardforks
+	require.NoError(t, l2Client.Call(&hardforks, "admin_hardforks"))
+	genesis, err := l2.HeaderByNumber(context.Background(), big.NewInt(0))
+	require.NoError(t, err)
+	require.Equal(t, head.Time+10, *hardforks.EcotoneTime(genesis.Time))
+
+	// The first Ecotone block upgrades the predeploys, even when the sequencer skips past the activation
+	require.NoError(t, l2Client.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(head.Time+20)))
+	_, err = l2Service.Mine(2)
+	require.NoError(t, err)
+	upgrade, err := l2.BlockByNumber(context.Background(), big.NewInt(3))
+	require.NoError(t, err)
+	require.NotNil(t, upgrade.Header().ParentBeaconRoot)
+	require.Len(t, upgrade.Transactions(), 7)
+	require.Equal(t, derive.L1InfoFuncBedrockBytes4, upgrade.Transactions()[0].Data()[:4])
+	for _, tx := range upgrade.Transactions() {
+		receipt, err := l2.TransactionReceipt(context.Background(), tx.Hash())
+		require.NoError(t, err)
+		require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status, tx.Hash())
+	}
+	block, err := l2.BlockByNumber(context.Background(), big.NewInt(4))
+	require.NoError(t, err)
+	require.Equal(t, derive.L1InfoFuncEcotoneBytes4, block.Transactions()[0].Data()[:4])
+
+	gasPriceOracle, err := bindings.NewGasPriceOracle(predeploys.GasPriceOracleAddr, l2)
+	require.NoError(t, err)
+	isEcotone, err := gasPriceOracle.IsEcotone(nil)
+	require.NoError(t, err)
+	require.True(t, isEcotone)
+}
+
+func TestGethScheduledHardforksPersist(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, _ := startGeth(t, testL1, GethConfig{})
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	l2Chain := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	genesis, err := NewGenesis(l2Chain)
+	require.NoError(t, err)
+	cfg := GethConfig{
+		Host:      "127.0.0.1",
+		DataDir:   filepath.Join(t.TempDir(), "L2", "geth"),
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000},
+			SeqWindowSize: 3600,
+		},
+	}
+	run := func(f func(service *Geth, client *rpc.Client)) {
+		service, err := NewGeth("L2", log.New("module", "test"), cfg, genesis, true)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- service.Start(ctx)
+		}()
+		require.Eventually(t, func() bool {
+			healthy, _ := service.HealthCheck()
+			return healthy
+		}, 5*time.Second, 100*time.Millisecond)
+		client, err := service.GetClient()
+		require.NoError(t, err)
+		f(service, client)
+		client.Close()
+		cancel()
+		require.NoError(t, <-done)
+	}
+
+	var canyonTime uint64
+	run(func(service *Geth, client *rpc.Client) {
+		_, err := service.Mine(1)
+		require.NoError(t, err)
+		head := service.eth.BlockChain().CurrentBlock()
+		canyonTime = head.Time + 4
+		require.NoError(t, client.Call(nil, "admin_scheduleHardfork", "canyon", hexutil.Uint64(canyonTime)))
+		// The chain config only changes once the next block is built
+		require.Nil(t, service.eth.BlockChain().Config().CanyonTime)
+		require.NoError(t, client.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(canyonTime)))
+		_, err = service.Mine(1)
+		require.NoError(t, err)
+		require.NotNil(t, service.eth.BlockChain().CurrentBlock().WithdrawalsHash)
+	})
+
+	// The restarted chain keeps the hardfork scheduled at runtime
+	run(func(service *Geth, client *rpc.Client) {
+		var hardforks config.Hardforks
+		require.NoError(t, client.Call(&hardforks, "admin_hardforks"))
+		require.Equal(t, canyonTime, *hardforks.CanyonTime(genesis.Timestamp))
+		require.Equal(t, canyonTime, *service.eth.BlockChain().Config().CanyonTime)
+		_, err := service.Mine(1)
+		require.NoError(t, err)
+		require.NotNil(t, service.eth.BlockChain().CurrentBlock().WithdrawalsHash)
+	})
+}
+
+func TestGethSequencerTimeTravel(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1Service, l1Client := startGeth(t, testL1, GethConfig{})
+	l1 := ethclient.NewClient(l1Client)
+
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 3)
+	require.NoError(t, err)
+	l2Chain := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2Service, l2Client := startGeth(t, l2Chain, GethConfig{
+		OpGeth:    true,
+		BlockTime: 2,
+		Sequencer: &SequencerConfig{
+			L1URL:         fmt.Sprintf("http://127.0.0.1:%d", l1Service.Port()),
+			Portal:        addresses["OptimismPortalProxy"],
+			SystemConfig:  opeth.SystemConfig{BatcherAddr: accs[2].Address, GasLimit: 30_000_000},
+			SeqWindowSize: 3600,
+		},
+	})
+	l2 := ethclient.NewClient(l2Client)
+	_, err = l2Service.Mine(1)
+	require.NoError(t, err)
+	head, err := l2.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+
+	// The next block can only skip ahead by multiples of the block time
+	require.Error(t, l2Client.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(head.Time+3)))
+	require.Error(t, l2Client.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(head.Time)))
+
+	// An L1 block far ahead becomes the L1 origin of the block skipping ahead to it
+	require.NoError(t, l1Client.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(head.Time+3600)))
+	require.NoError(t, l1Client.Call(nil, "evm_mine"))
+	l1Head, err := l1.HeaderByNumber(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, head.Time+3600, l1Head.Time)
+	require.NoError(t, l2Client.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(head.Time+3600)))
+	require.NoError(t, l2Client.Call(nil, "evm_mine"))
+	warped, err := l2.HeaderByNumber(context.Background(), new(big.Int).Add(head.Number, common.Big1))
+	require.NoError(t, err)
+	require.Equal(t, head.Time+3600, warped.Time)
+	l1Block, err := bindings.NewL1BlockCaller(predeploys.L1BlockAddr, l2)
+	require.NoError(t, err)
+	timestamp, err := l1Block.Timestamp(&bind.CallOpts{BlockNumber: warped.Number})
+	require.NoError(t, err)
+	require.Equal(t, l1Head.Time, timestamp)
+
+	// Increasing the time rounds up to the block time, and later blocks continue from the new time
+	require.NoError(t, l2Client.Call(nil, "evm_increaseTime", hexutil.Uint64(3)))
+	_, err = l2Service.Mine(2)
+	require.NoError(t, err)
+	for i, offset := range []uint64{2 + 4, 2 + 4 + 2} {
+		header, err := l2.HeaderByNumber(context.Background(), new(big.Int).SetUint64(warped.Number.Uint64()+uint64(i)+1))
+		require.NoError(t, err)
+		require.Equal(t, warped.Time+offset, header.Time)
+	}
+}
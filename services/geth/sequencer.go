@@ -0,0 +1,784 @@
+package geth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	opeth "github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/beacon/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SequencerConfig configures the block production of an L2 node
+type SequencerConfig struct {
+	// RPC url of the L1 the L2 derives from
+	L1URL string
+	// The L1 block the L2 genesis derives from
+	L1Genesis uint64
+	// The OptimismPortal deposits are read from
+	Portal common.Address
+	// The SystemConfig whose ConfigUpdate events change the system config as the L1 origin advances
+	SystemConfigAddr common.Address
+	// The system config of the L2 genesis
+	SystemConfig opeth.SystemConfig
+	// Number of L1 blocks after an L1 origin within which the batches of its epoch must be submitted
+	SeqWindowSize uint64
+	// The hardforks of the L2 in seconds after its genesis
+	Hardforks config.Hardforks
+}
+
+var (
+	// Errors of the admin API of op-node
+	errSequencerStarted = errors.New("sequencer already running")
+	errSequencerStopped = errors.New("sequencer not running")
+)
+
+// SequencerStatus reports the state of the sequencer and the deposits waiting for inclusion
+type SequencerStatus struct {
+	Active bool        `json:"active"`
+	Head   common.Hash `json:"head"`
+	// L1 origin of the head
+	L1Origin hexutil.Uint64 `json:"l1Origin"`
+	// Deposits the OptimismPortal emitted after the L1 origin of the head, included once the origin advances
+	QueuedDeposits hexutil.Uint64 `json:"queuedDeposits"`
+	// L1 block at which the sequencing window of the L1 origin of the next block elapses. From then on,
+	// a stopped sequencer still builds the blocks of that origin with its deposits only, like derivation would
+	SequencingWindowEnd hexutil.Uint64 `json:"sequencingWindowEnd"`
+}
+
+// sequencer builds the blocks of an op-geth L2 through the engine API like op-node in sequencer mode.
+// Every block starts with the L1 info deposit of its L1 origin, and the first block of an epoch
+// includes the deposits the OptimismPortal emitted in the L1 origin. The first Ecotone block also
+// includes the network upgrade transactions of Ecotone. While stopped, blocks are only
+// built once the sequencing window of their L1 origin elapsed, without the transactions of the
+// txpool, like the blocks derivation forces when a sequencer submits no batches.
+type sequencer struct {
+	log    log.Logger
+	eth    *eth.Ethereum
+	engine *catalyst.ConsensusAPI
+	config SequencerConfig
+	period uint64
+	l1     *ethclient.Client
+	// File the hardforks are persisted to, so hardforks scheduled at runtime survive restarts
+	hardforksPath string
+
+	// Serializes block production of the sequencing loop and manual mining
+	mu     sync.Mutex
+	origin *types.Header
+	active bool
+	// Seconds the clock of the sequencer is ahead of the wall clock after time travel
+	timeOffset uint64
+	// The timestamp of the next block set by time travel, 0 if unset
+	nextTimestamp uint64
+	// Chain config of a hardfork scheduled at runtime, swapped in before the next block is built
+	scheduledConfig *params.ChainConfig
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	shutdownCh chan struct{}
+	wg         sync.WaitGroup
+}
+
+func newSequencer(logger log.Logger, backend *eth.Ethereum, cfg SequencerConfig, period uint64, hardforksPath string) (*sequencer, error) {
+	if period == 0 {
+		return nil, errors.New("sequencer block time is required")
+	}
+	if cfg.SeqWindowSize == 0 {
+		return nil, errors.New("sequencing window size is required")
+	}
+	l1, err := ethclient.Dial(cfg.L1URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sequencer{
+		log:           logger,
+		eth:           backend,
+		engine:        catalyst.NewConsensusAPI(backend),
+		config:        cfg,
+		period:        period,
+		l1:            l1,
+		hardforksPath: hardforksPath,
+		active:        true,
+		ctx:           ctx,
+		cancel:        cancel,
+		shutdownCh:    make(chan struct{}),
+	}, nil
+}
+
+// Start implements node.Lifecycle
+func (s *sequencer) Start() error {
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle
+func (s *sequencer) Stop() error {
+	close(s.shutdownCh)
+	s.cancel()
+	s.wg.Wait()
+	s.l1.Close()
+	return nil
+}
+
+func (s *sequencer) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(s.period) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.shutdownCh:
+			return
+		case <-ticker.C:
+			if err := s.sequence(); err != nil {
+				s.log.Warn("failed to sequence block", "err", err)
+			}
+		}
+	}
+}
+
+// sequence builds every block due by the current time
+func (s *sequencer) sequence() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rewindOrphaned(); err != nil {
+		return err
+	}
+	now := uint64(time.Now().Unix()) + s.timeOffset
+	if !s.active {
+		return s.forceInclude(now)
+	}
+	for s.nextTime(s.eth.BlockChain().CurrentBlock()) <= now {
+		if _, err := s.buildBlock(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forceInclude builds the blocks due by the current time whose L1 origin is at least a sequencing window old
+// with the L1 info deposit and deposits only
+func (s *sequencer) forceInclude(now uint64) error {
+	l1Head, err := s.l1.HeaderByNumber(s.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	chain := s.eth.BlockChain()
+	for parent := chain.CurrentBlock(); s.nextTime(parent) <= now; parent = chain.CurrentBlock() {
+		origin, _, err := s.nextOrigin(parent, s.nextTime(parent))
+		if err != nil {
+			return err
+		}
+		if origin.Number.Uint64()+s.config.SeqWindowSize > l1Head.Number.Uint64() {
+			return nil
+		}
+		hash, err := s.buildBlock(true)
+		if err != nil {
+			return err
+		}
+		s.log.Info("force included block", "number", parent.Number.Uint64()+1, "hash", hash, "l1Origin", origin.Number)
+	}
+	return nil
+}
+
+// Mine builds blocks without waiting for their timestamps and returns the hash of the new head
+func (s *sequencer) Mine(blocks uint64) (common.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.active {
+		return common.Hash{}, errSequencerStopped
+	}
+	if err := s.rewindOrphaned(); err != nil {
+		return common.Hash{}, err
+	}
+	head := s.eth.BlockChain().CurrentBlock().Hash()
+	for i := uint64(0); i < blocks; i++ {
+		hash, err := s.buildBlock(false)
+		if err != nil {
+			return head, err
+		}
+		head = hash
+	}
+	return head, nil
+}
+
+// Clock returns the time travel of the sequencer
+func (s *sequencer) Clock() producerClock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return producerClock{timeOffset: s.timeOffset, nextTimestamp: s.nextTimestamp}
+}
+
+// SetClock restores the time travel of a previous Clock
+func (s *sequencer) SetClock(clock producerClock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeOffset, s.nextTimestamp = clock.timeOffset, clock.nextTimestamp
+}
+
+// Automine is always disabled as blocks are built at the block time
+func (s *sequencer) Automine() bool {
+	return false
+}
+
+// nextTime returns the timestamp of the block after parent
+func (s *sequencer) nextTime(parent *types.Header) uint64 {
+	if s.nextTimestamp != 0 {
+		return s.nextTimestamp
+	}
+	return parent.Time + s.period
+}
+
+// IncreaseTime skips the next block ahead by seconds, rounded up to a multiple of the block time,
+// without building the blocks in between. Returns the total offset of the clock of the sequencer
+func (s *sequencer) IncreaseTime(seconds uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skip := (seconds + s.period - 1) / s.period * s.period
+	if skip > 0 {
+		s.nextTimestamp = s.nextTime(s.eth.BlockChain().CurrentBlock()) + skip
+		s.timeOffset += skip
+	}
+	return s.timeOffset
+}
+
+// SetNextBlockTimestamp skips the next block ahead to timestamp, which must be a multiple of the block
+// time after the head, without building the blocks in between. Later blocks continue from it
+func (s *sequencer) SetNextBlockTimestamp(timestamp uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head := s.eth.BlockChain().CurrentBlock()
+	if timestamp <= head.Time || (timestamp-head.Time)%s.period != 0 {
+		return fmt.Errorf("timestamp %d is not a multiple of the block time %d after the head at %d", timestamp, s.period, head.Time)
+	}
+	s.nextTimestamp = timestamp
+	if now := uint64(time.Now().Unix()); timestamp > now+s.timeOffset {
+		s.timeOffset = timestamp - now
+	}
+	return nil
+}
+
+// Hardforks returns the hardforks of the L2 in seconds after its genesis
+func (s *sequencer) Hardforks() config.Hardforks {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.Hardforks
+}
+
+// ScheduleHardfork activates a hardfork with the first block at or after timestamp, which must be after
+// the head. A hardfork that is already active at the head cannot be rescheduled
+func (s *sequencer) ScheduleHardfork(name string, timestamp uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chain := s.eth.BlockChain()
+	head := chain.CurrentBlock()
+	if timestamp <= head.Time {
+		return fmt.Errorf("timestamp %d is not after the head at %d", timestamp, head.Time)
+	}
+	rollupCfg := s.rollupConfig(s.period)
+	active := map[string]bool{
+		"regolith": rollupCfg.IsRegolith(head.Time),
+		"canyon":   rollupCfg.IsCanyon(head.Time),
+		"delta":    rollupCfg.IsDelta(head.Time),
+		"ecotone":  rollupCfg.IsEcotone(head.Time),
+	}
+	if active[name] {
+		return fmt.Errorf("hardfork %s is already active", name)
+	}
+	genesisTime := chain.Genesis().Time()
+	hardforks, err := s.config.Hardforks.Schedule(name, uint(timestamp-genesisTime))
+	if err != nil {
+		return err
+	}
+	scheduled := *chain.Config()
+	setHardforks(&scheduled, hardforks, genesisTime)
+	// The schedule is stored with the chain, so a restarted node keeps it
+	if err := writeHardforks(s.hardforksPath, hardforks); err != nil {
+		return err
+	}
+	rawdb.WriteChainConfig(s.eth.ChainDb(), chain.Genesis().Hash(), &scheduled)
+	s.config.Hardforks = hardforks
+	s.scheduledConfig = &scheduled
+	s.log.Info("scheduled hardfork", "name", name, "time", timestamp)
+	return nil
+}
+
+// applyScheduledConfig swaps the chain config of a hardfork scheduled at runtime into the chain. op-geth looks up
+// the hardforks in its chain config for every block and shares it unsynchronized with the txpool, miner and RPC,
+// so the config is only swapped between blocks, while the sequencer holds its lock, and every block is built
+// and imported with a single schedule.
+func (s *sequencer) applyScheduledConfig() {
+	if s.scheduledConfig == nil {
+		return
+	}
+	*s.eth.BlockChain().Config() = *s.scheduledConfig
+	s.scheduledConfig = nil
+}
+
+// StopSequencer stops building blocks and returns the hash of the head, like admin_stopSequencer of op-node
+func (s *sequencer) StopSequencer() (common.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.active {
+		return common.Hash{}, errSequencerStopped
+	}
+	s.active = false
+	head := s.eth.BlockChain().CurrentBlock()
+	s.log.Info("stopped sequencer", "head", head.Number, "hash", head.Hash())
+	return head.Hash(), nil
+}
+
+// StartSequencer resumes building blocks on top of head, which must be the current head, like
+// admin_startSequencer of op-node. The blocks missed while stopped are built on the next tick.
+func (s *sequencer) StartSequencer(head common.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active {
+		return errSequencerStarted
+	}
+	if current := s.eth.BlockChain().CurrentBlock(); current.Hash() != head {
+		return fmt.Errorf("block hash does not match: head %d is %s", current.Number, current.Hash())
+	}
+	s.active = true
+	s.log.Info("started sequencer", "head", head)
+	return nil
+}
+
+// SequencerActive reports whether the sequencer builds blocks
+func (s *sequencer) SequencerActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Status reports the state of the sequencer and the deposits waiting for their L1 origin
+func (s *sequencer) Status() (*SequencerStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head := s.eth.BlockChain().CurrentBlock()
+	var origin uint64
+	if head.Number.Sign() == 0 {
+		origin = s.config.L1Genesis
+	} else {
+		info, err := s.l1Info(head)
+		if err != nil {
+			return nil, err
+		}
+		origin = info.Number
+	}
+	next, _, err := s.nextOrigin(head, s.nextTime(head))
+	if err != nil {
+		return nil, err
+	}
+	l1Head, err := s.l1.BlockNumber(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+
+	status := &SequencerStatus{
+		Active:              s.active,
+		Head:                head.Hash(),
+		L1Origin:            hexutil.Uint64(origin),
+		SequencingWindowEnd: hexutil.Uint64(next.Number.Uint64() + s.config.SeqWindowSize),
+	}
+	if l1Head > origin {
+		logs, err := s.l1.FilterLogs(s.ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(origin + 1),
+			ToBlock:   new(big.Int).SetUint64(l1Head),
+			Addresses: []common.Address{s.config.Portal},
+			Topics:    [][]common.Hash{{derive.DepositEventABIHash}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch queued deposits: %w", err)
+		}
+		status.QueuedDeposits = hexutil.Uint64(len(logs))
+	}
+	return status, nil
+}
+
+// buildBlock builds the next block, including the transactions of the txpool unless forced
+func (s *sequencer) buildBlock(forced bool) (common.Hash, error) {
+	s.applyScheduledConfig()
+	chain := s.eth.BlockChain()
+	parent := chain.CurrentBlock()
+	timestamp := s.nextTime(parent)
+
+	origin, seqNumber, err := s.nextOrigin(parent, timestamp)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sysCfg, err := s.systemConfig(parent, origin, seqNumber)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	rollupCfg := s.rollupConfig(timestamp - parent.Time)
+	l1Info, err := derive.L1InfoDeposit(rollupCfg, sysCfg, seqNumber, opeth.HeaderBlockInfo(origin), timestamp)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to create L1 info deposit: %w", err)
+	}
+	txs := []*types.Transaction{types.NewTx(l1Info)}
+	if seqNumber == 0 {
+		deposits, err := s.deposits(origin)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		txs = append(txs, deposits...)
+	}
+	if rollupCfg.IsEcotoneActivationBlock(timestamp) {
+		upgradeTxs, err := ecotoneUpgradeTxs()
+		if err != nil {
+			return common.Hash{}, err
+		}
+		txs = append(txs, upgradeTxs...)
+	}
+
+	number := new(big.Int).Add(parent.Number, common.Big1)
+	var withdrawals types.Withdrawals
+	if chain.Config().IsShanghai(number, timestamp) {
+		withdrawals = types.Withdrawals{}
+	}
+	var beaconRoot *common.Hash
+	if chain.Config().IsCancun(number, timestamp) {
+		// Like op-node, the zero hash stands in for the beacon root of L1 origins before Cancun
+		beaconRoot = origin.ParentBeaconRoot
+		if beaconRoot == nil {
+			beaconRoot = new(common.Hash)
+		}
+	}
+	gasLimit := sysCfg.GasLimit
+	if gasLimit == 0 {
+		gasLimit = chain.Genesis().GasLimit()
+	}
+	payload, err := s.eth.Miner().BuildPayload(&miner.BuildPayloadArgs{
+		Parent:       parent.Hash(),
+		Timestamp:    timestamp,
+		FeeRecipient: predeploys.SequencerFeeVaultAddr,
+		Random:       origin.MixDigest,
+		Withdrawals:  withdrawals,
+		BeaconRoot:   beaconRoot,
+		Transactions: txs,
+		NoTxPool:     forced,
+		GasLimit:     &gasLimit,
+	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to build payload: %w", err)
+	}
+	if !forced {
+		// Resolving interrupts the block being filled from the tx pool, so wait for it to be built first
+		payload.WaitFull()
+	}
+	data := payload.ResolveFull().ExecutionPayload
+
+	var status engine.PayloadStatusV1
+	if beaconRoot != nil {
+		// Blob transactions are not accepted by op-geth, so the payload carries no blob hashes
+		status, err = s.engine.NewPayloadV3(*data, []common.Hash{}, beaconRoot)
+	} else {
+		status, err = s.engine.NewPayloadV2(*data)
+	}
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if status.Status != engine.VALID {
+		return common.Hash{}, fmt.Errorf("invalid payload: %s", status.Status)
+	}
+
+	// Without a batcher, blocks stay unsafe
+	safe, finalized := chain.Genesis().Hash(), chain.Genesis().Hash()
+	if h := chain.CurrentSafeBlock(); h != nil {
+		safe = h.Hash()
+	}
+	if h := chain.CurrentFinalBlock(); h != nil {
+		finalized = h.Hash()
+	}
+	state := engine.ForkchoiceStateV1{HeadBlockHash: data.BlockHash, SafeBlockHash: safe, FinalizedBlockHash: finalized}
+	if _, err := s.engine.ForkchoiceUpdatedV2(state, nil); err != nil {
+		return common.Hash{}, err
+	}
+	s.origin = origin
+	s.nextTimestamp = 0
+	s.log.Debug("sequenced block", "number", data.Number, "hash", data.BlockHash, "txs", len(data.Transactions), "l1Origin", origin.Number, "seqNumber", seqNumber)
+	return data.BlockHash, nil
+}
+
+// rewindOrphaned rewinds the L2 to its latest block whose L1 origin is still canonical after an L1 reorg,
+// so the orphaned blocks and their deposits are derived again from the new L1 chain
+func (s *sequencer) rewindOrphaned() error {
+	chain := s.eth.BlockChain()
+	head := chain.CurrentBlock()
+	canonical := make(map[uint64]common.Hash)
+	for h := head; h.Number.Sign() > 0; h = chain.GetHeaderByNumber(h.Number.Uint64() - 1) {
+		info, err := s.l1Info(h)
+		if err != nil {
+			return err
+		}
+		hash, ok := canonical[info.Number]
+		if !ok {
+			l1Header, err := s.l1.HeaderByNumber(s.ctx, new(big.Int).SetUint64(info.Number))
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				return fmt.Errorf("failed to fetch L1 block %d: %w", info.Number, err)
+			}
+			if l1Header != nil {
+				hash = l1Header.Hash()
+			}
+			canonical[info.Number] = hash
+		}
+		if hash == info.BlockHash {
+			if h.Number.Cmp(head.Number) == 0 {
+				return nil
+			}
+			return s.rewind(head, h)
+		}
+	}
+	return s.rewind(head, chain.Genesis().Header())
+}
+
+func (s *sequencer) rewind(head *types.Header, to *types.Header) error {
+	if err := s.setHead(to.Number.Uint64()); err != nil {
+		return err
+	}
+	s.log.Warn("rewound blocks of orphaned L1 origins", "from", head.Number, "to", to.Number)
+	return nil
+}
+
+// Rewind resets the head of the L2 to a previous block. The blocks due since then are built again on the next tick
+func (s *sequencer) Rewind(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setHead(number)
+}
+
+func (s *sequencer) setHead(number uint64) error {
+	if err := s.eth.BlockChain().SetHead(number); err != nil {
+		return fmt.Errorf("failed to rewind to block %d: %w", number, err)
+	}
+	s.origin = nil
+	s.nextTimestamp = 0
+	return nil
+}
+
+// nextOrigin returns the L1 origin of the block after parent and its sequence number in the epoch.
+// The origin advances to the next L1 block once the L2 timestamp reaches it.
+func (s *sequencer) nextOrigin(parent *types.Header, timestamp uint64) (*types.Header, uint64, error) {
+	if parent.Number.Sign() == 0 {
+		origin, err := s.l1.HeaderByNumber(s.ctx, new(big.Int).SetUint64(s.config.L1Genesis))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch L1 genesis: %w", err)
+		}
+		return origin, 0, nil
+	}
+
+	info, err := s.l1Info(parent)
+	if err != nil {
+		return nil, 0, err
+	}
+	origin := s.origin
+	if origin == nil || origin.Hash() != info.BlockHash {
+		if origin, err = s.l1.HeaderByHash(s.ctx, info.BlockHash); err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch L1 origin %d: %w", info.Number, err)
+		}
+	}
+
+	next, err := s.l1.HeaderByNumber(s.ctx, new(big.Int).Add(origin.Number, common.Big1))
+	if errors.Is(err, ethereum.NotFound) {
+		return origin, info.SequenceNumber + 1, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch L1 block %d: %w", origin.Number.Uint64()+1, err)
+	}
+	if next.Time <= timestamp {
+		return next, 0, nil
+	}
+	return origin, info.SequenceNumber + 1, nil
+}
+
+// systemConfig returns the system config of the block after parent. Like op-node, the config of parent
+// is recovered from its L1 info deposit and gas limit, and the first block of an epoch applies the
+// ConfigUpdate events the SystemConfig emitted in its L1 origin.
+func (s *sequencer) systemConfig(parent *types.Header, origin *types.Header, seqNumber uint64) (opeth.SystemConfig, error) {
+	if parent.Number.Sign() == 0 {
+		return s.config.SystemConfig, nil
+	}
+	block := s.eth.BlockChain().GetBlock(parent.Hash(), parent.Number.Uint64())
+	if block == nil {
+		return opeth.SystemConfig{}, fmt.Errorf("block %d not found", parent.Number)
+	}
+	rollupCfg := s.rollupConfig(s.interval(parent))
+	payload, err := opeth.BlockAsPayload(block, rollupCfg.CanyonTime)
+	if err != nil {
+		return opeth.SystemConfig{}, fmt.Errorf("failed to convert block %d to payload: %w", parent.Number, err)
+	}
+	sysCfg, err := derive.PayloadToSystemConfig(rollupCfg, payload)
+	if err != nil {
+		return opeth.SystemConfig{}, fmt.Errorf("failed to recover system config of block %d: %w", parent.Number, err)
+	}
+	if seqNumber != 0 {
+		return sysCfg, nil
+	}
+
+	hash := origin.Hash()
+	logs, err := s.l1.FilterLogs(s.ctx, ethereum.FilterQuery{
+		BlockHash: &hash,
+		Addresses: []common.Address{s.config.SystemConfigAddr},
+		Topics:    [][]common.Hash{{derive.ConfigUpdateEventABIHash}},
+	})
+	if err != nil {
+		return opeth.SystemConfig{}, fmt.Errorf("failed to fetch system config updates of L1 block %d: %w", origin.Number, err)
+	}
+	for i := range logs {
+		if err := derive.ProcessSystemConfigUpdateLogEvent(&sysCfg, &logs[i], rollupCfg, origin.Time); err != nil {
+			s.log.Error("skipping invalid system config update", "tx", logs[i].TxHash, "err", err)
+			continue
+		}
+		s.log.Info("applied system config update", "l1Origin", origin.Number, "batcher", sysCfg.BatcherAddr, "gasLimit", sysCfg.GasLimit)
+	}
+	return sysCfg, nil
+}
+
+// l1Info decodes the L1 info deposit of an L2 block
+func (s *sequencer) l1Info(h *types.Header) (*derive.L1BlockInfo, error) {
+	block := s.eth.BlockChain().GetBlock(h.Hash(), h.Number.Uint64())
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", h.Number)
+	}
+	txs := block.Transactions()
+	if len(txs) == 0 || txs[0].Type() != types.DepositTxType {
+		return nil, fmt.Errorf("block %d has no L1 info deposit", h.Number)
+	}
+	info, err := derive.L1BlockInfoFromBytes(s.rollupConfig(s.interval(h)), h.Time, txs[0].Data())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode L1 info deposit of block %d: %w", h.Number, err)
+	}
+	return info, nil
+}
+
+// rollupConfig returns the hardforks of the L2 as the op-node rollup config the L1 info deposit of a block is
+// built with. Its block time is the interval of the block to its parent, so the first Ecotone block is still
+// found after the sequencer skipped ahead in time
+func (s *sequencer) rollupConfig(interval uint64) *rollup.Config {
+	genesisTime := s.eth.BlockChain().Genesis().Time()
+	hardforks := s.config.Hardforks
+	return &rollup.Config{
+		BlockTime:    interval,
+		RegolithTime: hardforks.RegolithTime(genesisTime),
+		CanyonTime:   hardforks.CanyonTime(genesisTime),
+		DeltaTime:    hardforks.DeltaTime(genesisTime),
+		EcotoneTime:  hardforks.EcotoneTime(genesisTime),
+	}
+}
+
+// interval returns the seconds between an L2 block and its parent
+func (s *sequencer) interval(h *types.Header) uint64 {
+	if h.Number.Sign() == 0 {
+		return s.period
+	}
+	parent := s.eth.BlockChain().GetHeader(h.ParentHash, h.Number.Uint64()-1)
+	if parent == nil {
+		return s.period
+	}
+	return h.Time - parent.Time
+}
+
+// ecotoneUpgradeTxs returns the deposits that deploy the Ecotone L1Block and GasPriceOracle and the beacon
+// block root contract of EIP-4788, and upgrade the predeploys to them
+func ecotoneUpgradeTxs() ([]*types.Transaction, error) {
+	upgradeTxs, err := derive.EcotoneNetworkUpgradeTransactions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ecotone upgrade transactions: %w", err)
+	}
+	txs := make([]*types.Transaction, len(upgradeTxs))
+	for i, data := range upgradeTxs {
+		txs[i] = new(types.Transaction)
+		if err := txs[i].UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("failed to decode Ecotone upgrade transaction: %w", err)
+		}
+	}
+	return txs, nil
+}
+
+// deposits returns the deposit transactions the OptimismPortal emitted in an L1 block
+func (s *sequencer) deposits(origin *types.Header) ([]*types.Transaction, error) {
+	hash := origin.Hash()
+	logs, err := s.l1.FilterLogs(s.ctx, ethereum.FilterQuery{
+		BlockHash: &hash,
+		Addresses: []common.Address{s.config.Portal},
+		Topics:    [][]common.Hash{{derive.DepositEventABIHash}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deposits of L1 block %d: %w", origin.Number, err)
+	}
+
+	var txs []*types.Transaction
+	for i := range logs {
+		deposit, err := derive.UnmarshalDepositLogEvent(&logs[i])
+		if err != nil {
+			s.log.Error("skipping invalid deposit", "tx", logs[i].TxHash, "err", err)
+			continue
+		}
+		txs = append(txs, types.NewTx(deposit))
+	}
+	return txs, nil
+}
+
+// adminAPI implements the sequencer methods of the admin API of op-node, plus admin_sequencerStatus and the
+// hardfork schedule
+type adminAPI struct {
+	seq *sequencer
+}
+
+// StopSequencer implements admin_stopSequencer
+func (api *adminAPI) StopSequencer() (common.Hash, error) {
+	return api.seq.StopSequencer()
+}
+
+// StartSequencer implements admin_startSequencer
+func (api *adminAPI) StartSequencer(head common.Hash) error {
+	return api.seq.StartSequencer(head)
+}
+
+// SequencerActive implements admin_sequencerActive
+func (api *adminAPI) SequencerActive() bool {
+	return api.seq.SequencerActive()
+}
+
+// SequencerStatus implements admin_sequencerStatus
+func (api *adminAPI) SequencerStatus() (*SequencerStatus, error) {
+	return api.seq.Status()
+}
+
+// Hardforks implements admin_hardforks
+func (api *adminAPI) Hardforks() config.Hardforks {
+	return api.seq.Hardforks()
+}
+
+// ScheduleHardfork implements admin_scheduleHardfork
+func (api *adminAPI) ScheduleHardfork(name string, timestamp hexutil.Uint64) error {
+	return api.seq.ScheduleHardfork(name, uint64(timestamp))
+}
@@ -0,0 +1,478 @@
+// Package interop relays messages between sibling L2s, the L2s of the same L1.
+//
+// Messages are sent through the L2CrossDomainMessenger predeploy, which the relayer installs on the L2s,
+// to the interop inbox of a sibling with the target on the sibling and the calldata of the target ABI
+// encoded. Like a message of the L1CrossDomainMessenger, the message is relayed by calling relayMessage
+// of the L2CrossDomainMessenger of the sibling from the aliased L1CrossDomainMessenger, minting the value
+// of the message first. The value stays in the L2ToL1MessagePasser of the source like a withdrawal.
+package interop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/services/relayer"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-chain-ops/crossdomain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	SERVICE_TYPE = "interop"
+)
+
+const (
+	pollInterval = 200 * time.Millisecond
+	// maxBlockRange limits the range of a single eth_getLogs request
+	maxBlockRange = 1000
+	// Number of messages reported by Messages
+	maxMessageHistory = 100
+	// Gas of relayed messages on top of their minimum gas limit, free like the gas of deposits
+	relayGasLimit = 5_000_000
+)
+
+var (
+	messengerABI = mustABI(bindings.L2CrossDomainMessengerMetaData)
+
+	// The storage slots of the L2CrossDomainMessenger initialize sets: the initialized version in the
+	// first slot after the spacer, the sender of the message being relayed and the other messenger
+	initializedSlot      = common.BigToHash(big.NewInt(0))
+	initializedValue     = common.BigToHash(new(big.Int).Lsh(big.NewInt(1), 160))
+	xDomainMsgSenderSlot = common.BigToHash(big.NewInt(204))
+	otherMessengerSlot   = common.BigToHash(big.NewInt(207))
+	// The xDomainMessageSender outside of relayed messages
+	defaultL2Sender = common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+)
+
+func mustABI(metadata *bind.MetaData) *abi.ABI {
+	parsed, err := metadata.GetAbi()
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// MessageStatus is the progress of a message sent to a sibling
+type MessageStatus string
+
+const (
+	// The message waits for the interop latency
+	MessagePending MessageStatus = "pending"
+	MessageRelayed MessageStatus = "relayed"
+	// The message was relayed, but the call of its target reverted
+	MessageFailed MessageStatus = "failed"
+)
+
+// Message reports a message sent to a sibling L2
+type Message struct {
+	Source      string         `json:"source"`
+	Destination string         `json:"destination"`
+	Sender      common.Address `json:"sender"`
+	Target      common.Address `json:"target"`
+	Value       *hexutil.Big   `json:"value"`
+	Data        hexutil.Bytes  `json:"data"`
+	SourceBlock hexutil.Uint64 `json:"sourceBlock"`
+	SourceTx    common.Hash    `json:"sourceTx"`
+	// The transaction relaying the message on the sibling, once relayed
+	RelayTx *common.Hash  `json:"relayTx,omitempty"`
+	Status  MessageStatus `json:"status"`
+
+	// The inbox the message was sent to, the nonce and minimum gas limit the source L2CrossDomainMessenger
+	// assigned to it and when it can be relayed
+	inbox    common.Address
+	nonce    *big.Int
+	gasLimit uint64
+	readyAt  time.Time
+}
+
+// InboxAddress returns the interop inbox of an L2 on its siblings following the 0xfe00..<chain id>
+// convention of the batch inbox
+func InboxAddress(chainID uint) common.Address {
+	return common.HexToAddress(fmt.Sprintf("0xfe%038d", chainID))
+}
+
+var payloadArgs = abi.Arguments{{Type: mustType("address")}, {Type: mustType("bytes")}}
+
+func mustType(t string) abi.Type {
+	typ, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+// EncodeMessage encodes the message to an inbox for a call of the target on the sibling
+func EncodeMessage(target common.Address, data []byte) ([]byte, error) {
+	return payloadArgs.Pack(target, data)
+}
+
+// DecodeMessage decodes the target and its calldata from the message to an inbox
+func DecodeMessage(message []byte) (common.Address, []byte, error) {
+	values, err := payloadArgs.Unpack(message)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return values[0].(common.Address), values[1].([]byte), nil
+}
+
+// sibling is an L2 messages are relayed to
+type sibling struct {
+	chain  config.Chain
+	client *rpc.Client
+}
+
+// Relayer relays the messages sent from an L2 to its siblings
+type Relayer struct {
+	log     log.Logger
+	l2      config.Chain
+	latency time.Duration
+	// Siblings by their inbox
+	siblings map[common.Address]*sibling
+
+	client *rpc.Client
+	// The L1CrossDomainMessenger the L2CrossDomainMessengers relay messages from
+	otherMessenger common.Address
+
+	mu sync.Mutex
+	// The next block of the L2 to scan for messages
+	cursor   uint64
+	messages []*Message
+}
+
+func NewRelayer(logger log.Logger, l2 config.Chain, siblings []config.Chain) (*Relayer, error) {
+	if !l2.IsL2() {
+		return nil, fmt.Errorf("chain %s is not an L2", l2.Name)
+	}
+	if len(siblings) == 0 {
+		return nil, fmt.Errorf("chain %s has no siblings", l2.Name)
+	}
+	for _, s := range siblings {
+		if !s.IsL2() || s.BaseChainID != l2.BaseChainID || s.ChainID == l2.ChainID {
+			return nil, fmt.Errorf("chain %s is not a sibling of chain %s", s.Name, l2.Name)
+		}
+	}
+
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	otherMessenger, ok := addresses["L1CrossDomainMessengerProxy"]
+	if !ok {
+		return nil, fmt.Errorf("L1CrossDomainMessengerProxy missing from the generated addresses")
+	}
+	client, err := rpc.Dial(l2.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L2 RPC: %w", err)
+	}
+	r := &Relayer{
+		log:            logger,
+		l2:             l2,
+		latency:        time.Duration(l2.InteropLatencyMs) * time.Millisecond,
+		siblings:       make(map[common.Address]*sibling, len(siblings)),
+		client:         client,
+		otherMessenger: otherMessenger,
+	}
+	for _, s := range siblings {
+		c, err := rpc.Dial(s.RPCURL())
+		if err != nil {
+			r.close()
+			return nil, fmt.Errorf("failed to dial RPC of sibling %s: %w", s.Name, err)
+		}
+		r.siblings[InboxAddress(s.ChainID)] = &sibling{chain: s, client: c}
+	}
+	return r, nil
+}
+
+func (r *Relayer) close() {
+	r.client.Close()
+	for _, s := range r.siblings {
+		s.client.Close()
+	}
+}
+
+func (r *Relayer) ID() string {
+	return fmt.Sprintf("%s-%s", SERVICE_TYPE, r.l2.Name)
+}
+
+// L2 returns the config of the chain messages are relayed from
+func (r *Relayer) L2() config.Chain {
+	return r.l2
+}
+
+// Start polls for new messages and relays them once the latency elapsed until the context is canceled
+func (r *Relayer) Start(ctx context.Context) error {
+	defer r.close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := r.relay(ctx, time.Now()); err != nil {
+				r.log.Debug("failed to relay messages", "err", err)
+			}
+		}
+	}
+}
+
+// Cursor returns the next block of the L2 scanned for messages
+func (r *Relayer) Cursor() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cursor
+}
+
+// SetCursor moves the relayer to a new block, e.g. after the chains were reverted to a snapshot.
+// Pending messages of later blocks are dropped.
+func (r *Relayer) SetCursor(cursor uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setCursor(cursor)
+}
+
+func (r *Relayer) setCursor(cursor uint64) {
+	r.cursor = cursor
+	messages := r.messages[:0]
+	for _, m := range r.messages {
+		if m.Status != MessagePending || uint64(m.SourceBlock) < cursor {
+			messages = append(messages, m)
+		}
+	}
+	r.messages = messages
+}
+
+// Messages returns the most recent messages sent from the L2 to its siblings, oldest first
+func (r *Relayer) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]Message, 0, len(r.messages))
+	for _, m := range r.messages {
+		messages = append(messages, *m)
+	}
+	return messages
+}
+
+// RelayPending relays every message sent up to the current L2 head without waiting for the latency and
+// returns the relayed messages
+func (r *Relayer) RelayPending(ctx context.Context) ([]Message, error) {
+	return r.relay(ctx, time.Time{})
+}
+
+// relay picks up the messages sent since the last call and relays the pending messages ready at a time.
+// The zero time relays every pending message.
+func (r *Relayer) relay(ctx context.Context, now time.Time) ([]Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.installMessengers(ctx); err != nil {
+		return nil, err
+	}
+	var head hexutil.Uint64
+	if err := r.client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 head: %w", err)
+	}
+	// The L2 was rolled back, e.g. after a reorg of its L1
+	if uint64(head)+1 < r.cursor {
+		r.setCursor(uint64(head) + 1)
+	}
+	for r.cursor <= uint64(head) {
+		end := min(r.cursor+maxBlockRange-1, uint64(head))
+		messages, err := r.sentMessages(ctx, r.cursor, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			m.readyAt = time.Now().Add(r.latency)
+			r.messages = append(r.messages, m)
+		}
+		r.cursor = end + 1
+	}
+
+	relayed := []Message{}
+	for _, m := range r.messages {
+		if m.Status != MessagePending || (!now.IsZero() && now.Before(m.readyAt)) {
+			continue
+		}
+		if err := r.relayMessage(ctx, m); err != nil {
+			return relayed, fmt.Errorf("failed to relay message of L2 transaction %s: %w", m.SourceTx, err)
+		}
+		relayed = append(relayed, *m)
+	}
+	// Pending messages are kept beyond the history
+	for len(r.messages) > maxMessageHistory && r.messages[0].Status != MessagePending {
+		r.messages = r.messages[1:]
+	}
+	return relayed, nil
+}
+
+// installMessengers installs the L2CrossDomainMessenger and the L2ToL1MessagePasser it sends messages
+// through on the L2 and the L2CrossDomainMessenger on the siblings, which rollbacks of the L2s may undo
+func (r *Relayer) installMessengers(ctx context.Context) error {
+	if _, err := installPredeploy(ctx, r.client, predeploys.L2ToL1MessagePasserAddr, bindings.L2ToL1MessagePasserDeployedBin); err != nil {
+		return fmt.Errorf("failed to install L2ToL1MessagePasser: %w", err)
+	}
+	if err := r.installMessenger(ctx, r.client); err != nil {
+		return err
+	}
+	for _, s := range r.siblings {
+		if err := r.installMessenger(ctx, s.client); err != nil {
+			return fmt.Errorf("sibling %s: %w", s.chain.Name, err)
+		}
+	}
+	return nil
+}
+
+// installMessenger installs the L2CrossDomainMessenger and initializes its storage like initialize
+// with the L1CrossDomainMessenger as the other messenger
+func (r *Relayer) installMessenger(ctx context.Context, client *rpc.Client) error {
+	installed, err := installPredeploy(ctx, client, predeploys.L2CrossDomainMessengerAddr, bindings.L2CrossDomainMessengerDeployedBin)
+	if err != nil {
+		return fmt.Errorf("failed to install L2CrossDomainMessenger: %w", err)
+	}
+	if !installed {
+		return nil
+	}
+	for _, slot := range []struct {
+		key   common.Hash
+		value common.Hash
+	}{
+		{initializedSlot, initializedValue},
+		{xDomainMsgSenderSlot, common.BytesToHash(defaultL2Sender.Bytes())},
+		{otherMessengerSlot, common.BytesToHash(r.otherMessenger.Bytes())},
+	} {
+		if err := client.CallContext(ctx, nil, "anvil_setStorageAt", predeploys.L2CrossDomainMessengerAddr, slot.key, slot.value); err != nil {
+			return fmt.Errorf("failed to initialize L2CrossDomainMessenger: %w", err)
+		}
+	}
+	return nil
+}
+
+// installPredeploy sets the code of a predeploy without code and reports whether it did
+func installPredeploy(ctx context.Context, client *rpc.Client, addr common.Address, code string) (bool, error) {
+	var current hexutil.Bytes
+	if err := client.CallContext(ctx, &current, "eth_getCode", addr, "latest"); err != nil {
+		return false, err
+	}
+	if len(current) > 0 {
+		return false, nil
+	}
+	if err := client.CallContext(ctx, nil, "anvil_setCode", addr, code); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sentMessages returns the messages sent through the L2CrossDomainMessenger to the inboxes of the
+// siblings within a block range
+func (r *Relayer) sentMessages(ctx context.Context, start, end uint64) ([]*Message, error) {
+	sentMessage, sentMessageExtension := messengerABI.Events["SentMessage"], messengerABI.Events["SentMessageExtension1"]
+	logs, err := ethclient.NewClient(r.client).FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(start),
+		ToBlock:   new(big.Int).SetUint64(end),
+		Addresses: []common.Address{predeploys.L2CrossDomainMessengerAddr},
+		Topics:    [][]common.Hash{{sentMessage.ID, sentMessageExtension.ID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter sent messages: %w", err)
+	}
+	filterer, err := bindings.NewL2CrossDomainMessengerFilterer(predeploys.L2CrossDomainMessengerAddr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*Message
+	// The L2CrossDomainMessenger emits the value of a message in a SentMessageExtension1 right after it
+	for i := 0; i+1 < len(logs); i++ {
+		if logs[i].Topics[0] != sentMessage.ID || logs[i+1].Topics[0] != sentMessageExtension.ID ||
+			logs[i+1].TxHash != logs[i].TxHash {
+			continue
+		}
+		sent, err := filterer.ParseSentMessage(logs[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sent message: %w", err)
+		}
+		extension, err := filterer.ParseSentMessageExtension1(logs[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sent message: %w", err)
+		}
+		s, ok := r.siblings[sent.Target]
+		if !ok {
+			continue
+		}
+		target, data, err := DecodeMessage(sent.Message)
+		if err != nil {
+			r.log.Error("skipping message without target", "tx", logs[i].TxHash, "sibling", s.chain.Name, "err", err)
+			continue
+		}
+		messages = append(messages, &Message{
+			Source:      r.l2.Name,
+			Destination: s.chain.Name,
+			Sender:      sent.Sender,
+			Target:      target,
+			Value:       (*hexutil.Big)(extension.Value),
+			Data:        data,
+			SourceBlock: hexutil.Uint64(logs[i].BlockNumber),
+			SourceTx:    logs[i].TxHash,
+			Status:      MessagePending,
+			inbox:       sent.Target,
+			nonce:       sent.MessageNonce,
+			gasLimit:    sent.GasLimit.Uint64(),
+		})
+	}
+	return messages, nil
+}
+
+// relayMessage relays a message through the L2CrossDomainMessenger of its sibling from the aliased
+// L1CrossDomainMessenger, which sets the sender as the xDomainMessageSender while calling the target
+func (r *Relayer) relayMessage(ctx context.Context, m *Message) error {
+	s := r.siblings[m.inbox]
+	data, err := messengerABI.Pack("relayMessage", m.nonce, m.Sender, m.Target, m.Value.ToInt(), new(big.Int).SetUint64(m.gasLimit), []byte(m.Data))
+	if err != nil {
+		return err
+	}
+	messenger := predeploys.L2CrossDomainMessengerAddr
+	txHash, _, err := relayer.Execute(ctx, r.log, s.client, relayer.Call{
+		From:  crossdomain.ApplyL1ToL2Alias(r.otherMessenger),
+		To:    &messenger,
+		Mint:  m.Value.ToInt(),
+		Value: m.Value.ToInt(),
+		Gas:   relayGasLimit + m.gasLimit,
+		Data:  data,
+	})
+	if err != nil {
+		return err
+	}
+	receipt, err := ethclient.NewClient(s.client).TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("relay transaction %s was not mined", txHash)
+	} else if err != nil {
+		return err
+	}
+
+	m.RelayTx = &txHash
+	// The messenger records a reverting target as a failed message instead of reverting
+	m.Status = MessageFailed
+	for _, l := range receipt.Logs {
+		if l.Address == messenger && len(l.Topics) > 0 && l.Topics[0] == messengerABI.Events["RelayedMessage"].ID {
+			m.Status = MessageRelayed
+		}
+	}
+	r.log.Info("relayed message", "destination", m.Destination, "sender", m.Sender, "target", m.Target, "value", m.Value, "sourceTx", m.SourceTx, "relayTx", txHash, "status", m.Status)
+	return nil
+}
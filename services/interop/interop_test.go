@@ -0,0 +1,133 @@
+package interop
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboxAddress(t *testing.T) {
+	require.Equal(t, common.HexToAddress("0xfe00000000000000000000000000000000000902"), InboxAddress(902))
+}
+
+func TestMessageEncoding(t *testing.T) {
+	target := common.HexToAddress("0x1234")
+	message, err := EncodeMessage(target, []byte{1, 2, 3})
+	require.NoError(t, err)
+	decodedTarget, data, err := DecodeMessage(message)
+	require.NoError(t, err)
+	require.Equal(t, target, decodedTarget)
+	require.Equal(t, []byte{1, 2, 3}, data)
+
+	_, _, err = DecodeMessage([]byte{1, 2, 3})
+	require.Error(t, err)
+}
+
+func TestNewRelayerRequiresSiblings(t *testing.T) {
+	l2 := config.Chain{Name: "A", ChainID: 901, BaseChainID: 900, Host: "127.0.0.1", Port: 9545}
+	for _, siblings := range [][]config.Chain{
+		nil,
+		{l2},
+		{{Name: "L1", ChainID: 900, BaseChainID: 900}},
+		{{Name: "B", ChainID: 902, BaseChainID: 1}},
+	} {
+		_, err := NewRelayer(log.New("module", "test"), l2, siblings)
+		require.Error(t, err)
+	}
+}
+
+func TestRelayerRelaysMessagesToSiblings(t *testing.T) {
+	a := config.Chain{Name: "A", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000,
+		Backend: config.BackendSimulated, Interop: true, InteropLatencyMs: 500}
+	b := config.Chain{Name: "B", ChainID: 902, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000,
+		Backend: config.BackendSimulated, Interop: true}
+	a, rpcA := simtest.StartSimulated(t, a)
+	b, rpcB := simtest.StartSimulated(t, b)
+	clientA, clientB := ethclient.NewClient(rpcA), ethclient.NewClient(rpcB)
+
+	r, err := NewRelayer(log.New("module", "test", "service", SERVICE_TYPE), a, []config.Chain{b})
+	require.NoError(t, err)
+	defer r.close()
+
+	// Send ETH to bob on B, and a call of a reverting contract on B
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 2)
+	require.NoError(t, err)
+	alice, bob := accs[0], accs[1]
+	reverter := common.HexToAddress("0x1234")
+	require.NoError(t, clientB.Client().Call(nil, "anvil_setCode", reverter, hexutil.Bytes{0xfe}))
+	// The first pass installs the L2CrossDomainMessengers on A and B
+	_, err = r.relay(context.Background(), time.Now())
+	require.NoError(t, err)
+	send := func(nonce uint64, target common.Address, value int64) *types.Transaction {
+		message, err := EncodeMessage(target, []byte{1})
+		require.NoError(t, err)
+		data, err := messengerABI.Pack("sendMessage", InboxAddress(902), message, uint32(100_000))
+		require.NoError(t, err)
+		messenger := predeploys.L2CrossDomainMessengerAddr
+		tx, err := types.SignNewTx(alice.PrivateKey, types.LatestSignerForChainID(big.NewInt(901)), &types.DynamicFeeTx{
+			ChainID:   big.NewInt(901),
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(params.GWei * 10),
+			Gas:       500_000,
+			To:        &messenger,
+			Value:     big.NewInt(value),
+			Data:      data,
+		})
+		require.NoError(t, err)
+		require.NoError(t, clientA.SendTransaction(context.Background(), tx))
+		return tx
+	}
+	tx := send(0, bob.Address, 1000)
+	failing := send(1, reverter, 0)
+	before, err := clientB.BalanceAt(context.Background(), bob.Address, nil)
+	require.NoError(t, err)
+
+	// The message waits for the latency
+	relayed, err := r.relay(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.Empty(t, relayed)
+	messages := r.Messages()
+	require.Len(t, messages, 2)
+	require.Equal(t, MessagePending, messages[0].Status)
+	require.Equal(t, tx.Hash(), messages[0].SourceTx)
+	require.Equal(t, "B", messages[0].Destination)
+	require.Equal(t, alice.Address, messages[0].Sender)
+	require.Equal(t, bob.Address, messages[0].Target)
+	require.EqualValues(t, 1000, messages[0].Value.ToInt().Int64())
+	require.Equal(t, hexutil.Bytes{1}, messages[0].Data)
+	require.Equal(t, failing.Hash(), messages[1].SourceTx)
+
+	relayed, err = r.relay(context.Background(), time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, relayed, 2)
+	require.Equal(t, MessageRelayed, relayed[0].Status)
+	require.Equal(t, MessageFailed, relayed[1].Status)
+	require.NotNil(t, relayed[0].RelayTx)
+	require.Equal(t, relayed, r.Messages())
+	after, err := clientB.BalanceAt(context.Background(), bob.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Add(before, big.NewInt(1000)), after)
+	// The value stays in the L2ToL1MessagePasser of A
+	escrowed, err := clientA.BalanceAt(context.Background(), predeploys.L2ToL1MessagePasserAddr, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1000, escrowed.Int64())
+
+	// Messages are relayed once
+	relayed, err = r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, relayed)
+}
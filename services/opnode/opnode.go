@@ -0,0 +1,191 @@
+package opnode
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/optimism/op-node/metrics"
+	"github.com/ethereum-optimism/optimism/op-node/node"
+	oprollup "github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/driver"
+	"github.com/ethereum-optimism/optimism/op-service/sources"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	SERVICE_TYPE = "op-node"
+)
+
+const (
+	// Interval of polling the L1 for new heads and of retrying to reach the chains on startup
+	pollInterval = time.Second
+	// Interval of polling the safe and finalized L1 blocks
+	epochPollInterval = 2 * time.Second
+	// Default of the op-node l1.rpc-max-batch-size flag
+	l1BatchSize = 20
+	// Default of the op-node l1.max-concurrency flag
+	l1MaxConcurrency = 10
+)
+
+// OpNode runs the op-node rollup node in-process for a geth L2. It sequences the L2 through the
+// engine API and derives its safe chain from the batches and deposits on the L1, like in production.
+type OpNode struct {
+	id  string
+	log log.Logger
+	l1  config.Chain
+	l2  config.Chain
+
+	// The URL of the rollup RPC of the running op-node, empty while it is not running
+	mu     sync.Mutex
+	rpcURL string
+}
+
+func validateConfig(l1 config.Chain, l2 config.Chain) error {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	if l2.Backend != config.BackendGeth {
+		return fmt.Errorf("op-node requires a geth L2")
+	}
+	if l2.AuthPort == 0 {
+		return fmt.Errorf("op-node requires the engine API of the L2")
+	}
+	return nil
+}
+
+func NewOpNode(logger log.Logger, l1 config.Chain, l2 config.Chain) (*OpNode, error) {
+	if err := validateConfig(l1, l2); err != nil {
+		return nil, err
+	}
+	return &OpNode{
+		id:  l2.Name,
+		log: logger,
+		l1:  l1,
+		l2:  l2,
+	}, nil
+}
+
+func (n *OpNode) Hostname() string {
+	return n.l2.Host
+}
+
+func (n *OpNode) Port() int {
+	return int(n.l2.AuthPort)
+}
+
+func (n *OpNode) ServiceType() string {
+	return SERVICE_TYPE
+}
+
+func (n *OpNode) ID() string {
+	return n.id
+}
+
+func (n *OpNode) Config() interface{} {
+	return map[string]string{
+		"l1":     n.l1.Name,
+		"l2":     n.l2.Name,
+		"engine": n.l2.AuthURL(),
+	}
+}
+
+// Start waits for the L1 and L2 to serve the rollup config and runs op-node until the context is canceled
+func (n *OpNode) Start(ctx context.Context) error {
+	rollupCfg, err := n.rollupConfig(ctx)
+	if err != nil {
+		return err
+	}
+	n.log.Info("Starting op-node...", "l1", n.l1.WSURL(), "engine", n.l2.AuthURL())
+
+	opNode, err := node.New(ctx, n.nodeConfig(rollupCfg), n.log, n.log, "mocktimism", metrics.NewMetrics("default"))
+	if err != nil {
+		return fmt.Errorf("failed to create op-node: %w", err)
+	}
+	if err := opNode.Start(ctx); err != nil {
+		if stopErr := opNode.Stop(context.Background()); stopErr != nil {
+			n.log.Error("failed to stop op-node", "err", stopErr)
+		}
+		return fmt.Errorf("failed to start op-node: %w", err)
+	}
+	n.log.Info("Started op-node", "rpc", opNode.HTTPEndpoint())
+	n.mu.Lock()
+	n.rpcURL = opNode.HTTPEndpoint()
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		n.rpcURL = ""
+		n.mu.Unlock()
+	}()
+
+	<-ctx.Done()
+	if err := opNode.Stop(context.Background()); err != nil {
+		n.log.Error("failed to stop op-node", "err", err)
+		return err
+	}
+	n.log.Info("op-node terminated normally")
+	return nil
+}
+
+// RollupRPC returns the URL of the rollup RPC of the running op-node
+func (n *OpNode) RollupRPC() (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.rpcURL == "" {
+		return "", fmt.Errorf("op-node of %s is not running", n.l2.Name)
+	}
+	return n.rpcURL, nil
+}
+
+// rollupConfig generates the rollup config from the genesis blocks of the L1 and L2, retrying until both serve them
+func (n *OpNode) rollupConfig(ctx context.Context) (*oprollup.Config, error) {
+	api, err := rollup.NewAPI(n.log, n.l1, n.l2)
+	if err != nil {
+		return nil, err
+	}
+	defer api.Close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		cfg, err := api.RollupConfig(ctx)
+		if err == nil {
+			return cfg.OpNodeConfig()
+		}
+		n.log.Debug("waiting for the L1 and L2", "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *OpNode) nodeConfig(rollupCfg *oprollup.Config) *node.Config {
+	return &node.Config{
+		L1: &node.L1EndpointConfig{
+			L1NodeAddr:       n.l1.WSURL(),
+			L1RPCKind:        sources.RPCKindBasic,
+			BatchSize:        l1BatchSize,
+			MaxConcurrency:   l1MaxConcurrency,
+			HttpPollInterval: pollInterval,
+		},
+		L2: &node.L2EndpointConfig{
+			L2EngineAddr:      n.l2.AuthURL(),
+			L2EngineJWTSecret: common.HexToHash(geth.JWTSecret),
+		},
+		Driver: driver.Config{
+			SequencerEnabled: true,
+		},
+		Rollup: *rollupCfg,
+		// The RPC listens on a random port, the gateway serves the rollup API of the L2
+		RPC:                 node.RPCConfig{ListenAddr: "127.0.0.1"},
+		L1EpochPollInterval: epochPollInterval,
+		ConfigPersistence:   node.DisabledConfigPersistence{},
+	}
+}
@@ -0,0 +1,97 @@
+package opnode
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/batcher"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpNodeValidation(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}
+	for _, l2 := range []config.Chain{
+		{Name: "L2", ChainID: 901, BaseChainID: 1, Backend: config.BackendGeth, AuthPort: 8551},
+		{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendAnvil, AuthPort: 8551},
+		{Name: "L2", ChainID: 901, BaseChainID: 900, Backend: config.BackendGeth},
+	} {
+		_, err := NewOpNode(log.New("module", "test"), l1, l2)
+		require.Error(t, err)
+	}
+}
+
+func TestOpNodeSequencesL2(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Backend: config.BackendGeth, Derivation: true, BlockTime: 1}
+	l1, _ = simtest.StartGeth(t, l1, geth.GethConfig{BlockTime: 1})
+	l2, _ = simtest.StartGeth(t, l2, geth.GethConfig{Engine: true, OpGeth: true})
+
+	n, err := NewOpNode(log.New("module", "test", "service", SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	simtest.Start(t, n)
+
+	client, err := rpc.Dial(l2.RPCURL())
+	require.NoError(t, err)
+	defer client.Close()
+	require.Eventually(t, func() bool {
+		var number hexutil.Uint64
+		err := client.Call(&number, "eth_blockNumber")
+		return err == nil && number >= 2
+	}, 30*time.Second, 200*time.Millisecond)
+
+	// Every block starts with the L1 info deposit
+	var block struct {
+		Transactions []struct {
+			Type hexutil.Uint64 `json:"type"`
+		} `json:"transactions"`
+	}
+	require.NoError(t, client.Call(&block, "eth_getBlockByNumber", "0x1", true))
+	require.NotEmpty(t, block.Transactions)
+	require.Equal(t, hexutil.Uint64(0x7e), block.Transactions[0].Type)
+
+	// The rollup RPC of op-node is served through the proxy of the gateway
+	proxy := rollup.NewProxy(n)
+	defer proxy.Close()
+	var cfg struct {
+		L2ChainID uint64 `json:"l2_chain_id"`
+	}
+	data, err := proxy.RollupConfig(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &cfg))
+	require.Equal(t, uint64(901), cfg.L2ChainID)
+}
+
+func TestOpNodeDerivesSafeChain(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Backend: config.BackendGeth, Derivation: true, Batcher: true, BatchInterval: 1, BlockTime: 1}
+	l1, _ = simtest.StartGeth(t, l1, geth.GethConfig{BlockTime: 1})
+	l2, _ = simtest.StartGeth(t, l2, geth.GethConfig{Engine: true, OpGeth: true})
+
+	n, err := NewOpNode(log.New("module", "test", "service", SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	simtest.Start(t, n)
+	b, err := batcher.NewBatcher(log.New("module", "test", "service", batcher.SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	simtest.Start(t, b)
+
+	// The batches of the batcher advance the safe head
+	client, err := rpc.Dial(l2.RPCURL())
+	require.NoError(t, err)
+	defer client.Close()
+	require.Eventually(t, func() bool {
+		var safe struct {
+			Number hexutil.Uint64 `json:"number"`
+		}
+		err := client.Call(&safe, "eth_getBlockByNumber", "safe", false)
+		return err == nil && safe.Number >= 2
+	}, 60*time.Second, 500*time.Millisecond)
+}
@@ -0,0 +1,428 @@
+// Package proposer proposes the outputs of an L2 to the L2OutputOracle on its L1 like op-proposer.
+//
+// With fault proofs, every proposed output is also put on trial in a dispute game created through the
+// DisputeGameFactory, whose root claim is the output. The game of an honest output is expected to be
+// left uncontested by the honest challenger of the challenger package and to resolve in favor of the
+// proposer.
+package proposer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	SERVICE_TYPE = "proposer"
+)
+
+const (
+	defaultProposalInterval = 12 * time.Second
+	// Number of proposals reported by Proposals
+	maxProposalHistory = 100
+
+	// GameType is the alphabet FaultDisputeGame of the devnet deployment, whose trace is short enough
+	// to be played without cannon
+	GameType uint32 = 255
+
+	receiptPollInterval = 100 * time.Millisecond
+)
+
+// Proposal reports an output proposed to the L2OutputOracle
+type Proposal struct {
+	L2BlockNumber hexutil.Uint64 `json:"l2BlockNumber"`
+	OutputRoot    common.Hash    `json:"outputRoot"`
+	// Index of the output in the L2OutputOracle
+	Index       hexutil.Uint64 `json:"index"`
+	Transaction common.Hash    `json:"transaction"`
+	// The dispute game of the output, created with fault proofs
+	Game *common.Address `json:"game,omitempty"`
+}
+
+type Proposer struct {
+	log          log.Logger
+	l1           config.Chain
+	l2           config.Chain
+	account      accounts.Account
+	interval     time.Duration
+	faultProofs  bool
+	gameDuration uint64
+	addresses    map[string]common.Address
+
+	l1Client *ethclient.Client
+	rollup   *rollup.API
+	oracle   *bindings.L2OutputOracle
+	factory  *bindings.DisputeGameFactory
+
+	mu        sync.Mutex
+	proposals []Proposal
+	// Whether the dispute game with the configured duration is registered with the factory
+	gameRegistered bool
+}
+
+func NewProposer(logger log.Logger, l1 config.Chain, l2 config.Chain) (*Proposer, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	account, err := rollup.Proposer()
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	interval := defaultProposalInterval
+	if l2.ProposalInterval != 0 {
+		interval = time.Duration(l2.ProposalInterval) * time.Second
+	}
+
+	l1Client, err := ethclient.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	api, err := rollup.NewAPI(logger, l1, l2)
+	if err != nil {
+		l1Client.Close()
+		return nil, err
+	}
+	oracle, err := bindings.NewL2OutputOracle(addresses["L2OutputOracleProxy"], l1Client)
+	if err != nil {
+		l1Client.Close()
+		api.Close()
+		return nil, err
+	}
+	factory, err := bindings.NewDisputeGameFactory(addresses["DisputeGameFactoryProxy"], l1Client)
+	if err != nil {
+		l1Client.Close()
+		api.Close()
+		return nil, err
+	}
+
+	return &Proposer{
+		log:          logger,
+		l1:           l1,
+		l2:           l2,
+		account:      account,
+		interval:     interval,
+		faultProofs:  l2.FaultProofs,
+		gameDuration: uint64(l2.DisputeGameDuration),
+		addresses:    addresses,
+		l1Client:     l1Client,
+		rollup:       api,
+		oracle:       oracle,
+		factory:      factory,
+	}, nil
+}
+
+func (p *Proposer) ID() string {
+	return fmt.Sprintf("%s-%s", SERVICE_TYPE, p.l2.Name)
+}
+
+// L2 returns the config of the chain whose outputs are proposed
+func (p *Proposer) L2() config.Chain {
+	return p.l2
+}
+
+// Start proposes the new outputs of the L2 every proposal interval until the context is canceled
+func (p *Proposer) Start(ctx context.Context) error {
+	defer p.l1Client.Close()
+	defer p.rollup.Close()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := p.ProposePending(ctx); err != nil {
+				p.log.Warn("failed to propose outputs", "err", err)
+			}
+		}
+	}
+}
+
+// Proposals returns the most recent proposals, oldest first
+func (p *Proposer) Proposals() []Proposal {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Proposal(nil), p.proposals...)
+}
+
+// Cursor returns the index in the L2OutputOracle after the last proposal
+func (p *Proposer) Cursor() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.proposals) == 0 {
+		return 0
+	}
+	return uint64(p.proposals[len(p.proposals)-1].Index) + 1
+}
+
+// SetCursor drops the proposals from an index in the L2OutputOracle on, e.g. after the chains were reverted
+// to a snapshot. The dispute game is registered again in case its registration was reverted.
+func (p *Proposer) SetCursor(cursor uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	proposals := p.proposals[:0]
+	for _, proposal := range p.proposals {
+		if uint64(proposal.Index) < cursor {
+			proposals = append(proposals, proposal)
+		}
+	}
+	p.proposals = proposals
+	p.gameRegistered = false
+}
+
+// ProposePending proposes every output the L2OutputOracle expects up to the safe head of the L2
+// and returns the proposals
+func (p *Proposer) ProposePending(ctx context.Context) ([]Proposal, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.faultProofs && !p.gameRegistered {
+		if err := p.registerGame(ctx); err != nil {
+			return nil, err
+		}
+		p.gameRegistered = true
+	}
+	status, err := p.rollup.SyncStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 safe head: %w", err)
+	}
+
+	proposals := []Proposal{}
+	for {
+		next, err := p.oracle.NextBlockNumber(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return proposals, fmt.Errorf("failed to fetch next output block: %w", err)
+		}
+		if next.Uint64() > status.SafeL2.Number {
+			return proposals, nil
+		}
+		proposal, err := p.propose(ctx, next.Uint64())
+		if err != nil {
+			return proposals, err
+		}
+		proposals = append(proposals, *proposal)
+		p.proposals = append(p.proposals, *proposal)
+		if len(p.proposals) > maxProposalHistory {
+			p.proposals = p.proposals[len(p.proposals)-maxProposalHistory:]
+		}
+	}
+}
+
+// propose proposes the output of an L2 block and creates its dispute game with fault proofs
+func (p *Proposer) propose(ctx context.Context, number uint64) (*Proposal, error) {
+	output, err := p.rollup.OutputAtBlock(ctx, hexutil.Uint64(number))
+	if err != nil {
+		return nil, err
+	}
+	opts, err := p.transactOpts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := p.oracle.ProposeL2Output(opts, output.OutputRoot, new(big.Int).SetUint64(number), [32]byte{}, common.Big0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose output of block %d: %w", number, err)
+	}
+	receipt, err := p.waitMined(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	var proposed *bindings.L2OutputOracleOutputProposed
+	for _, l := range receipt.Logs {
+		if proposed, err = p.oracle.ParseOutputProposed(*l); err == nil {
+			break
+		}
+	}
+	if proposed == nil {
+		return nil, fmt.Errorf("output proposal %s emitted no OutputProposed event", tx.Hash())
+	}
+	proposal := &Proposal{
+		L2BlockNumber: hexutil.Uint64(number),
+		OutputRoot:    common.Hash(output.OutputRoot),
+		Index:         hexutil.Uint64(proposed.L2OutputIndex.Uint64()),
+		Transaction:   tx.Hash(),
+	}
+
+	if p.faultProofs {
+		game, err := p.createGame(ctx, proposal)
+		if err != nil {
+			return nil, err
+		}
+		proposal.Game = &game
+	}
+	p.log.Info("proposed output", "block", number, "index", uint64(proposal.Index), "output", proposal.OutputRoot, "game", proposal.Game)
+	return proposal, nil
+}
+
+// createGame creates the dispute game of a proposed output, claiming the output at its L2 block
+func (p *Proposer) createGame(ctx context.Context, proposal *Proposal) (common.Address, error) {
+	opts, err := p.transactOpts(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	extraData := common.BigToHash(new(big.Int).SetUint64(uint64(proposal.L2BlockNumber))).Bytes()
+	tx, err := p.factory.Create(opts, GameType, proposal.OutputRoot, extraData)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to create dispute game: %w", err)
+	}
+	receipt, err := p.waitMined(ctx, tx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	for _, l := range receipt.Logs {
+		if created, err := p.factory.ParseDisputeGameCreated(*l); err == nil {
+			return created.DisputeProxy, nil
+		}
+	}
+	return common.Address{}, fmt.Errorf("dispute game creation %s emitted no DisputeGameCreated event", tx.Hash())
+}
+
+// registerGame registers a copy of the dispute game of the devnet deployment with the configured duration.
+// The factory is owned by the SystemOwnerSafe, which is impersonated on the L1.
+func (p *Proposer) registerGame(ctx context.Context) error {
+	callOpts := &bind.CallOpts{Context: ctx}
+	impl, err := p.factory.GameImpls(callOpts, GameType)
+	if err != nil {
+		return fmt.Errorf("failed to fetch dispute game implementation: %w", err)
+	}
+	game, err := bindings.NewFaultDisputeGameCaller(impl, p.l1Client)
+	if err != nil {
+		return err
+	}
+	duration, err := game.GameDuration(callOpts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch dispute game duration: %w", err)
+	}
+	if p.gameDuration == 0 || duration == p.gameDuration {
+		return nil
+	}
+
+	prestate, err := game.AbsolutePrestate(callOpts)
+	if err != nil {
+		return err
+	}
+	genesisBlock, err := game.GenesisBlockNumber(callOpts)
+	if err != nil {
+		return err
+	}
+	genesisOutput, err := game.GenesisOutputRoot(callOpts)
+	if err != nil {
+		return err
+	}
+	maxDepth, err := game.MaxGameDepth(callOpts)
+	if err != nil {
+		return err
+	}
+	splitDepth, err := game.SplitDepth(callOpts)
+	if err != nil {
+		return err
+	}
+	vm, err := game.Vm(callOpts)
+	if err != nil {
+		return err
+	}
+	opts, err := p.transactOpts(ctx)
+	if err != nil {
+		return err
+	}
+	addr, tx, _, err := bindings.DeployFaultDisputeGame(opts, p.l1Client, GameType, prestate, genesisBlock, genesisOutput, maxDepth, splitDepth, p.gameDuration, vm)
+	if err != nil {
+		return fmt.Errorf("failed to deploy dispute game: %w", err)
+	}
+	if _, err := p.waitMined(ctx, tx); err != nil {
+		return err
+	}
+
+	abi, err := bindings.DisputeGameFactoryMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	data, err := abi.Pack("setImplementation", GameType, addr)
+	if err != nil {
+		return err
+	}
+	owner, factory := p.addresses["SystemOwnerSafe"], p.addresses["DisputeGameFactoryProxy"]
+	client := p.l1Client.Client()
+	if err := client.CallContext(ctx, nil, "anvil_impersonateAccount", owner); err != nil {
+		return fmt.Errorf("failed to impersonate factory owner: %w", err)
+	}
+	defer func() {
+		if err := client.CallContext(ctx, nil, "anvil_stopImpersonatingAccount", owner); err != nil {
+			p.log.Warn("failed to stop impersonating factory owner", "err", err)
+		}
+	}()
+	var hash common.Hash
+	err = client.CallContext(ctx, &hash, "eth_sendTransaction", map[string]interface{}{
+		"from": owner,
+		"to":   factory,
+		"data": hexutil.Bytes(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register dispute game: %w", err)
+	}
+	if _, err := p.waitReceipt(ctx, hash); err != nil {
+		return err
+	}
+	p.log.Info("registered dispute game", "gameType", GameType, "implementation", addr, "duration", p.gameDuration)
+	return nil
+}
+
+func (p *Proposer) transactOpts(ctx context.Context) (*bind.TransactOpts, error) {
+	chainID, err := p.l1Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 chain id: %w", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(p.account.PrivateKey, chainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = ctx
+	return opts, nil
+}
+
+// waitMined waits for the inclusion of a transaction and fails if it reverted
+func (p *Proposer) waitMined(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	return p.waitReceipt(ctx, tx.Hash())
+}
+
+func (p *Proposer) waitReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := p.l1Client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return nil, fmt.Errorf("transaction %s failed", hash)
+			}
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to fetch receipt of transaction %s: %w", hash, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
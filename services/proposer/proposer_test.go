@@ -0,0 +1,91 @@
+package proposer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposerValidation(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}
+	for _, l2 := range []config.Chain{
+		{Name: "L2", ChainID: 901, BaseChainID: 1, Backend: config.BackendSimulated},
+		{Name: "L1", ChainID: 900, BaseChainID: 900, Backend: config.BackendSimulated},
+	} {
+		_, err := NewProposer(log.New("module", "test"), l1, l2)
+		require.Error(t, err)
+	}
+}
+
+func TestProposerCreatesDisputeGames(t *testing.T) {
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000,
+		Backend: config.BackendSimulated, Proposer: true, FaultProofs: true, DisputeGameDuration: 4}
+	l1, l1RPC := simtest.StartSimulated(t, l1)
+	l2, l2RPC := simtest.StartSimulated(t, l2)
+
+	p, err := NewProposer(log.New("module", "test", "service", SERVICE_TYPE), l1, l2)
+	require.NoError(t, err)
+	proposals, err := p.ProposePending(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, proposals)
+
+	// Outputs of the devnet L2OutputOracle are every 10 blocks and safe once the L1 reaches their timestamp
+	require.NoError(t, l2RPC.Call(nil, "anvil_mine", hexutil.Uint64(25)))
+	require.NoError(t, l1RPC.Call(nil, "evm_increaseTime", hexutil.Uint64(60)))
+	require.NoError(t, l1RPC.Call(nil, "anvil_mine", hexutil.Uint64(1)))
+	proposals, err = p.ProposePending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, proposals, 2)
+	require.Equal(t, proposals, p.Proposals())
+	for i, proposal := range proposals {
+		require.EqualValues(t, 10*(i+1), proposal.L2BlockNumber)
+		require.EqualValues(t, i, proposal.Index)
+	}
+	for _, proposal := range proposals {
+		require.NotNil(t, proposal.Game)
+	}
+	require.EqualValues(t, 2, p.Cursor())
+
+	client := ethclient.NewClient(l1RPC)
+	oracle, err := bindings.NewL2OutputOracleCaller(addresses["L2OutputOracleProxy"], client)
+	require.NoError(t, err)
+	output, err := oracle.GetL2Output(&bind.CallOpts{}, common.Big1)
+	require.NoError(t, err)
+	require.Equal(t, proposals[1].OutputRoot, common.Hash(output.OutputRoot))
+
+	// The game claims the proposed output with the shortened clock
+	game, err := bindings.NewFaultDisputeGameCaller(*proposals[1].Game, client)
+	require.NoError(t, err)
+	duration, err := game.GameDuration(&bind.CallOpts{})
+	require.NoError(t, err)
+	require.EqualValues(t, 4, duration)
+	number, err := game.L2BlockNumber(&bind.CallOpts{})
+	require.NoError(t, err)
+	require.EqualValues(t, 20, number.Uint64())
+	root, err := game.RootClaim(&bind.CallOpts{})
+	require.NoError(t, err)
+	require.Equal(t, proposals[1].OutputRoot, common.Hash(root))
+
+	// Proposed outputs are not proposed again
+	proposals, err = p.ProposePending(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, proposals)
+
+	// Moving the cursor back drops the later proposals
+	p.SetCursor(1)
+	require.Len(t, p.Proposals(), 1)
+	require.EqualValues(t, 1, p.Cursor())
+}
@@ -0,0 +1,59 @@
+package relayer
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Deposit is a deposit transaction emitted by the OptimismPortal on L1
+type Deposit struct {
+	From        common.Address
+	To          *common.Address
+	Mint        *big.Int
+	Value       *big.Int
+	Gas         uint64
+	Data        []byte
+	L1Block     uint64
+	L1BlockHash common.Hash
+	L1TxHash    common.Hash
+	L1LogIndex  uint
+	// ETH deposited to a custom gas token L2 is refunded on L1 instead of relayed
+	Refund bool
+}
+
+// decodeDeposit decodes the version 0 opaque data of a TransactionDeposited event.
+// The opaque data is abi.encodePacked(mint, value, gasLimit, isCreation, data)
+func decodeDeposit(ev *bindings.OptimismPortalTransactionDeposited) (*Deposit, error) {
+	if ev.Version.Sign() != 0 {
+		return nil, fmt.Errorf("unsupported deposit version %v", ev.Version)
+	}
+	data := ev.OpaqueData
+	if len(data) < 73 {
+		return nil, fmt.Errorf("unexpected opaque data length %d", len(data))
+	}
+
+	deposit := &Deposit{
+		From:        ev.From,
+		Mint:        new(big.Int).SetBytes(data[0:32]),
+		Value:       new(big.Int).SetBytes(data[32:64]),
+		Gas:         new(big.Int).SetBytes(data[64:72]).Uint64(),
+		Data:        common.CopyBytes(data[73:]),
+		L1Block:     ev.Raw.BlockNumber,
+		L1BlockHash: ev.Raw.BlockHash,
+		L1TxHash:    ev.Raw.TxHash,
+		L1LogIndex:  ev.Raw.Index,
+	}
+	switch data[72] {
+	case 0:
+		to := ev.To
+		deposit.To = &to
+	case 1:
+		// contract creation
+	default:
+		return nil, fmt.Errorf("invalid isCreation flag %d", data[72])
+	}
+	return deposit, nil
+}
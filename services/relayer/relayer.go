@@ -0,0 +1,860 @@
+// Package relayer relays deposits made on the L1 OptimismPortal to an anvil L2.
+//
+// Deposits are replayed on the L2 by impersonating the depositor, which mocks the bridge
+// without running the op-node derivation pipeline.
+//
+// L2s with a custom gas paying token mint their native balance for the token their depositors transfer to the
+// OptimismPortal instead of the ETH deposited, which is refunded, and burn the native balance withdrawn
+// through the L2ToL1MessagePasser, which the OptimismPortal releases to the target of the withdrawal on L1.
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-chain-ops/crossdomain"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	SERVICE_TYPE = "relayer"
+)
+
+const (
+	pollInterval = time.Second
+	// maxBlockRange limits the range of a single eth_getLogs request
+	maxBlockRange = 1000
+	// The gas of the L2 transaction minting a deposit of the custom gas token
+	tokenDepositGas = 21_000
+	// The gas of the L1 transaction releasing a withdrawal of the custom gas token
+	tokenWithdrawalGas = 100_000
+	// The gas of the L1 transaction refunding the ETH deposited to a custom gas token L2
+	refundGas = 100_000
+	// The decimals of the native balance, which the SystemConfig requires of a custom gas token
+	gasTokenDecimals = 18
+	// Attempts to relay a deposit before it is skipped, e.g. a deposit the L2 keeps rejecting
+	maxDepositAttempts = 5
+)
+
+var (
+	// The storage slots of the GasPayingToken library, where the SystemConfig and the L1Block predeploy
+	// of custom gas token chains keep the token, its decimals, name and symbol
+	gasPayingTokenSlot       = libraryStorageSlot("opstack.gaspayingtoken")
+	gasPayingTokenNameSlot   = libraryStorageSlot("opstack.gaspayingtokenname")
+	gasPayingTokenSymbolSlot = libraryStorageSlot("opstack.gaspayingtokensymbol")
+
+	// errReverted is returned for L1 transactions that were mined but reverted
+	errReverted = errors.New("transaction reverted")
+)
+
+func libraryStorageSlot(name string) common.Hash {
+	return common.BigToHash(new(big.Int).Sub(crypto.Keccak256Hash([]byte(name)).Big(), common.Big1))
+}
+
+// Cursor is the position of the next deposit to relay
+type Cursor struct {
+	// The next L1 block to scan for deposits
+	Block uint64
+	// Deposits of Block with a lower log index were already relayed
+	LogIndex uint
+	// The next L2 block to scan for withdrawals of the custom gas token
+	L2Block uint64
+}
+
+// relayedDeposit records where a relayed deposit came from and the L2 block it was relayed on top of
+type relayedDeposit struct {
+	L1Block     uint64
+	L1BlockHash common.Hash
+	L1LogIndex  uint
+	L2Parent    uint64
+}
+
+// withdrawal tracks the release of a withdrawal of the custom gas token on L1 and its burn on L2,
+// so that each happens once when a relay is retried
+type withdrawal struct {
+	L2Block uint64
+	// The L1 transaction releasing the token from the OptimismPortal, once sent
+	L1TxHash common.Hash
+	Released bool
+	Burned   bool
+}
+
+type Relayer struct {
+	log    log.Logger
+	l1     config.Chain
+	l2     config.Chain
+	portal common.Address
+
+	l1Client *rpc.Client
+	l2Client *rpc.Client
+	filterer *bindings.OptimismPortalFilterer
+	// The custom gas paying token of the L2, or the zero address if the L2 pays for gas in ETH
+	gasToken       common.Address
+	tokenFilterer  *bindings.ERC20Filterer
+	systemConfig   common.Address
+	passerFilterer *bindings.L2ToL1MessagePasserFilterer
+
+	mu          sync.Mutex
+	cursor      Cursor
+	initialized bool
+	// Deposits relayed so far in order, to roll them back when their L1 blocks are orphaned
+	relayed []relayedDeposit
+	// Failed attempts to relay the deposit at the cursor
+	failedAttempts int
+	// Whether the custom gas token is written to the SystemConfig and L1Block
+	tokenConfigured bool
+	// Withdrawals of the custom gas token by withdrawal hash
+	withdrawals map[common.Hash]*withdrawal
+}
+
+func NewRelayer(logger log.Logger, l1 config.Chain, l2 config.Chain, portal common.Address) (*Relayer, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+
+	l1Client, err := rpc.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	l2Client, err := rpc.Dial(l2.RPCURL())
+	if err != nil {
+		l1Client.Close()
+		return nil, fmt.Errorf("failed to dial L2 RPC: %w", err)
+	}
+	filterer, err := bindings.NewOptimismPortalFilterer(portal, ethclient.NewClient(l1Client))
+	if err != nil {
+		l1Client.Close()
+		l2Client.Close()
+		return nil, err
+	}
+
+	r := &Relayer{
+		log:      logger,
+		l1:       l1,
+		l2:       l2,
+		portal:   portal,
+		l1Client: l1Client,
+		l2Client: l2Client,
+		filterer: filterer,
+	}
+	if l2.GasPayingToken != "" {
+		if err := r.initGasToken(common.HexToAddress(l2.GasPayingToken)); err != nil {
+			l1Client.Close()
+			l2Client.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// initGasToken prepares relaying the deposits and withdrawals of a custom gas token
+func (r *Relayer) initGasToken(token common.Address) error {
+	addresses, err := generated.Addresses()
+	if err != nil {
+		return err
+	}
+	systemConfig, ok := addresses["SystemConfigProxy"]
+	if !ok {
+		return fmt.Errorf("SystemConfigProxy missing from the generated addresses")
+	}
+	r.tokenFilterer, err = bindings.NewERC20Filterer(token, ethclient.NewClient(r.l1Client))
+	if err != nil {
+		return err
+	}
+	r.passerFilterer, err = bindings.NewL2ToL1MessagePasserFilterer(predeploys.L2ToL1MessagePasserAddr, ethclient.NewClient(r.l2Client))
+	if err != nil {
+		return err
+	}
+	r.gasToken = token
+	r.systemConfig = systemConfig
+	r.withdrawals = make(map[common.Hash]*withdrawal)
+	return nil
+}
+
+func (r *Relayer) ID() string {
+	return fmt.Sprintf("%s-%s", SERVICE_TYPE, r.l2.Name)
+}
+
+// L1 returns the config of the chain deposits are relayed from
+func (r *Relayer) L1() config.Chain {
+	return r.l1
+}
+
+// L2 returns the config of the chain deposits are relayed to
+func (r *Relayer) L2() config.Chain {
+	return r.l2
+}
+
+// GasPayingToken returns the L1 token the L2 pays for gas with, or the zero address if it pays with ETH
+func (r *Relayer) GasPayingToken() common.Address {
+	return r.gasToken
+}
+
+// Start polls for new deposits until the context is canceled
+func (r *Relayer) Start(ctx context.Context) error {
+	defer r.l1Client.Close()
+	defer r.l2Client.Close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := r.RelayPending(ctx); err != nil {
+				r.log.Debug("failed to relay pending deposits", "err", err)
+			}
+		}
+	}
+}
+
+// Cursor returns the position of the next deposit to relay
+func (r *Relayer) Cursor() Cursor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cursor
+}
+
+// SetCursor moves the relayer to a new position, e.g. after the chains were reverted to a snapshot
+func (r *Relayer) SetCursor(cursor Cursor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cursor = cursor
+	r.initialized = true
+	r.failedAttempts = 0
+	// The releases of reverted withdrawals are reverted on L1 as well
+	for hash, w := range r.withdrawals {
+		if w.L2Block >= cursor.L2Block {
+			delete(r.withdrawals, hash)
+		}
+	}
+	for i, d := range r.relayed {
+		if d.L1Block > cursor.Block || (d.L1Block == cursor.Block && d.L1LogIndex >= cursor.LogIndex) {
+			r.relayed = r.relayed[:i]
+			break
+		}
+	}
+}
+
+// RelayPending relays every deposit up to the current L1 head and every withdrawal of the custom gas token
+// up to the current L2 head, and returns the number of relayed deposits and withdrawals
+func (r *Relayer) RelayPending(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var head hexutil.Uint64
+	if err := r.l1Client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return 0, fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	if !r.initialized {
+		// Deposits made on the forked chain before mocktimism started are not relayed
+		if r.l1.ForkURL != "" {
+			r.cursor.Block = uint64(head) + 1
+		}
+		if r.l2.ForkURL != "" {
+			var l2Head hexutil.Uint64
+			if err := r.l2Client.CallContext(ctx, &l2Head, "eth_blockNumber"); err != nil {
+				return 0, fmt.Errorf("failed to fetch L2 head: %w", err)
+			}
+			r.cursor.L2Block = uint64(l2Head) + 1
+		}
+		r.initialized = true
+	}
+	if r.gasToken != (common.Address{}) && !r.tokenConfigured {
+		if err := r.configureGasToken(ctx); err != nil {
+			return 0, err
+		}
+		r.tokenConfigured = true
+	}
+
+	relayed := 0
+	for r.cursor.Block <= uint64(head) {
+		end := min(r.cursor.Block+maxBlockRange-1, uint64(head))
+		deposits, err := r.deposits(ctx, r.cursor.Block, end)
+		if err != nil {
+			return relayed, err
+		}
+		for _, deposit := range deposits {
+			if deposit.L1Block == r.cursor.Block && deposit.L1LogIndex < r.cursor.LogIndex {
+				continue
+			}
+			if deposit.Refund {
+				if err := r.refund(ctx, deposit); err != nil {
+					err = fmt.Errorf("failed to refund deposit of L1 transaction %s: %w", deposit.L1TxHash, err)
+					if !r.skipFailed(deposit, err) {
+						return relayed, err
+					}
+				}
+				r.cursor.Block, r.cursor.LogIndex = deposit.L1Block, deposit.L1LogIndex+1
+				continue
+			}
+			parent, err := r.relay(ctx, deposit)
+			if err != nil {
+				err = fmt.Errorf("failed to relay deposit of L1 transaction %s: %w", deposit.L1TxHash, err)
+				if !r.skipFailed(deposit, err) {
+					return relayed, err
+				}
+				r.cursor.Block, r.cursor.LogIndex = deposit.L1Block, deposit.L1LogIndex+1
+				continue
+			}
+			r.failedAttempts = 0
+			r.cursor.Block, r.cursor.LogIndex = deposit.L1Block, deposit.L1LogIndex+1
+			r.relayed = append(r.relayed, relayedDeposit{
+				L1Block:     deposit.L1Block,
+				L1BlockHash: deposit.L1BlockHash,
+				L1LogIndex:  deposit.L1LogIndex,
+				L2Parent:    parent,
+			})
+			relayed++
+		}
+		r.cursor.Block, r.cursor.LogIndex = end+1, 0
+	}
+
+	if r.gasToken != (common.Address{}) {
+		withdrawn, err := r.withdrawPending(ctx)
+		relayed += withdrawn
+		if err != nil {
+			return relayed, err
+		}
+	}
+	return relayed, nil
+}
+
+// skipFailed counts a failed attempt to relay the deposit at the cursor and reports whether the deposit is
+// skipped, once it failed maxDepositAttempts times. Skipped deposits are not relayed, like a deposit
+// transaction failing on a real L2 apart from its mint
+func (r *Relayer) skipFailed(deposit *Deposit, err error) bool {
+	r.failedAttempts++
+	if r.failedAttempts < maxDepositAttempts {
+		return false
+	}
+	r.log.Error("skipping deposit", "attempts", r.failedAttempts, "l1Block", deposit.L1Block, "l1Tx", deposit.L1TxHash, "err", err)
+	r.failedAttempts = 0
+	return true
+}
+
+// Reorg handles a reorg of the L1 above its ancestor block. The L2 is rolled back to before the first deposit
+// relayed from an orphaned L1 block, and deposits are relayed again from the new L1 blocks.
+// Returns the number of rolled back deposits
+func (r *Relayer) Reorg(ctx context.Context, ancestor uint64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orphaned := len(r.relayed)
+	for i, d := range r.relayed {
+		if d.L1Block <= ancestor {
+			continue
+		}
+		var block *struct {
+			Hash common.Hash `json:"hash"`
+		}
+		if err := r.l1Client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.Uint64(d.L1Block), false); err != nil {
+			return 0, fmt.Errorf("failed to fetch L1 block %d: %w", d.L1Block, err)
+		}
+		if block == nil || block.Hash != d.L1BlockHash {
+			orphaned = i
+			break
+		}
+	}
+
+	rolledBack := len(r.relayed) - orphaned
+	if rolledBack > 0 {
+		parent := r.relayed[orphaned].L2Parent
+		var head hexutil.Uint64
+		if err := r.l2Client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+			return 0, fmt.Errorf("failed to fetch L2 head: %w", err)
+		}
+		if uint64(head) > parent {
+			if err := r.l2Client.CallContext(ctx, nil, "anvil_rollback", uint64(head)-parent); err != nil {
+				return 0, fmt.Errorf("failed to roll back L2 to block %d: %w", parent, err)
+			}
+		}
+		r.relayed = r.relayed[:orphaned]
+		// Withdrawals of the rolled back L2 blocks were already released on L1
+		r.cursor.L2Block = min(r.cursor.L2Block, parent+1)
+		r.log.Warn("rolled back deposits of orphaned L1 blocks", "deposits", rolledBack, "l2Block", parent)
+	}
+
+	if r.cursor.Block > ancestor {
+		r.cursor.Block, r.cursor.LogIndex = ancestor+1, 0
+		r.failedAttempts = 0
+	}
+	// Deposits of the new L1 blocks relayed since the reorg are not relayed again
+	if n := len(r.relayed); n > 0 {
+		last := r.relayed[n-1]
+		if last.L1Block > r.cursor.Block || (last.L1Block == r.cursor.Block && last.L1LogIndex >= r.cursor.LogIndex) {
+			r.cursor.Block, r.cursor.LogIndex = last.L1Block, last.L1LogIndex+1
+		}
+	}
+	return rolledBack, nil
+}
+
+func (r *Relayer) deposits(ctx context.Context, start, end uint64) ([]*Deposit, error) {
+	iter, err := r.filterer.FilterTransactionDeposited(&bind.FilterOpts{Start: start, End: &end, Context: ctx}, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter deposits: %w", err)
+	}
+	defer iter.Close()
+
+	var deposits []*Deposit
+	for iter.Next() {
+		deposit, err := decodeDeposit(iter.Event)
+		if err != nil {
+			r.log.Error("skipping invalid deposit", "tx", iter.Event.Raw.TxHash, "err", err)
+			continue
+		}
+		// The OptimismPortal of a custom gas token L2 rejects ETH
+		deposit.Refund = r.gasToken != (common.Address{}) && deposit.Mint.Sign() > 0
+		deposits = append(deposits, deposit)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if r.gasToken == (common.Address{}) {
+		return deposits, nil
+	}
+
+	tokenDeposits, err := r.tokenDeposits(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	deposits = append(deposits, tokenDeposits...)
+	sort.SliceStable(deposits, func(i, j int) bool {
+		if deposits[i].L1Block != deposits[j].L1Block {
+			return deposits[i].L1Block < deposits[j].L1Block
+		}
+		return deposits[i].L1LogIndex < deposits[j].L1LogIndex
+	})
+	return deposits, nil
+}
+
+// tokenDeposits returns the transfers of the custom gas token to the OptimismPortal as deposits minting
+// the transferred amount to the sender on the L2.
+//
+// The OptimismPortal of the generated contracts predates custom gas tokens and has no depositERC20Transaction,
+// so the entry point of deposits is a transaction of the depositor calling transfer on the token. Other transfers
+// to the portal, like mints or transfers by contracts, are not deposits, as the portal would not see them either.
+func (r *Relayer) tokenDeposits(ctx context.Context, start, end uint64) ([]*Deposit, error) {
+	iter, err := r.tokenFilterer.FilterTransfer(&bind.FilterOpts{Start: start, End: &end, Context: ctx}, nil, []common.Address{r.portal})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter gas token deposits: %w", err)
+	}
+	defer iter.Close()
+
+	var deposits []*Deposit
+	for iter.Next() {
+		ev := iter.Event
+		from := ev.From
+		deposit, err := r.isTokenDeposit(ctx, ev)
+		if err != nil {
+			return nil, err
+		}
+		if !deposit {
+			r.log.Warn("ignoring transfer of gas paying token to the portal outside of a deposit", "tx", ev.Raw.TxHash, "from", from, "value", ev.Value)
+			continue
+		}
+		deposits = append(deposits, &Deposit{
+			From:        from,
+			To:          &from,
+			Mint:        ev.Value,
+			Value:       new(big.Int),
+			Gas:         tokenDepositGas,
+			L1Block:     ev.Raw.BlockNumber,
+			L1BlockHash: ev.Raw.BlockHash,
+			L1TxHash:    ev.Raw.TxHash,
+			L1LogIndex:  ev.Raw.Index,
+		})
+	}
+	return deposits, iter.Error()
+}
+
+// isTokenDeposit reports whether a transfer of the custom gas token to the OptimismPortal is a deposit,
+// i.e. made by a transaction of the sender calling transfer on the token
+func (r *Relayer) isTokenDeposit(ctx context.Context, ev *bindings.ERC20Transfer) (bool, error) {
+	var tx struct {
+		From  common.Address  `json:"from"`
+		To    *common.Address `json:"to"`
+		Input hexutil.Bytes   `json:"input"`
+	}
+	if err := r.l1Client.CallContext(ctx, &tx, "eth_getTransactionByHash", ev.Raw.TxHash); err != nil {
+		return false, fmt.Errorf("failed to fetch gas token transfer %s: %w", ev.Raw.TxHash, err)
+	}
+	erc20ABI, err := bindings.ERC20MetaData.GetAbi()
+	if err != nil {
+		return false, err
+	}
+	transfer := erc20ABI.Methods["transfer"].ID
+	return tx.From == ev.From && tx.To != nil && *tx.To == r.gasToken && bytes.HasPrefix(tx.Input, transfer), nil
+}
+
+// configureGasToken writes the custom gas token to the SystemConfig on L1 and the L1Block predeploy on L2
+// like the SystemConfig does when initialized with it. The L2ToL1MessagePasser and L1Block predeploys
+// are installed on the L2 if it lacks them.
+//
+// The generated contracts do not support custom gas tokens: the SystemConfig and the L1Block only get the
+// slots written, without getters or an initializer reading them, and the OptimismPortal keeps accepting ETH,
+// so the relayer emulates the deposits and withdrawals of the token.
+func (r *Relayer) configureGasToken(ctx context.Context) error {
+	token, err := bindings.NewERC20Caller(r.gasToken, ethclient.NewClient(r.l1Client))
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	decimals, err := token.Decimals(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch decimals of gas paying token %s: %w", r.gasToken, err)
+	}
+	if decimals != gasTokenDecimals {
+		return fmt.Errorf("gas paying token %s has %d decimals instead of %d", r.gasToken, decimals, gasTokenDecimals)
+	}
+	name, err := token.Name(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch name of gas paying token %s: %w", r.gasToken, err)
+	}
+	symbol, err := token.Symbol(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch symbol of gas paying token %s: %w", r.gasToken, err)
+	}
+	nameValue, err := smallString(name)
+	if err != nil {
+		return fmt.Errorf("invalid name of gas paying token %s: %w", r.gasToken, err)
+	}
+	symbolValue, err := smallString(symbol)
+	if err != nil {
+		return fmt.Errorf("invalid symbol of gas paying token %s: %w", r.gasToken, err)
+	}
+	tokenValue := new(big.Int).Lsh(big.NewInt(gasTokenDecimals), 160)
+	tokenValue.Or(tokenValue, new(big.Int).SetBytes(r.gasToken.Bytes()))
+
+	for _, predeploy := range []struct {
+		addr common.Address
+		code string
+	}{
+		{predeploys.L1BlockAddr, bindings.L1BlockDeployedBin},
+		{predeploys.L2ToL1MessagePasserAddr, bindings.L2ToL1MessagePasserDeployedBin},
+	} {
+		var code hexutil.Bytes
+		if err := r.l2Client.CallContext(ctx, &code, "eth_getCode", predeploy.addr, "latest"); err != nil {
+			return fmt.Errorf("failed to fetch code of %s: %w", predeploy.addr, err)
+		}
+		if len(code) > 0 {
+			continue
+		}
+		if err := r.l2Client.CallContext(ctx, nil, "anvil_setCode", predeploy.addr, predeploy.code); err != nil {
+			return fmt.Errorf("failed to install predeploy %s: %w", predeploy.addr, err)
+		}
+	}
+	for _, slot := range []struct {
+		key   common.Hash
+		value common.Hash
+	}{
+		{gasPayingTokenSlot, common.BigToHash(tokenValue)},
+		{gasPayingTokenNameSlot, nameValue},
+		{gasPayingTokenSymbolSlot, symbolValue},
+	} {
+		if err := r.l1Client.CallContext(ctx, nil, "anvil_setStorageAt", r.systemConfig, slot.key, slot.value); err != nil {
+			return fmt.Errorf("failed to configure gas paying token of the SystemConfig: %w", err)
+		}
+		if err := r.l2Client.CallContext(ctx, nil, "anvil_setStorageAt", predeploys.L1BlockAddr, slot.key, slot.value); err != nil {
+			return fmt.Errorf("failed to configure gas paying token of the L1Block: %w", err)
+		}
+	}
+	r.log.Info("configured gas paying token", "token", r.gasToken, "name", name, "symbol", symbol)
+	return nil
+}
+
+// smallString encodes a string left aligned in a word like the GasPayingToken library, which requires
+// it to be shorter than a word
+func smallString(s string) (common.Hash, error) {
+	if len(s) >= common.HashLength {
+		return common.Hash{}, fmt.Errorf("%q is longer than %d bytes", s, common.HashLength-1)
+	}
+	var word common.Hash
+	copy(word[:], s)
+	return word, nil
+}
+
+// refund returns the ETH of a deposit to its sender on L1 from the OptimismPortal, as the OptimismPortal
+// of a custom gas token L2 rejects ETH. The deposit is not relayed, like its reverted L1 transaction
+func (r *Relayer) refund(ctx context.Context, deposit *Deposit) error {
+	var tx *struct {
+		From common.Address `json:"from"`
+	}
+	if err := r.l1Client.CallContext(ctx, &tx, "eth_getTransactionByHash", deposit.L1TxHash); err != nil {
+		return err
+	}
+	// Contracts deposit from their aliased address
+	sender := deposit.From
+	if tx != nil && tx.From != deposit.From {
+		sender = crossdomain.UndoL1ToL2Alias(deposit.From)
+	}
+	txHash, _, err := Execute(ctx, r.log, r.l1Client, Call{
+		From:  r.portal,
+		To:    &sender,
+		Value: deposit.Mint,
+		Gas:   refundGas,
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.checkL1Receipt(ctx, txHash); errors.Is(err, errReverted) {
+		// Like a contract rejecting ETH, the deposit stays in the OptimismPortal
+		r.log.Warn("sender rejected refund of ETH deposited to custom gas token L2", "to", sender, "value", deposit.Mint, "l1Tx", deposit.L1TxHash)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.log.Warn("refunded ETH deposited to custom gas token L2", "to", sender, "value", deposit.Mint, "l1Tx", deposit.L1TxHash, "refundTx", txHash)
+	return nil
+}
+
+// withdrawPending releases the custom gas token withdrawn through the L2ToL1MessagePasser up to the current
+// L2 head from the OptimismPortal to the target of each withdrawal on L1. Returns the number of withdrawals
+func (r *Relayer) withdrawPending(ctx context.Context) (int, error) {
+	var head hexutil.Uint64
+	if err := r.l2Client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return 0, fmt.Errorf("failed to fetch L2 head: %w", err)
+	}
+
+	withdrawn := 0
+	for r.cursor.L2Block <= uint64(head) {
+		end := min(r.cursor.L2Block+maxBlockRange-1, uint64(head))
+		events, err := r.withdrawalEvents(ctx, r.cursor.L2Block, end)
+		if err != nil {
+			return withdrawn, err
+		}
+		for _, ev := range events {
+			done, err := r.withdraw(ctx, ev)
+			if err != nil {
+				return withdrawn, fmt.Errorf("failed to withdraw L2 transaction %s: %w", ev.Raw.TxHash, err)
+			}
+			if done {
+				withdrawn++
+			}
+		}
+		r.cursor.L2Block = end + 1
+	}
+	return withdrawn, nil
+}
+
+// withdrawalEvents returns the withdrawals with a value passed to the L2ToL1MessagePasser from start to end
+func (r *Relayer) withdrawalEvents(ctx context.Context, start, end uint64) ([]*bindings.L2ToL1MessagePasserMessagePassed, error) {
+	iter, err := r.passerFilterer.FilterMessagePassed(&bind.FilterOpts{Start: start, End: &end, Context: ctx}, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter withdrawals: %w", err)
+	}
+	defer iter.Close()
+
+	var events []*bindings.L2ToL1MessagePasserMessagePassed
+	for iter.Next() {
+		if iter.Event.Value.Sign() > 0 {
+			events = append(events, iter.Event)
+		}
+	}
+	return events, iter.Error()
+}
+
+// withdraw releases the value of a withdrawal of the custom gas token from the OptimismPortal to its target
+// on L1 and burns it from the balance of the L2ToL1MessagePasser. Steps completed by a failed attempt are
+// not repeated. Returns whether the withdrawal was completed by this call
+func (r *Relayer) withdraw(ctx context.Context, ev *bindings.L2ToL1MessagePasserMessagePassed) (bool, error) {
+	hash := common.Hash(ev.WithdrawalHash)
+	w, ok := r.withdrawals[hash]
+	if !ok {
+		w = &withdrawal{L2Block: ev.Raw.BlockNumber}
+		r.withdrawals[hash] = w
+	}
+	if w.Released && w.Burned {
+		return false, nil
+	}
+
+	if !w.Released {
+		if w.L1TxHash == (common.Hash{}) {
+			erc20ABI, err := bindings.ERC20MetaData.GetAbi()
+			if err != nil {
+				return false, err
+			}
+			data, err := erc20ABI.Pack("transfer", ev.Target, ev.Value)
+			if err != nil {
+				return false, err
+			}
+			w.L1TxHash, _, err = Execute(ctx, r.log, r.l1Client, Call{
+				From:  r.portal,
+				To:    &r.gasToken,
+				Value: new(big.Int),
+				Gas:   tokenWithdrawalGas,
+				Data:  data,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+		if err := r.checkL1Receipt(ctx, w.L1TxHash); err != nil {
+			// A failed release is sent again when retried
+			if !errors.Is(err, errReverted) {
+				return false, err
+			}
+			w.L1TxHash = common.Hash{}
+			return false, fmt.Errorf("release of the gas token failed: %w", err)
+		}
+		w.Released = true
+	}
+
+	if !w.Burned {
+		var balance hexutil.Big
+		if err := r.l2Client.CallContext(ctx, &balance, "eth_getBalance", predeploys.L2ToL1MessagePasserAddr, "latest"); err != nil {
+			return false, err
+		}
+		remaining := new(big.Int).Sub(balance.ToInt(), ev.Value)
+		if remaining.Sign() < 0 {
+			remaining.SetInt64(0)
+		}
+		if err := r.l2Client.CallContext(ctx, nil, "anvil_setBalance", predeploys.L2ToL1MessagePasserAddr, (*hexutil.Big)(remaining)); err != nil {
+			return false, err
+		}
+		w.Burned = true
+	}
+	r.log.Info("relayed withdrawal", "sender", ev.Sender, "target", ev.Target, "value", ev.Value, "l2Block", ev.Raw.BlockNumber,
+		"l2Tx", ev.Raw.TxHash, "l1Tx", w.L1TxHash)
+	return true, nil
+}
+
+// checkL1Receipt checks that an L1 transaction was mined successfully
+func (r *Relayer) checkL1Receipt(ctx context.Context, txHash common.Hash) error {
+	var receipt *struct {
+		Status hexutil.Uint64 `json:"status"`
+	}
+	if err := r.l1Client.CallContext(ctx, &receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		return fmt.Errorf("failed to fetch receipt of L1 transaction %s: %w", txHash, err)
+	}
+	if receipt == nil {
+		return fmt.Errorf("L1 transaction %s not mined", txHash)
+	}
+	if receipt.Status == 0 {
+		return fmt.Errorf("L1 transaction %s: %w", txHash, errReverted)
+	}
+	return nil
+}
+
+// relay executes the deposit on the L2 from the depositor and returns the L2 block the deposit was relayed on top of
+func (r *Relayer) relay(ctx context.Context, deposit *Deposit) (uint64, error) {
+	txHash, parent, err := Execute(ctx, r.log, r.l2Client, Call{
+		From:  deposit.From,
+		To:    deposit.To,
+		Mint:  deposit.Mint,
+		Value: deposit.Value,
+		Gas:   deposit.Gas,
+		Data:  deposit.Data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.log.Info("relayed deposit", "from", deposit.From, "to", deposit.To, "mint", deposit.Mint, "l1Block", deposit.L1Block, "l1Tx", deposit.L1TxHash, "l2Tx", txHash)
+	return parent, nil
+}
+
+// Call is a transaction executed on an anvil or simulated L2 like a deposit transaction
+type Call struct {
+	From common.Address
+	// Nil for contract creations
+	To *common.Address
+	// ETH, or the custom gas token, minted to the sender before the call
+	Mint  *big.Int
+	Value *big.Int
+	Gas   uint64
+	Data  []byte
+}
+
+// Execute executes a call on the L2 from the impersonated sender without charging L2 gas and returns
+// the hash of its transaction and the L2 block it was executed on top of. The mint is undone if the
+// transaction could not be sent, so retries do not mint again
+func Execute(ctx context.Context, logger log.Logger, client *rpc.Client, call Call) (txHash common.Hash, parent uint64, err error) {
+	if call.Mint != nil && call.Mint.Sign() > 0 {
+		var balance hexutil.Big
+		if err := client.CallContext(ctx, &balance, "eth_getBalance", call.From, "latest"); err != nil {
+			return common.Hash{}, 0, err
+		}
+		minted := new(big.Int).Add(balance.ToInt(), call.Mint)
+		if err := client.CallContext(ctx, nil, "anvil_setBalance", call.From, (*hexutil.Big)(minted)); err != nil {
+			return common.Hash{}, 0, err
+		}
+		defer func() {
+			if txHash != (common.Hash{}) {
+				return
+			}
+			// The context may be canceled already
+			if err := client.CallContext(context.Background(), nil, "anvil_setBalance", call.From, &balance); err != nil {
+				logger.Error("failed to undo mint of failed call", "from", call.From, "mint", call.Mint, "err", err)
+			}
+		}()
+	}
+
+	var head struct {
+		Number  hexutil.Uint64 `json:"number"`
+		BaseFee *hexutil.Big   `json:"baseFeePerGas"`
+	}
+	if err := client.CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return common.Hash{}, 0, err
+	}
+	var automine bool
+	if err := client.CallContext(ctx, &automine, "anvil_getAutomine"); err != nil {
+		return common.Hash{}, 0, err
+	}
+
+	if err := client.CallContext(ctx, nil, "anvil_impersonateAccount", call.From); err != nil {
+		return common.Hash{}, 0, err
+	}
+	defer func() {
+		if err := client.CallContext(ctx, nil, "anvil_stopImpersonatingAccount", call.From); err != nil {
+			logger.Error("failed to stop impersonating sender", "from", call.From, "err", err)
+		}
+	}()
+
+	// Deposits are paid for on L1, so the block including the call has no base fee
+	if err := client.CallContext(ctx, nil, "anvil_setNextBlockBaseFeePerGas", (*hexutil.Big)(common.Big0)); err != nil {
+		return common.Hash{}, 0, err
+	}
+	tx := map[string]interface{}{
+		"from":     call.From,
+		"value":    (*hexutil.Big)(call.Value),
+		"gas":      hexutil.Uint64(call.Gas),
+		"gasPrice": (*hexutil.Big)(common.Big0),
+		"input":    hexutil.Bytes(call.Data),
+	}
+	if call.To != nil {
+		tx["to"] = call.To
+	}
+	var sent common.Hash
+	if err := client.CallContext(ctx, &sent, "eth_sendTransaction", tx); err != nil {
+		return common.Hash{}, 0, err
+	}
+	// The transaction spends the mint once sent, so later failures are returned with its hash
+	if !automine {
+		if err := client.CallContext(ctx, nil, "evm_mine"); err != nil {
+			return sent, 0, err
+		}
+	}
+	if head.BaseFee != nil {
+		if err := client.CallContext(ctx, nil, "anvil_setNextBlockBaseFeePerGas", head.BaseFee); err != nil {
+			return sent, 0, err
+		}
+	}
+	return sent, uint64(head.Number), nil
+}
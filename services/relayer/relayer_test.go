@@ -0,0 +1,500 @@
+package relayer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/generated"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	portal = common.HexToAddress("0xf5faA1161967DfCfc99A28a246234176d0c92989")
+	alice  = common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	bob    = common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+)
+
+// depositLog builds a TransactionDeposited log the way the OptimismPortal emits it
+func depositLog(t *testing.T, block uint64, index uint, from, to common.Address, mint, value *big.Int, gas uint64, isCreation bool, data []byte) types.Log {
+	portalABI, err := bindings.OptimismPortalMetaData.GetAbi()
+	require.NoError(t, err)
+	event := portalABI.Events["TransactionDeposited"]
+
+	opaqueData := append(common.LeftPadBytes(mint.Bytes(), 32), common.LeftPadBytes(value.Bytes(), 32)...)
+	opaqueData = append(opaqueData, new(big.Int).SetUint64(gas).FillBytes(make([]byte, 8))...)
+	if isCreation {
+		opaqueData = append(opaqueData, 1)
+	} else {
+		opaqueData = append(opaqueData, 0)
+	}
+	opaqueData = append(opaqueData, data...)
+	packed, err := event.Inputs.NonIndexed().Pack(opaqueData)
+	require.NoError(t, err)
+
+	return types.Log{
+		Address:     portal,
+		Topics:      []common.Hash{event.ID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes()), {}},
+		Data:        packed,
+		BlockNumber: block,
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(block)),
+		Index:       index,
+	}
+}
+
+type fakeL1 struct {
+	head   uint64
+	logs   []types.Log
+	hashes map[uint64]common.Hash
+}
+
+func (f *fakeL1) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(f.head)
+}
+
+func (f *fakeL1) GetBlockByNumber(number hexutil.Uint64, full bool) map[string]interface{} {
+	if uint64(number) > f.head {
+		return nil
+	}
+	return map[string]interface{}{"number": number, "hash": f.hashes[uint64(number)]}
+}
+
+func (f *fakeL1) GetLogs(crit map[string]interface{}) ([]types.Log, error) {
+	from, err := hexutil.DecodeUint64(crit["fromBlock"].(string))
+	if err != nil {
+		return nil, err
+	}
+	to, err := hexutil.DecodeUint64(crit["toBlock"].(string))
+	if err != nil {
+		return nil, err
+	}
+	logs := []types.Log{}
+	for _, l := range f.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+// fakeL2 records the anvil methods used to relay deposits
+type fakeL2 struct {
+	mu            sync.Mutex
+	balances      map[common.Address]*big.Int
+	baseFee       *big.Int
+	nextBaseFees  []uint64
+	impersonating map[common.Address]bool
+	txs           []map[string]interface{}
+	automine      bool
+	mined         int
+	number        uint64
+	rollbacks     []uint64
+	// Transactions with this gas are rejected
+	rejectGas uint64
+}
+
+type fakeL2Eth struct{ *fakeL2 }
+
+func (f fakeL2Eth) GetBalance(address common.Address, tag string) *hexutil.Big {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if balance, ok := f.balances[address]; ok {
+		return (*hexutil.Big)(balance)
+	}
+	return (*hexutil.Big)(new(big.Int))
+}
+
+func (f fakeL2Eth) GetBlockByNumber(tag string, full bool) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return map[string]interface{}{"number": hexutil.Uint64(f.number), "baseFeePerGas": (*hexutil.Big)(f.baseFee)}
+}
+
+func (f fakeL2Eth) BlockNumber() hexutil.Uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return hexutil.Uint64(f.number)
+}
+
+func (f fakeL2Eth) SendTransaction(tx map[string]interface{}) (common.Hash, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if gas, _ := hexutil.DecodeUint64(tx["gas"].(string)); f.rejectGas != 0 && gas == f.rejectGas {
+		return common.Hash{}, errors.New("rejected")
+	}
+	f.txs = append(f.txs, tx)
+	return common.Hash{byte(len(f.txs))}, nil
+}
+
+type fakeL2Anvil struct{ *fakeL2 }
+
+func (f fakeL2Anvil) SetBalance(address common.Address, balance *hexutil.Big) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[address] = balance.ToInt()
+}
+
+func (f fakeL2Anvil) ImpersonateAccount(address common.Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.impersonating[address] = true
+}
+
+func (f fakeL2Anvil) StopImpersonatingAccount(address common.Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.impersonating, address)
+}
+
+func (f fakeL2Anvil) SetNextBlockBaseFeePerGas(fee *hexutil.Big) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextBaseFees = append(f.nextBaseFees, fee.ToInt().Uint64())
+}
+
+func (f fakeL2Anvil) GetAutomine() bool {
+	return f.automine
+}
+
+func (f fakeL2Anvil) Rollback(depth uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.number -= depth
+	f.rollbacks = append(f.rollbacks, depth)
+}
+
+type fakeL2Evm struct{ *fakeL2 }
+
+func (f fakeL2Evm) Mine() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mined++
+	f.number++
+}
+
+func serve(t *testing.T, chain config.Chain, apis map[string]interface{}) config.Chain {
+	srv := rpc.NewServer()
+	for namespace, api := range apis {
+		require.NoError(t, srv.RegisterName(namespace, api))
+	}
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		httpSrv.Close()
+		srv.Stop()
+	})
+
+	u, err := url.Parse(httpSrv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	chain.Host = u.Hostname()
+	chain.Port = uint(port)
+	return chain
+}
+
+func TestNewRelayerRequiresL2(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, Host: "127.0.0.1", Port: 8545}
+	_, err := NewRelayer(log.New("module", "test"), l1, l1, portal)
+	require.Error(t, err)
+
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 1, Host: "127.0.0.1", Port: 9545}
+	_, err = NewRelayer(log.New("module", "test"), l1, l2, portal)
+	require.Error(t, err)
+}
+
+func TestRelayPending(t *testing.T) {
+	l1Fake := &fakeL1{
+		head: 3,
+		logs: []types.Log{
+			depositLog(t, 2, 0, alice, bob, big.NewInt(100), big.NewInt(40), 21000, false, nil),
+			depositLog(t, 3, 4, bob, common.Address{}, big.NewInt(0), big.NewInt(0), 100000, true, []byte{0x60, 0x00}),
+		},
+	}
+	l2Fake := &fakeL2{
+		balances:      map[common.Address]*big.Int{alice: big.NewInt(5)},
+		baseFee:       big.NewInt(7),
+		impersonating: make(map[common.Address]bool),
+		automine:      false,
+	}
+	l1 := serve(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}, map[string]interface{}{"eth": l1Fake})
+	l2 := serve(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900}, map[string]interface{}{
+		"eth":   fakeL2Eth{l2Fake},
+		"anvil": fakeL2Anvil{l2Fake},
+		"evm":   fakeL2Evm{l2Fake},
+	})
+
+	r, err := NewRelayer(log.New("module", "test"), l1, l2, portal)
+	require.NoError(t, err)
+
+	relayed, err := r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, relayed)
+	require.Equal(t, Cursor{Block: 4}, r.Cursor())
+
+	// mint is credited before executing the deposit
+	require.Equal(t, big.NewInt(105), l2Fake.balances[alice])
+	require.NotContains(t, l2Fake.balances, bob)
+	require.Empty(t, l2Fake.impersonating)
+	require.Equal(t, 2, l2Fake.mined)
+	require.Equal(t, []uint64{0, 7, 0, 7}, l2Fake.nextBaseFees)
+
+	require.Len(t, l2Fake.txs, 2)
+	require.Equal(t, map[string]interface{}{
+		"from":     "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266",
+		"to":       "0x70997970c51812dc3a010c7d01b50e0d17dc79c8",
+		"value":    "0x28",
+		"gas":      "0x5208",
+		"gasPrice": "0x0",
+		"input":    "0x",
+	}, l2Fake.txs[0])
+	require.NotContains(t, l2Fake.txs[1], "to")
+	require.Equal(t, "0x6000", l2Fake.txs[1]["input"])
+
+	// Deposits are only relayed once
+	relayed, err = r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, relayed)
+
+	// Rewinding the cursor relays the deposits again
+	r.SetCursor(Cursor{Block: 3, LogIndex: 4})
+	relayed, err = r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, relayed)
+}
+
+func TestRelayerSkipsFailingDeposits(t *testing.T) {
+	l1Fake := &fakeL1{
+		head: 3,
+		logs: []types.Log{
+			depositLog(t, 2, 0, alice, bob, big.NewInt(100), big.NewInt(40), 99_999, false, nil),
+			depositLog(t, 3, 1, alice, bob, big.NewInt(10), big.NewInt(0), 21000, false, nil),
+		},
+	}
+	l2Fake := &fakeL2{
+		balances:      map[common.Address]*big.Int{alice: big.NewInt(5)},
+		baseFee:       big.NewInt(7),
+		impersonating: make(map[common.Address]bool),
+		automine:      true,
+		rejectGas:     99_999,
+	}
+	l1 := serve(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}, map[string]interface{}{"eth": l1Fake})
+	l2 := serve(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900}, map[string]interface{}{
+		"eth":   fakeL2Eth{l2Fake},
+		"anvil": fakeL2Anvil{l2Fake},
+		"evm":   fakeL2Evm{l2Fake},
+	})
+
+	r, err := NewRelayer(log.New("module", "test"), l1, l2, portal)
+	require.NoError(t, err)
+
+	// Failed attempts undo the mint and retry the deposit
+	for i := 1; i < maxDepositAttempts; i++ {
+		_, err := r.RelayPending(context.Background())
+		require.Error(t, err)
+		require.Equal(t, Cursor{}, r.Cursor())
+		require.Equal(t, big.NewInt(5), l2Fake.balances[alice])
+	}
+	// The last attempt skips the deposit
+	relayed, err := r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, relayed)
+	require.Equal(t, Cursor{Block: 4}, r.Cursor())
+	require.Equal(t, big.NewInt(15), l2Fake.balances[alice])
+	require.Len(t, l2Fake.txs, 1)
+}
+
+func TestRelayerReorg(t *testing.T) {
+	withHash := func(l types.Log, hash common.Hash) types.Log {
+		l.BlockHash = hash
+		return l
+	}
+	l1Fake := &fakeL1{
+		head: 3,
+		logs: []types.Log{
+			withHash(depositLog(t, 2, 0, alice, bob, big.NewInt(0), big.NewInt(0), 21000, false, nil), common.Hash{0xa2}),
+			withHash(depositLog(t, 3, 0, alice, bob, big.NewInt(0), big.NewInt(0), 21000, false, nil), common.Hash{0xa3}),
+		},
+		hashes: map[uint64]common.Hash{2: {0xa2}, 3: {0xa3}},
+	}
+	l2Fake := &fakeL2{
+		balances:      make(map[common.Address]*big.Int),
+		baseFee:       big.NewInt(7),
+		impersonating: make(map[common.Address]bool),
+		number:        10,
+	}
+	l1 := serve(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}, map[string]interface{}{"eth": l1Fake})
+	l2 := serve(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900}, map[string]interface{}{
+		"eth":   fakeL2Eth{l2Fake},
+		"anvil": fakeL2Anvil{l2Fake},
+		"evm":   fakeL2Evm{l2Fake},
+	})
+
+	r, err := NewRelayer(log.New("module", "test"), l1, l2, portal)
+	require.NoError(t, err)
+	relayed, err := r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, relayed)
+	require.Equal(t, uint64(12), l2Fake.number)
+
+	// Nothing is rolled back without orphaned deposits
+	rolledBack, err := r.Reorg(context.Background(), 3)
+	require.NoError(t, err)
+	require.Zero(t, rolledBack)
+	require.Empty(t, l2Fake.rollbacks)
+
+	// Block 3 is replaced by a block with another deposit
+	l1Fake.hashes[3] = common.Hash{0xb3}
+	l1Fake.logs[1] = withHash(depositLog(t, 3, 1, bob, alice, big.NewInt(0), big.NewInt(0), 21000, false, nil), common.Hash{0xb3})
+	rolledBack, err = r.Reorg(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, rolledBack)
+	require.Equal(t, []uint64{1}, l2Fake.rollbacks)
+	require.Equal(t, uint64(11), l2Fake.number)
+	require.Equal(t, Cursor{Block: 3}, r.Cursor())
+
+	// The deposit of the new block is relayed in place of the orphaned one
+	relayed, err = r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, relayed)
+	require.Len(t, l2Fake.txs, 3)
+	require.Equal(t, "0x70997970c51812dc3a010c7d01b50e0d17dc79c8", l2Fake.txs[2]["from"])
+}
+
+func TestRelayerGasPayingToken(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000,
+		Backend: config.BackendSimulated}
+	l1, l1RPC := simtest.StartSimulated(t, l1)
+	l1Client := ethclient.NewClient(l1RPC)
+
+	// The gas paying token is minted by alice, who is its bridge
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 1)
+	require.NoError(t, err)
+	opts, err := bind.NewKeyedTransactorWithChainID(accs[0].PrivateKey, big.NewInt(900))
+	require.NoError(t, err)
+	wait := func(tx *types.Transaction, err error) {
+		require.NoError(t, err)
+		receipt, err := bind.WaitMined(context.Background(), l1Client, tx)
+		require.NoError(t, err)
+		require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	}
+	token, tx, erc20, err := bindings.DeployOptimismMintableERC20(opts, l1Client, alice, common.Address{}, "Gas", "GAS", 18)
+	wait(tx, err)
+	wait(erc20.Mint(opts, alice, big.NewInt(params.Ether)))
+	l2.GasPayingToken = token.Hex()
+	l2, l2RPC := simtest.StartSimulated(t, l2)
+	l2Client := ethclient.NewClient(l2RPC)
+
+	// Only tokens with the 18 decimals of the native balance pay for gas
+	sixDecimals, tx, _, err := bindings.DeployOptimismMintableERC20(opts, l1Client, alice, common.Address{}, "USD", "USD", 6)
+	wait(tx, err)
+	invalid := l2
+	invalid.GasPayingToken = sixDecimals.Hex()
+	r, err := NewRelayer(log.New("module", "test"), l1, invalid, portal)
+	require.NoError(t, err)
+	_, err = r.RelayPending(context.Background())
+	require.ErrorContains(t, err, "6 decimals")
+
+	r, err = NewRelayer(log.New("module", "test"), l1, l2, portal)
+	require.NoError(t, err)
+	require.Equal(t, token, r.GasPayingToken())
+
+	// Transferring the token to the portal mints it on the L2, while the ETH of deposits is refunded
+	deposited := big.NewInt(params.Ether / 2)
+	wait(erc20.Transfer(opts, portal, deposited))
+	// Tokens reaching the portal other than through a transfer of the depositor are not deposits
+	stray := big.NewInt(params.Ether)
+	wait(erc20.Mint(opts, portal, stray))
+	portalBalance, err := l1Client.BalanceAt(context.Background(), portal, nil)
+	require.NoError(t, err)
+	portalContract, err := bindings.NewOptimismPortalTransactor(portal, l1Client)
+	require.NoError(t, err)
+	opts.Value = big.NewInt(500)
+	wait(portalContract.DepositTransaction(opts, bob, big.NewInt(0), 21000, false, nil))
+	opts.Value = nil
+	funded, err := l2Client.BalanceAt(context.Background(), alice, nil)
+	require.NoError(t, err)
+	bobFunded, err := l2Client.BalanceAt(context.Background(), bob, nil)
+	require.NoError(t, err)
+	relayed, err := r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, relayed)
+	balance, err := l2Client.BalanceAt(context.Background(), alice, nil)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Add(funded, deposited), balance)
+	balance, err = l2Client.BalanceAt(context.Background(), bob, nil)
+	require.NoError(t, err)
+	require.Equal(t, bobFunded, balance)
+	balance, err = l1Client.BalanceAt(context.Background(), portal, nil)
+	require.NoError(t, err)
+	require.Equal(t, portalBalance, balance)
+
+	// The token is configured in the SystemConfig and the L1Block like the GasPayingToken library stores it
+	addresses, err := generated.Addresses()
+	require.NoError(t, err)
+	expected := common.HexToHash("0x" + strings.Repeat("00", 11) + "12" + strings.TrimPrefix(token.Hex(), "0x"))
+	systemConfig, err := l1Client.StorageAt(context.Background(), addresses["SystemConfigProxy"], gasPayingTokenSlot, nil)
+	require.NoError(t, err)
+	require.Equal(t, expected, common.BytesToHash(systemConfig))
+	l1Block, err := l2Client.StorageAt(context.Background(), predeploys.L1BlockAddr, gasPayingTokenSlot, nil)
+	require.NoError(t, err)
+	require.Equal(t, expected, common.BytesToHash(l1Block))
+	symbol, err := l2Client.StorageAt(context.Background(), predeploys.L1BlockAddr, gasPayingTokenSymbolSlot, nil)
+	require.NoError(t, err)
+	require.Equal(t, common.RightPadBytes([]byte("GAS"), 32), symbol)
+
+	// Withdrawals through the L2ToL1MessagePasser burn the token and release it from the portal to their target
+	passer, err := bindings.NewL2ToL1MessagePasserTransactor(predeploys.L2ToL1MessagePasserAddr, l2Client)
+	require.NoError(t, err)
+	l2Opts, err := bind.NewKeyedTransactorWithChainID(accs[0].PrivateKey, big.NewInt(901))
+	require.NoError(t, err)
+	// The head was mined without base fee by the relayer, so the fee cap estimated from it is too low
+	l2Opts.GasFeeCap, l2Opts.GasTipCap = big.NewInt(params.GWei*10), big.NewInt(1)
+	l2Opts.Value = big.NewInt(400)
+	withdrawal, err := passer.InitiateWithdrawal(l2Opts, bob, big.NewInt(100_000), nil)
+	require.NoError(t, err)
+	receipt, err := bind.WaitMined(context.Background(), l2Client, withdrawal)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	relayed, err = r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, relayed)
+	balance, err = l2Client.BalanceAt(context.Background(), predeploys.L2ToL1MessagePasserAddr, nil)
+	require.NoError(t, err)
+	require.Zero(t, balance.Sign())
+	balance, err = erc20.BalanceOf(nil, portal)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Sub(new(big.Int).Add(deposited, stray), big.NewInt(400)), balance)
+	balance, err = erc20.BalanceOf(nil, bob)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(400), balance)
+
+	// Withdrawals are only relayed once, even when scanned again
+	r.cursor.L2Block = 0
+	relayed, err = r.RelayPending(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, relayed)
+	balance, err = erc20.BalanceOf(nil, bob)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(400), balance)
+}
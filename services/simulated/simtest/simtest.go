@@ -0,0 +1,91 @@
+// Package simtest runs the chains of tests in-process. Chains listen on ports picked by the operating
+// system and report them once started, so tests running in parallel never race for a port.
+package simtest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum-optimism/mocktimism/services/simulated"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// Service is a service run until the test ends
+type Service interface {
+	Start(ctx context.Context) error
+}
+
+// Node is a chain that reports when it serves requests
+type Node interface {
+	Service
+	HealthCheck() (bool, error)
+}
+
+// Start runs a service until the test ends
+func Start(t testing.TB, service Service) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- service.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+}
+
+// StartNode runs a chain until the test ends and waits until it is healthy
+func StartNode(t testing.TB, node Node) {
+	Start(t, node)
+	require.Eventually(t, func() bool {
+		healthy, _ := node.HealthCheck()
+		return healthy
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// StartSimulated runs a chain on the simulated backend on a free port of localhost until the test ends.
+// It returns the chain with the port it listens on and a client of the chain.
+func StartSimulated(t testing.TB, chain config.Chain) (config.Chain, *rpc.Client) {
+	chain.Host, chain.Port = "127.0.0.1", 0
+	service, err := simulated.NewSimulated(chain.Name, log.New("module", "test", "chain", chain.Name), chain)
+	require.NoError(t, err)
+	StartNode(t, service)
+	chain.Port = uint(service.Port())
+
+	client, err := service.GetClient()
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return chain, client
+}
+
+// StartGeth runs a chain on a geth node on free ports of localhost until the test ends. The HTTP and engine API
+// ports of cfg are replaced. It returns the chain with the ports it listens on and the node.
+func StartGeth(t testing.TB, chain config.Chain, cfg geth.GethConfig) (config.Chain, *geth.Geth) {
+	chain.Host, chain.Port, chain.AuthPort = "127.0.0.1", 0, 0
+	cfg.Host, cfg.HTTPPort, cfg.AuthPort = chain.Host, 0, 0
+	genesis, err := geth.NewGenesis(chain)
+	require.NoError(t, err)
+	service, err := geth.NewGeth(chain.Name, log.New("module", "test", "chain", chain.Name), cfg, genesis, chain.IsL2())
+	require.NoError(t, err)
+	StartNode(t, service)
+	chain.Port = uint(service.Port())
+	if cfg.Engine {
+		chain.AuthPort = uint(service.AuthPort())
+	}
+	return chain, service
+}
+
+// FreePort returns a port of localhost that was free when checked. It is only meant for processes that
+// cannot report the port they listen on, like the CLI, as another process may take the port first.
+func FreePort(t testing.TB) uint {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return uint(l.Addr().(*net.TCPAddr).Port)
+}
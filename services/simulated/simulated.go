@@ -0,0 +1,65 @@
+package simulated
+
+import (
+	"fmt"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/services/geth"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	SERVICE_TYPE = "simulated"
+)
+
+// Simulated runs a chain in-process on a go-ethereum node like the go-ethereum simulated backend.
+// The chain lives in a temporary directory and serves the anvil methods the devnet relies on,
+// so devnets and tests run without the anvil binary. L2s are plain chains deposits are relayed to.
+type Simulated struct {
+	*geth.Geth
+	config config.Chain
+}
+
+func validateConfig(cfg config.Chain) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if cfg.ForkURL != "" {
+		return fmt.Errorf("the simulated backend cannot fork")
+	}
+	return nil
+}
+
+func NewSimulated(id string, logger log.Logger, cfg config.Chain) (*Simulated, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	cfg.Backend = config.BackendSimulated
+	genesis, err := geth.NewGenesis(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cors := cfg.AllowOrigin
+	if cors == "" {
+		cors = "*"
+	}
+	node, err := geth.NewGeth(id, logger, geth.GethConfig{
+		Host:      cfg.Host,
+		HTTPPort:  int(cfg.Port),
+		CORS:      []string{cors},
+		BlockTime: uint64(cfg.BlockTime),
+		Cheats:    true,
+	}, genesis, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulated{Geth: node, config: cfg}, nil
+}
+
+func (s *Simulated) ServiceType() string {
+	return SERVICE_TYPE
+}
+
+func (s *Simulated) Config() interface{} {
+	return s.config
+}
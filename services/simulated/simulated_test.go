@@ -0,0 +1,154 @@
+package simulated
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatedValidation(t *testing.T) {
+	invalidCfgs := []config.Chain{
+		{Port: 8545},
+		{Host: "127.0.0.1", Port: 8545, ForkURL: "https://mainnet.optimism.io"},
+	}
+	for _, cfg := range invalidCfgs {
+		_, err := NewSimulated("TestService", log.New("module", "test"), cfg)
+		require.Error(t, err)
+	}
+}
+
+func startSimulated(t *testing.T, chain config.Chain) (*Simulated, *rpc.Client) {
+	chain.Host = "127.0.0.1"
+	service, err := NewSimulated(chain.Name, log.New("module", "test", "chain", chain.Name), chain)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- service.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	require.Eventually(t, func() bool {
+		healthy, _ := service.HealthCheck()
+		return healthy
+	}, 5*time.Second, 100*time.Millisecond)
+
+	client, err := service.GetClient()
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return service, client
+}
+
+func TestSimulatedService(t *testing.T) {
+	service, client := startSimulated(t, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000})
+	require.Equal(t, "simulated", service.ServiceType())
+	require.Equal(t, "127.0.0.1", service.Hostname())
+
+	var chainID hexutil.Uint64
+	require.NoError(t, client.Call(&chainID, "eth_chainId"))
+	require.Equal(t, hexutil.Uint64(901), chainID)
+	var automine bool
+	require.NoError(t, client.Call(&automine, "anvil_getAutomine"))
+	require.True(t, automine)
+}
+
+func TestSimulatedCheats(t *testing.T) {
+	_, client := startSimulated(t, config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000})
+
+	balance := func(addr common.Address) string {
+		var b hexutil.Big
+		require.NoError(t, client.Call(&b, "eth_getBalance", addr, "latest"))
+		return b.String()
+	}
+	depositor := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	recipient := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	var snapshot hexutil.Big
+	require.NoError(t, client.Call(&snapshot, "evm_snapshot"))
+
+	require.NoError(t, client.Call(nil, "anvil_setBalance", depositor, "0x100"))
+	require.Equal(t, "0x100", balance(depositor))
+	// The code of impersonated accounts is kept
+	require.NoError(t, client.Call(nil, "anvil_setCode", depositor, "0x00"))
+
+	type receipt struct {
+		From        common.Address `json:"from"`
+		Status      hexutil.Uint64 `json:"status"`
+		BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	}
+
+	// The funded accounts sign their transactions, which are mined before eth_sendTransaction returns
+	var devAccounts []common.Address
+	require.NoError(t, client.Call(&devAccounts, "eth_accounts"))
+	require.Len(t, devAccounts, 10)
+	var txHash common.Hash
+	require.NoError(t, client.Call(&txHash, "eth_sendTransaction", map[string]interface{}{"from": devAccounts[0], "to": recipient, "value": "0x40"}))
+	var signed receipt
+	require.NoError(t, client.Call(&signed, "eth_getTransactionReceipt", txHash))
+	require.Equal(t, hexutil.Uint64(1), signed.Status)
+	require.Equal(t, devAccounts[0], signed.From)
+	require.Equal(t, "0x40", balance(recipient))
+
+	// Other accounts can only send transactions while impersonated
+	tx := map[string]interface{}{"from": depositor, "to": recipient, "value": "0x40", "gasPrice": "0x0"}
+	require.Error(t, client.Call(nil, "eth_sendTransaction", tx))
+	require.NoError(t, client.Call(nil, "anvil_impersonateAccount", depositor))
+	require.NoError(t, client.Call(nil, "anvil_setNextBlockBaseFeePerGas", "0x0"))
+	require.NoError(t, client.Call(&txHash, "eth_sendTransaction", tx))
+	// Contracts see impersonated accounts as the sender of their calls
+	caller := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	require.NoError(t, client.Call(nil, "anvil_setCode", caller, "0x33600055")) // sstore(0, caller())
+	var callHash common.Hash
+	require.NoError(t, client.Call(&callHash, "eth_sendTransaction", map[string]interface{}{"from": depositor, "to": caller}))
+	var stored common.Hash
+	require.NoError(t, client.Call(&stored, "eth_getStorageAt", caller, "0x0", "latest"))
+	require.Equal(t, common.BytesToHash(depositor.Bytes()), stored)
+	require.NoError(t, client.Call(nil, "anvil_stopImpersonatingAccount", depositor))
+
+	var relayed receipt
+	require.NoError(t, client.Call(&relayed, "eth_getTransactionReceipt", txHash))
+	require.Equal(t, hexutil.Uint64(1), relayed.Status)
+	require.NotEqual(t, depositor, relayed.From)
+	var block struct {
+		BaseFee hexutil.Big `json:"baseFeePerGas"`
+	}
+	require.NoError(t, client.Call(&block, "eth_getBlockByNumber", relayed.BlockNumber, false))
+	require.Zero(t, block.BaseFee.ToInt().Sign())
+	require.Equal(t, "0xc0", balance(depositor))
+	require.Equal(t, "0x80", balance(recipient))
+	var code hexutil.Bytes
+	require.NoError(t, client.Call(&code, "eth_getCode", depositor, "latest"))
+	require.Equal(t, hexutil.Bytes{0x00}, code)
+
+	now := uint64(time.Now().Unix())
+	require.NoError(t, client.Call(nil, "evm_increaseTime", hexutil.Uint64(3600)))
+	require.NoError(t, client.Call(nil, "evm_mine"))
+	var next struct {
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	require.NoError(t, client.Call(&next, "eth_getBlockByNumber", "latest", false))
+	require.GreaterOrEqual(t, uint64(next.Timestamp), now+3600)
+
+	var reverted bool
+	require.NoError(t, client.Call(&reverted, "evm_revert", &snapshot))
+	require.True(t, reverted)
+	require.Equal(t, "0x0", balance(depositor))
+	require.Equal(t, "0x0", balance(recipient))
+	var number hexutil.Uint64
+	require.NoError(t, client.Call(&number, "eth_blockNumber"))
+	require.Equal(t, hexutil.Uint64(0), number)
+
+	// The snapshot can only be reverted to once
+	require.NoError(t, client.Call(&reverted, "evm_revert", &snapshot))
+	require.False(t, reverted)
+}
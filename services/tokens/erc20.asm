@@ -0,0 +1,310 @@
+;; Runtime of the fresh L1 tokens: a plain ERC20 whose owner can mint, compiled with core/asm.
+;;
+;; Storage: 0 owner, 1 totalSupply, 2 decimals, 3 name, 4 length of the name, 5 symbol,
+;; 6 length of the symbol, keccak256(account) balances, keccak256(owner . spender) allowances.
+;; The constructor of deployCode sets the owner, decimals, name and symbol.
+
+    callvalue
+    jumpi @revert
+    push 0
+    calldataload
+    push 224
+    shr
+    dup1
+    push 0x06fdde03 ;; name()
+    eq
+    jumpi @name
+    dup1
+    push 0x95d89b41 ;; symbol()
+    eq
+    jumpi @symbol
+    dup1
+    push 0x313ce567 ;; decimals()
+    eq
+    jumpi @decimals
+    dup1
+    push 0x18160ddd ;; totalSupply()
+    eq
+    jumpi @totalSupply
+    dup1
+    push 0x70a08231 ;; balanceOf(address)
+    eq
+    jumpi @balanceOf
+    dup1
+    push 0xdd62ed3e ;; allowance(address,address)
+    eq
+    jumpi @allowance
+    dup1
+    push 0x095ea7b3 ;; approve(address,uint256)
+    eq
+    jumpi @approve
+    dup1
+    push 0xa9059cbb ;; transfer(address,uint256)
+    eq
+    jumpi @transfer
+    dup1
+    push 0x23b872dd ;; transferFrom(address,address,uint256)
+    eq
+    jumpi @transferFrom
+    dup1
+    push 0x40c10f19 ;; mint(address,uint256)
+    eq
+    jumpi @mint
+    dup1
+    push 0x8da5cb5b ;; owner()
+    eq
+    jumpi @owner
+revert:
+    push 0
+    dup1
+    revert
+
+returnWord:
+    push 0
+    mstore
+    push 0x20
+    push 0
+    return
+
+returnTrue:
+    push 1
+    jump @returnWord
+
+name:
+    push 0x20
+    push 0
+    mstore
+    push 4
+    sload
+    push 0x20
+    mstore
+    push 3
+    sload
+    push 0x40
+    mstore
+    push 0x60
+    push 0
+    return
+
+symbol:
+    push 0x20
+    push 0
+    mstore
+    push 6
+    sload
+    push 0x20
+    mstore
+    push 5
+    sload
+    push 0x40
+    mstore
+    push 0x60
+    push 0
+    return
+
+decimals:
+    push 2
+    sload
+    jump @returnWord
+
+totalSupply:
+    push 1
+    sload
+    jump @returnWord
+
+owner:
+    push 0
+    sload
+    jump @returnWord
+
+balanceOf:
+    push 4
+    calldataload
+    push 0
+    mstore
+    push 0x20
+    push 0
+    keccak256
+    sload
+    jump @returnWord
+
+allowance:
+    push 4
+    calldataload
+    push 0
+    mstore
+    push 0x24
+    calldataload
+    push 0x20
+    mstore
+    push 0x40
+    push 0
+    keccak256
+    sload
+    jump @returnWord
+
+approve:
+    caller
+    push 0
+    mstore
+    push 4
+    calldataload
+    push 0x20
+    mstore
+    push 0x24
+    calldataload
+    dup1
+    push 0x40
+    push 0
+    keccak256
+    sstore
+    push 0
+    mstore
+    ;; Approval(caller, spender, amount)
+    push 4
+    calldataload
+    caller
+    push 0x8c5be1e5ebec7d5bd14f71427e1e84f3dd0314c0f7b2291e5b200ac8c7c3b925
+    push 0x20
+    push 0
+    log3
+    jump @returnTrue
+
+transfer:
+    caller
+    push 4
+    calldataload
+    push 0x24
+    calldataload
+    jump @move
+
+transferFrom:
+    ;; Spend the allowance of the caller unless it is unlimited
+    push 4
+    calldataload
+    push 0
+    mstore
+    caller
+    push 0x20
+    mstore
+    push 0x40
+    push 0
+    keccak256
+    dup1
+    sload
+    dup1
+    push 0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff
+    eq
+    jumpi @unlimited
+    push 0x44
+    calldataload
+    dup1
+    dup3
+    lt
+    jumpi @revert
+    swap1
+    sub
+    swap1
+    sstore
+    jump @transferFromMove
+unlimited:
+    pop
+    pop
+transferFromMove:
+    push 4
+    calldataload
+    push 0x24
+    calldataload
+    push 0x44
+    calldataload
+    jump @move
+
+;; Moves amount from from to to with the stack from, to, amount
+move:
+    dup3
+    push 0
+    mstore
+    push 0x20
+    push 0
+    keccak256
+    dup1
+    sload
+    dup3
+    dup2
+    lt
+    jumpi @revert
+    dup3
+    swap1
+    sub
+    swap1
+    sstore
+    dup2
+    push 0
+    mstore
+    push 0x20
+    push 0
+    keccak256
+    dup1
+    sload
+    dup3
+    add
+    swap1
+    sstore
+    ;; Transfer(from, to, amount)
+    push 0
+    mstore
+    swap1
+    push 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef
+    push 0x20
+    push 0
+    log3
+    jump @returnTrue
+
+mint:
+    push 0
+    sload
+    caller
+    eq
+    iszero
+    jumpi @revert
+    ;; The total supply bounds the balances
+    push 1
+    sload
+    dup1
+    push 0x24
+    calldataload
+    add
+    dup1
+    dup3
+    gt
+    jumpi @revert
+    push 1
+    sstore
+    pop
+    push 4
+    calldataload
+    push 0
+    mstore
+    push 0x20
+    push 0
+    keccak256
+    dup1
+    sload
+    push 0x24
+    calldataload
+    add
+    swap1
+    sstore
+    ;; Transfer(0, to, amount)
+    push 0x24
+    calldataload
+    push 0
+    mstore
+    push 4
+    calldataload
+    push 0
+    push 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef
+    push 0x20
+    push 0
+    log3
+    stop
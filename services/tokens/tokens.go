@@ -0,0 +1,495 @@
+// Package tokens pairs the ERC20s of an L1 with OptimismMintableERC20s on its L2s at startup, like the
+// token deployments bridging tests otherwise repeat by hand.
+//
+// The L2 token is created through the OptimismMintableERC20Factory predeploy, which is installed on L2s
+// without predeploys, and initial balances are minted on the L2 by impersonating the L2StandardBridge.
+// Fresh L1 tokens are plain ERC20s owned by the token deployer account, so it can mint their initial
+// balances on the L1 and the L1StandardBridge escrows them like any other L1 token.
+package tokens
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/rollup"
+	"github.com/ethereum-optimism/mocktimism/services/relayer"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	SERVICE_TYPE = "tokens"
+)
+
+const (
+	defaultDecimals = 18
+	// Interval between deployment attempts while the chains start
+	retryInterval       = time.Second
+	receiptPollInterval = 100 * time.Millisecond
+	// Gas of the impersonated calls on the L2
+	callGasLimit = 5_000_000
+)
+
+// erc20Asm is the runtime of the fresh L1 tokens
+//
+//go:embed erc20.asm
+var erc20Asm []byte
+
+// l1TokenRuntime compiles the runtime of the fresh L1 tokens
+var l1TokenRuntime = sync.OnceValues(func() ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex(erc20Asm, false))
+	code, errs := compiler.Compile()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to compile L1 token: %w", errors.Join(errs...))
+	}
+	return common.FromHex(code), nil
+})
+
+// Pair reports an L1 token and its OptimismMintableERC20 on the L2
+type Pair struct {
+	Name     string         `json:"name"`
+	Symbol   string         `json:"symbol"`
+	Decimals uint8          `json:"decimals"`
+	L1Token  common.Address `json:"l1Token"`
+	L2Token  common.Address `json:"l2Token"`
+}
+
+// Deployer deploys and pairs the tokens of an L2
+type Deployer struct {
+	log     log.Logger
+	l1      config.Chain
+	l2      config.Chain
+	tokens  []config.Token
+	account accounts.Account
+
+	l1Client *ethclient.Client
+	l2RPC    *rpc.Client
+	l2Client *ethclient.Client
+
+	mu sync.Mutex
+	// Pairs of the tokens deployed so far, in the order of the config
+	pairs []Pair
+}
+
+func NewDeployer(logger log.Logger, l1 config.Chain, l2 config.Chain, tokens []config.Token) (*Deployer, error) {
+	if !l2.IsL2() || l2.BaseChainID != l1.EffectiveChainID() {
+		return nil, fmt.Errorf("chain %s is not an L2 of chain %s", l2.Name, l1.Name)
+	}
+	for _, token := range tokens {
+		if token.L2 != l2.Name {
+			return nil, fmt.Errorf("token %s is not paired on chain %s", token.Symbol, l2.Name)
+		}
+	}
+	account, err := rollup.TokenDeployer()
+	if err != nil {
+		return nil, err
+	}
+
+	l1Client, err := ethclient.Dial(l1.RPCURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial L1 RPC: %w", err)
+	}
+	l2RPC, err := rpc.Dial(l2.RPCURL())
+	if err != nil {
+		l1Client.Close()
+		return nil, fmt.Errorf("failed to dial L2 RPC: %w", err)
+	}
+	return &Deployer{
+		log:      logger,
+		l1:       l1,
+		l2:       l2,
+		tokens:   tokens,
+		account:  account,
+		l1Client: l1Client,
+		l2RPC:    l2RPC,
+		l2Client: ethclient.NewClient(l2RPC),
+	}, nil
+}
+
+func (d *Deployer) ID() string {
+	return fmt.Sprintf("%s-%s", SERVICE_TYPE, d.l2.Name)
+}
+
+// L2 returns the config of the chain the tokens are paired on
+func (d *Deployer) L2() config.Chain {
+	return d.l2
+}
+
+// Start deploys the tokens once the chains are up and idles until the context is canceled
+func (d *Deployer) Start(ctx context.Context) error {
+	defer d.close()
+
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		pairs, err := d.Deploy(ctx)
+		if err == nil {
+			d.log.Info("paired tokens", "tokens", len(pairs))
+			break
+		}
+		d.log.Warn("failed to deploy tokens", "err", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (d *Deployer) close() {
+	d.l1Client.Close()
+	d.l2RPC.Close()
+}
+
+// Pairs returns the pairs of the tokens deployed so far
+func (d *Deployer) Pairs() []Pair {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Pair(nil), d.pairs...)
+}
+
+// Deploy deploys and pairs the tokens not paired yet and returns every pair
+func (d *Deployer) Deploy(ctx context.Context) ([]Pair, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.pairs) == len(d.tokens) {
+		return append([]Pair(nil), d.pairs...), nil
+	}
+	if err := d.installFactory(ctx); err != nil {
+		return nil, err
+	}
+	for _, token := range d.tokens[len(d.pairs):] {
+		pair, err := d.pair(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pair token %s: %w", token.Symbol, err)
+		}
+		d.pairs = append(d.pairs, *pair)
+		d.log.Info("paired token", "symbol", pair.Symbol, "l1Token", pair.L1Token, "l2Token", pair.L2Token)
+	}
+	return append([]Pair(nil), d.pairs...), nil
+}
+
+// installFactory installs the OptimismMintableERC20Factory predeploy on L2s without predeploys
+func (d *Deployer) installFactory(ctx context.Context) error {
+	code, err := d.l2Client.CodeAt(ctx, predeploys.OptimismMintableERC20FactoryAddr, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch code of the OptimismMintableERC20Factory: %w", err)
+	}
+	if len(code) > 0 {
+		return nil
+	}
+	if err := d.l2RPC.CallContext(ctx, nil, "anvil_setCode", predeploys.OptimismMintableERC20FactoryAddr, bindings.OptimismMintableERC20FactoryDeployedBin); err != nil {
+		return fmt.Errorf("failed to install the OptimismMintableERC20Factory: %w", err)
+	}
+	factoryABI, err := bindings.OptimismMintableERC20FactoryMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	data, err := factoryABI.Pack("initialize", predeploys.L2StandardBridgeAddr)
+	if err != nil {
+		return err
+	}
+	if err := d.execute(ctx, d.account.Address, predeploys.OptimismMintableERC20FactoryAddr, data); err != nil {
+		return fmt.Errorf("failed to initialize the OptimismMintableERC20Factory: %w", err)
+	}
+	d.log.Info("installed OptimismMintableERC20Factory", "bridge", predeploys.L2StandardBridgeAddr)
+	return nil
+}
+
+// pair deploys the L1 token unless configured, creates its L2 token and mints the initial balances
+func (d *Deployer) pair(ctx context.Context, token config.Token) (*Pair, error) {
+	decimals := token.Decimals
+	if decimals == 0 {
+		decimals = defaultDecimals
+	}
+	amount := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	amount.Mul(amount, new(big.Int).SetUint64(uint64(token.Balance)))
+	holders := make([]common.Address, len(token.Accounts))
+	for i, account := range token.Accounts {
+		holders[i] = common.HexToAddress(account)
+	}
+
+	var l1Token common.Address
+	if token.Address != "" {
+		l1Token = common.HexToAddress(token.Address)
+		code, err := d.l1Client.CodeAt(ctx, l1Token, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch code of L1 token: %w", err)
+		}
+		if len(code) == 0 {
+			return nil, fmt.Errorf("no contract at L1 token %s", l1Token)
+		}
+	} else {
+		var err error
+		if l1Token, err = d.deployL1Token(ctx, token.Name, token.Symbol, decimals, holders, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	l2Token, err := d.createL2Token(ctx, l1Token, token.Name, token.Symbol, decimals)
+	if err != nil {
+		return nil, err
+	}
+	if amount.Sign() > 0 {
+		tokenABI, err := bindings.OptimismMintableERC20MetaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		bridge, err := d.l2Bridge(ctx, l2Token)
+		if err != nil {
+			return nil, err
+		}
+		for _, holder := range holders {
+			data, err := tokenABI.Pack("mint", holder, amount)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.execute(ctx, bridge, l2Token, data); err != nil {
+				return nil, fmt.Errorf("failed to mint L2 tokens to %s: %w", holder, err)
+			}
+		}
+	}
+
+	return &Pair{
+		Name:     token.Name,
+		Symbol:   token.Symbol,
+		Decimals: decimals,
+		L1Token:  l1Token,
+		L2Token:  l2Token,
+	}, nil
+}
+
+// deployL1Token deploys a fresh token to the L1 and mints the initial balances
+func (d *Deployer) deployL1Token(ctx context.Context, name, symbol string, decimals uint8, holders []common.Address, amount *big.Int) (common.Address, error) {
+	opts, err := d.transactOpts(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	initCode, err := l1TokenInitCode(d.account.Address, name, symbol, decimals)
+	if err != nil {
+		return common.Address{}, err
+	}
+	addr, tx, _, err := bind.DeployContract(opts, abi.ABI{}, initCode, d.l1Client)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to deploy L1 token: %w", err)
+	}
+	if _, err := waitReceipt(ctx, d.l1Client, tx.Hash()); err != nil {
+		return common.Address{}, err
+	}
+	if amount.Sign() == 0 {
+		return addr, nil
+	}
+	// The L1 token mints like an OptimismMintableERC20, but only for its owner
+	tokenABI, err := bindings.OptimismMintableERC20MetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, err
+	}
+	l1Token := bind.NewBoundContract(addr, *tokenABI, nil, d.l1Client, nil)
+	for _, holder := range holders {
+		tx, err := l1Token.Transact(opts, "mint", holder, amount)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("failed to mint L1 tokens to %s: %w", holder, err)
+		}
+		if _, err := waitReceipt(ctx, d.l1Client, tx.Hash()); err != nil {
+			return common.Address{}, err
+		}
+	}
+	return addr, nil
+}
+
+// l1TokenInitCode returns the init code of a fresh L1 token, which stores the owner, decimals, name and
+// symbol in the slots of erc20.asm before returning its runtime
+func l1TokenInitCode(owner common.Address, name, symbol string, decimals uint8) ([]byte, error) {
+	runtime, err := l1TokenRuntime()
+	if err != nil {
+		return nil, err
+	}
+	nameWord, err := shortString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name of L1 token: %w", err)
+	}
+	symbolWord, err := shortString(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("invalid symbol of L1 token: %w", err)
+	}
+
+	var code []byte
+	for slot, value := range []common.Hash{
+		common.BytesToHash(owner.Bytes()),
+		{},
+		common.BigToHash(big.NewInt(int64(decimals))),
+		nameWord,
+		common.BigToHash(big.NewInt(int64(len(name)))),
+		symbolWord,
+		common.BigToHash(big.NewInt(int64(len(symbol)))),
+	} {
+		if value == (common.Hash{}) {
+			continue
+		}
+		// PUSH32 value PUSH1 slot SSTORE
+		code = append(code, byte(vm.PUSH32))
+		code = append(code, value.Bytes()...)
+		code = append(code, byte(vm.PUSH1), byte(slot), byte(vm.SSTORE))
+	}
+	// PUSH2 size DUP1 PUSH2 offset PUSH1 0 CODECOPY PUSH1 0 RETURN, followed by the runtime
+	offset := len(code) + 13
+	code = append(code, byte(vm.PUSH2), byte(len(runtime)>>8), byte(len(runtime)), byte(vm.DUP1),
+		byte(vm.PUSH2), byte(offset>>8), byte(offset), byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), 0, byte(vm.RETURN))
+	return append(code, runtime...), nil
+}
+
+// shortString encodes a string shorter than a word left aligned in a word
+func shortString(s string) (common.Hash, error) {
+	if len(s) >= common.HashLength {
+		return common.Hash{}, fmt.Errorf("%q is longer than %d bytes", s, common.HashLength-1)
+	}
+	var word common.Hash
+	copy(word[:], s)
+	return word, nil
+}
+
+// createL2Token creates the OptimismMintableERC20 of an L1 token through the factory unless it exists
+func (d *Deployer) createL2Token(ctx context.Context, l1Token common.Address, name, symbol string, decimals uint8) (common.Address, error) {
+	bridge, err := d.factoryBridge(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	l2Token, err := L2TokenAddress(predeploys.OptimismMintableERC20FactoryAddr, bridge, l1Token, name, symbol, decimals)
+	if err != nil {
+		return common.Address{}, err
+	}
+	code, err := d.l2Client.CodeAt(ctx, l2Token, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to fetch code of L2 token: %w", err)
+	}
+	if len(code) > 0 {
+		return l2Token, nil
+	}
+
+	factoryABI, err := bindings.OptimismMintableERC20FactoryMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, err
+	}
+	data, err := factoryABI.Pack("createOptimismMintableERC20WithDecimals", l1Token, name, symbol, decimals)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if err := d.execute(ctx, d.account.Address, predeploys.OptimismMintableERC20FactoryAddr, data); err != nil {
+		return common.Address{}, fmt.Errorf("failed to create L2 token: %w", err)
+	}
+	return l2Token, nil
+}
+
+func (d *Deployer) factoryBridge(ctx context.Context) (common.Address, error) {
+	factory, err := bindings.NewOptimismMintableERC20FactoryCaller(predeploys.OptimismMintableERC20FactoryAddr, d.l2Client)
+	if err != nil {
+		return common.Address{}, err
+	}
+	bridge, err := factory.Bridge(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to fetch bridge of the OptimismMintableERC20Factory: %w", err)
+	}
+	return bridge, nil
+}
+
+func (d *Deployer) l2Bridge(ctx context.Context, l2Token common.Address) (common.Address, error) {
+	caller, err := bindings.NewOptimismMintableERC20Caller(l2Token, d.l2Client)
+	if err != nil {
+		return common.Address{}, err
+	}
+	bridge, err := caller.BRIDGE(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to fetch bridge of L2 token: %w", err)
+	}
+	return bridge, nil
+}
+
+// L2TokenAddress returns the address the OptimismMintableERC20Factory creates the L2 token of an L1 token at
+func L2TokenAddress(factory, bridge, l1Token common.Address, name, symbol string, decimals uint8) (common.Address, error) {
+	addressType, _ := abi.NewType("address", "", nil)
+	stringType, _ := abi.NewType("string", "", nil)
+	uint8Type, _ := abi.NewType("uint8", "", nil)
+	salt, err := abi.Arguments{{Type: addressType}, {Type: stringType}, {Type: stringType}, {Type: uint8Type}}.Pack(l1Token, name, symbol, decimals)
+	if err != nil {
+		return common.Address{}, err
+	}
+	tokenABI, err := bindings.OptimismMintableERC20MetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, err
+	}
+	args, err := tokenABI.Pack("", bridge, l1Token, name, symbol, decimals)
+	if err != nil {
+		return common.Address{}, err
+	}
+	initCode := append(common.FromHex(bindings.OptimismMintableERC20MetaData.Bin), args...)
+	return crypto.CreateAddress2(factory, crypto.Keccak256Hash(salt), crypto.Keccak256(initCode)), nil
+}
+
+// execute calls a contract on the L2 from an impersonated sender and fails if the call reverted
+func (d *Deployer) execute(ctx context.Context, from common.Address, to common.Address, data []byte) error {
+	hash, _, err := relayer.Execute(ctx, d.log, d.l2RPC, relayer.Call{From: from, To: &to, Value: new(big.Int), Gas: callGasLimit, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = waitReceipt(ctx, d.l2Client, hash)
+	return err
+}
+
+func (d *Deployer) transactOpts(ctx context.Context) (*bind.TransactOpts, error) {
+	chainID, err := d.l1Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 chain id: %w", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(d.account.PrivateKey, chainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = ctx
+	return opts, nil
+}
+
+// waitReceipt waits for the inclusion of a transaction and fails if it reverted
+func waitReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return nil, fmt.Errorf("transaction %s failed", hash)
+			}
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to fetch receipt of transaction %s: %w", hash, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package tokens
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum-optimism/mocktimism/accounts"
+	"github.com/ethereum-optimism/mocktimism/config"
+	"github.com/ethereum-optimism/mocktimism/services/simulated/simtest"
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeployerValidation(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900}
+	_, err := NewDeployer(log.New("module", "test"), l1, l1, nil)
+	require.Error(t, err)
+	_, err = NewDeployer(log.New("module", "test"), l1, config.Chain{Name: "L2", ChainID: 901, BaseChainID: 1}, nil)
+	require.Error(t, err)
+	_, err = NewDeployer(log.New("module", "test"), l1, l2, []config.Token{{L2: "other", Name: "Test", Symbol: "TEST"}})
+	require.Error(t, err)
+}
+
+func TestDeployerPairsTokens(t *testing.T) {
+	l1 := config.Chain{Name: "L1", ChainID: 900, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000}
+	l2 := config.Chain{Name: "L2", ChainID: 901, BaseChainID: 900, GasLimit: 30_000_000, Balance: 1000,
+		Backend: config.BackendSimulated}
+	l1, l1RPC := simtest.StartSimulated(t, l1)
+	l2, l2RPC := simtest.StartSimulated(t, l2)
+	l1Client, l2Client := ethclient.NewClient(l1RPC), ethclient.NewClient(l2RPC)
+
+	// A token already on the L1, like the token of a forked L1
+	accs, err := accounts.Derive(accounts.DefaultMnemonic, 2)
+	require.NoError(t, err)
+	opts, err := bind.NewKeyedTransactorWithChainID(accs[0].PrivateKey, big.NewInt(900))
+	require.NoError(t, err)
+	existing, tx, _, err := bindings.DeployOptimismMintableERC20(opts, l1Client, accs[0].Address, common.Address{}, "Existing", "EXT", 6)
+	require.NoError(t, err)
+	_, err = waitReceipt(context.Background(), l1Client, tx.Hash())
+	require.NoError(t, err)
+
+	holder := accs[1].Address
+	tokens := []config.Token{
+		{L2: "L2", Name: "Test Token", Symbol: "TEST", Accounts: []string{holder.Hex()}, Balance: 1000},
+		{L2: "L2", Name: "Existing", Symbol: "EXT", Decimals: 6, Address: existing.Hex(), Accounts: []string{holder.Hex()}, Balance: 5},
+	}
+	d, err := NewDeployer(log.New("module", "test", "service", SERVICE_TYPE), l1, l2, tokens)
+	require.NoError(t, err)
+	defer d.close()
+	pairs, err := d.Deploy(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pairs, 2)
+	require.Equal(t, pairs, d.Pairs())
+	require.Equal(t, existing, pairs[1].L1Token)
+	require.EqualValues(t, 6, pairs[1].Decimals)
+
+	balanceOf := func(client *ethclient.Client, token common.Address) *big.Int {
+		erc20, err := bindings.NewOptimismMintableERC20Caller(token, client)
+		require.NoError(t, err)
+		balance, err := erc20.BalanceOf(nil, holder)
+		require.NoError(t, err)
+		return balance
+	}
+	tokens18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	require.Equal(t, new(big.Int).Mul(big.NewInt(1000), tokens18), balanceOf(l1Client, pairs[0].L1Token))
+	require.Equal(t, new(big.Int).Mul(big.NewInt(1000), tokens18), balanceOf(l2Client, pairs[0].L2Token))
+	// Fresh L1 tokens are plain ERC20s owned by the token deployer
+	l1Token, err := bindings.NewERC20(pairs[0].L1Token, l1Client)
+	require.NoError(t, err)
+	name, err := l1Token.Name(nil)
+	require.NoError(t, err)
+	require.Equal(t, "Test Token", name)
+	symbol, err := l1Token.Symbol(nil)
+	require.NoError(t, err)
+	require.Equal(t, "TEST", symbol)
+	decimals, err := l1Token.Decimals(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 18, decimals)
+	// The L1StandardBridge escrows tokens without the IOptimismMintableERC20 interface
+	mintable, err := bindings.NewOptimismMintableERC20Caller(pairs[0].L1Token, l1Client)
+	require.NoError(t, err)
+	_, err = mintable.SupportsInterface(nil, [4]byte{0x01, 0xff, 0xc9, 0xa7})
+	require.Error(t, err)
+	holderOpts, err := bind.NewKeyedTransactorWithChainID(accs[1].PrivateKey, big.NewInt(900))
+	require.NoError(t, err)
+	tx, err = l1Token.Transfer(holderOpts, accs[0].Address, big.NewInt(100))
+	require.NoError(t, err)
+	_, err = waitReceipt(context.Background(), l1Client, tx.Hash())
+	require.NoError(t, err)
+	tx, err = l1Token.Approve(holderOpts, accs[0].Address, big.NewInt(50))
+	require.NoError(t, err)
+	_, err = waitReceipt(context.Background(), l1Client, tx.Hash())
+	require.NoError(t, err)
+	tx, err = l1Token.TransferFrom(opts, holder, accs[0].Address, big.NewInt(50))
+	require.NoError(t, err)
+	_, err = waitReceipt(context.Background(), l1Client, tx.Hash())
+	require.NoError(t, err)
+	received, err := l1Token.BalanceOf(nil, accs[0].Address)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(150), received)
+	// The allowance is spent, and only the owner mints
+	_, err = l1Token.TransferFrom(opts, holder, accs[0].Address, big.NewInt(1))
+	require.Error(t, err)
+	tokenABI, err := bindings.OptimismMintableERC20MetaData.GetAbi()
+	require.NoError(t, err)
+	_, err = bind.NewBoundContract(pairs[0].L1Token, *tokenABI, nil, l1Client, nil).Transact(opts, "mint", holder, big.NewInt(1))
+	require.Error(t, err)
+	require.Equal(t, new(big.Int).Sub(new(big.Int).Mul(big.NewInt(1000), tokens18), big.NewInt(150)), balanceOf(l1Client, pairs[0].L1Token))
+
+	// Balances of tokens on the L1 are left as is
+	require.Zero(t, balanceOf(l1Client, existing).Sign())
+	require.Equal(t, big.NewInt(5_000_000), balanceOf(l2Client, pairs[1].L2Token))
+
+	// The L2 tokens are OptimismMintableERC20s of the L2StandardBridge paired with the L1 tokens
+	for _, pair := range pairs {
+		l2Token, err := bindings.NewOptimismMintableERC20Caller(pair.L2Token, l2Client)
+		require.NoError(t, err)
+		remote, err := l2Token.REMOTETOKEN(nil)
+		require.NoError(t, err)
+		require.Equal(t, pair.L1Token, remote)
+		bridge, err := l2Token.BRIDGE(nil)
+		require.NoError(t, err)
+		require.Equal(t, predeploys.L2StandardBridgeAddr, bridge)
+		symbol, err := l2Token.Symbol(nil)
+		require.NoError(t, err)
+		require.Equal(t, pair.Symbol, symbol)
+	}
+
+	// Paired tokens are not deployed again
+	again, err := d.Deploy(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, pairs, again)
+
+	// A restarted deployer finds the L2 token of a configured L1 token
+	restarted, err := NewDeployer(log.New("module", "test", "service", SERVICE_TYPE), l1, l2, tokens[1:])
+	require.NoError(t, err)
+	defer restarted.close()
+	again, err = restarted.Deploy(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, pairs[1:], again)
+}